@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"micro/config"
+	bookRepo "micro/internal/domain/book/repository"
+	db "micro/third_party/database"
+)
+
+// Version is injected using ldflags during build time
+var Version string
+
+// Permanently deletes books that have been in the trash for longer than the
+// given number of days. Meant to be run periodically, e.g. from cron.
+func main() {
+	days := flag.Int("days", 30, "purge books soft-deleted more than this many days ago")
+	flag.Parse()
+
+	log.Printf("Version: %s\n", Version)
+
+	if *days < 0 {
+		log.Fatalln("days cannot be negative")
+	}
+
+	cfg := config.New()
+	store := db.NewSqlx(cfg.Database)
+	defer store.Close()
+
+	before := time.Now().AddDate(0, 0, -*days)
+
+	purged, err := bookRepo.New(store).Purge(context.Background(), before)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("purged %d book(s) deleted before %s\n", purged, before.Format(time.RFC3339))
+}
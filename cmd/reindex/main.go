@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+
+	"micro/config"
+	"micro/ent/gen"
+	authorRepo "micro/internal/domain/author/repository"
+	db "micro/third_party/database"
+	"micro/third_party/elasticsearch"
+)
+
+// Version is injected using ldflags during build time
+var Version string
+
+// Rebuilds the Elasticsearch author index from the database. Run it after
+// enabling Elasticsearch, after changing the index mapping, or to recover
+// from writes that did not reach the index.
+func main() {
+	log.Printf("Version: %s\n", Version)
+
+	cfg := config.New()
+	store := db.NewSqlx(cfg.Database)
+	defer store.Close()
+
+	client := gen.NewClient(gen.Driver(entsql.OpenDB(dialect.Postgres, store.DB)))
+
+	searcher := authorRepo.NewElasticsearch(
+		elasticsearch.New(cfg.Elasticsearch),
+		cfg.Elasticsearch.Index,
+	)
+
+	indexed, err := searcher.Reindex(context.Background(), client)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("indexed %d author(s) into %s\n", indexed, cfg.Elasticsearch.Index)
+}
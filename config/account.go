@@ -0,0 +1,46 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+type Account struct {
+	// Secret signs the tokens of email verification and password reset
+	// links. Without one, a random secret is made on start, and links stop
+	// working on restart.
+	Secret string
+	// BaseURL is the front end that the links open. Its /verify and
+	// /password/reset pages post the token in the link to the API.
+	BaseURL string `split_words:"true" default:"http://localhost:3000"`
+
+	VerifyExpiry time.Duration `split_words:"true" default:"48h"`
+	ResetExpiry  time.Duration `split_words:"true" default:"1h"`
+
+	// RequireVerified refuses to log in users that have not verified their
+	// email address.
+	RequireVerified bool `split_words:"true" default:"false"`
+
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string `split_words:"true" default:"go8"`
+}
+
+func NewAccount() Account {
+	var account Account
+	envconfig.MustProcess("ACCOUNT", &account)
+
+	if account.Secret == "" {
+		log.Println("ACCOUNT_SECRET is not set, verification and reset links will not survive a restart")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalln(err)
+		}
+		account.Secret = base64.RawURLEncoding.EncodeToString(secret)
+	}
+
+	return account
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/netip"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -15,6 +16,10 @@ type Api struct {
 
 	RequestLog bool `split_words:"true" default:"false"`
 	RunSwagger bool `split_words:"true" default:"true"`
+
+	// TrustedProxies are the CIDR ranges of the proxies in front, whose
+	// X-Forwarded-For header tells the address of the client.
+	TrustedProxies []netip.Prefix `split_words:"true"`
 }
 
 func API() Api {
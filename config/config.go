@@ -13,6 +13,13 @@ type Config struct {
 	Database
 	Cache
 	Elasticsearch
+	Storage
+	Permission
+	Mail
+	Account
+	Lockout
+	OAuth
+	CSRF
 
 	OpenTelemetry
 	Session
@@ -31,6 +38,13 @@ func New() *Config {
 		Database:      DataStore(),
 		Cache:         NewCache(),
 		Elasticsearch: ElasticSearch(),
+		Storage:       NewStorage(),
+		Permission:    NewPermission(),
+		Mail:          NewMail(),
+		Account:       NewAccount(),
+		Lockout:       NewLockout(),
+		OAuth:         NewOAuth(),
+		CSRF:          NewCSRF(),
 		Session:       NewSession(),
 		OpenTelemetry: NewOpenTelemetry(),
 	}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// CSRF configures the tokens that requests authenticated with the session
+// cookie send along when they change data.
+type CSRF struct {
+	// Mode is session to keep one token for the whole session, or once for a
+	// new token for every request.
+	Mode string `default:"session"`
+}
+
+func NewCSRF() CSRF {
+	var csrf CSRF
+	envconfig.MustProcess("CSRF", &csrf)
+
+	return csrf
+}
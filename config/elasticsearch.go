@@ -5,9 +5,15 @@ import (
 )
 
 type Elasticsearch struct {
+	// Enable switches author search from PostgreSQL full-text search to
+	// Elasticsearch.
+	Enable   bool   `default:"false"`
 	Address  string `default:"http://localhost:9200"`
 	User     string
 	Password string
+	// Index is the alias that author searches and writes go through.
+	// Reindexing builds a new index and moves the alias onto it.
+	Index string `default:"authors"`
 }
 
 func ElasticSearch() Elasticsearch {
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Lockout slows down and then stops password guessing. Failed logins are
+// counted per email address and per IP address, in Redis when caching is
+// enabled, or else in the login_attempts table.
+//
+// After FreeAttempts failures, each attempt waits for BaseDelay, doubled with
+// every further failure up to MaxDelay. After Threshold failures, the account
+// is locked for Duration. Counts are forgotten Window after the last failure.
+type Lockout struct {
+	FreeAttempts int           `split_words:"true" default:"3"`
+	BaseDelay    time.Duration `split_words:"true" default:"1s"`
+	MaxDelay     time.Duration `split_words:"true" default:"1m"`
+	Threshold    int           `default:"10"`
+	Duration     time.Duration `default:"15m"`
+
+	// Many users may share an address, so it takes more failures from one.
+	IPFreeAttempts int `envconfig:"IP_FREE_ATTEMPTS" default:"20"`
+	IPThreshold    int `envconfig:"IP_THRESHOLD" default:"100"`
+
+	Window time.Duration `default:"1h"`
+}
+
+func NewLockout() Lockout {
+	var lockout Lockout
+	envconfig.MustProcess("LOCKOUT", &lockout)
+
+	return lockout
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+type Mail struct {
+	// Driver sends emails over smtp, writes them to files in Dir, or only
+	// logs them with log.
+	Driver string `default:"log"`
+	From   string `default:"no-reply@localhost"`
+
+	Host     string `default:"localhost"`
+	Port     int    `default:"1025"`
+	Username string
+	Password string
+
+	Dir string `default:"./mail"`
+}
+
+func NewMail() Mail {
+	var mail Mail
+	envconfig.MustProcess("MAIL", &mail)
+
+	return mail
+}
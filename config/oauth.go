@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// OAuth configures logging in with accounts at external providers. A
+// provider is enabled once it has a client ID.
+type OAuth struct {
+	// RedirectURL is where providers send users back to after they log in,
+	// with the name of the provider in place of {provider}.
+	RedirectURL string `split_words:"true" default:"http://localhost:3080/api/v1/oauth/{provider}/callback"`
+
+	Google OAuthProvider
+	GitHub OAuthProvider `envconfig:"GITHUB"`
+	// OIDC is any other provider that supports OpenID Connect discovery.
+	OIDC OAuthProvider `envconfig:"OIDC"`
+}
+
+type OAuthProvider struct {
+	ClientID     string `split_words:"true"`
+	ClientSecret string `split_words:"true"`
+
+	// Issuer is where the OpenID configuration is discovered from. Google
+	// has one by default.
+	Issuer string
+	// Scopes are asked for in addition to those needed to log in.
+	Scopes []string
+	// Name is the provider in URLs, for the generic OIDC provider.
+	Name string `default:"oidc"`
+}
+
+func NewOAuth() OAuth {
+	var oauth OAuth
+	envconfig.MustProcess("OAUTH", &oauth)
+
+	return oauth
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Permission names the permission that guards each group of endpoints.
+// Roles grant permissions through the role_permissions table. An empty
+// permission lets any logged-in user through.
+type Permission struct {
+	BookWrite     string `split_words:"true" default:"books:write"`
+	AuthorWrite   string `split_words:"true" default:"authors:write"`
+	AuditRead     string `split_words:"true" default:"audit:read"`
+	RoleManage    string `split_words:"true" default:"roles:manage"`
+	SessionManage string `split_words:"true" default:"sessions:manage"`
+	AccountUnlock string `split_words:"true" default:"accounts:unlock"`
+}
+
+func NewPermission() Permission {
+	var permission Permission
+	envconfig.MustProcess("PERMISSION", &permission)
+
+	return permission
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+type Storage struct {
+	// Driver is where uploaded files such as book covers are kept, either
+	// local for a directory on disk or s3 for an S3-compatible bucket.
+	Driver string `default:"local"`
+	// BaseURL is where clients download the files from. Files of the local
+	// driver are served under its path. For s3, it defaults to the bucket
+	// URL and may be set to a CDN in front of the bucket.
+	BaseURL string `split_words:"true"`
+
+	Dir string `default:"./storage"`
+
+	Endpoint  string `default:"http://localhost:9000"`
+	Region    string `default:"us-east-1"`
+	Bucket    string `default:"covers"`
+	AccessKey string `split_words:"true"`
+	SecretKey string `split_words:"true"`
+	// PathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint, as MinIO expects.
+	PathStyle bool `split_words:"true" default:"true"`
+}
+
+func NewStorage() Storage {
+	var storage Storage
+	envconfig.MustProcess("STORAGE", &storage)
+
+	if storage.BaseURL == "" && storage.Driver == "local" {
+		storage.BaseURL = "http://localhost:3080/storage"
+	}
+
+	return storage
+}
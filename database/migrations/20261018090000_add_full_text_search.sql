@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS books_search_idx ON books USING GIN (search);
+
+-- Names are not stemmed, hence the 'simple' configuration.
+ALTER TABLE authors
+    ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(last_name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(first_name, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(middle_name, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS authors_search_idx ON authors USING GIN (search);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS authors_search_idx;
+ALTER TABLE authors DROP COLUMN IF EXISTS search;
+
+DROP INDEX IF EXISTS books_search_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS search;
+-- +goose StatementEnd
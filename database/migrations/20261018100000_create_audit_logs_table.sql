@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_logs
+(
+    id bigserial,
+    actor_id bigint,
+    table_name text not null,
+    table_row_id bigint not null,
+    action text not null,
+    old_values jsonb,
+    new_values jsonb,
+    http_method text not null default '',
+    url text not null default '',
+    ip_address text not null default '',
+    user_agent text not null default '',
+    created_at timestamp with time zone not null default current_timestamp,
+    primary key (id)
+);
+
+CREATE INDEX IF NOT EXISTS audit_logs_table_row_idx ON audit_logs (table_name, table_row_id);
+CREATE INDEX IF NOT EXISTS audit_logs_actor_idx ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS audit_logs_created_at_idx ON audit_logs (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_logs;
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE roles
+(
+    id          bigint generated always as identity primary key,
+    name        text not null unique,
+    description text
+);
+
+CREATE TABLE permissions
+(
+    id          bigint generated always as identity primary key,
+    name        text not null unique,
+    description text
+);
+
+CREATE TABLE role_permissions
+(
+    role_id       bigint not null
+        constraint role_permissions_roles_id_fk
+            references roles
+            on delete cascade,
+    permission_id bigint not null
+        constraint role_permissions_permissions_id_fk
+            references permissions
+            on delete cascade,
+    constraint role_permissions_pk
+        primary key (role_id, permission_id)
+);
+
+CREATE TABLE user_roles
+(
+    user_id bigint not null
+        constraint user_roles_users_id_fk
+            references users
+            on delete cascade,
+    role_id bigint not null
+        constraint user_roles_roles_id_fk
+            references roles
+            on delete cascade,
+    constraint user_roles_pk
+        primary key (user_id, role_id)
+);
+
+CREATE INDEX user_roles_role_id_idx ON user_roles (role_id);
+
+INSERT INTO permissions (name, description)
+VALUES ('books:write', 'Create, update and delete books'),
+       ('authors:write', 'Create, update and delete authors'),
+       ('audit:read', 'Read the audit log'),
+       ('roles:manage', 'Grant and revoke roles of users'),
+       ('sessions:manage', 'Log other users out');
+
+INSERT INTO roles (name, description)
+VALUES ('admin', 'Has every permission');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles,
+     permissions
+WHERE roles.name = 'admin';
+
+-- User 1 used to be the only administrator.
+INSERT INTO user_roles (user_id, role_id)
+SELECT users.id, roles.id
+FROM users,
+     roles
+WHERE users.id = 1
+  AND roles.name = 'admin';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN totp_secret     text,
+    ADD COLUMN totp_enabled_at timestamptz,
+    ADD COLUMN totp_last_step  bigint;
+
+CREATE TABLE recovery_codes
+(
+    id        bigint generated always as identity primary key,
+    user_id   bigint not null
+        constraint recovery_codes_users_id_fk
+            references users
+            on delete cascade,
+    code_hash text   not null,
+    constraint recovery_codes_user_id_code_hash_key
+        unique (user_id, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_enabled_at,
+    DROP COLUMN totp_secret;
+-- +goose StatementEnd
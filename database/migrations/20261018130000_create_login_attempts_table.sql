@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed logins, counted by account and by IP address when Redis is not used.
+CREATE TABLE login_attempts
+(
+    key            text primary key,
+    failures       integer     not null,
+    last_failed_at timestamptz not null
+);
+
+CREATE INDEX login_attempts_last_failed_at_idx ON login_attempts (last_failed_at);
+
+INSERT INTO permissions (name, description)
+VALUES ('accounts:unlock', 'Unlock accounts locked by failed logins');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles,
+     permissions
+WHERE roles.name = 'admin'
+  AND permissions.name = 'accounts:unlock';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'accounts:unlock';
+DROP TABLE login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The device each session is used from, so that users can tell their
+-- sessions apart.
+ALTER TABLE sessions
+    ADD COLUMN user_agent   text        not null default '',
+    ADD COLUMN ip_address   text        not null default '',
+    ADD COLUMN created_at   timestamptz not null default current_timestamp,
+    ADD COLUMN last_seen_at timestamptz not null default current_timestamp;
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS sessions_user_id_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN user_agent,
+    DROP COLUMN ip_address,
+    DROP COLUMN created_at,
+    DROP COLUMN last_seen_at;
+-- +goose StatementEnd
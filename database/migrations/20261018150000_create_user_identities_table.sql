@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounts at external providers that users log in with.
+CREATE TABLE user_identities
+(
+    id         bigint generated always as identity primary key,
+    user_id    bigint      not null
+        constraint user_identities_users_id_fk
+            references users
+            on delete cascade,
+    provider   text        not null,
+    subject    text        not null,
+    email      text,
+    created_at timestamptz not null default current_timestamp,
+    constraint user_identities_provider_subject_key
+        unique (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_identities;
+-- +goose StatementEnd
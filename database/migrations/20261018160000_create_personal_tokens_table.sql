@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tokens that scripts authenticate with as a user. Only a hash of each token
+-- is kept, and the prefix that it is found by.
+CREATE TABLE personal_tokens
+(
+    id           bigint generated always as identity primary key,
+    user_id      bigint      not null
+        constraint personal_tokens_users_id_fk
+            references users
+            on delete cascade,
+    name         text        not null,
+    prefix       text        not null
+        constraint personal_tokens_prefix_key
+            unique,
+    token_hash   text        not null,
+    scopes       jsonb       not null default '[]',
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    created_at   timestamptz not null default current_timestamp
+);
+
+CREATE INDEX personal_tokens_user_id_idx ON personal_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tenants, roles and resources that the gateway checks permissions with, when
+-- its policy store is postgres.
+CREATE TABLE gateway_tenants
+(
+    id          text primary key,
+    name        text        not null,
+    description text        not null default '',
+    status      text        not null default 'active',
+    created_at  timestamptz not null default current_timestamp,
+    updated_at  timestamptz not null default current_timestamp
+);
+
+CREATE TABLE gateway_roles
+(
+    id          text primary key,
+    name        text        not null,
+    tenant_id   text        not null
+        constraint gateway_roles_gateway_tenants_id_fk
+            references gateway_tenants,
+    permissions jsonb       not null default '[]',
+    created_at  timestamptz not null default current_timestamp,
+    updated_at  timestamptz not null default current_timestamp
+);
+
+CREATE INDEX gateway_roles_tenant_id_idx ON gateway_roles (tenant_id);
+
+CREATE TABLE gateway_resources
+(
+    name        text primary key,
+    description text        not null default '',
+    prefixes    jsonb       not null default '[]',
+    actions     jsonb       not null default '{}',
+    updated_at  timestamptz not null default current_timestamp
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE gateway_resources;
+DROP TABLE gateway_roles;
+DROP TABLE gateway_tenants;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The failure before the last, so that an attempt counted before its check
+-- can be taken back.
+ALTER TABLE login_attempts ADD COLUMN previous_failed_at timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE login_attempts DROP COLUMN previous_failed_at;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The gateway reloads its policy when the latest updated_at of a table
+-- changes, so rows edited with plain SQL need it set too.
+CREATE TRIGGER update_gateway_tenant_updated_at BEFORE UPDATE
+    ON gateway_tenants FOR EACH ROW EXECUTE PROCEDURE
+    update_updated_at_column();
+
+CREATE TRIGGER update_gateway_role_updated_at BEFORE UPDATE
+    ON gateway_roles FOR EACH ROW EXECUTE PROCEDURE
+    update_updated_at_column();
+
+CREATE TRIGGER update_gateway_resource_updated_at BEFORE UPDATE
+    ON gateway_resources FOR EACH ROW EXECUTE PROCEDURE
+    update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER update_gateway_resource_updated_at ON gateway_resources;
+DROP TRIGGER update_gateway_role_updated_at ON gateway_roles;
+DROP TRIGGER update_gateway_tenant_updated_at ON gateway_tenants;
+-- +goose StatementEnd
@@ -12,6 +12,7 @@ import (
 
 	"micro/config"
 	"micro/internal/domain/book"
+	"micro/internal/utility/respond"
 )
 
 // Version is injected using ldflags during build time
@@ -61,9 +62,14 @@ func testEmptyBook() {
 			status, http.StatusOK)
 	}
 
+	var list respond.Standard
+	if err := json.Unmarshal(got, &list); err != nil {
+		log.Fatalln(err)
+	}
+	data, _ := json.Marshal(list.Data)
 	expected, _ := json.Marshal(make([]*book.Res, 0))
 
-	if !bytes.Equal(expected, got) {
+	if !bytes.Equal(expected, data) || list.Meta.Total != 0 {
 		log.Printf("handler returned unexpected body: got %v want %v", string(got), expected)
 	}
 
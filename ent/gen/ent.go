@@ -14,8 +14,13 @@ import (
 	"entgo.io/ent/dialect/sql/sqlgraph"
 	"micro/ent/gen/author"
 	"micro/ent/gen/book"
+	"micro/ent/gen/permission"
+	"micro/ent/gen/personaltoken"
+	"micro/ent/gen/recoverycode"
+	"micro/ent/gen/role"
 	"micro/ent/gen/session"
 	"micro/ent/gen/user"
+	"micro/ent/gen/useridentity"
 )
 
 // ent aliases to avoid import conflicts in user's code.
@@ -76,10 +81,15 @@ var (
 func checkColumn(table, column string) error {
 	initCheck.Do(func() {
 		columnCheck = sql.NewColumnCheck(map[string]func(string) bool{
-			author.Table:  author.ValidColumn,
-			book.Table:    book.ValidColumn,
-			session.Table: session.ValidColumn,
-			user.Table:    user.ValidColumn,
+			author.Table:        author.ValidColumn,
+			book.Table:          book.ValidColumn,
+			permission.Table:    permission.ValidColumn,
+			personaltoken.Table: personaltoken.ValidColumn,
+			recoverycode.Table:  recoverycode.ValidColumn,
+			role.Table:          role.ValidColumn,
+			session.Table:       session.ValidColumn,
+			user.Table:          user.ValidColumn,
+			useridentity.Table:  useridentity.ValidColumn,
 		})
 	})
 	return columnCheck(table, column)
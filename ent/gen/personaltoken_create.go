@@ -0,0 +1,337 @@
+// Code generated by ent, DO NOT EDIT.
+
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"entgo.io/ent/schema/field"
+	"micro/ent/gen/personaltoken"
+	"micro/ent/gen/user"
+)
+
+// PersonalTokenCreate is the builder for creating a PersonalToken entity.
+type PersonalTokenCreate struct {
+	config
+	mutation *PersonalTokenMutation
+	hooks    []Hook
+}
+
+// SetUserID sets the "user_id" field.
+func (ptc *PersonalTokenCreate) SetUserID(u uint64) *PersonalTokenCreate {
+	ptc.mutation.SetUserID(u)
+	return ptc
+}
+
+// SetName sets the "name" field.
+func (ptc *PersonalTokenCreate) SetName(s string) *PersonalTokenCreate {
+	ptc.mutation.SetName(s)
+	return ptc
+}
+
+// SetPrefix sets the "prefix" field.
+func (ptc *PersonalTokenCreate) SetPrefix(s string) *PersonalTokenCreate {
+	ptc.mutation.SetPrefix(s)
+	return ptc
+}
+
+// SetTokenHash sets the "token_hash" field.
+func (ptc *PersonalTokenCreate) SetTokenHash(s string) *PersonalTokenCreate {
+	ptc.mutation.SetTokenHash(s)
+	return ptc
+}
+
+// SetScopes sets the "scopes" field.
+func (ptc *PersonalTokenCreate) SetScopes(s []string) *PersonalTokenCreate {
+	ptc.mutation.SetScopes(s)
+	return ptc
+}
+
+// SetExpiresAt sets the "expires_at" field.
+func (ptc *PersonalTokenCreate) SetExpiresAt(t time.Time) *PersonalTokenCreate {
+	ptc.mutation.SetExpiresAt(t)
+	return ptc
+}
+
+// SetNillableExpiresAt sets the "expires_at" field if the given value is not nil.
+func (ptc *PersonalTokenCreate) SetNillableExpiresAt(t *time.Time) *PersonalTokenCreate {
+	if t != nil {
+		ptc.SetExpiresAt(*t)
+	}
+	return ptc
+}
+
+// SetLastUsedAt sets the "last_used_at" field.
+func (ptc *PersonalTokenCreate) SetLastUsedAt(t time.Time) *PersonalTokenCreate {
+	ptc.mutation.SetLastUsedAt(t)
+	return ptc
+}
+
+// SetNillableLastUsedAt sets the "last_used_at" field if the given value is not nil.
+func (ptc *PersonalTokenCreate) SetNillableLastUsedAt(t *time.Time) *PersonalTokenCreate {
+	if t != nil {
+		ptc.SetLastUsedAt(*t)
+	}
+	return ptc
+}
+
+// SetCreatedAt sets the "created_at" field.
+func (ptc *PersonalTokenCreate) SetCreatedAt(t time.Time) *PersonalTokenCreate {
+	ptc.mutation.SetCreatedAt(t)
+	return ptc
+}
+
+// SetNillableCreatedAt sets the "created_at" field if the given value is not nil.
+func (ptc *PersonalTokenCreate) SetNillableCreatedAt(t *time.Time) *PersonalTokenCreate {
+	if t != nil {
+		ptc.SetCreatedAt(*t)
+	}
+	return ptc
+}
+
+// SetID sets the "id" field.
+func (ptc *PersonalTokenCreate) SetID(u uint64) *PersonalTokenCreate {
+	ptc.mutation.SetID(u)
+	return ptc
+}
+
+// SetUser sets the "user" edge to the User entity.
+func (ptc *PersonalTokenCreate) SetUser(u *User) *PersonalTokenCreate {
+	return ptc.SetUserID(u.ID)
+}
+
+// Mutation returns the PersonalTokenMutation object of the builder.
+func (ptc *PersonalTokenCreate) Mutation() *PersonalTokenMutation {
+	return ptc.mutation
+}
+
+// Save creates the PersonalToken in the database.
+func (ptc *PersonalTokenCreate) Save(ctx context.Context) (*PersonalToken, error) {
+	ptc.defaults()
+	return withHooks(ctx, ptc.sqlSave, ptc.mutation, ptc.hooks)
+}
+
+// SaveX calls Save and panics if Save returns an error.
+func (ptc *PersonalTokenCreate) SaveX(ctx context.Context) *PersonalToken {
+	v, err := ptc.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Exec executes the query.
+func (ptc *PersonalTokenCreate) Exec(ctx context.Context) error {
+	_, err := ptc.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (ptc *PersonalTokenCreate) ExecX(ctx context.Context) {
+	if err := ptc.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// defaults sets the default values of the builder before save.
+func (ptc *PersonalTokenCreate) defaults() {
+	if _, ok := ptc.mutation.CreatedAt(); !ok {
+		v := personaltoken.DefaultCreatedAt()
+		ptc.mutation.SetCreatedAt(v)
+	}
+}
+
+// check runs all checks and user-defined validators on the builder.
+func (ptc *PersonalTokenCreate) check() error {
+	if _, ok := ptc.mutation.UserID(); !ok {
+		return &ValidationError{Name: "user_id", err: errors.New(`gen: missing required field "PersonalToken.user_id"`)}
+	}
+	if _, ok := ptc.mutation.Name(); !ok {
+		return &ValidationError{Name: "name", err: errors.New(`gen: missing required field "PersonalToken.name"`)}
+	}
+	if _, ok := ptc.mutation.Prefix(); !ok {
+		return &ValidationError{Name: "prefix", err: errors.New(`gen: missing required field "PersonalToken.prefix"`)}
+	}
+	if _, ok := ptc.mutation.TokenHash(); !ok {
+		return &ValidationError{Name: "token_hash", err: errors.New(`gen: missing required field "PersonalToken.token_hash"`)}
+	}
+	if _, ok := ptc.mutation.Scopes(); !ok {
+		return &ValidationError{Name: "scopes", err: errors.New(`gen: missing required field "PersonalToken.scopes"`)}
+	}
+	if _, ok := ptc.mutation.CreatedAt(); !ok {
+		return &ValidationError{Name: "created_at", err: errors.New(`gen: missing required field "PersonalToken.created_at"`)}
+	}
+	if len(ptc.mutation.UserIDs()) == 0 {
+		return &ValidationError{Name: "user", err: errors.New(`gen: missing required edge "PersonalToken.user"`)}
+	}
+	return nil
+}
+
+func (ptc *PersonalTokenCreate) sqlSave(ctx context.Context) (*PersonalToken, error) {
+	if err := ptc.check(); err != nil {
+		return nil, err
+	}
+	_node, _spec := ptc.createSpec()
+	if err := sqlgraph.CreateNode(ctx, ptc.driver, _spec); err != nil {
+		if sqlgraph.IsConstraintError(err) {
+			err = &ConstraintError{msg: err.Error(), wrap: err}
+		}
+		return nil, err
+	}
+	if _spec.ID.Value != _node.ID {
+		id := _spec.ID.Value.(int64)
+		_node.ID = uint64(id)
+	}
+	ptc.mutation.id = &_node.ID
+	ptc.mutation.done = true
+	return _node, nil
+}
+
+func (ptc *PersonalTokenCreate) createSpec() (*PersonalToken, *sqlgraph.CreateSpec) {
+	var (
+		_node = &PersonalToken{config: ptc.config}
+		_spec = sqlgraph.NewCreateSpec(personaltoken.Table, sqlgraph.NewFieldSpec(personaltoken.FieldID, field.TypeUint64))
+	)
+	if id, ok := ptc.mutation.ID(); ok {
+		_node.ID = id
+		_spec.ID.Value = id
+	}
+	if value, ok := ptc.mutation.Name(); ok {
+		_spec.SetField(personaltoken.FieldName, field.TypeString, value)
+		_node.Name = value
+	}
+	if value, ok := ptc.mutation.Prefix(); ok {
+		_spec.SetField(personaltoken.FieldPrefix, field.TypeString, value)
+		_node.Prefix = value
+	}
+	if value, ok := ptc.mutation.TokenHash(); ok {
+		_spec.SetField(personaltoken.FieldTokenHash, field.TypeString, value)
+		_node.TokenHash = value
+	}
+	if value, ok := ptc.mutation.Scopes(); ok {
+		_spec.SetField(personaltoken.FieldScopes, field.TypeJSON, value)
+		_node.Scopes = value
+	}
+	if value, ok := ptc.mutation.ExpiresAt(); ok {
+		_spec.SetField(personaltoken.FieldExpiresAt, field.TypeTime, value)
+		_node.ExpiresAt = &value
+	}
+	if value, ok := ptc.mutation.LastUsedAt(); ok {
+		_spec.SetField(personaltoken.FieldLastUsedAt, field.TypeTime, value)
+		_node.LastUsedAt = &value
+	}
+	if value, ok := ptc.mutation.CreatedAt(); ok {
+		_spec.SetField(personaltoken.FieldCreatedAt, field.TypeTime, value)
+		_node.CreatedAt = value
+	}
+	if nodes := ptc.mutation.UserIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   personaltoken.UserTable,
+			Columns: []string{personaltoken.UserColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: sqlgraph.NewFieldSpec(user.FieldID, field.TypeUint64),
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_node.UserID = nodes[0]
+		_spec.Edges = append(_spec.Edges, edge)
+	}
+	return _node, _spec
+}
+
+// PersonalTokenCreateBulk is the builder for creating many PersonalToken entities in bulk.
+type PersonalTokenCreateBulk struct {
+	config
+	err      error
+	builders []*PersonalTokenCreate
+}
+
+// Save creates the PersonalToken entities in the database.
+func (ptcb *PersonalTokenCreateBulk) Save(ctx context.Context) ([]*PersonalToken, error) {
+	if ptcb.err != nil {
+		return nil, ptcb.err
+	}
+	specs := make([]*sqlgraph.CreateSpec, len(ptcb.builders))
+	nodes := make([]*PersonalToken, len(ptcb.builders))
+	mutators := make([]Mutator, len(ptcb.builders))
+	for i := range ptcb.builders {
+		func(i int, root context.Context) {
+			builder := ptcb.builders[i]
+			builder.defaults()
+			var mut Mutator = MutateFunc(func(ctx context.Context, m Mutation) (Value, error) {
+				mutation, ok := m.(*PersonalTokenMutation)
+				if !ok {
+					return nil, fmt.Errorf("unexpected mutation type %T", m)
+				}
+				if err := builder.check(); err != nil {
+					return nil, err
+				}
+				builder.mutation = mutation
+				var err error
+				nodes[i], specs[i] = builder.createSpec()
+				if i < len(mutators)-1 {
+					_, err = mutators[i+1].Mutate(root, ptcb.builders[i+1].mutation)
+				} else {
+					spec := &sqlgraph.BatchCreateSpec{Nodes: specs}
+					// Invoke the actual operation on the latest mutation in the chain.
+					if err = sqlgraph.BatchCreate(ctx, ptcb.driver, spec); err != nil {
+						if sqlgraph.IsConstraintError(err) {
+							err = &ConstraintError{msg: err.Error(), wrap: err}
+						}
+					}
+				}
+				if err != nil {
+					return nil, err
+				}
+				mutation.id = &nodes[i].ID
+				if specs[i].ID.Value != nil && nodes[i].ID == 0 {
+					id := specs[i].ID.Value.(int64)
+					nodes[i].ID = uint64(id)
+				}
+				mutation.done = true
+				return nodes[i], nil
+			})
+			for i := len(builder.hooks) - 1; i >= 0; i-- {
+				mut = builder.hooks[i](mut)
+			}
+			mutators[i] = mut
+		}(i, ctx)
+	}
+	if len(mutators) > 0 {
+		if _, err := mutators[0].Mutate(ctx, ptcb.builders[0].mutation); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// SaveX is like Save, but panics if an error occurs.
+func (ptcb *PersonalTokenCreateBulk) SaveX(ctx context.Context) []*PersonalToken {
+	v, err := ptcb.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Exec executes the query.
+func (ptcb *PersonalTokenCreateBulk) Exec(ctx context.Context) error {
+	_, err := ptcb.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (ptcb *PersonalTokenCreateBulk) ExecX(ctx context.Context) {
+	if err := ptcb.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+// PersonalToken holds the schema definition for the PersonalToken entity. It
+// lets scripts authenticate as a user, with some of their permissions. Only a
+// hash of each token is kept, along with its prefix to find it by.
+type PersonalToken struct {
+	ent.Schema
+}
+
+// Fields of the PersonalToken.
+func (PersonalToken) Fields() []ent.Field {
+	return []ent.Field{
+		field.Uint64("id"),
+		field.Uint64("user_id"),
+		field.String("name"),
+		field.String("prefix").Unique(),
+		field.String("token_hash").Sensitive(),
+		field.Strings("scopes"),
+		field.Time("expires_at").Optional().Nillable(),
+		field.Time("last_used_at").Optional().Nillable(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+	}
+}
+
+// Edges of the PersonalToken.
+func (PersonalToken) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("user", User.Type).Ref("personal_tokens").Field("user_id").Unique().Required(),
+	}
+}
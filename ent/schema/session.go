@@ -1,6 +1,8 @@
 package schema
 
 import (
+	"time"
+
 	"entgo.io/ent"
 	"entgo.io/ent/schema/field"
 )
@@ -17,5 +19,9 @@ func (Session) Fields() []ent.Field {
 		field.Uint64("user_id").Nillable().Optional(),
 		field.Bytes("data"),
 		field.Time("expiry"),
+		field.String("user_agent").Default(""),
+		field.String("ip_address").Default(""),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("last_seen_at").Default(time.Now),
 	}
 }
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 )
 
@@ -20,5 +21,18 @@ func (User) Fields() []ent.Field {
 		field.String("email"),
 		field.String("password"),
 		field.Time("verified_at").Optional().Nillable().StructTag(`json:"-"`),
+		field.String("totp_secret").Optional().Sensitive(),
+		field.Time("totp_enabled_at").Optional().Nillable().StructTag(`json:"-"`),
+		field.Int64("totp_last_step").Optional().StructTag(`json:"-"`),
+	}
+}
+
+// Edges of the User.
+func (User) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("roles", Role.Type),
+		edge.To("recovery_codes", RecoveryCode.Type),
+		edge.To("identities", UserIdentity.Type),
+		edge.To("personal_tokens", PersonalToken.Type),
 	}
 }
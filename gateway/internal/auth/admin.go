@@ -0,0 +1,520 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var (
+	ErrTenantNotFound = fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+	ErrRoleNotFound   = fiber.NewError(fiber.StatusNotFound, "Role not found")
+	ErrTenantExists   = fiber.NewError(fiber.StatusConflict, "Tenant already exists")
+	ErrRoleExists     = fiber.NewError(fiber.StatusConflict, "Role already exists")
+	ErrTenantHasRoles = fiber.NewError(fiber.StatusConflict, "Tenant still has roles")
+	ErrOtherTenant    = fiber.NewError(fiber.StatusForbidden, "Belongs to another tenant")
+	ErrNotGrantable   = fiber.NewError(fiber.StatusForbidden, "Permission is not held by the caller")
+)
+
+// permissionScopes xếp từ rộng tới hẹp: một scope bao gồm các scope sau nó.
+var permissionScopes = []string{"all", "tenant", "own"}
+
+// AdminHandler là REST API quản lý tenant, role và permission của role. Mọi
+// thay đổi được ghi vào store trước, rồi mới áp dụng cho PermissionManager.
+type AdminHandler struct {
+	pm    *PermissionManager
+	store PolicyStore
+	// mu tránh hai request cùng sửa một role và làm mất thay đổi của nhau
+	mu sync.Mutex
+}
+
+func NewAdminHandler(pm *PermissionManager, store PolicyStore) *AdminHandler {
+	return &AdminHandler{
+		pm:    pm,
+		store: store,
+	}
+}
+
+// RegisterRoutes thêm các route /admin. AuthMiddleware phân quyền chúng như
+// các service khác: /admin/tenants là resource "tenants", /admin/roles là
+// resource "roles", và /admin/roles/:id/permissions là action "permissions".
+// Handler cần claims của caller trong c.Locals("user"). Caller không có scope
+// "all" chỉ thấy tenant và role của tenant mình.
+func (h *AdminHandler) RegisterRoutes(router fiber.Router) {
+	h.pm.RegisterService("tenants", []string{"/admin/tenants"})
+	h.pm.RegisterService("roles", []string{"/admin/roles"})
+
+	admin := router.Group("/admin")
+
+	admin.Get("/tenants", h.Tenants)
+	admin.Get("/tenants/:id", h.Tenant)
+	admin.Post("/tenants", h.CreateTenant)
+	admin.Put("/tenants/:id", h.UpdateTenant)
+	admin.Delete("/tenants/:id", h.DeleteTenant)
+
+	admin.Get("/roles", h.Roles)
+	admin.Get("/roles/:id", h.Role)
+	admin.Post("/roles", h.CreateRole)
+	admin.Put("/roles/:id", h.UpdateRole)
+	admin.Delete("/roles/:id", h.DeleteRole)
+
+	admin.Get("/roles/:id/permissions", h.Permissions)
+	admin.Put("/roles/:id/permissions", h.SetPermissions)
+	admin.Post("/roles/:id/permissions", h.AddPermission)
+	admin.Delete("/roles/:id/permissions/:resource/:action", h.RemovePermission)
+}
+
+func (h *AdminHandler) Tenants(c *fiber.Ctx) error {
+	claims, err := caller(c)
+	if err != nil {
+		return err
+	}
+
+	tenants := slices.DeleteFunc(h.pm.Tenants(), func(t Tenant) bool {
+		return !h.sees(claims, "tenants", t.ID)
+	})
+	return c.JSON(tenants)
+}
+
+func (h *AdminHandler) Tenant(c *fiber.Ctx) error {
+	claims, err := caller(c)
+	if err != nil {
+		return err
+	}
+
+	tenant, exists := h.pm.GetTenant(c.Params("id"))
+	if !exists || !h.sees(claims, "tenants", tenant.ID) {
+		return ErrTenantNotFound
+	}
+	return c.JSON(tenant)
+}
+
+func (h *AdminHandler) CreateTenant(c *fiber.Ctx) error {
+	var tenant Tenant
+	if err := c.BodyParser(&tenant); err != nil {
+		return fiber.ErrBadRequest
+	}
+	if err := validateTenant(&tenant); err != nil {
+		return err
+	}
+	if err := h.authorizeTenant(c, "create", tenant.ID); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.pm.GetTenant(tenant.ID); exists {
+		return ErrTenantExists
+	}
+	tenant.CreatedAt = time.Now()
+	tenant.UpdatedAt = tenant.CreatedAt
+
+	if err := h.store.SaveTenant(c.UserContext(), tenant); err != nil {
+		return publicError(err)
+	}
+	h.pm.AddTenant(tenant)
+
+	return c.Status(fiber.StatusCreated).JSON(tenant)
+}
+
+func (h *AdminHandler) UpdateTenant(c *fiber.Ctx) error {
+	var tenant Tenant
+	if err := c.BodyParser(&tenant); err != nil {
+		return fiber.ErrBadRequest
+	}
+	// Params dùng lại buffer của request, nên ID được giữ cần là bản sao
+	tenant.ID = utils.CopyString(c.Params("id"))
+	if err := validateTenant(&tenant); err != nil {
+		return err
+	}
+	if err := h.authorizeTenant(c, "update", tenant.ID); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current, exists := h.pm.GetTenant(tenant.ID)
+	if !exists {
+		return ErrTenantNotFound
+	}
+	tenant.CreatedAt = current.CreatedAt
+	tenant.UpdatedAt = time.Now()
+
+	if err := h.store.SaveTenant(c.UserContext(), tenant); err != nil {
+		return publicError(err)
+	}
+	h.pm.UpdateTenant(tenant)
+
+	return c.JSON(tenant)
+}
+
+func (h *AdminHandler) DeleteTenant(c *fiber.Ctx) error {
+	tenantID := c.Params("id")
+	if err := h.authorizeTenant(c, "delete", tenantID); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.pm.GetTenant(tenantID); !exists {
+		return ErrTenantNotFound
+	}
+	for _, role := range h.pm.Roles() {
+		if role.TenantID == tenantID {
+			return ErrTenantHasRoles
+		}
+	}
+
+	if err := h.store.DeleteTenant(c.UserContext(), tenantID); err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return publicError(err)
+	}
+	h.pm.DeleteTenant(tenantID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AdminHandler) Roles(c *fiber.Ctx) error {
+	claims, err := caller(c)
+	if err != nil {
+		return err
+	}
+
+	roles := slices.DeleteFunc(h.pm.Roles(), func(r Role) bool {
+		return !h.sees(claims, "roles", r.TenantID)
+	})
+	return c.JSON(roles)
+}
+
+func (h *AdminHandler) Role(c *fiber.Ctx) error {
+	role, err := h.visibleRole(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(role)
+}
+
+func (h *AdminHandler) CreateRole(c *fiber.Ctx) error {
+	var role Role
+	if err := c.BodyParser(&role); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.validateRole(&role); err != nil {
+		return err
+	}
+	if err := h.authorizeRole(c, "create", Role{}, role); err != nil {
+		return err
+	}
+	if _, exists := h.pm.GetRole(role.ID); exists {
+		return ErrRoleExists
+	}
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+
+	if err := h.store.SaveRole(c.UserContext(), role); err != nil {
+		return publicError(err)
+	}
+	h.pm.AddRole(role)
+
+	return c.Status(fiber.StatusCreated).JSON(role)
+}
+
+func (h *AdminHandler) UpdateRole(c *fiber.Ctx) error {
+	var role Role
+	if err := c.BodyParser(&role); err != nil {
+		return fiber.ErrBadRequest
+	}
+	role.ID = utils.CopyString(c.Params("id"))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.validateRole(&role); err != nil {
+		return err
+	}
+	current, exists := h.pm.GetRole(role.ID)
+	if !exists {
+		return ErrRoleNotFound
+	}
+	if err := h.authorizeRole(c, "update", current, role); err != nil {
+		return err
+	}
+
+	return h.saveRole(c, current, role)
+}
+
+func (h *AdminHandler) DeleteRole(c *fiber.Ctx) error {
+	roleID := c.Params("id")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	role, exists := h.pm.GetRole(roleID)
+	if !exists {
+		return ErrRoleNotFound
+	}
+	if err := h.authorizeRole(c, "delete", role, role); err != nil {
+		return err
+	}
+
+	if err := h.store.DeleteRole(c.UserContext(), roleID); err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return publicError(err)
+	}
+	h.pm.DeleteRole(roleID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AdminHandler) Permissions(c *fiber.Ctx) error {
+	role, err := h.visibleRole(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(role.Permissions)
+}
+
+// SetPermissions thay toàn bộ permission của role.
+func (h *AdminHandler) SetPermissions(c *fiber.Ctx) error {
+	var permissions []Permission
+	if err := c.BodyParser(&permissions); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	role, exists := h.pm.GetRole(c.Params("id"))
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	updated := role
+	updated.Permissions = permissions
+	if err := h.validateRole(&updated); err != nil {
+		return err
+	}
+	if err := h.authorizeRole(c, "permissions", role, updated); err != nil {
+		return err
+	}
+
+	return h.saveRole(c, role, updated)
+}
+
+// AddPermission thêm permission cho role, hoặc thay permission có cùng
+// resource và action.
+func (h *AdminHandler) AddPermission(c *fiber.Ctx) error {
+	var permission Permission
+	if err := c.BodyParser(&permission); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	role, exists := h.pm.GetRole(c.Params("id"))
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	updated := role
+	updated.Permissions = slices.DeleteFunc(slices.Clone(role.Permissions), func(p Permission) bool {
+		return p.ResourceName == permission.ResourceName && p.ActionName == permission.ActionName
+	})
+	updated.Permissions = append(updated.Permissions, permission)
+	if err := h.validateRole(&updated); err != nil {
+		return err
+	}
+	if err := h.authorizeRole(c, "permissions", role, updated); err != nil {
+		return err
+	}
+
+	return h.saveRole(c, role, updated)
+}
+
+func (h *AdminHandler) RemovePermission(c *fiber.Ctx) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	role, exists := h.pm.GetRole(c.Params("id"))
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	updated := role
+	updated.Permissions = slices.DeleteFunc(slices.Clone(role.Permissions), func(p Permission) bool {
+		return p.ResourceName == c.Params("resource") && p.ActionName == c.Params("action")
+	})
+	if len(updated.Permissions) == len(role.Permissions) {
+		return fiber.NewError(fiber.StatusNotFound, "Permission not found")
+	}
+	if err := h.authorizeRole(c, "permissions", role, updated); err != nil {
+		return err
+	}
+
+	if err := h.saveRole(c, role, updated); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// saveRole ghi role đã sửa, giữ thời điểm tạo của role hiện tại. Caller cần
+// giữ h.mu.
+func (h *AdminHandler) saveRole(c *fiber.Ctx, current Role, role Role) error {
+	role.CreatedAt = current.CreatedAt
+	role.UpdatedAt = time.Now()
+
+	if err := h.store.SaveRole(c.UserContext(), role); err != nil {
+		return publicError(err)
+	}
+	h.pm.UpdateRole(role)
+
+	return c.JSON(role)
+}
+
+func validateTenant(tenant *Tenant) error {
+	if tenant.ID == "" || tenant.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id and name are required")
+	}
+	if tenant.Status == "" {
+		tenant.Status = "active"
+	}
+	return nil
+}
+
+// validateRole kiểm tra role thuộc một tenant có thật, và mỗi permission có
+// resource, action và scope hợp lệ. Resource có thể chưa được đăng ký, vì
+// service có thể đăng ký sau.
+func (h *AdminHandler) validateRole(role *Role) error {
+	if role.ID == "" || role.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id and name are required")
+	}
+	if _, exists := h.pm.GetTenant(role.TenantID); !exists {
+		return fiber.NewError(fiber.StatusBadRequest, "tenant_id is not a tenant")
+	}
+
+	if role.Permissions == nil {
+		role.Permissions = []Permission{}
+	}
+	for _, p := range role.Permissions {
+		if p.ResourceName == "" || p.ActionName == "" || len(p.Scopes) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "a permission needs resource_name, action_name and scopes")
+		}
+		for _, scope := range p.Scopes {
+			if !slices.Contains(permissionScopes, scope) {
+				return fiber.NewError(fiber.StatusBadRequest, "scope must be one of all, tenant and own")
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeTenant kiểm tra caller được dùng action trên tenantID: tenant của
+// chính caller, hoặc mọi tenant với scope "all". Tenant mới vì vậy cần scope
+// "all".
+func (h *AdminHandler) authorizeTenant(c *fiber.Ctx, action string, tenantID string) error {
+	claims, err := caller(c)
+	if err != nil {
+		return err
+	}
+
+	if tenantID != claims.TenantID && !h.holds(claims, "tenants", action, "all") {
+		return ErrOtherTenant
+	}
+	return nil
+}
+
+// authorizeRole kiểm tra caller được đổi current thành role bằng action trên
+// resource "roles". Caller không có scope "all" cho action đó chỉ sửa được
+// role trong tenant của mình. Permission mới của role phải là permission mà
+// chính caller có, với scope rộng bằng hoặc hơn. current rỗng khi tạo role.
+func (h *AdminHandler) authorizeRole(c *fiber.Ctx, action string, current Role, role Role) error {
+	claims, err := caller(c)
+	if err != nil {
+		return err
+	}
+
+	if !h.holds(claims, "roles", action, "all") {
+		if role.TenantID != claims.TenantID || (current.ID != "" && current.TenantID != claims.TenantID) {
+			return ErrOtherTenant
+		}
+	}
+
+	for _, p := range role.Permissions {
+		for _, scope := range p.Scopes {
+			if grants(current.Permissions, p.ResourceName, p.ActionName, scope) {
+				continue
+			}
+			if !h.holds(claims, p.ResourceName, p.ActionName, scope) {
+				return ErrNotGrantable
+			}
+		}
+	}
+	return nil
+}
+
+// visibleRole là role có id trong đường dẫn, nếu caller thấy được nó.
+func (h *AdminHandler) visibleRole(c *fiber.Ctx) (Role, error) {
+	claims, err := caller(c)
+	if err != nil {
+		return Role{}, err
+	}
+
+	role, exists := h.pm.GetRole(c.Params("id"))
+	if !exists || !h.sees(claims, "roles", role.TenantID) {
+		return Role{}, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// sees cho biết caller đọc được dữ liệu của tenantID trên resource: tenant của
+// chính caller, hoặc mọi tenant với scope "all".
+func (h *AdminHandler) sees(claims *JWTClaims, resourceName string, tenantID string) bool {
+	return tenantID == claims.TenantID || h.holds(claims, resourceName, "read", "all")
+}
+
+// caller là claims mà AuthMiddleware đặt cho request.
+func caller(c *fiber.Ctx) (*JWTClaims, error) {
+	claims, ok := c.Locals("user").(*JWTClaims)
+	if !ok {
+		return nil, fiber.ErrUnauthorized
+	}
+	return claims, nil
+}
+
+// holds cho biết một role của caller, trong tenant của caller, có permission
+// với scope bao gồm scope.
+func (h *AdminHandler) holds(claims *JWTClaims, resourceName string, actionName string, scope string) bool {
+	for _, roleID := range claims.Roles {
+		role, exists := h.pm.GetRole(roleID)
+		if exists && role.TenantID == claims.TenantID && grants(role.Permissions, resourceName, actionName, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// grants cho biết permissions có resource và action với scope bao gồm scope.
+func grants(permissions []Permission, resourceName string, actionName string, scope string) bool {
+	wanted := slices.Index(permissionScopes, scope)
+	for _, p := range permissions {
+		if p.ResourceName != resourceName || p.ActionName != actionName {
+			continue
+		}
+		for _, held := range p.Scopes {
+			if i := slices.Index(permissionScopes, held); i >= 0 && i <= wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	store := NewYAMLPolicyStore(path)
+
+	pm := NewPermissionManager(time.Hour)
+	pm.RegisterService("orders", []string{"/api/orders"})
+	require.NoError(t, pm.Load(context.Background(), store))
+
+	// The caller holds every permission it grants below.
+	pm.AddRole(Role{ID: "root", TenantID: "tenant-1", Permissions: []Permission{
+		{ResourceName: "tenants", ActionName: "read", Scopes: []string{"all"}},
+		{ResourceName: "tenants", ActionName: "create", Scopes: []string{"all"}},
+		{ResourceName: "tenants", ActionName: "update", Scopes: []string{"all"}},
+		{ResourceName: "tenants", ActionName: "delete", Scopes: []string{"all"}},
+		{ResourceName: "roles", ActionName: "read", Scopes: []string{"all"}},
+		{ResourceName: "roles", ActionName: "create", Scopes: []string{"all"}},
+		{ResourceName: "roles", ActionName: "update", Scopes: []string{"all"}},
+		{ResourceName: "roles", ActionName: "delete", Scopes: []string{"all"}},
+		{ResourceName: "roles", ActionName: "permissions", Scopes: []string{"all"}},
+		{ResourceName: "orders", ActionName: "read", Scopes: []string{"all"}},
+		{ResourceName: "orders", ActionName: "create", Scopes: []string{"all"}},
+	}})
+	root := &JWTClaims{UserID: "root", TenantID: "tenant-1", Roles: []string{"root"}}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", root)
+		return c.Next()
+	})
+	NewAdminHandler(pm, store).RegisterRoutes(app)
+
+	do := func(method string, path string, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(b)
+	}
+	stored := func() *Policy {
+		policy, err := store.Load(context.Background())
+		require.NoError(t, err)
+		return policy
+	}
+
+	t.Run("tenants", func(t *testing.T) {
+		code, _ := do(http.MethodPost, "/admin/tenants", `{"name": "No ID"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, body := do(http.MethodPost, "/admin/tenants", `{"id": "tenant-2", "name": "Tenant 2"}`)
+		assert.Equal(t, http.StatusCreated, code)
+		var tenant Tenant
+		require.NoError(t, json.Unmarshal([]byte(body), &tenant))
+		assert.Equal(t, "active", tenant.Status)
+		assert.False(t, tenant.CreatedAt.IsZero())
+
+		code, _ = do(http.MethodPost, "/admin/tenants", `{"id": "tenant-2", "name": "Again"}`)
+		assert.Equal(t, http.StatusConflict, code)
+
+		code, _ = do(http.MethodPut, "/admin/tenants/tenant-2", `{"name": "Tenant 2", "status": "suspended"}`)
+		assert.Equal(t, http.StatusOK, code)
+		code, body = do(http.MethodGet, "/admin/tenants/tenant-2", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"status":"suspended"`)
+
+		code, _ = do(http.MethodPut, "/admin/tenants/missing", `{"name": "Missing"}`)
+		assert.Equal(t, http.StatusNotFound, code)
+
+		// A tenant with roles stays.
+		code, _ = do(http.MethodDelete, "/admin/tenants/tenant-1", "")
+		assert.Equal(t, http.StatusConflict, code)
+
+		code, _ = do(http.MethodDelete, "/admin/tenants/tenant-2", "")
+		assert.Equal(t, http.StatusNoContent, code)
+		code, _ = do(http.MethodGet, "/admin/tenants/tenant-2", "")
+		assert.Equal(t, http.StatusNotFound, code)
+
+		code, body = do(http.MethodGet, "/admin/tenants", "")
+		assert.Equal(t, http.StatusOK, code)
+		var tenants []Tenant
+		require.NoError(t, json.Unmarshal([]byte(body), &tenants))
+		assert.Len(t, tenants, 1)
+		assert.Len(t, stored().Tenants, 1)
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		code, _ := do(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "missing"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1",
+			"permissions": [{"resource_name": "orders", "action_name": "create", "scopes": ["everywhere"]}]}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1"}`)
+		assert.Equal(t, http.StatusCreated, code)
+		code, _ = do(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1"}`)
+		assert.Equal(t, http.StatusConflict, code)
+
+		code, _ = do(http.MethodPut, "/admin/roles/writer", `{"name": "Author", "tenant_id": "tenant-1"}`)
+		assert.Equal(t, http.StatusOK, code)
+		role, exists := pm.GetRole("writer")
+		assert.True(t, exists)
+		assert.Equal(t, "Author", role.Name)
+
+		code, _ = do(http.MethodDelete, "/admin/roles/writer", "")
+		assert.Equal(t, http.StatusNoContent, code)
+		code, _ = do(http.MethodDelete, "/admin/roles/writer", "")
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Len(t, stored().Roles, 1)
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		// A cached result is dropped when the permissions of the role change.
+		assert.True(t, pm.HasPermission(reader, "orders", "read", ""))
+		assert.False(t, pm.HasPermission(reader, "orders", "create", ""))
+
+		code, _ := do(http.MethodPost, "/admin/roles/reader/permissions", `{"resource_name": "orders", "action_name": "create", "scopes": ["all"]}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, pm.HasPermission(reader, "orders", "create", ""))
+
+		code, body := do(http.MethodGet, "/admin/roles/reader/permissions", "")
+		assert.Equal(t, http.StatusOK, code)
+		var permissions []Permission
+		require.NoError(t, json.Unmarshal([]byte(body), &permissions))
+		assert.Len(t, permissions, 2)
+
+		code, _ = do(http.MethodDelete, "/admin/roles/reader/permissions/orders/read", "")
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.False(t, pm.HasPermission(reader, "orders", "read", ""))
+		code, _ = do(http.MethodDelete, "/admin/roles/reader/permissions/orders/read", "")
+		assert.Equal(t, http.StatusNotFound, code)
+
+		code, _ = do(http.MethodPut, "/admin/roles/reader/permissions", `[]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.False(t, pm.HasPermission(reader, "orders", "create", ""))
+		assert.Empty(t, stored().Roles[0].Permissions)
+	})
+}
+
+func TestAdminHandler_Authorization(t *testing.T) {
+	pm := NewPermissionManager(time.Hour)
+	store := NewYAMLPolicyStore(filepath.Join(t.TempDir(), "policy.yaml"))
+	pm.Reload(&Policy{
+		Tenants: []Tenant{{ID: "tenant-1", Status: "active"}},
+		Roles: []Role{{ID: "admin", TenantID: "tenant-1", Permissions: []Permission{
+			{ResourceName: "roles", ActionName: "read", Scopes: []string{"all"}},
+			{ResourceName: "roles", ActionName: "permissions", Scopes: []string{"all"}},
+		}}},
+	})
+
+	admin := &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"admin"}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		// As AuthMiddleware.Handle, with the claims of the admin.
+		m := &AuthMiddleware{permissionMgr: pm}
+		resource, action, targetID := m.parseRequest(c)
+		if !pm.HasPermission(admin, resource, action, targetID) {
+			return fiber.ErrForbidden
+		}
+		c.Locals("user", admin)
+		return c.Next()
+	})
+	NewAdminHandler(pm, store).RegisterRoutes(app)
+
+	status := func(method string, path string) int {
+		res, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/admin/roles"))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/admin/roles/admin/permissions"))
+	assert.Equal(t, http.StatusForbidden, status(http.MethodDelete, "/admin/roles/admin"))
+	assert.Equal(t, http.StatusForbidden, status(http.MethodGet, "/admin/tenants"))
+}
+
+func TestAdminHandler_Escalation(t *testing.T) {
+	pm := NewPermissionManager(time.Hour)
+	store := NewYAMLPolicyStore(filepath.Join(t.TempDir(), "policy.yaml"))
+	pm.Reload(&Policy{
+		Tenants: []Tenant{{ID: "tenant-1", Status: "active"}, {ID: "tenant-2", Status: "active"}},
+		Roles: []Role{
+			{ID: "tenant-admin", Name: "Tenant admin", TenantID: "tenant-1", Permissions: []Permission{
+				{ResourceName: "tenants", ActionName: "read", Scopes: []string{"tenant"}},
+				{ResourceName: "tenants", ActionName: "update", Scopes: []string{"tenant"}},
+				{ResourceName: "tenants", ActionName: "delete", Scopes: []string{"tenant"}},
+				{ResourceName: "roles", ActionName: "read", Scopes: []string{"tenant"}},
+				{ResourceName: "roles", ActionName: "create", Scopes: []string{"tenant"}},
+				{ResourceName: "roles", ActionName: "update", Scopes: []string{"tenant"}},
+				{ResourceName: "roles", ActionName: "permissions", Scopes: []string{"tenant"}},
+				{ResourceName: "orders", ActionName: "read", Scopes: []string{"tenant"}},
+			}},
+			{ID: "global-admin", Name: "Global admin", TenantID: "tenant-1", Permissions: []Permission{
+				{ResourceName: "roles", ActionName: "read", Scopes: []string{"all"}},
+				{ResourceName: "roles", ActionName: "create", Scopes: []string{"all"}},
+				{ResourceName: "orders", ActionName: "read", Scopes: []string{"all"}},
+			}},
+			{ID: "other", Name: "Other", TenantID: "tenant-2", Permissions: []Permission{}},
+		},
+	})
+
+	var caller *JWTClaims
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", caller)
+		return c.Next()
+	})
+	NewAdminHandler(pm, store).RegisterRoutes(app)
+
+	do := func(method string, path string, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(b)
+	}
+	status := func(method string, path string, body string) int {
+		code, _ := do(method, path, body)
+		return code
+	}
+
+	t.Run("permissions the caller does not hold", func(t *testing.T) {
+		caller = &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"tenant-admin"}}
+
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1",
+			"permissions": [{"resource_name": "orders", "action_name": "create", "scopes": ["tenant"]}]}`))
+		// A wider scope than the caller holds.
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1",
+			"permissions": [{"resource_name": "orders", "action_name": "read", "scopes": ["all"]}]}`))
+		// The caller can not widen its own role either.
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles/tenant-admin/permissions",
+			`{"resource_name": "roles", "action_name": "delete", "scopes": ["tenant"]}`))
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPut, "/admin/roles/tenant-admin/permissions",
+			`[{"resource_name": "orders", "action_name": "read", "scopes": ["all"]}]`))
+
+		assert.Equal(t, http.StatusCreated, status(http.MethodPost, "/admin/roles", `{"id": "writer", "name": "Writer", "tenant_id": "tenant-1",
+			"permissions": [{"resource_name": "orders", "action_name": "read", "scopes": ["own"]}]}`))
+		assert.Equal(t, http.StatusOK, status(http.MethodPost, "/admin/roles/writer/permissions",
+			`{"resource_name": "orders", "action_name": "read", "scopes": ["tenant"]}`))
+
+		role, _ := pm.GetRole("tenant-admin")
+		assert.Len(t, role.Permissions, 8)
+	})
+
+	t.Run("reading another tenant", func(t *testing.T) {
+		caller = &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"tenant-admin"}}
+
+		code, body := do(http.MethodGet, "/admin/tenants", "")
+		assert.Equal(t, http.StatusOK, code)
+		var tenants []Tenant
+		require.NoError(t, json.Unmarshal([]byte(body), &tenants))
+		require.Len(t, tenants, 1)
+		assert.Equal(t, "tenant-1", tenants[0].ID)
+		assert.Equal(t, http.StatusOK, status(http.MethodGet, "/admin/tenants/tenant-1", ""))
+		assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/admin/tenants/tenant-2", ""))
+
+		code, body = do(http.MethodGet, "/admin/roles", "")
+		assert.Equal(t, http.StatusOK, code)
+		var roles []Role
+		require.NoError(t, json.Unmarshal([]byte(body), &roles))
+		for _, role := range roles {
+			assert.Equal(t, "tenant-1", role.TenantID)
+		}
+		assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/admin/roles/other", ""))
+		assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/admin/roles/other/permissions", ""))
+
+		// The all scope reads every tenant.
+		caller = &JWTClaims{UserID: "user-2", TenantID: "tenant-1", Roles: []string{"global-admin"}}
+		code, body = do(http.MethodGet, "/admin/roles", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"id":"other"`)
+		assert.Equal(t, http.StatusOK, status(http.MethodGet, "/admin/roles/other", ""))
+	})
+
+	t.Run("another tenant", func(t *testing.T) {
+		caller = &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"tenant-admin"}}
+
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPut, "/admin/tenants/tenant-2", `{"name": "Renamed"}`))
+		assert.Equal(t, http.StatusForbidden, status(http.MethodDelete, "/admin/tenants/tenant-2", ""))
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/tenants", `{"id": "tenant-3", "name": "Tenant 3"}`))
+
+		tenant, exists := pm.GetTenant("tenant-2")
+		assert.True(t, exists)
+		assert.Empty(t, tenant.Name)
+		_, exists = pm.GetTenant("tenant-3")
+		assert.False(t, exists)
+
+		assert.Equal(t, http.StatusOK, status(http.MethodPut, "/admin/tenants/tenant-1", `{"name": "Tenant 1"}`))
+	})
+
+	t.Run("roles of another tenant", func(t *testing.T) {
+		caller = &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"tenant-admin"}}
+
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles", `{"id": "spy", "name": "Spy", "tenant_id": "tenant-2"}`))
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles/other/permissions",
+			`{"resource_name": "orders", "action_name": "read", "scopes": ["tenant"]}`))
+		// Nor move one into the tenant of the caller.
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPut, "/admin/roles/other", `{"name": "Other", "tenant_id": "tenant-1"}`))
+
+		role, _ := pm.GetRole("other")
+		assert.Equal(t, "tenant-2", role.TenantID)
+		assert.Empty(t, role.Permissions)
+
+		// A caller with the all scope manages roles of every tenant.
+		caller = &JWTClaims{UserID: "user-2", TenantID: "tenant-1", Roles: []string{"global-admin"}}
+		assert.Equal(t, http.StatusCreated, status(http.MethodPost, "/admin/roles", `{"id": "auditor", "name": "Auditor", "tenant_id": "tenant-2",
+			"permissions": [{"resource_name": "orders", "action_name": "read", "scopes": ["tenant"]}]}`))
+		assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/roles", `{"id": "clerk", "name": "Clerk", "tenant_id": "tenant-2",
+			"permissions": [{"resource_name": "orders", "action_name": "create", "scopes": ["own"]}]}`))
+	})
+}
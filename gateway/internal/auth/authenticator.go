@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// UpstreamAuthenticator gửi username và password tới service quản lý user.
+// Service trả về 200 với User dạng JSON, hoặc 401 khi sai thông tin đăng nhập.
+// Khi refresh, user được đọc lại bằng GET usersURL/{id}, trả về 404 nếu user
+// không còn.
+type UpstreamAuthenticator struct {
+	url      string
+	usersURL string
+	client   *http.Client
+}
+
+func NewUpstreamAuthenticator(url string, usersURL string) *UpstreamAuthenticator {
+	return &UpstreamAuthenticator{
+		url:      url,
+		usersURL: usersURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *UpstreamAuthenticator) Authenticate(ctx context.Context, username string, password string) (*User, error) {
+	body, err := json.Marshal(map[string]string{
+		"username": username,
+		"password": password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return a.do(req, ErrInvalidCredentials)
+}
+
+func (a *UpstreamAuthenticator) User(ctx context.Context, userID string) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.usersURL+"/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.do(req, ErrUserNotFound)
+}
+
+// do gửi request và đọc User. denied là lỗi trả về khi service từ chối.
+func (a *UpstreamAuthenticator) do(req *http.Request, denied error) (*User, error) {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, denied
+	default:
+		return nil, fmt.Errorf("auth: unexpected status %d from %s", resp.StatusCode, req.URL)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	if user.ID == "" {
+		return nil, fmt.Errorf("auth: %s returned a user without id", req.URL)
+	}
+	return &user, nil
+}
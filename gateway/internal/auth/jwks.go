@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSMaxAge             = time.Hour
+	defaultJWKSMinRefresh         = 30 * time.Second
+	defaultJWKSFetchTimeout       = 5 * time.Second
+	maxJWKSSize             int64 = 1 << 20
+)
+
+var ErrUnknownKey = errors.New("jwks: no key with this kid")
+
+// JWKSConfig cho biết lấy public key để xác thực token từ đâu: URL hoặc file.
+type JWKSConfig struct {
+	URL  string
+	File string
+	// MaxAge là thời gian giữ key trước khi tải lại.
+	MaxAge time.Duration
+	// MinRefreshInterval giới hạn tần suất tải lại khi gặp kid lạ, để token
+	// giả mạo không làm quá tải JWKS endpoint.
+	MinRefreshInterval time.Duration
+}
+
+// KeySet giữ các public key của JWKS theo kid. Khi issuer xoay key, kid mới
+// chưa có trong cache sẽ làm KeySet tải lại JWKS.
+type KeySet struct {
+	config JWKSConfig
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewKeySet tải JWKS lần đầu, để gateway không khởi động với cấu hình sai.
+func NewKeySet(config JWKSConfig) (*KeySet, error) {
+	if (config.URL == "") == (config.File == "") {
+		return nil, errors.New("jwks: set exactly one of url and file")
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = defaultJWKSMaxAge
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = defaultJWKSMinRefresh
+	}
+
+	ks := &KeySet{
+		config: config,
+		client: &http.Client{Timeout: defaultJWKSFetchTimeout},
+	}
+	if err := ks.Refresh(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Key trả về public key của kid, tải lại JWKS nếu key đã cũ hoặc kid chưa có.
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	key, exists := ks.keys[kid]
+	age := time.Since(ks.fetchedAt)
+	ks.mu.RUnlock()
+
+	if exists && age < ks.config.MaxAge {
+		return key, nil
+	}
+	if !exists && age < ks.config.MinRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	if err := ks.Refresh(); err != nil {
+		// Vẫn dùng key cũ khi JWKS tạm thời không tải được
+		if exists {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if key, exists := ks.keys[kid]; exists {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Refresh tải lại toàn bộ JWKS. Key đã bị issuer gỡ bỏ cũng bị xóa khỏi cache.
+func (ks *KeySet) Refresh() error {
+	data, err := ks.fetch()
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	return nil
+}
+
+func (ks *KeySet) fetch() ([]byte, error) {
+	if ks.config.File != "" {
+		return os.ReadFile(ks.config.File)
+	}
+
+	resp, err := ks.client.Get(ks.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d from %s", resp.StatusCode, ks.config.URL)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS đọc các RSA và EC public key của một JWKS. Key không dùng để ký,
+// không có kid, hoặc có kty lạ được bỏ qua.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kid == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid rsa key")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	// ECDH kiểm tra điểm có nằm trên curve
+	if _, err := key.ECDH(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
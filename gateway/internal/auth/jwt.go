@@ -1,49 +1,147 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// Chỉ chấp nhận thuật toán bất đối xứng, để token không thể ký bằng public key
+var validMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+
 type JWTConfig struct {
-	Secret     string
+	Issuer   string
+	Audience string
+	// Leeway là độ lệch đồng hồ cho phép khi kiểm tra exp, nbf và iat.
+	Leeway     time.Duration
 	Expiration time.Duration
+	// Keys xác thực token. Signer chỉ cần khi gateway tự cấp token.
+	Keys   *KeySet
+	Signer *Signer
+}
+
+// Signer ký token bằng private key có kid, để có thể xoay key: key mới được
+// thêm vào JWKS trước khi dùng để ký, key cũ được gỡ khi token cuối cùng hết hạn.
+type Signer struct {
+	KeyID  string
+	Key    crypto.Signer
+	Method jwt.SigningMethod
+}
+
+// NewSigner đọc private key RSA hoặc EC P-256 dạng PEM.
+func NewSigner(keyID string, pemData []byte) (*Signer, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pemData); err == nil {
+		return &Signer{KeyID: keyID, Key: key, Method: jwt.SigningMethodRS256}, nil
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(pemData)
+	if err != nil {
+		return nil, errors.New("jwt: the private key is neither rsa nor ec")
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("jwt: only ec keys on P-256 are supported")
+	}
+	return &Signer{KeyID: keyID, Key: key, Method: jwt.SigningMethodES256}, nil
+}
+
+func LoadSigner(keyID string, path string) (*Signer, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(keyID, pemData)
 }
 
 func GenerateToken(user *User, config JWTConfig) (string, error) {
+	token, _, err := generateToken(user, config)
+	return token, err
+}
+
+// generateToken trả về token cùng jti của nó, để có thể thu hồi token sau này.
+func generateToken(user *User, config JWTConfig) (string, string, error) {
+	if config.Signer == nil {
+		return "", "", errors.New("jwt: no signing key configured")
+	}
+
+	jti, err := randomString(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
 	claims := JWTClaims{
 		UserID:   user.ID,
 		Username: user.Username,
 		TenantID: user.TenantID,
 		Roles:    user.Roles,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(config.Expiration).Unix(),
-			IssuedAt:  time.Now().Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    config.Issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{config.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.Expiration)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.Secret))
+	token := jwt.NewWithClaims(config.Signer.Method, claims)
+	token.Header["kid"] = config.Signer.KeyID
+	signed, err := token.SignedString(config.Signer.Key)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
 }
 
 func ValidateToken(tokenString string, config JWTConfig) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(config.Secret), nil
-	})
+	// Claims được kiểm tra bên dưới, vì parser của jwt/v4 không hỗ trợ leeway
+	parser := jwt.NewParser(jwt.WithValidMethods(validMethods), jwt.WithoutClaimsValidation())
 
-	if err != nil {
-		return nil, err
+	claims := &JWTClaims{}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" || config.Keys == nil {
+			return nil, ErrUnknownKey
+		}
+		return config.Keys.Key(kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	now := time.Now()
+	switch {
+	case !claims.VerifyExpiresAt(now.Add(-config.Leeway), true):
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Token expired")
+	case !claims.VerifyNotBefore(now.Add(config.Leeway), false),
+		!claims.VerifyIssuedAt(now.Add(config.Leeway), false):
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Token not valid yet")
+	case !claims.VerifyIssuer(config.Issuer, true):
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token issuer")
+	case !claims.VerifyAudience(config.Audience, true):
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token audience")
 	}
 
-	return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+	return claims, nil
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// RefreshToken là chuỗi ngẫu nhiên, không phải JWT. Gateway chỉ lưu hash của nó.
 type RefreshToken struct {
 	Token     string
 	ExpiresAt time.Time
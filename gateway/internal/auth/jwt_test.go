@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwks encodes the public keys of signers as a JWKS.
+func jwks(t *testing.T, signers ...*Signer) []byte {
+	t.Helper()
+
+	keys := make([]jsonWebKey, 0, len(signers))
+	for _, s := range signers {
+		switch pub := s.Key.Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: s.KeyID,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			keys = append(keys, jsonWebKey{
+				Kty: "EC",
+				Kid: s.KeyID,
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+				Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func ecSigner(t *testing.T, kid string) *Signer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	s, err := NewSigner(kid, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	return s
+}
+
+func rsaSigner(t *testing.T, kid string) *Signer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der := x509.MarshalPKCS1PrivateKey(key)
+
+	s, err := NewSigner(kid, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	return s
+}
+
+func jwksFile(t *testing.T, signers ...*Signer) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks(t, signers...), 0o600))
+	return path
+}
+
+// sign makes a token with the claims of the test user, changed by edit.
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, edit func(*jwt.RegisteredClaims)) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := JWTClaims{
+		UserID:   "user-1",
+		TenantID: "tenant-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://auth.example.com",
+			Audience:  jwt.ClaimStrings{"micro-template"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if edit != nil {
+		edit(&claims.RegisteredClaims)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestValidateToken(t *testing.T) {
+	es := ecSigner(t, "es-1")
+	rs := rsaSigner(t, "rs-1")
+
+	keys, err := NewKeySet(JWKSConfig{File: jwksFile(t, es, rs)})
+	require.NoError(t, err)
+
+	config := JWTConfig{
+		Issuer:     "https://auth.example.com",
+		Audience:   "micro-template",
+		Leeway:     30 * time.Second,
+		Expiration: time.Minute,
+		Keys:       keys,
+		Signer:     es,
+	}
+
+	t.Run("issued by the gateway", func(t *testing.T) {
+		token, err := GenerateToken(&User{ID: "user-1", TenantID: "tenant-1", Roles: []string{"admin"}}, config)
+		require.NoError(t, err)
+
+		claims, err := ValidateToken(token, config)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.UserID)
+		assert.Equal(t, []string{"admin"}, claims.Roles)
+		assert.NotEmpty(t, claims.ID)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rs256", sign(t, jwt.SigningMethodRS256, "rs-1", rs.Key, nil), true},
+		{"es256", sign(t, jwt.SigningMethodES256, "es-1", es.Key, nil), true},
+		{"expired within leeway", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+		}), true},
+		{"issued within leeway", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(10 * time.Second))
+		}), true},
+		{"expired", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), false},
+		{"without expiry", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = nil
+		}), false},
+		{"not valid yet", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}), false},
+		{"another issuer", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.Issuer = "https://evil.example.com"
+		}), false},
+		{"another audience", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.Audience = jwt.ClaimStrings{"another-service"}
+		}), false},
+		{"without audience", sign(t, jwt.SigningMethodES256, "es-1", es.Key, func(c *jwt.RegisteredClaims) {
+			c.Audience = nil
+		}), false},
+		{"without kid", sign(t, jwt.SigningMethodES256, "", es.Key, nil), false},
+		{"kid of another key", sign(t, jwt.SigningMethodES256, "rs-1", es.Key, nil), false},
+		{"unknown kid", sign(t, jwt.SigningMethodES256, "es-2", ecSigner(t, "es-2").Key, nil), false},
+		{"hs256", sign(t, jwt.SigningMethodHS256, "es-1", []byte("shared-secret"), nil), false},
+		{"malformed", "not.a.token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateToken(tt.token, config)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	current := ecSigner(t, "2026-01")
+	next := ecSigner(t, "2026-02")
+
+	var mu sync.Mutex
+	published := jwks(t, current)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		_, _ = w.Write(published)
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Nanosecond})
+	require.NoError(t, err)
+	config := JWTConfig{Issuer: "https://auth.example.com", Audience: "micro-template", Keys: keys}
+
+	_, err = ValidateToken(sign(t, jwt.SigningMethodES256, current.KeyID, current.Key, nil), config)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetches)
+
+	// Tokens of the next key are valid once it is published.
+	token := sign(t, jwt.SigningMethodES256, next.KeyID, next.Key, nil)
+	_, err = ValidateToken(token, config)
+	assert.Error(t, err)
+	assert.Equal(t, 2, fetches)
+
+	mu.Lock()
+	published = jwks(t, current, next)
+	mu.Unlock()
+
+	_, err = ValidateToken(token, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fetches)
+
+	// Retired keys are dropped on the next refresh.
+	mu.Lock()
+	published = jwks(t, next)
+	mu.Unlock()
+	require.NoError(t, keys.Refresh())
+
+	_, err = ValidateToken(sign(t, jwt.SigningMethodES256, current.KeyID, current.Key, nil), config)
+	assert.Error(t, err)
+}
+
+func TestKeySet_MinRefreshInterval(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write([]byte(`{"keys": []}`))
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Hour})
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err := keys.Key("unknown")
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	}
+	assert.Equal(t, 1, fetches)
+}
+
+func TestNewKeySet(t *testing.T) {
+	_, err := NewKeySet(JWKSConfig{})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(JWKSConfig{URL: "http://localhost", File: "jwks.json"})
+	assert.Error(t, err)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	_, err = NewKeySet(JWKSConfig{URL: server.URL})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(JWKSConfig{File: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
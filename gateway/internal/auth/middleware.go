@@ -1,23 +1,33 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"log"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// Revocations là deny-list jti của các access token đã bị thu hồi.
+type Revocations interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
 type AuthMiddleware struct {
 	jwtConfig     JWTConfig
 	apiConfig     APIKeyConfig
 	permissionMgr *PermissionManager
+	revocations   Revocations
 	excludedPaths []string
 }
 
-func NewAuthMiddleware(jwtCfg JWTConfig, apiCfg APIKeyConfig, permMgr *PermissionManager, excluded []string) *AuthMiddleware {
+func NewAuthMiddleware(jwtCfg JWTConfig, apiCfg APIKeyConfig, permMgr *PermissionManager, revocations Revocations, excluded []string) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtConfig:     jwtCfg,
 		apiConfig:     apiCfg,
 		permissionMgr: permMgr,
+		revocations:   revocations,
 		excludedPaths: excluded,
 	}
 }
@@ -33,6 +43,10 @@ func (m *AuthMiddleware) Handle(c *fiber.Ctx) error {
 	// Xác thực JWT
 	claims, err := m.validateAuth(c)
 	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiberErr
+		}
 		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 	}
 
@@ -76,7 +90,24 @@ func (m *AuthMiddleware) validateAuth(c *fiber.Ctx) (*JWTClaims, error) {
 	}
 
 	token := strings.TrimPrefix(auth, "Bearer ")
-	return ValidateToken(token, m.jwtConfig)
+	claims, err := ValidateToken(token, m.jwtConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kiểm tra deny-list, ví dụ token của family bị thu hồi do refresh token bị dùng lại
+	if m.revocations != nil && claims.ID != "" {
+		revoked, err := m.revocations.IsRevoked(c.UserContext(), claims.ID)
+		if err != nil {
+			log.Printf("auth: checking revoked tokens: %v", err)
+			return nil, fiber.ErrServiceUnavailable
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
 }
 
 func (m *AuthMiddleware) parseRequest(c *fiber.Ctx) (string, string, string) {
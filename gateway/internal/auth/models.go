@@ -7,40 +7,40 @@ import (
 )
 
 type Tenant struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string    `json:"id" yaml:"id"`
+	Name        string    `json:"name" yaml:"name"`
+	Description string    `json:"description" yaml:"description"`
+	Status      string    `json:"status" yaml:"status"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at,omitempty"`
 }
 
 type ResourceAction struct {
-	Name        string   `json:"name"`
-	Scopes      []string `json:"scopes"`
-	Description string   `json:"description"`
+	Name        string   `json:"name" yaml:"name"`
+	Scopes      []string `json:"scopes" yaml:"scopes"`
+	Description string   `json:"description" yaml:"description"`
 }
 
 type Resource struct {
-	Name        string                    `json:"name"`
-	Actions     map[string]ResourceAction `json:"actions"`
-	Prefixes    []string                  `json:"prefixes"`
-	Description string                    `json:"description"`
+	Name        string                    `json:"name" yaml:"name"`
+	Actions     map[string]ResourceAction `json:"actions" yaml:"actions"`
+	Prefixes    []string                  `json:"prefixes" yaml:"prefixes"`
+	Description string                    `json:"description" yaml:"description"`
 }
 
 type Permission struct {
-	ResourceName string   `json:"resource_name"`
-	ActionName   string   `json:"action_name"`
-	Scopes       []string `json:"scopes"`
+	ResourceName string   `json:"resource_name" yaml:"resource_name"`
+	ActionName   string   `json:"action_name" yaml:"action_name"`
+	Scopes       []string `json:"scopes" yaml:"scopes"`
 }
 
 type Role struct {
-	ID          string       `json:"id"`
-	Name        string       `json:"name"`
-	TenantID    string       `json:"tenant_id"`
-	Permissions []Permission `json:"permissions"`
-	CreatedAt   time.Time    `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
+	ID          string       `json:"id" yaml:"id"`
+	Name        string       `json:"name" yaml:"name"`
+	TenantID    string       `json:"tenant_id" yaml:"tenant_id"`
+	Permissions []Permission `json:"permissions" yaml:"permissions"`
+	CreatedAt   time.Time    `json:"created_at" yaml:"created_at,omitempty"`
+	UpdatedAt   time.Time    `json:"updated_at" yaml:"updated_at,omitempty"`
 }
 
 type User struct {
@@ -57,5 +57,5 @@ type JWTClaims struct {
 	Username string   `json:"username"`
 	TenantID string   `json:"tenant_id"`
 	Roles    []string `json:"roles"`
-	jwt.StandardClaims
+	jwt.RegisteredClaims
 }
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,16 +22,20 @@ type permissionCacheEntry struct {
 
 type PermissionManager struct {
 	resources map[string]Resource
-	roles     map[string]Role
-	tenants   map[string]Tenant
-	cacheTTL  time.Duration
-	cache     map[permissionCacheKey]permissionCacheEntry
-	mu        sync.RWMutex
+	// services là các resource được đăng ký bằng RegisterService, được giữ
+	// lại khi nạp lại policy
+	services map[string]Resource
+	roles    map[string]Role
+	tenants  map[string]Tenant
+	cacheTTL time.Duration
+	cache    map[permissionCacheKey]permissionCacheEntry
+	mu       sync.RWMutex
 }
 
 func NewPermissionManager(cacheTTL time.Duration) *PermissionManager {
 	return &PermissionManager{
 		resources: make(map[string]Resource),
+		services:  make(map[string]Resource),
 		roles:     make(map[string]Role),
 		tenants:   make(map[string]Tenant),
 		cacheTTL:  cacheTTL,
@@ -37,6 +43,40 @@ func NewPermissionManager(cacheTTL time.Duration) *PermissionManager {
 	}
 }
 
+// Reload thay toàn bộ tenant, role và resource bằng policy. Các service đã
+// đăng ký vẫn được giữ, trừ khi policy định nghĩa resource cùng tên.
+func (pm *PermissionManager) Reload(policy *Policy) {
+	resources := make(map[string]Resource, len(pm.services)+len(policy.Resources))
+	roles := make(map[string]Role, len(policy.Roles))
+	tenants := make(map[string]Tenant, len(policy.Tenants))
+	for _, resource := range policy.Resources {
+		resources[resource.Name] = resource
+	}
+	for _, role := range policy.Roles {
+		roles[role.ID] = role
+	}
+	for _, tenant := range policy.Tenants {
+		tenants[tenant.ID] = tenant
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for name, resource := range pm.services {
+		if _, exists := resources[name]; !exists {
+			resources[name] = resource
+		}
+	}
+	pm.resources = resources
+	pm.roles = roles
+	pm.tenants = tenants
+	pm.invalidate()
+}
+
+// invalidate xóa cache sau khi policy thay đổi. Caller cần giữ pm.mu.
+func (pm *PermissionManager) invalidate() {
+	clear(pm.cache)
+}
+
 func (pm *PermissionManager) HasPermission(claims *JWTClaims, resourceName string, actionName string, targetID string) bool {
 	pm.mu.RLock()
 
@@ -116,10 +156,8 @@ func (pm *PermissionManager) checkPermission(claims *JWTClaims, resourceName str
 	return false
 }
 
+// isInSameTenant được gọi từ checkPermission, khi caller đã giữ pm.mu.
 func (pm *PermissionManager) isInSameTenant(targetID string, tenantID string) bool {
-	pm.mu.RLock()
-	defer pm.mu.RUnlock()
-
 	// Kiểm tra trong danh sách users
 	for _, role := range pm.roles {
 		if role.ID == targetID {
@@ -149,10 +187,24 @@ func (pm *PermissionManager) GetRole(roleID string) (Role, bool) {
 	return role, exists
 }
 
+// Roles trả về các role, sắp xếp theo ID.
+func (pm *PermissionManager) Roles() []Role {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	roles := make([]Role, 0, len(pm.roles))
+	for _, role := range pm.roles {
+		roles = append(roles, role)
+	}
+	slices.SortFunc(roles, func(a, b Role) int { return strings.Compare(a.ID, b.ID) })
+	return roles
+}
+
 func (pm *PermissionManager) AddRole(role Role) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 	pm.roles[role.ID] = role
+	pm.invalidate()
 }
 
 func (pm *PermissionManager) UpdateRole(role Role) bool {
@@ -162,6 +214,7 @@ func (pm *PermissionManager) UpdateRole(role Role) bool {
 		return false
 	}
 	pm.roles[role.ID] = role
+	pm.invalidate()
 	return true
 }
 
@@ -172,6 +225,7 @@ func (pm *PermissionManager) DeleteRole(roleID string) bool {
 		return false
 	}
 	delete(pm.roles, roleID)
+	pm.invalidate()
 	return true
 }
 
@@ -183,10 +237,24 @@ func (pm *PermissionManager) GetTenant(tenantID string) (Tenant, bool) {
 	return tenant, exists
 }
 
+// Tenants trả về các tenant, sắp xếp theo ID.
+func (pm *PermissionManager) Tenants() []Tenant {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	tenants := make([]Tenant, 0, len(pm.tenants))
+	for _, tenant := range pm.tenants {
+		tenants = append(tenants, tenant)
+	}
+	slices.SortFunc(tenants, func(a, b Tenant) int { return strings.Compare(a.ID, b.ID) })
+	return tenants
+}
+
 func (pm *PermissionManager) AddTenant(tenant Tenant) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 	pm.tenants[tenant.ID] = tenant
+	pm.invalidate()
 }
 
 func (pm *PermissionManager) UpdateTenant(tenant Tenant) bool {
@@ -196,6 +264,7 @@ func (pm *PermissionManager) UpdateTenant(tenant Tenant) bool {
 		return false
 	}
 	pm.tenants[tenant.ID] = tenant
+	pm.invalidate()
 	return true
 }
 
@@ -206,6 +275,7 @@ func (pm *PermissionManager) DeleteTenant(tenantID string) bool {
 		return false
 	}
 	delete(pm.tenants, tenantID)
+	pm.invalidate()
 	return true
 }
 
@@ -215,7 +285,9 @@ func (pm *PermissionManager) RegisterService(serviceName string, prefixes []stri
 	defer pm.mu.Unlock()
 
 	resource := NewResourceFromService(serviceName, prefixes)
+	pm.services[serviceName] = resource
 	pm.resources[serviceName] = resource
+	pm.invalidate()
 }
 
 // Thêm method để lấy danh sách services đã đăng ký
@@ -235,5 +307,7 @@ func (pm *PermissionManager) UnregisterService(serviceName string) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
+	delete(pm.services, serviceName)
 	delete(pm.resources, serviceName)
+	pm.invalidate()
 }
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrPolicyNotFound = errors.New("policy: not found")
+
+// Policy là toàn bộ tenant, role và resource mà PermissionManager dùng.
+type Policy struct {
+	Tenants   []Tenant   `json:"tenants" yaml:"tenants"`
+	Roles     []Role     `json:"roles" yaml:"roles"`
+	Resources []Resource `json:"resources" yaml:"resources"`
+}
+
+// PolicyStore lưu policy. Version thay đổi mỗi khi policy thay đổi, kể cả
+// khi do một gateway khác ghi, để Watch biết khi nào cần nạp lại.
+type PolicyStore interface {
+	Load(ctx context.Context) (*Policy, error)
+	Version(ctx context.Context) (string, error)
+	SaveTenant(ctx context.Context, tenant Tenant) error
+	DeleteTenant(ctx context.Context, tenantID string) error
+	SaveRole(ctx context.Context, role Role) error
+	DeleteRole(ctx context.Context, roleID string) error
+}
+
+// Load nạp policy từ store.
+func (pm *PermissionManager) Load(ctx context.Context, store PolicyStore) error {
+	policy, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	pm.Reload(policy)
+	return nil
+}
+
+// Watch nạp lại policy mỗi khi version của store thay đổi, tới khi ctx bị hủy.
+// Lần kiểm tra đầu tiên luôn nạp lại, để không bỏ lỡ thay đổi xảy ra giữa
+// Load và Watch.
+func (pm *PermissionManager) Watch(ctx context.Context, store PolicyStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		version, err := store.Version(ctx)
+		if err != nil {
+			log.Printf("policy: reading version: %v", err)
+			continue
+		}
+		if version == last {
+			continue
+		}
+
+		if err := pm.Load(ctx, store); err != nil {
+			log.Printf("policy: reloading: %v", err)
+			continue
+		}
+		last = version
+	}
+}
+
+// yamlPolicyStore giữ policy trong một file YAML. Chỉ phù hợp khi có một
+// gateway ghi vào file; nhiều gateway nên dùng Postgres.
+type yamlPolicyStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewYAMLPolicyStore(path string) *yamlPolicyStore {
+	return &yamlPolicyStore{path: path}
+}
+
+func (s *yamlPolicyStore) Load(ctx context.Context) (*Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Version là thời điểm sửa và kích thước của file, nên sửa file bằng tay
+// cũng làm policy được nạp lại.
+func (s *yamlPolicyStore) Version(ctx context.Context) (string, error) {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+func (s *yamlPolicyStore) SaveTenant(ctx context.Context, tenant Tenant) error {
+	return s.update(func(policy *Policy) error {
+		for i := range policy.Tenants {
+			if policy.Tenants[i].ID == tenant.ID {
+				policy.Tenants[i] = tenant
+				return nil
+			}
+		}
+		policy.Tenants = append(policy.Tenants, tenant)
+		return nil
+	})
+}
+
+func (s *yamlPolicyStore) DeleteTenant(ctx context.Context, tenantID string) error {
+	return s.update(func(policy *Policy) error {
+		for i := range policy.Tenants {
+			if policy.Tenants[i].ID == tenantID {
+				policy.Tenants = append(policy.Tenants[:i], policy.Tenants[i+1:]...)
+				return nil
+			}
+		}
+		return ErrPolicyNotFound
+	})
+}
+
+func (s *yamlPolicyStore) SaveRole(ctx context.Context, role Role) error {
+	return s.update(func(policy *Policy) error {
+		for i := range policy.Roles {
+			if policy.Roles[i].ID == role.ID {
+				policy.Roles[i] = role
+				return nil
+			}
+		}
+		policy.Roles = append(policy.Roles, role)
+		return nil
+	})
+}
+
+func (s *yamlPolicyStore) DeleteRole(ctx context.Context, roleID string) error {
+	return s.update(func(policy *Policy) error {
+		for i := range policy.Roles {
+			if policy.Roles[i].ID == roleID {
+				policy.Roles = append(policy.Roles[:i], policy.Roles[i+1:]...)
+				return nil
+			}
+		}
+		return ErrPolicyNotFound
+	})
+}
+
+func (s *yamlPolicyStore) read() (*Policy, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("policy: %s: %w", s.path, err)
+	}
+	return &policy, nil
+}
+
+// update sửa policy và ghi lại file. File được ghi ra file tạm rồi đổi tên,
+// để Watch không đọc phải file ghi dở.
+func (s *yamlPolicyStore) update(fn func(*Policy) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(policy); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if info, err := os.Stat(s.path); err == nil {
+		if err := tmp.Chmod(info.Mode()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
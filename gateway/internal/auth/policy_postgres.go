@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// postgresPolicyStore giữ policy trong các bảng gateway_tenants, gateway_roles
+// và gateway_resources, để nhiều gateway dùng chung.
+type postgresPolicyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPolicyStore(db *sql.DB) *postgresPolicyStore {
+	return &postgresPolicyStore{db: db}
+}
+
+func (s *postgresPolicyStore) Load(ctx context.Context) (*Policy, error) {
+	var policy Policy
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, description, status, created_at, updated_at
+		FROM gateway_tenants ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policy.Tenants = append(policy.Tenants, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT id, name, tenant_id, permissions, created_at, updated_at
+		FROM gateway_roles ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Role
+		var permissions []byte
+		if err := rows.Scan(&r.ID, &r.Name, &r.TenantID, &permissions, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(permissions, &r.Permissions); err != nil {
+			return nil, err
+		}
+		policy.Roles = append(policy.Roles, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT name, description, prefixes, actions
+		FROM gateway_resources ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Resource
+		var prefixes, actions []byte
+		if err := rows.Scan(&r.Name, &r.Description, &prefixes, &actions); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(prefixes, &r.Prefixes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(actions, &r.Actions); err != nil {
+			return nil, err
+		}
+		policy.Resources = append(policy.Resources, r)
+	}
+
+	return &policy, rows.Err()
+}
+
+// Version đổi khi có dòng được thêm, sửa hoặc xóa ở bất kỳ bảng nào.
+func (s *postgresPolicyStore) Version(ctx context.Context) (string, error) {
+	var version string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT concat_ws(':',
+			(SELECT count(*) FROM gateway_tenants), (SELECT max(updated_at) FROM gateway_tenants),
+			(SELECT count(*) FROM gateway_roles), (SELECT max(updated_at) FROM gateway_roles),
+			(SELECT count(*) FROM gateway_resources), (SELECT max(updated_at) FROM gateway_resources)
+		)
+	`).Scan(&version)
+	return version, err
+}
+
+func (s *postgresPolicyStore) SaveTenant(ctx context.Context, tenant Tenant) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO gateway_tenants (id, name, description, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE
+		SET name = excluded.name,
+			description = excluded.description,
+			status = excluded.status,
+			updated_at = excluded.updated_at
+	`, tenant.ID, tenant.Name, tenant.Description, tenant.Status, tenant.CreatedAt, tenant.UpdatedAt)
+	return err
+}
+
+func (s *postgresPolicyStore) DeleteTenant(ctx context.Context, tenantID string) error {
+	return deleted(s.db.ExecContext(ctx, `DELETE FROM gateway_tenants WHERE id = $1`, tenantID))
+}
+
+func (s *postgresPolicyStore) SaveRole(ctx context.Context, role Role) error {
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO gateway_roles (id, name, tenant_id, permissions, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE
+		SET name = excluded.name,
+			tenant_id = excluded.tenant_id,
+			permissions = excluded.permissions,
+			updated_at = excluded.updated_at
+	`, role.ID, role.Name, role.TenantID, permissions, role.CreatedAt, role.UpdatedAt)
+	return err
+}
+
+func (s *postgresPolicyStore) DeleteRole(ctx context.Context, roleID string) error {
+	return deleted(s.db.ExecContext(ctx, `DELETE FROM gateway_roles WHERE id = $1`, roleID))
+}
+
+func deleted(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+tenants:
+  - id: tenant-1
+    name: Tenant 1
+    status: active
+roles:
+  - id: reader
+    name: Reader
+    tenant_id: tenant-1
+    permissions:
+      - resource_name: orders
+        action_name: read
+        scopes: [all]
+`
+
+var reader = &JWTClaims{UserID: "user-1", TenantID: "tenant-1", Roles: []string{"reader"}}
+
+func TestYAMLPolicyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	store := NewYAMLPolicyStore(path)
+	ctx := context.Background()
+
+	// A missing file is an empty policy.
+	policy, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, policy.Roles)
+
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	policy, err = store.Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, policy.Roles, 1)
+	assert.Equal(t, "tenant-1", policy.Roles[0].TenantID)
+	assert.Equal(t, []Permission{{ResourceName: "orders", ActionName: "read", Scopes: []string{"all"}}}, policy.Roles[0].Permissions)
+
+	require.NoError(t, store.SaveTenant(ctx, Tenant{ID: "tenant-2", Name: "Tenant 2", Status: "active"}))
+	require.NoError(t, store.SaveRole(ctx, Role{ID: "reader", Name: "Renamed", TenantID: "tenant-1"}))
+	require.NoError(t, store.DeleteTenant(ctx, "tenant-1"))
+	assert.ErrorIs(t, store.DeleteRole(ctx, "writer"), ErrPolicyNotFound)
+
+	policy, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Tenant{{ID: "tenant-2", Name: "Tenant 2", Status: "active"}}, policy.Tenants)
+	assert.Equal(t, []Role{{ID: "reader", Name: "Renamed", TenantID: "tenant-1", Permissions: []Permission{}}}, policy.Roles)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestPermissionManager_Reload(t *testing.T) {
+	pm := NewPermissionManager(time.Hour)
+	pm.RegisterService("orders", []string{"/api/orders"})
+	assert.False(t, pm.HasPermission(reader, "orders", "read", ""))
+
+	// Policies replace cached results, and keep registered services.
+	pm.Reload(&Policy{
+		Tenants: []Tenant{{ID: "tenant-1", Status: "active"}},
+		Roles: []Role{{ID: "reader", TenantID: "tenant-1", Permissions: []Permission{
+			{ResourceName: "orders", ActionName: "read", Scopes: []string{"all"}},
+		}}},
+	})
+	assert.True(t, pm.HasPermission(reader, "orders", "read", ""))
+	assert.False(t, pm.HasPermission(reader, "orders", "delete", ""))
+
+	pm.UpdateTenant(Tenant{ID: "tenant-1", Status: "suspended"})
+	assert.False(t, pm.HasPermission(reader, "orders", "read", ""))
+
+	pm.AddTenant(Tenant{ID: "tenant-1", Status: "active"})
+	assert.True(t, pm.HasPermission(reader, "orders", "read", ""))
+
+	pm.DeleteRole("reader")
+	assert.False(t, pm.HasPermission(reader, "orders", "read", ""))
+}
+
+func TestPermissionManager_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	store := NewYAMLPolicyStore(path)
+
+	pm := NewPermissionManager(time.Hour)
+	pm.RegisterService("orders", []string{"/api/orders"})
+	require.NoError(t, pm.Load(context.Background(), store))
+	assert.True(t, pm.HasPermission(reader, "orders", "read", ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pm.Watch(ctx, store, 10*time.Millisecond)
+
+	// The file is edited by hand, without the reader role.
+	require.NoError(t, os.WriteFile(path, []byte("tenants:\n  - id: tenant-1\n    status: active\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return !pm.HasPermission(reader, "orders", "read", "")
+	}, time.Second, 10*time.Millisecond)
+}
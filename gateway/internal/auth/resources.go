@@ -72,6 +72,12 @@ func NewResourceFromService(serviceName string, prefixes []string) Resource {
 			Scopes:      []string{"tenant"},
 			Description: "Broadcast notification to all users",
 		}
+	case "roles":
+		actions["permissions"] = ResourceAction{
+			Name:        "permissions",
+			Scopes:      []string{"all", "tenant"},
+			Description: "Manage role permissions",
+		}
 	case "logging-service":
 		actions["export"] = ResourceAction{
 			Name:        "export",
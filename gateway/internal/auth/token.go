@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrInvalidCredentials  = fiber.NewError(fiber.StatusUnauthorized, "Invalid username or password")
+	ErrInvalidRefreshToken = fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	ErrTokenRevoked        = fiber.NewError(fiber.StatusUnauthorized, "Token revoked")
+	ErrUserNotFound        = fiber.NewError(fiber.StatusUnauthorized, "User not found")
+)
+
+// RefreshSession là thông tin được lưu cùng mỗi refresh token. Mọi refresh
+// token sinh ra từ một lần đăng nhập thuộc cùng một family. User là user lúc
+// cấp token, khi refresh chỉ ID của nó được dùng.
+type RefreshSession struct {
+	Family string `json:"family"`
+	User   User   `json:"user"`
+}
+
+// TokenStore lưu refresh token theo hash, và deny-list jti của access token.
+type TokenStore interface {
+	// SaveRefresh lưu refresh token, cùng jti của access token được cấp kèm.
+	SaveRefresh(ctx context.Context, hash string, session RefreshSession, accessJTI string, ttl time.Duration) error
+	// UseRefresh đánh dấu refresh token đã dùng. Trả về nil nếu không có
+	// token, và reused là true nếu token đã được dùng trước đó.
+	UseRefresh(ctx context.Context, hash string) (session *RefreshSession, reused bool, err error)
+	// RevokeFamily thu hồi mọi refresh token của family, và mọi access token
+	// đã cấp cho family tới khi chúng hết hạn.
+	RevokeFamily(ctx context.Context, family string, ttl time.Duration, accessTTL time.Duration) error
+	FamilyRevoked(ctx context.Context, family string) (bool, error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Authenticator kiểm tra username và password khi cấp token.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username string, password string) (*User, error)
+}
+
+// Users đọc lại user khi refresh, để role hoặc tenant đã đổi, hay user đã bị
+// xóa, có hiệu lực mà không cần chờ refresh token hết hạn.
+type Users interface {
+	// User trả về ErrUserNotFound nếu user không còn đăng nhập được.
+	User(ctx context.Context, userID string) (*User, error)
+}
+
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+// TokenService cấp access token ngắn hạn và refresh token xoay vòng: mỗi
+// refresh token chỉ dùng được một lần. Một refresh token bị dùng lại nghĩa là
+// nó đã bị lộ, nên cả family bị thu hồi.
+type TokenService struct {
+	config     JWTConfig
+	store      TokenStore
+	users      Users
+	refreshTTL time.Duration
+}
+
+func NewTokenService(config JWTConfig, store TokenStore, users Users, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		config:     config,
+		store:      store,
+		users:      users,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue cấp token cho một lần đăng nhập mới, với family mới.
+func (s *TokenService) Issue(ctx context.Context, user *User) (*TokenPair, error) {
+	family, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, RefreshSession{Family: family, User: *user})
+}
+
+// Refresh đổi refresh token lấy cặp token mới của cùng family, với thông tin
+// hiện tại của user.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	session, reused, err := s.store.UseRefresh(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if reused {
+		return nil, s.revoke(ctx, session.Family)
+	}
+
+	revoked, err := s.store.FamilyRevoked(ctx, session.Family)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.User(ctx, session.User.ID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, s.revoke(ctx, session.Family)
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.User = *user
+
+	return s.issue(ctx, *session)
+}
+
+// revoke thu hồi family, và trả về ErrInvalidRefreshToken nếu thành công.
+func (s *TokenService) revoke(ctx context.Context, family string) error {
+	if err := s.store.RevokeFamily(ctx, family, s.refreshTTL, s.config.Expiration+s.config.Leeway); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+// IsRevoked cho biết access token có jti đã bị thu hồi chưa.
+func (s *TokenService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.store.IsRevoked(ctx, jti)
+}
+
+func (s *TokenService) issue(ctx context.Context, session RefreshSession) (*TokenPair, error) {
+	accessToken, jti, err := generateToken(&session.User, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	refresh := RefreshToken{
+		Token:     secret,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+
+	if err := s.store.SaveRefresh(ctx, hashToken(refresh.Token), session, jti, s.refreshTTL); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.config.Expiration.Seconds()),
+		RefreshToken:     refresh.Token,
+		RefreshExpiresIn: int(s.refreshTTL.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type TokenHandler struct {
+	service       *TokenService
+	authenticator Authenticator
+}
+
+func NewTokenHandler(service *TokenService, authenticator Authenticator) *TokenHandler {
+	return &TokenHandler{
+		service:       service,
+		authenticator: authenticator,
+	}
+}
+
+// RegisterRoutes thêm /auth/token và /auth/refresh. Cả hai cần nằm trong
+// excluded_paths của AuthMiddleware.
+func (h *TokenHandler) RegisterRoutes(router fiber.Router) {
+	router.Post("/auth/token", h.Token)
+	router.Post("/auth/refresh", h.Refresh)
+}
+
+func (h *TokenHandler) Token(c *fiber.Ctx) error {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Username == "" || req.Password == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "username and password are required")
+	}
+
+	user, err := h.authenticator.Authenticate(c.UserContext(), req.Username, req.Password)
+	if err != nil {
+		return publicError(err)
+	}
+
+	pair, err := h.service.Issue(c.UserContext(), user)
+	if err != nil {
+		return publicError(err)
+	}
+	return tokenResponse(c, pair)
+}
+
+func (h *TokenHandler) Refresh(c *fiber.Ctx) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "refresh_token is required")
+	}
+
+	pair, err := h.service.Refresh(c.UserContext(), req.RefreshToken)
+	if err != nil {
+		return publicError(err)
+	}
+	return tokenResponse(c, pair)
+}
+
+func tokenResponse(c *fiber.Ctx, pair *TokenPair) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(pair)
+}
+
+// publicError ẩn lỗi nội bộ, ví dụ lỗi Redis, khỏi response.
+func publicError(err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr
+	}
+	log.Printf("auth: %v", err)
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRefresh struct {
+	session RefreshSession
+	used    bool
+}
+
+// memoryTokenStore keeps tokens in memory, without expiry.
+type memoryTokenStore struct {
+	mu       sync.Mutex
+	refresh  map[string]*memoryRefresh
+	jtis     map[string][]string
+	families map[string]bool
+	revoked  map[string]bool
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{
+		refresh:  map[string]*memoryRefresh{},
+		jtis:     map[string][]string{},
+		families: map[string]bool{},
+		revoked:  map[string]bool{},
+	}
+}
+
+func (s *memoryTokenStore) SaveRefresh(ctx context.Context, hash string, session RefreshSession, accessJTI string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh[hash] = &memoryRefresh{session: session}
+	s.jtis[session.Family] = append(s.jtis[session.Family], accessJTI)
+	return nil
+}
+
+func (s *memoryTokenStore) UseRefresh(ctx context.Context, hash string) (*RefreshSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.refresh[hash]
+	if !ok {
+		return nil, false, nil
+	}
+	reused := rt.used
+	rt.used = true
+	session := rt.session
+	return &session, reused, nil
+}
+
+func (s *memoryTokenStore) RevokeFamily(ctx context.Context, family string, ttl time.Duration, accessTTL time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.families[family] = true
+	for _, jti := range s.jtis[family] {
+		s.revoked[jti] = true
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) FamilyRevoked(ctx context.Context, family string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.families[family], nil
+}
+
+func (s *memoryTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked[jti], nil
+}
+
+type authenticatorFunc func(ctx context.Context, username string, password string) (*User, error)
+
+func (f authenticatorFunc) Authenticate(ctx context.Context, username string, password string) (*User, error) {
+	return f(ctx, username, password)
+}
+
+type usersFunc func(ctx context.Context, userID string) (*User, error)
+
+func (f usersFunc) User(ctx context.Context, userID string) (*User, error) {
+	return f(ctx, userID)
+}
+
+func TestTokenHandler(t *testing.T) {
+	signer := ecSigner(t, "es-1")
+	keys, err := NewKeySet(JWKSConfig{File: jwksFile(t, signer)})
+	require.NoError(t, err)
+	config := JWTConfig{
+		Issuer:     "https://auth.example.com",
+		Audience:   "micro-template",
+		Expiration: 5 * time.Minute,
+		Keys:       keys,
+		Signer:     signer,
+	}
+
+	users := map[string]User{
+		"user-1": {ID: "user-1", Username: "jane", TenantID: "tenant-1", Roles: []string{"reader"}},
+	}
+	authenticator := authenticatorFunc(func(ctx context.Context, username string, password string) (*User, error) {
+		if username != "jane" || password != "highEntropyPassword" {
+			return nil, ErrInvalidCredentials
+		}
+		user := users["user-1"]
+		return &user, nil
+	})
+	lookup := usersFunc(func(ctx context.Context, userID string) (*User, error) {
+		user, ok := users[userID]
+		if !ok {
+			return nil, ErrUserNotFound
+		}
+		return &user, nil
+	})
+
+	pm := NewPermissionManager(time.Minute)
+	pm.RegisterService("orders", []string{"/api/orders"})
+	pm.AddTenant(Tenant{ID: "tenant-1", Status: "active"})
+	pm.AddRole(Role{ID: "reader", TenantID: "tenant-1", Permissions: []Permission{
+		{ResourceName: "orders", ActionName: "read", Scopes: []string{"all"}},
+	}})
+
+	store := newMemoryTokenStore()
+	service := NewTokenService(config, store, lookup, 24*time.Hour)
+	middleware := NewAuthMiddleware(config, APIKeyConfig{Header: "X-API-Key"}, pm, service, []string{"/auth/"})
+
+	app := fiber.New()
+	app.Use(middleware.Handle)
+	NewTokenHandler(service, authenticator).RegisterRoutes(app)
+	app.Get("/api/orders", func(c *fiber.Ctx) error {
+		return c.SendString("orders")
+	})
+
+	post := func(path string, body string) (*http.Response, *TokenPair) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req)
+		require.NoError(t, err)
+
+		var pair TokenPair
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&pair))
+		}
+		return res, &pair
+	}
+	orders := func(accessToken string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res.StatusCode
+	}
+	refresh := func(refreshToken string) (*http.Response, *TokenPair) {
+		return post("/auth/refresh", `{"refresh_token": "`+refreshToken+`"}`)
+	}
+
+	res, _ := post("/auth/token", `{"username": "jane", "password": "wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = post("/auth/token", `{"username": "jane"}`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, first := post("/auth/token", `{"username": "jane", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+	assert.Equal(t, "Bearer", first.TokenType)
+	assert.Equal(t, 300, first.ExpiresIn)
+	assert.Equal(t, 86400, first.RefreshExpiresIn)
+	assert.Equal(t, http.StatusOK, orders(first.AccessToken))
+
+	// Each refresh token is exchanged once, for a new pair.
+	res, second := refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, first.AccessToken, second.AccessToken)
+	assert.Equal(t, http.StatusOK, orders(second.AccessToken))
+
+	res, _ = refresh("unknown")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Another login is another family, which reuse elsewhere does not affect.
+	_, other := post("/auth/token", `{"username": "jane", "password": "highEntropyPassword"}`)
+
+	// Reusing the first refresh token revokes the whole family.
+	res, _ = refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = refresh(second.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, orders(first.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, orders(second.AccessToken))
+
+	assert.Equal(t, http.StatusOK, orders(other.AccessToken))
+	res, other = refresh(other.RefreshToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// A refresh uses the current roles of the user, not those at login.
+	users["user-1"] = User{ID: "user-1", Username: "jane", TenantID: "tenant-1"}
+	res, other = refresh(other.RefreshToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	claims, err := ValidateToken(other.AccessToken, config)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Roles)
+
+	// A user who is gone can not refresh, and their tokens are revoked.
+	delete(users, "user-1")
+	res, _ = refresh(other.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, orders(other.AccessToken))
+}
+
+func TestUpstreamAuthenticator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if r.URL.Path != "/users/user-1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": "user-1", "username": "jane", "tenant_id": "tenant-1", "roles": ["writer"]}`))
+			return
+		}
+
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["password"] {
+		case "highEntropyPassword":
+			_, _ = w.Write([]byte(`{"id": "user-1", "username": "jane", "tenant_id": "tenant-1", "roles": ["reader"]}`))
+		case "unavailable":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	a := NewUpstreamAuthenticator(server.URL+"/authenticate", server.URL+"/users")
+
+	user, err := a.Authenticate(context.Background(), "jane", "highEntropyPassword")
+	require.NoError(t, err)
+	assert.Equal(t, &User{ID: "user-1", Username: "jane", TenantID: "tenant-1", Roles: []string{"reader"}}, user)
+
+	_, err = a.Authenticate(context.Background(), "jane", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(context.Background(), "jane", "unavailable")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+
+	user, err = a.User(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"writer"}, user.Roles)
+
+	_, err = a.User(context.Background(), "user-2")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshPrefix    = "gateway:refresh:"
+	familyPrefix     = "gateway:refresh_family:"
+	revokedJTIPrefix = "gateway:revoked_jti:"
+)
+
+// useRefresh đọc session và đánh dấu token đã dùng trong một bước, để hai
+// request đồng thời không thể cùng đổi một refresh token.
+var useRefresh = redis.NewScript(`
+local session = redis.call("HGET", KEYS[1], "session")
+if not session then
+	return false
+end
+local fresh = redis.call("HSETNX", KEYS[1], "used", "1")
+return {session, fresh}
+`)
+
+type redisTokenStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisTokenStore giữ mỗi refresh token trong một hash hết hạn cùng token.
+// Token đã dùng được giữ lại tới khi hết hạn, để phát hiện dùng lại.
+func NewRedisTokenStore(client redis.UniversalClient) *redisTokenStore {
+	return &redisTokenStore{client: client}
+}
+
+func (s *redisTokenStore) SaveRefresh(ctx context.Context, hash string, session RefreshSession, accessJTI string, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	jtis := familyPrefix + session.Family + ":jtis"
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, refreshPrefix+hash, "session", data)
+		pipe.PExpire(ctx, refreshPrefix+hash, ttl)
+		pipe.SAdd(ctx, jtis, accessJTI)
+		pipe.PExpire(ctx, jtis, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *redisTokenStore) UseRefresh(ctx context.Context, hash string) (*RefreshSession, bool, error) {
+	res, err := useRefresh.Run(ctx, s.client, []string{refreshPrefix + hash}).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, _ := res[0].(string)
+	fresh, _ := res[1].(int64)
+
+	var session RefreshSession
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, false, err
+	}
+
+	return &session, fresh == 0, nil
+}
+
+func (s *redisTokenStore) RevokeFamily(ctx context.Context, family string, ttl time.Duration, accessTTL time.Duration) error {
+	jtis, err := s.client.SMembers(ctx, familyPrefix+family+":jtis").Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, familyPrefix+family+":revoked", 1, ttl)
+		for _, jti := range jtis {
+			pipe.Set(ctx, revokedJTIPrefix+jti, 1, accessTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisTokenStore) FamilyRevoked(ctx context.Context, family string) (bool, error) {
+	n, err := s.client.Exists(ctx, familyPrefix+family+":revoked").Result()
+	return n == 1, err
+}
+
+func (s *redisTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := s.client.Exists(ctx, revokedJTIPrefix+jti).Result()
+	return n == 1, err
+}
@@ -1,9 +1,15 @@
 package gateway
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
+	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/spf13/viper"
+
+	"github.com/forum_golang/micro-template/gateway/internal/auth"
 )
 
 type Config struct {
@@ -13,19 +19,45 @@ type Config struct {
 	ConsulAddress   string                   `mapstructure:"consul_address"`
 	ServiceRegistry map[string]ServiceConfig `mapstructure:"services"`
 	Auth            struct {
-		Enabled       bool     `mapstructure:"enabled"`
-		JWTSecret     string   `mapstructure:"jwt_secret"`
-		JWTExpiration int      `mapstructure:"jwt_expiration"`
-		APIKeyHeader  string   `mapstructure:"api_key_header"`
-		APIKeySecret  string   `mapstructure:"api_key_secret"`
-		ExcludedPaths []string `mapstructure:"excluded_paths"`
-		Permissions   struct {
-			CacheTTL int `mapstructure:"cache_ttl"`
+		Enabled       bool      `mapstructure:"enabled"`
+		JWT           JWTConfig `mapstructure:"jwt"`
+		JWTExpiration int       `mapstructure:"jwt_expiration"`
+		APIKeyHeader  string    `mapstructure:"api_key_header"`
+		APIKeySecret  string    `mapstructure:"api_key_secret"`
+		ExcludedPaths []string  `mapstructure:"excluded_paths"`
+		Token         struct {
+			AuthenticateURL   string `mapstructure:"authenticate_url"`
+			UsersURL          string `mapstructure:"users_url"`
+			RefreshExpiration int    `mapstructure:"refresh_expiration"`
+		} `mapstructure:"token"`
+		Permissions struct {
+			CacheTTL        int    `mapstructure:"cache_ttl"`
+			RefreshInterval int    `mapstructure:"refresh_interval"`
+			Store           string `mapstructure:"store"`
+			File            string `mapstructure:"file"`
+			DatabaseURL     string `mapstructure:"database_url"`
 		} `mapstructure:"permissions"`
 	} `mapstructure:"auth"`
+	Redis struct {
+		Address  string `mapstructure:"address"`
+		Password string `mapstructure:"password"`
+		DB       int    `mapstructure:"db"`
+	} `mapstructure:"redis"`
 	CORS CORSConfig `mapstructure:"cors"`
 }
 
+// JWTConfig cấu hình xác thực token bằng JWKS. Thời gian tính bằng giây.
+type JWTConfig struct {
+	Issuer         string `mapstructure:"issuer"`
+	Audience       string `mapstructure:"audience"`
+	Leeway         int    `mapstructure:"leeway"`
+	JWKSURL        string `mapstructure:"jwks_url"`
+	JWKSFile       string `mapstructure:"jwks_file"`
+	JWKSMaxAge     int    `mapstructure:"jwks_max_age"`
+	KeyID          string `mapstructure:"key_id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+}
+
 type ServiceConfig struct {
 	Name         string   `mapstructure:"name"`
 	URLs         []string `mapstructure:"urls"`
@@ -33,12 +65,36 @@ type ServiceConfig struct {
 	AuthRequired bool     `mapstructure:"auth_required"`
 }
 
+// envKeys là các biến môi trường trong env.example, và key trong config.yaml
+// mà chúng thay. Thời gian tính bằng giây như trong config.yaml.
+var envKeys = map[string]string{
+	"auth.enabled":                  "AUTH_ENABLED",
+	"auth.jwt.issuer":               "JWT_ISSUER",
+	"auth.jwt.audience":             "JWT_AUDIENCE",
+	"auth.jwt.leeway":               "JWT_LEEWAY",
+	"auth.jwt.jwks_url":             "JWT_JWKS_URL",
+	"auth.jwt.jwks_file":            "JWT_JWKS_FILE",
+	"auth.jwt.key_id":               "JWT_KEY_ID",
+	"auth.jwt.private_key_file":     "JWT_PRIVATE_KEY_FILE",
+	"auth.jwt_expiration":           "JWT_EXPIRATION",
+	"auth.token.refresh_expiration": "JWT_REFRESH_EXPIRATION",
+	"auth.token.authenticate_url":   "AUTH_AUTHENTICATE_URL",
+	"auth.token.users_url":          "AUTH_USERS_URL",
+	"auth.api_key_header":           "API_KEY_HEADER",
+	"auth.api_key_secret":           "API_KEY_SECRET",
+}
+
 func NewConfig() *Config {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("/app/config")
 	viper.AddConfigPath("config")
 	viper.AutomaticEnv()
+	for key, env := range envKeys {
+		if err := viper.BindEnv(key, env); err != nil {
+			log.Fatalf("Error binding %s: %s", env, err)
+		}
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file: %s", err)
@@ -51,3 +107,54 @@ func NewConfig() *Config {
 
 	return &config
 }
+
+// NewJWTConfig tạo cấu hình JWT cho auth. Private key chỉ cần khi gateway tự
+// cấp token.
+func NewJWTConfig(config *Config) (auth.JWTConfig, error) {
+	c := config.Auth.JWT
+
+	keys, err := auth.NewKeySet(auth.JWKSConfig{
+		URL:    c.JWKSURL,
+		File:   c.JWKSFile,
+		MaxAge: time.Duration(c.JWKSMaxAge) * time.Second,
+	})
+	if err != nil {
+		return auth.JWTConfig{}, err
+	}
+
+	jwtConfig := auth.JWTConfig{
+		Issuer:     c.Issuer,
+		Audience:   c.Audience,
+		Leeway:     time.Duration(c.Leeway) * time.Second,
+		Expiration: time.Duration(config.Auth.JWTExpiration) * time.Second,
+		Keys:       keys,
+	}
+
+	if c.PrivateKeyFile != "" {
+		signer, err := auth.LoadSigner(c.KeyID, c.PrivateKeyFile)
+		if err != nil {
+			return auth.JWTConfig{}, err
+		}
+		jwtConfig.Signer = signer
+	}
+
+	return jwtConfig, nil
+}
+
+// NewPolicyStore tạo store của tenant, role và resource: "yaml" hoặc "postgres".
+func NewPolicyStore(config *Config) (auth.PolicyStore, error) {
+	c := config.Auth.Permissions
+
+	switch c.Store {
+	case "", "yaml":
+		return auth.NewYAMLPolicyStore(c.File), nil
+	case "postgres":
+		db, err := sql.Open("pgx", c.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewPostgresPolicyStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown permission store %q", c.Store)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -9,13 +10,28 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/forum_golang/micro-template/gateway/internal/auth"
+	"github.com/forum_golang/micro-template/gateway/internal/gateway"
 	"github.com/forum_golang/micro-template/internal/pkg/discovery"
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	app := fiber.New()
+	config := gateway.NewConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Xác thực JWT/API Key và phân quyền cho các route bên dưới
+	if config.Auth.Enabled {
+		if err := setupAuth(ctx, app, config); err != nil {
+			log.Fatalf("Failed to set up auth: %v", err)
+		}
+	}
 
 	// Initialize service discovery
 	sd, err := discovery.NewServiceDiscovery()
@@ -98,6 +114,7 @@ func main() {
 	go func() {
 		<-sigChan
 		log.Println("Shutting down...")
+		cancel()
 		if err := sd.DeregisterService("api-gateway-80"); err != nil {
 			log.Printf("Error deregistering service: %v", err)
 		}
@@ -111,3 +128,57 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// setupAuth thêm AuthMiddleware trước mọi route, /auth/token, /auth/refresh
+// với refresh token trong Redis, và API /admin quản lý policy. Mỗi service
+// trong config là một resource, phân quyền theo prefix của nó. Policy được
+// nạp lại khi store thay đổi, tới khi ctx bị hủy.
+func setupAuth(ctx context.Context, app *fiber.App, config *gateway.Config) error {
+	jwtConfig, err := gateway.NewJWTConfig(config)
+	if err != nil {
+		return err
+	}
+
+	pm := auth.NewPermissionManager(time.Duration(config.Auth.Permissions.CacheTTL) * time.Second)
+	for _, service := range config.ServiceRegistry {
+		pm.RegisterService(service.Name, service.Prefixes)
+	}
+
+	store, err := gateway.NewPolicyStore(config)
+	if err != nil {
+		return err
+	}
+	if err := pm.Load(ctx, store); err != nil {
+		return err
+	}
+	if interval := config.Auth.Permissions.RefreshInterval; interval > 0 {
+		go pm.Watch(ctx, store, time.Duration(interval)*time.Second)
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Address,
+		Password: config.Redis.Password,
+		DB:       config.Redis.DB,
+	})
+	authenticator := auth.NewUpstreamAuthenticator(config.Auth.Token.AuthenticateURL, config.Auth.Token.UsersURL)
+	tokens := auth.NewTokenService(
+		jwtConfig,
+		auth.NewRedisTokenStore(client),
+		authenticator,
+		time.Duration(config.Auth.Token.RefreshExpiration)*time.Second,
+	)
+
+	middleware := auth.NewAuthMiddleware(jwtConfig, auth.APIKeyConfig{
+		Header: config.Auth.APIKeyHeader,
+		Secret: config.Auth.APIKeySecret,
+	}, pm, tokens, config.Auth.ExcludedPaths)
+	app.Use(middleware.Handle)
+	auth.NewAdminHandler(pm, store).RegisterRoutes(app)
+
+	// Chỉ gateway có private key mới cấp token
+	if jwtConfig.Signer != nil {
+		auth.NewTokenHandler(tokens, authenticator).RegisterRoutes(app)
+	}
+
+	return nil
+}
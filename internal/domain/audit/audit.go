@@ -0,0 +1,117 @@
+// Package audit records who changed which rows, with their values before and
+// after the change, and lists those records.
+//
+// Writes are recorded for requests that went through middleware.Audit, which
+// puts a middleware.Event with the actor and the request into the context.
+// Writes made outside a request, such as by a command, are not recorded.
+package audit
+
+import (
+	"context"
+	"log/slog"
+
+	"micro/internal/middleware"
+)
+
+// Recorder reads the values of rows and records the changes made to them.
+type Recorder interface {
+	// Snapshot reads the rows of table with ids as JSON objects, keyed by
+	// their ID. Rows that do not exist are left out.
+	Snapshot(ctx context.Context, table string, ids []uint64) (map[uint64]string, error)
+	Record(ctx context.Context, events ...middleware.Event) error
+}
+
+// Change is a write to rows of a table that is being recorded.
+type Change struct {
+	rec    Recorder
+	table  string
+	action middleware.Action
+	event  middleware.Event
+	ids    []uint64
+	old    map[uint64]string
+}
+
+// Begin starts recording a write to the rows of table with ids, reading their
+// values before the write. Rows that are yet to be created have no ids. It
+// returns nil, on which Commit does nothing, outside a request.
+func Begin(ctx context.Context, rec Recorder, table string, action middleware.Action, ids ...uint64) *Change {
+	event, ok := ctx.Value(middleware.KeyAuditID).(middleware.Event)
+	if !ok {
+		return nil
+	}
+
+	c := &Change{rec: rec, table: table, action: action, event: event, ids: ids}
+	if len(ids) > 0 {
+		old, err := rec.Snapshot(ctx, table, ids)
+		if err != nil {
+			slog.ErrorContext(ctx, "reading audited rows", "table", table, "error", err)
+		}
+		c.old = old
+	}
+
+	return c
+}
+
+// Commit records the write after it succeeded, reading the values of the rows
+// with ids, or with the ids given to Begin when there are none. Rows whose
+// values did not change are not recorded.
+//
+// The write is done by then, so a failure to record it is only logged.
+func (c *Change) Commit(ctx context.Context, ids ...uint64) {
+	if c == nil {
+		return
+	}
+	if len(ids) == 0 {
+		ids = c.ids
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	values, err := c.rec.Snapshot(ctx, c.table, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "reading audited rows", "table", c.table, "error", err)
+	}
+
+	events := make([]middleware.Event, 0, len(ids))
+	for _, id := range ids {
+		if c.old[id] == values[id] {
+			continue
+		}
+
+		event := c.event
+		event.Table = c.table
+		event.TableRowID = id
+		event.Action = c.action
+		event.OldValues = c.old[id]
+		event.NewValues = values[id]
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	if err = c.rec.Record(context.WithoutCancel(ctx), events...); err != nil {
+		slog.ErrorContext(ctx, "recording audit events", "table", c.table, "error", err)
+	}
+}
+
+// Log records an event on a row of table that is not a write to it, such as
+// an account being locked, with values describing it. Like Commit, it does
+// nothing outside a request, and a failure to record is only logged.
+func Log(ctx context.Context, rec Recorder, table string, action middleware.Action, id uint64, oldValues, newValues string) {
+	event, ok := ctx.Value(middleware.KeyAuditID).(middleware.Event)
+	if !ok {
+		return
+	}
+
+	event.Table = table
+	event.TableRowID = id
+	event.Action = action
+	event.OldValues = oldValues
+	event.NewValues = newValues
+
+	if err := rec.Record(context.WithoutCancel(ctx), event); err != nil {
+		slog.ErrorContext(ctx, "recording audit events", "table", table, "error", err)
+	}
+}
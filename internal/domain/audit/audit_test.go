@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/middleware"
+)
+
+func TestChange(t *testing.T) {
+	request := middleware.Event{ActorID: 7, HTTPMethod: "PUT", URL: "/api/v1/book/1", IPAddress: "127.0.0.1", UserAgent: "test"}
+	ctx := context.WithValue(context.Background(), middleware.KeyAuditID, request)
+
+	rows := map[uint64]string{
+		1: `{"id": 1, "title": "old"}`,
+		2: `{"id": 2, "title": "same"}`,
+	}
+	var recorded []middleware.Event
+	rec := &RepoMock{
+		SnapshotFunc: func(ctx context.Context, table string, ids []uint64) (map[uint64]string, error) {
+			assert.Equal(t, "books", table)
+			values := make(map[uint64]string)
+			for _, id := range ids {
+				if v, ok := rows[id]; ok {
+					values[id] = v
+				}
+			}
+			return values, nil
+		},
+		RecordFunc: func(ctx context.Context, events ...middleware.Event) error {
+			recorded = append(recorded, events...)
+			return nil
+		},
+	}
+
+	change := Begin(ctx, rec, "books", middleware.ActionUpdate, 1, 2, 3)
+	rows[1] = `{"id": 1, "title": "new"}`
+	change.Commit(ctx)
+
+	want := request
+	want.Table = "books"
+	want.TableRowID = 1
+	want.Action = middleware.ActionUpdate
+	want.OldValues = `{"id": 1, "title": "old"}`
+	want.NewValues = `{"id": 1, "title": "new"}`
+	assert.Equal(t, []middleware.Event{want}, recorded)
+
+	// Created rows only have new values.
+	recorded = nil
+	change = Begin(ctx, rec, "books", middleware.ActionCreate)
+	rows[3] = `{"id": 3, "title": "created"}`
+	change.Commit(ctx, 3)
+	assert.Len(t, recorded, 1)
+	assert.Equal(t, uint64(3), recorded[0].TableRowID)
+	assert.Empty(t, recorded[0].OldValues)
+	assert.Equal(t, `{"id": 3, "title": "created"}`, recorded[0].NewValues)
+
+	// Outside a request, nothing is read or recorded.
+	recorded = nil
+	change = Begin(context.Background(), &RepoMock{}, "books", middleware.ActionDelete, 1)
+	assert.Nil(t, change)
+	change.Commit(context.Background())
+	assert.Empty(t, recorded)
+}
+
+func TestLog(t *testing.T) {
+	request := middleware.Event{IPAddress: "127.0.0.1", HTTPMethod: "POST", URL: "/api/v1/login"}
+	ctx := context.WithValue(context.Background(), middleware.KeyAuditID, request)
+
+	var recorded []middleware.Event
+	rec := &RepoMock{
+		RecordFunc: func(ctx context.Context, events ...middleware.Event) error {
+			recorded = append(recorded, events...)
+			return nil
+		},
+	}
+
+	Log(ctx, rec, "users", middleware.ActionLock, 2, "", `{"failures": 10}`)
+
+	want := request
+	want.Table = "users"
+	want.TableRowID = 2
+	want.Action = middleware.ActionLock
+	want.NewValues = `{"failures": 10}`
+	assert.Equal(t, []middleware.Event{want}, recorded)
+
+	Log(context.Background(), rec, "users", middleware.ActionUnlock, 2, `{"failures": 10}`, "")
+	assert.Len(t, recorded, 1)
+}
+
+func TestEntID(t *testing.T) {
+	type entity struct {
+		ID   uint64
+		Name string
+	}
+
+	id, ok := entID(&entity{ID: 4})
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), id)
+
+	_, ok = entID(&struct{ ID string }{ID: "token"})
+	assert.False(t, ok)
+
+	_, ok = entID(1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"net/http"
+
+	"micro/internal/utility/respond"
+)
+
+type Handler struct {
+	repo Repo
+}
+
+func NewHandler(repo Repo) *Handler {
+	return &Handler{
+		repo: repo,
+	}
+}
+
+// List lists the audit log
+// @Summary List the audit log
+// @Description Lists the recorded changes to authors, books, the authors of books and users, newest first. Each record has the values of the row before and after the change, who made it, and the request that made it.
+// @Produce json
+// @Param table query string false "table name, such as books"
+// @Param row_id query int false "ID of the changed row"
+// @Param actor_id query int false "ID of the user who made the change"
+// @Param action query string false "create, update, delete or restore"
+// @Param from query string false "changes at or after this time, such as 2006-01-02 or 2006-01-02T15:04:05Z"
+// @Param to query string false "changes before this time"
+// @Param page query string false "page number"
+// @Param limit query string false "limit of result"
+// @Success 200 {object} respond.Standard
+// @Failure 400 {object} respond.Problem
+// @Failure 401 {object} respond.Problem
+// @Failure 403 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/audit [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	f, err := Filters(r.URL.Query())
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	logs, total, err := h.repo.List(r.Context(), f)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	list := Resources(logs)
+
+	respond.Json(w, http.StatusOK, respond.Standard{
+		Data: list,
+		Meta: respond.Paginate(w, r, f.Base, len(list), total, ""),
+	})
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_List(t *testing.T) {
+	created := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		query  string
+		filter *Filter
+		logs   []*Schema
+		err    error
+		status int
+	}{
+		{
+			name:   "filtered",
+			query:  "?table=books&row_id=1&actor_id=7&action=update&from=2026-10-01",
+			filter: &Filter{Table: "books", RowID: 1, ActorID: 7, Action: "update", From: &from},
+			logs: []*Schema{{
+				ID: 2, ActorID: 7, Table: "books", TableRowID: 1, Action: "update",
+				OldValues: `{"title": "old"}`, NewValues: `{"title": "new"}`, CreatedAt: created,
+			}},
+			status: http.StatusOK,
+		},
+		{name: "invalid row id", query: "?row_id=-1", status: http.StatusBadRequest},
+		{name: "invalid time", query: "?to=yesterday", status: http.StatusBadRequest},
+		{name: "lower layer error", err: errors.New("lower layer error"), filter: &Filter{}, status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &RepoMock{
+				ListFunc: func(ctx context.Context, f *Filter) ([]*Schema, int, error) {
+					tt.filter.Base = f.Base
+					assert.Equal(t, tt.filter, f)
+					return tt.logs, len(tt.logs), tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			h := NewHandler(repo)
+			router.Get("/api/v1/audit", h.List)
+
+			rr := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+tt.query, nil)
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data []map[string]any `json:"data"`
+			}
+			assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+			assert.Len(t, got.Data, 1)
+			assert.Equal(t, "books", got.Data[0]["table"])
+			assert.Equal(t, map[string]any{"title": "old"}, got.Data[0]["old_values"])
+			assert.Equal(t, map[string]any{"title": "new"}, got.Data[0]["new_values"])
+		})
+	}
+}
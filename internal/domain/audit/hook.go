@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+
+	"entgo.io/ent"
+
+	"micro/ent/gen/author"
+	"micro/ent/gen/book"
+	"micro/ent/gen/user"
+	"micro/internal/middleware"
+)
+
+// entTables are the tables of the ent types whose writes are recorded.
+// Sessions are left out, as their rows hold the session tokens.
+var entTables = map[string]string{
+	"Author": author.Table,
+	"Book":   book.Table,
+	"User":   user.Table,
+}
+
+// Hook records the writes made through ent.
+func Hook(rec Recorder) ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			table, ok := entTables[m.Type()]
+			if !ok {
+				return next.Mutate(ctx, m)
+			}
+
+			var (
+				action = middleware.ActionUpdate
+				ids    []uint64
+			)
+			switch {
+			case m.Op().Is(ent.OpCreate):
+				action = middleware.ActionCreate
+			case m.Op().Is(ent.OpDelete | ent.OpDeleteOne):
+				action = middleware.ActionDelete
+			case m.FieldCleared("deleted_at"):
+				action = middleware.ActionRestore
+			default:
+				// Authors and books are deleted by moving them to the
+				// trash.
+				if _, trashed := m.Field("deleted_at"); trashed {
+					action = middleware.ActionDelete
+				}
+			}
+
+			// Updates and deletes may match many rows, which are found
+			// before they change.
+			if action != middleware.ActionCreate {
+				if _, inRequest := ctx.Value(middleware.KeyAuditID).(middleware.Event); inRequest {
+					mutation, ok := m.(interface {
+						IDs(ctx context.Context) ([]uint64, error)
+					})
+					if ok {
+						found, err := mutation.IDs(ctx)
+						if err != nil {
+							return nil, err
+						}
+						ids = found
+					}
+				}
+			}
+
+			change := Begin(ctx, rec, table, action, ids...)
+
+			val, err := next.Mutate(ctx, m)
+			if err != nil {
+				return val, err
+			}
+
+			if action == middleware.ActionCreate {
+				if id, ok := entID(val); ok {
+					ids = []uint64{id}
+				}
+			}
+			change.Commit(ctx, ids...)
+
+			return val, nil
+		})
+	}
+}
+
+// entID is the ID of an entity that ent created.
+func entID(val ent.Value) (uint64, bool) {
+	v := reflect.Indirect(reflect.ValueOf(val))
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	id := v.FieldByName("ID")
+	if !id.IsValid() || id.Kind() != reflect.Uint64 {
+		return 0, false
+	}
+	return id.Uint(), true
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/filter"
+)
+
+// Schema is a row of audit_logs. The values are JSON objects, and are empty
+// for rows that did not exist before or after the change.
+type Schema struct {
+	ID         uint64    `db:"id"`
+	ActorID    uint64    `db:"actor_id"`
+	Table      string    `db:"table_name"`
+	TableRowID uint64    `db:"table_row_id"`
+	Action     string    `db:"action"`
+	OldValues  string    `db:"old_values"`
+	NewValues  string    `db:"new_values"`
+	HTTPMethod string    `db:"http_method"`
+	URL        string    `db:"url"`
+	IPAddress  string    `db:"ip_address"`
+	UserAgent  string    `db:"user_agent"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Filter narrows down the audit log. Zero fields match any record. From is
+// inclusive and To is exclusive.
+type Filter struct {
+	Base    filter.Filter
+	Table   string
+	RowID   uint64
+	ActorID uint64
+	Action  string
+	From    *time.Time
+	To      *time.Time
+}
+
+func Filters(queries url.Values) (*Filter, error) {
+	f := &Filter{
+		Base:   *filter.New(queries),
+		Table:  queries.Get("table"),
+		Action: queries.Get("action"),
+	}
+
+	var err error
+	if f.RowID, err = parseID(queries, "row_id"); err != nil {
+		return nil, err
+	}
+	if f.ActorID, err = parseID(queries, "actor_id"); err != nil {
+		return nil, err
+	}
+	if f.From, err = parseTime(queries, "from"); err != nil {
+		return nil, err
+	}
+	if f.To, err = parseTime(queries, "to"); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func parseID(queries url.Values, name string) (uint64, error) {
+	value := queries.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, apperror.Validation(fmt.Sprintf("%s must be a positive integer", name))
+	}
+	return id, nil
+}
+
+// parseTime takes an RFC 3339 time, or a date that stands for its midnight
+// in UTC.
+func parseTime(queries url.Values, name string) (*time.Time, error) {
+	value := queries.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, apperror.Validation(fmt.Sprintf("%s must be a date such as 2006-01-02 or 2006-01-02T15:04:05Z", name))
+}
+
+type Res struct {
+	ID         uint64          `json:"id"`
+	ActorID    uint64          `json:"actor_id,omitempty"`
+	Table      string          `json:"table"`
+	TableRowID uint64          `json:"row_id"`
+	Action     string          `json:"action"`
+	OldValues  json.RawMessage `json:"old_values" swaggertype:"object"`
+	NewValues  json.RawMessage `json:"new_values" swaggertype:"object"`
+	HTTPMethod string          `json:"http_method"`
+	URL        string          `json:"url"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func Resource(s *Schema) *Res {
+	return &Res{
+		ID:         s.ID,
+		ActorID:    s.ActorID,
+		Table:      s.Table,
+		TableRowID: s.TableRowID,
+		Action:     s.Action,
+		OldValues:  rawJSON(s.OldValues),
+		NewValues:  rawJSON(s.NewValues),
+		HTTPMethod: s.HTTPMethod,
+		URL:        s.URL,
+		IPAddress:  s.IPAddress,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+func Resources(logs []*Schema) []*Res {
+	resources := make([]*Res, 0, len(logs))
+	for _, s := range logs {
+		resources = append(resources, Resource(s))
+	}
+	return resources
+}
+
+// rawJSON is null for values that are empty.
+func rawJSON(values string) json.RawMessage {
+	if values == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(values)
+}
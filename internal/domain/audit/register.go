@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterHTTPEndPoints mounts the audit log behind read.
+func RegisterHTTPEndPoints(router *chi.Mux, read func(http.Handler) http.Handler, repo Repo) *Handler {
+	h := NewHandler(repo)
+
+	router.Route("/api/v1/audit", func(router chi.Router) {
+		router.Use(read)
+		router.Get("/", h.List)
+	})
+
+	return h
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"micro/internal/middleware"
+)
+
+//go:generate mirip -rm -pkg audit -out repository_mock.go . Repo
+type Repo interface {
+	Recorder
+	List(ctx context.Context, f *Filter) ([]*Schema, int, error)
+}
+
+type repo struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) *repo {
+	return &repo{db: db}
+}
+
+// snapshots read the rows of each audited table as JSON. Secrets, and columns
+// that are derived from others, are left out. The authors of a book are
+// audited as a row of book_authors with the ID of the book.
+var snapshots = map[string]string{
+	"authors":      "SELECT id, (to_jsonb(t) - 'search')::text FROM authors t WHERE id = ANY($1::bigint[])",
+	"books":        "SELECT id, (to_jsonb(t) - 'search')::text FROM books t WHERE id = ANY($1::bigint[])",
+	"users":        "SELECT id, (to_jsonb(t) - 'password' - 'totp_secret' - 'totp_last_step')::text FROM users t WHERE id = ANY($1::bigint[])",
+	"book_authors": "SELECT book_id, jsonb_build_object('author_ids', jsonb_agg(author_id ORDER BY author_id))::text FROM book_authors WHERE book_id = ANY($1::bigint[]) GROUP BY book_id",
+}
+
+// Events are inserted from arrays, one element per event. Zero actors and
+// empty values are stored as NULL.
+//
+// Listing takes the Filter fields in order, each of which matches any record
+// when it is zero.
+const (
+	InsertAuditLogs = "INSERT INTO audit_logs (actor_id, table_name, table_row_id, action, old_values, new_values, http_method, url, ip_address, user_agent) " +
+		"SELECT NULLIF(actor_id, 0), table_name, table_row_id, action, NULLIF(old_values, '')::jsonb, NULLIF(new_values, '')::jsonb, http_method, url, ip_address, user_agent " +
+		"FROM unnest($1::bigint[], $2::text[], $3::bigint[], $4::text[], $5::text[], $6::text[], $7::text[], $8::text[], $9::text[], $10::text[]) " +
+		"AS e(actor_id, table_name, table_row_id, action, old_values, new_values, http_method, url, ip_address, user_agent)"
+
+	auditLogColumns = "id, COALESCE(actor_id, 0) AS actor_id, table_name, table_row_id, action, COALESCE(old_values::text, '') AS old_values, COALESCE(new_values::text, '') AS new_values, http_method, url, ip_address, user_agent, created_at"
+	auditLogWhere   = " WHERE ($1 = '' OR table_name = $1) AND ($2::bigint = 0 OR table_row_id = $2) AND ($3::bigint = 0 OR actor_id = $3) AND ($4 = '' OR action = $4) AND ($5::timestamptz IS NULL OR created_at >= $5) AND ($6::timestamptz IS NULL OR created_at < $6)"
+
+	CountAuditLogs          = "SELECT count(*) FROM audit_logs" + auditLogWhere
+	SelectAuditLogs         = "SELECT " + auditLogColumns + " FROM audit_logs" + auditLogWhere + " ORDER BY id DESC"
+	SelectAuditLogsPaginate = "SELECT " + auditLogColumns + " FROM audit_logs" + auditLogWhere + " ORDER BY id DESC LIMIT $7 OFFSET $8"
+)
+
+func (r *repo) Snapshot(ctx context.Context, table string, ids []uint64) (map[uint64]string, error) {
+	query, ok := snapshots[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s is not audited", table)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(int64s(ids)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[uint64]string, len(ids))
+	for rows.Next() {
+		var (
+			id    uint64
+			value string
+		)
+		if err = rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		values[id] = value
+	}
+
+	return values, rows.Err()
+}
+
+func (r *repo) Record(ctx context.Context, events ...middleware.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	n := len(events)
+	var (
+		actorIDs    = make([]int64, n)
+		tables      = make([]string, n)
+		rowIDs      = make([]int64, n)
+		actions     = make([]string, n)
+		olds        = make([]string, n)
+		news        = make([]string, n)
+		methods     = make([]string, n)
+		urls        = make([]string, n)
+		ipAddresses = make([]string, n)
+		userAgents  = make([]string, n)
+	)
+	for i, e := range events {
+		actorIDs[i] = int64(e.ActorID)
+		tables[i] = e.Table
+		rowIDs[i] = int64(e.TableRowID)
+		actions[i] = string(e.Action)
+		olds[i] = e.OldValues
+		news[i] = e.NewValues
+		methods[i] = e.HTTPMethod
+		urls[i] = e.URL
+		ipAddresses[i] = e.IPAddress
+		userAgents[i] = e.UserAgent
+	}
+
+	_, err := r.db.ExecContext(ctx, InsertAuditLogs,
+		pq.Array(actorIDs), pq.Array(tables), pq.Array(rowIDs), pq.Array(actions), pq.Array(olds),
+		pq.Array(news), pq.Array(methods), pq.Array(urls), pq.Array(ipAddresses), pq.Array(userAgents),
+	)
+	return err
+}
+
+func (r *repo) List(ctx context.Context, f *Filter) ([]*Schema, int, error) {
+	args := []any{f.Table, int64(f.RowID), int64(f.ActorID), f.Action, f.From, f.To}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, CountAuditLogs, args...); err != nil {
+		return nil, 0, err
+	}
+
+	logs := make([]*Schema, 0)
+	var err error
+	if f.Base.DisablePaging {
+		err = r.db.SelectContext(ctx, &logs, SelectAuditLogs, args...)
+	} else {
+		err = r.db.SelectContext(ctx, &logs, SelectAuditLogsPaginate, append(args, f.Base.Limit, f.Base.Offset)...)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+func int64s(ids []uint64) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
@@ -0,0 +1,213 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+
+	"micro/ent/gen"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/internal/utility/request"
+	"micro/internal/utility/respond"
+	"micro/internal/utility/signedtoken"
+	"micro/internal/utility/validate"
+	"micro/third_party/mailer"
+)
+
+// Tokens of one flow are not accepted by the other.
+const (
+	purposeVerify = "verify"
+	purposeReset  = "reset"
+)
+
+var ErrInvalidToken = apperror.Validation("the link is invalid or has expired")
+
+// Verify marks the email address of a user as verified with the token from
+// the link in their verification email.
+func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req VerifyRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	// A verification link is bound to the address it was sent to.
+	user, ok := h.userOfToken(w, r, purposeVerify, req.Token, func(u *gen.User) string { return u.Email })
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Verify(r.Context(), user.ID); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// ResendVerification sends another verification link to an unverified
+// address. It answers the same whether the address is registered or not. The
+// server sends emails in the background, so that the answer takes the same
+// time too.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	user, err := h.repo.UserByEmail(r.Context(), req.Email)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+	case err != nil:
+		respond.Fail(w, r, err)
+		return
+	case user.VerifiedAt == nil:
+		if err := h.sendVerification(r.Context(), user); err != nil {
+			slog.ErrorContext(r.Context(), "sending verification email", "user_id", user.ID, "error", err)
+		}
+	}
+
+	respond.Status(w, http.StatusAccepted)
+}
+
+// ForgotPassword sends a password reset link. It answers the same whether the
+// address is registered or not, and as quickly, as with ResendVerification.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	user, err := h.repo.UserByEmail(r.Context(), req.Email)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+	case err != nil:
+		respond.Fail(w, r, err)
+		return
+	default:
+		if err := h.sendReset(r.Context(), user); err != nil {
+			slog.ErrorContext(r.Context(), "sending password reset email", "user_id", user.ID, "error", err)
+		}
+	}
+
+	respond.Status(w, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with the token from the link in a
+// password reset email, and logs the user out everywhere.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	// A reset link is bound to the password it replaces, so it works once.
+	user, ok := h.userOfToken(w, r, purposeReset, req.Token, func(u *gen.User) string { return u.Password })
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := argon2id.CreateHash(req.Password, argon2id.DefaultParams)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	if err := h.repo.ResetPassword(r.Context(), user.ID, hashedPassword); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, req any) bool {
+	if err := request.DecodeJSON(w, r, req); err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return false
+	}
+
+	if errs := validate.Validate(h.validate, req); errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
+		return false
+	}
+
+	return true
+}
+
+// userOfToken finds the user a token was issued to, and checks the token
+// against what it is bound to.
+func (h *Handler) userOfToken(w http.ResponseWriter, r *http.Request, purpose, token string, binding func(*gen.User) string) (*gen.User, bool) {
+	id, err := signedtoken.ID(token)
+	if err != nil {
+		respond.Fail(w, r, ErrInvalidToken)
+		return nil, false
+	}
+
+	user, err := h.repo.User(r.Context(), id)
+	if errors.Is(err, ErrUserNotFound) {
+		respond.Fail(w, r, ErrInvalidToken)
+		return nil, false
+	}
+	if err != nil {
+		respond.Fail(w, r, err)
+		return nil, false
+	}
+
+	if err := h.tokens.Verify(purpose, token, binding(user)); err != nil {
+		respond.Fail(w, r, ErrInvalidToken)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (h *Handler) sendVerification(ctx context.Context, user *gen.User) error {
+	token := h.tokens.Sign(purposeVerify, user.ID, user.Email, h.account.VerifyExpiry)
+
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open the link below to verify your email address. It expires at %s.\n\n"+
+			"%s\n\n"+
+			"If you did not sign up, you can ignore this email.\n",
+			greeting(user), expiry(h.account.VerifyExpiry), h.link("/verify", token)),
+	})
+}
+
+func (h *Handler) sendReset(ctx context.Context, user *gen.User) error {
+	token := h.tokens.Sign(purposeReset, user.ID, user.Password, h.account.ResetExpiry)
+
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open the link below to choose a new password. It expires at %s and works once.\n\n"+
+			"%s\n\n"+
+			"If you did not ask to reset your password, you can ignore this email.\n",
+			greeting(user), expiry(h.account.ResetExpiry), h.link("/password/reset", token)),
+	})
+}
+
+func (h *Handler) link(path, token string) string {
+	return strings.TrimSuffix(h.account.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func greeting(user *gen.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return "there"
+}
+
+func expiry(ttl time.Duration) string {
+	return time.Now().Add(ttl).UTC().Format("2 Jan 2006 15:04 MST")
+}
@@ -0,0 +1,205 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/config"
+	"micro/ent/gen"
+	"micro/third_party/mailer"
+	"micro/third_party/validate"
+)
+
+type mailbox struct {
+	sent []mailer.Message
+}
+
+func (m *mailbox) Send(_ context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// token is the token in the link of the last email that was sent.
+func (m *mailbox) token(t *testing.T) string {
+	t.Helper()
+	if !assert.NotEmpty(t, m.sent) {
+		t.FailNow()
+	}
+	match := linkToken.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	if !assert.Len(t, match, 2) {
+		t.FailNow()
+	}
+	token, err := url.QueryUnescape(match[1])
+	assert.Nil(t, err)
+	return token
+}
+
+func newAccountRouter(repo Repo, mail mailer.Mailer, account config.Account) *chi.Mux {
+	h := NewHandler(nil, validate.New(), repo, mail, account, nil, nil, nil)
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/login", h.Login)
+	router.Post("/api/v1/register", h.Register)
+	router.Post("/api/v1/verify", h.Verify)
+	router.Post("/api/v1/verify/resend", h.ResendVerification)
+	router.Post("/api/v1/password/forgot", h.ForgotPassword)
+	router.Post("/api/v1/password/reset", h.ResetPassword)
+	return router
+}
+
+func post(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	ww := httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return ww
+}
+
+var testAccount = config.Account{
+	Secret:       "secret",
+	BaseURL:      "http://localhost:3000/",
+	VerifyExpiry: time.Hour,
+	ResetExpiry:  time.Hour,
+	TOTPIssuer:   "go8",
+}
+
+func TestHandler_Verify(t *testing.T) {
+	u := &gen.User{ID: 2, FirstName: "Jane", Email: "jane@example.com"}
+	var verified []uint64
+
+	repo := &RepoMock{
+		RegisterFunc: func(ctx context.Context, firstName, lastName, email, hashedPassword string) (*gen.User, error) {
+			return u, nil
+		},
+		UserFunc: func(ctx context.Context, userID uint64) (*gen.User, error) {
+			if userID != u.ID {
+				return nil, ErrUserNotFound
+			}
+			return u, nil
+		},
+		UserByEmailFunc: func(ctx context.Context, email string) (*gen.User, error) {
+			if email != u.Email {
+				return nil, ErrUserNotFound
+			}
+			return u, nil
+		},
+		VerifyFunc: func(ctx context.Context, userID uint64) error {
+			verified = append(verified, userID)
+			return nil
+		},
+	}
+	mail := &mailbox{}
+	router := newAccountRouter(repo, mail, testAccount)
+
+	ww := post(router, "/api/v1/register", `{"email": "jane@example.com", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusCreated, ww.Code)
+	assert.Len(t, mail.sent, 1)
+	assert.Equal(t, "jane@example.com", mail.sent[0].To)
+	assert.Contains(t, mail.sent[0].Body, "http://localhost:3000/verify?token=")
+	token := mail.token(t)
+
+	ww = post(router, "/api/v1/verify", `{"token": "`+token+`x"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	ww = post(router, "/api/v1/verify", `{"token": "3`+token[1:]+`"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	ww = post(router, "/api/v1/verify", `{}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	assert.Empty(t, verified)
+
+	ww = post(router, "/api/v1/verify", `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Equal(t, []uint64{2}, verified)
+
+	// Nothing is sent to unknown addresses, and the response does not tell.
+	ww = post(router, "/api/v1/verify/resend", `{"email": "john@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Len(t, mail.sent, 1)
+
+	ww = post(router, "/api/v1/verify/resend", `{"email": "jane@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Len(t, mail.sent, 2)
+
+	// A link stops working once the address changes.
+	u.Email = "jane@example.org"
+	ww = post(router, "/api/v1/verify", `{"token": "`+mail.token(t)+`"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	// Verified addresses get no more links.
+	now := time.Now()
+	u.VerifiedAt = &now
+	ww = post(router, "/api/v1/verify/resend", `{"email": "jane@example.org"}`)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Len(t, mail.sent, 2)
+}
+
+func TestHandler_ResetPassword(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword}
+
+	repo := &RepoMock{
+		UserFunc: func(ctx context.Context, userID uint64) (*gen.User, error) {
+			return u, nil
+		},
+		UserByEmailFunc: func(ctx context.Context, email string) (*gen.User, error) {
+			if email != u.Email {
+				return nil, ErrUserNotFound
+			}
+			return u, nil
+		},
+		ResetPasswordFunc: func(ctx context.Context, userID uint64, hashedPassword string) error {
+			assert.Equal(t, u.ID, userID)
+			u.Password = hashedPassword
+			return nil
+		},
+	}
+	mail := &mailbox{}
+	router := newAccountRouter(repo, mail, testAccount)
+
+	ww := post(router, "/api/v1/password/forgot", `{"email": "john@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Empty(t, mail.sent)
+
+	ww = post(router, "/api/v1/password/forgot", `{"email": "jane@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Contains(t, mail.sent[0].Body, "http://localhost:3000/password/reset?token=")
+	token := mail.token(t)
+
+	ww = post(router, "/api/v1/password/reset", `{"token": "`+token+`", "password": "weak"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	assert.Equal(t, hashedPassword, u.Password)
+
+	ww = post(router, "/api/v1/password/reset", `{"token": "`+token+`", "password": "anotherHighEntropyPassword"}`)
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	match, err := argon2id.ComparePasswordAndHash("anotherHighEntropyPassword", u.Password)
+	assert.Nil(t, err)
+	assert.True(t, match)
+
+	// The link works once.
+	ww = post(router, "/api/v1/password/reset", `{"token": "`+token+`", "password": "yetAnotherHighEntropyPassword"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+}
+
+func TestHandler_Login_RequireVerified(t *testing.T) {
+	repo := &RepoMock{
+		LoginFunc: func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+			return &gen.User{ID: 2, Email: req.Email}, true, nil
+		},
+	}
+	account := testAccount
+	account.RequireVerified = true
+	router := newAccountRouter(repo, &mailbox{}, account)
+
+	ww := post(router, "/api/v1/login", `{"email": "jane@example.com", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+}
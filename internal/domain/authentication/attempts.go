@@ -0,0 +1,184 @@
+package authentication
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Attempts counts failed logins by key. Failures older than the window are
+// forgotten.
+type Attempts interface {
+	// Failures is the number of failures for key within window, and the time
+	// of the last one.
+	Failures(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Attempt counts an attempt for key as a failure, before it is checked,
+	// in one atomic step. It returns the number of failures within window,
+	// this one included, and the time of the one before.
+	Attempt(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Undo takes back the last attempt for key, which did not fail.
+	Undo(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+const attemptsPrefix = "login_attempts:"
+
+type redisAttempts struct {
+	client redis.UniversalClient
+}
+
+// NewRedisAttempts keeps each count in a hash that expires with the window.
+func NewRedisAttempts(client redis.UniversalClient) *redisAttempts {
+	return &redisAttempts{client: client}
+}
+
+func (a *redisAttempts) Failures(ctx context.Context, key string, _ time.Duration) (int, time.Time, error) {
+	values, err := a.client.HGetAll(ctx, attemptsPrefix+key).Result()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(values) == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	failures, err := strconv.Atoi(values["failures"])
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	last, err := strconv.ParseInt(values["last"], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return failures, unixMilli(last), nil
+}
+
+// attemptScript keeps the time of the failure before the last, for Undo.
+var attemptScript = redis.NewScript(`
+local previous = redis.call('HGET', KEYS[1], 'last')
+local failures = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+redis.call('HSET', KEYS[1], 'last', ARGV[1], 'previous', previous or '0')
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return {failures, previous or '0'}
+`)
+
+var undoScript = redis.NewScript(`
+if redis.call('HINCRBY', KEYS[1], 'failures', -1) <= 0 then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+local previous = redis.call('HGET', KEYS[1], 'previous')
+if previous then
+	redis.call('HSET', KEYS[1], 'last', previous)
+end
+return 0
+`)
+
+func (a *redisAttempts) Attempt(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	values, err := attemptScript.Run(ctx, a.client, []string{attemptsPrefix + key}, time.Now().UnixMilli(), window.Milliseconds()).Slice()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	failures, ok := values[0].(int64)
+	if !ok {
+		return 0, time.Time{}, errors.New("unexpected failures from redis")
+	}
+	previous, ok := values[1].(string)
+	if !ok {
+		return 0, time.Time{}, errors.New("unexpected previous failure from redis")
+	}
+	last, err := strconv.ParseInt(previous, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return int(failures), unixMilli(last), nil
+}
+
+func (a *redisAttempts) Undo(ctx context.Context, key string) error {
+	return undoScript.Run(ctx, a.client, []string{attemptsPrefix + key}).Err()
+}
+
+func (a *redisAttempts) Reset(ctx context.Context, key string) error {
+	return a.client.Del(ctx, attemptsPrefix+key).Err()
+}
+
+type postgresAttempts struct {
+	db *sql.DB
+}
+
+// NewPostgresAttempts keeps the counts in the login_attempts table, for when
+// caching is disabled.
+func NewPostgresAttempts(db *sql.DB) *postgresAttempts {
+	return &postgresAttempts{db: db}
+}
+
+// A failure after the window starts the count again. Windows are passed in
+// seconds.
+const (
+	SelectLoginAttempts = "SELECT failures, last_failed_at FROM login_attempts WHERE key = $1 AND last_failed_at > now() - $2 * interval '1 second'"
+	UpsertLoginAttempts = "INSERT INTO login_attempts (key, failures, last_failed_at) VALUES ($1, 1, now()) " +
+		"ON CONFLICT (key) DO UPDATE SET failures = CASE WHEN login_attempts.last_failed_at > now() - $2 * interval '1 second' THEN login_attempts.failures + 1 ELSE 1 END, " +
+		"previous_failed_at = login_attempts.last_failed_at, last_failed_at = now() " +
+		"RETURNING failures, previous_failed_at"
+	UndoLoginAttempts          = "UPDATE login_attempts SET failures = greatest(failures - 1, 0), last_failed_at = coalesce(previous_failed_at, last_failed_at) WHERE key = $1"
+	DeleteLoginAttempts        = "DELETE FROM login_attempts WHERE key = $1"
+	DeleteExpiredLoginAttempts = "DELETE FROM login_attempts WHERE last_failed_at < now() - $1 * interval '1 second'"
+)
+
+func (a *postgresAttempts) Failures(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var (
+		failures int
+		last     time.Time
+	)
+	err := a.db.QueryRowContext(ctx, SelectLoginAttempts, key, window.Seconds()).Scan(&failures, &last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return failures, last, nil
+}
+
+func (a *postgresAttempts) Attempt(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	// Rows are not removed when they expire, so stale ones are cleared here.
+	if _, err := a.db.ExecContext(ctx, DeleteExpiredLoginAttempts, window.Seconds()); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var (
+		failures int
+		previous sql.NullTime
+	)
+	err := a.db.QueryRowContext(ctx, UpsertLoginAttempts, key, window.Seconds()).Scan(&failures, &previous)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return failures, previous.Time, nil
+}
+
+func (a *postgresAttempts) Undo(ctx context.Context, key string) error {
+	_, err := a.db.ExecContext(ctx, UndoLoginAttempts, key)
+	return err
+}
+
+func (a *postgresAttempts) Reset(ctx context.Context, key string) error {
+	_, err := a.db.ExecContext(ctx, DeleteLoginAttempts, key)
+	return err
+}
+
+// unixMilli is the zero time for 0, which Redis keeps when there is no time.
+func unixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
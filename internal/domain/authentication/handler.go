@@ -2,59 +2,81 @@ package authentication
 
 import (
 	"errors"
-	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/alexedwards/argon2id"
 	"github.com/gmhafiz/scs/v2"
+	"github.com/go-playground/validator/v10"
 
+	"micro/config"
+	"micro/ent/gen"
 	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/csrf"
+	"micro/internal/utility/message"
 	"micro/internal/utility/param"
 	"micro/internal/utility/request"
 	"micro/internal/utility/respond"
-)
-
-const (
-	minPasswordLength = 13
+	"micro/internal/utility/signedtoken"
+	"micro/internal/utility/validate"
+	"micro/third_party/mailer"
+	"micro/third_party/oauth"
 )
 
 var (
-	ErrEmailRequired  = errors.New("email is required")
-	ErrPasswordLength = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrInvalidCredentials = apperror.Unauthorized("email or password is incorrect")
+	ErrLoginRequired      = apperror.Unauthorized("you need to be logged in")
+	ErrNotVerified        = apperror.Forbidden("verify your email address before logging in")
 )
 
 type Handler struct {
-	repo    Repo
-	session *scs.SessionManager
+	repo     Repo
+	session  *scs.SessionManager
+	validate *validator.Validate
+
+	mailer  mailer.Mailer
+	tokens  *signedtoken.Signer
+	account config.Account
+	lockout *Lockout
+
+	providers map[string]oauth.Provider
+	csrf      csrf.Store
 }
 
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
 	err := request.DecodeJSON(w, r, &req)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, nil)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 
-	if req.Email == "" {
-		respond.Error(w, http.StatusBadRequest, ErrEmailRequired)
+	errs := validate.Validate(h.validate, req)
+	if errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
 		return
 	}
 
-	if len(req.Password) < minPasswordLength {
-		respond.Error(w, http.StatusBadRequest, ErrPasswordLength)
+	hashedPassword, err := argon2id.CreateHash(req.Password, argon2id.DefaultParams)
+	if err != nil {
+		respond.Fail(w, r, err)
 		return
 	}
 
-	hashedPassword, err := argon2id.CreateHash(req.Password, argon2id.DefaultParams)
+	user, err := h.repo.Register(r.Context(), req.FirstName, req.LastName, req.Email, hashedPassword)
 	if err != nil {
-		respond.Error(w, http.StatusInternalServerError, nil)
+		respond.Fail(w, r, err)
 		return
 	}
 
-	if err := h.repo.Register(r.Context(), req.FirstName, req.LastName, req.Email, hashedPassword); err != nil {
-		respond.Error(w, http.StatusBadRequest, err)
-		return
+	// The account exists either way. The user can ask for another link if
+	// this one does not arrive.
+	if err := h.sendVerification(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "sending verification email", "user_id", user.ID, "error", err)
 	}
 
 	respond.Status(w, http.StatusCreated)
@@ -64,20 +86,83 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	err := request.DecodeJSON(w, r, &req)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, nil)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 
 	ctx := r.Context()
+	ip := middleware.ClientIP(r)
+
+	// The login counts as failed until the password matches. Waiting logins
+	// fail like a wrong password, without checking it.
+	wait, err := h.lockout.Attempt(ctx, req.Email, ip)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	if wait > 0 {
+		retryAfter(w, wait)
+		respond.Fail(w, r, ErrInvalidCredentials)
+		return
+	}
 
-	user, _, err := h.repo.Login(ctx, req)
+	user, match, err := h.repo.Login(ctx, req)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	if !match {
+		if err := h.lockout.Fail(ctx, req.Email, user); err != nil {
+			slog.ErrorContext(ctx, "recording failed login", "error", err)
+		}
+		respond.Fail(w, r, ErrInvalidCredentials)
+		return
+	}
+	// With two-factor authentication, the failures are only forgotten once
+	// LoginTwoFactor accepts a code. Otherwise, the password alone would
+	// reset the count of wrong codes.
+	if user.TotpEnabledAt == nil {
+		err = h.lockout.Succeed(ctx, req.Email, ip)
+	} else {
+		err = h.lockout.Pass(ctx, req.Email, ip)
+	}
 	if err != nil {
-		respond.Status(w, http.StatusUnauthorized)
+		slog.ErrorContext(ctx, "taking back login attempt", "user_id", user.ID, "error", err)
+	}
+
+	h.logIn(w, r, user)
+}
+
+func retryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// logIn starts a session for the user, once they have proven who they are
+// with a password or at an external provider.
+func (h *Handler) logIn(w http.ResponseWriter, r *http.Request, user *gen.User) {
+	ctx := r.Context()
+
+	if h.account.RequireVerified && user.VerifiedAt == nil {
+		respond.Fail(w, r, ErrNotVerified)
 		return
 	}
 
 	if err := h.session.RenewToken(ctx); err != nil {
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
+		return
+	}
+	// The csrf token of the session is only issued after logging in.
+	h.session.Remove(ctx, csrf.SessionKey)
+
+	// With two-factor authentication, the session is not logged in until
+	// LoginTwoFactor accepts a code.
+	if user.TotpEnabledAt != nil {
+		h.session.Remove(ctx, string(middleware.KeyID))
+		h.session.Remove(ctx, keyPendingAttempts)
+		h.session.Put(ctx, keyPendingUser, user.ID)
+		h.session.Put(ctx, keyPendingAt, time.Now().Unix())
+
+		respond.Json(w, http.StatusAccepted, &LoginResponse{TwoFactorRequired: true})
 		return
 	}
 
@@ -91,7 +176,7 @@ func (h *Handler) Protected(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
-	userID := h.session.Get(r.Context(), string(middleware.KeyID))
+	userID := r.Context().Value(middleware.KeyID)
 
 	respond.Json(w, http.StatusOK, map[string]any{"user_id": userID})
 }
@@ -99,61 +184,86 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	err := h.session.Destroy(r.Context())
 	if err != nil {
-		respond.Status(w, http.StatusBadRequest)
+		respond.Fail(w, r, err)
 		return
 	}
 }
 
+// ForceLogout logs another user out of all of their sessions. The route is
+// guarded by a permission, see RegisterHTTPEndPoints.
 func (h *Handler) ForceLogout(w http.ResponseWriter, r *http.Request) {
-	// Authorization is needed to ensure that only super admin can force delete
-	currUser := h.session.Get(r.Context(), string(middleware.KeyID))
-	// A more robust authorization is needed for real-world implementation.
-	// For now, we naively check if user id is equal to 1.
-	if currUser.(uint64) != 1 {
-		respond.Status(w, http.StatusInternalServerError)
+	userID, err := param.UInt64(r, "userID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	ok, err := h.repo.Logout(r.Context(), userID)
+	if err != nil {
+		respond.Fail(w, r, err)
 		return
 	}
 
+	if !ok {
+		respond.Fail(w, r, errors.New("unable to log out"))
+	}
+}
+
+// Unlock forgets the failed logins of another user, which lifts a lockout.
+// The route is guarded by a permission, see RegisterHTTPEndPoints.
+func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
 	userID, err := param.UInt64(r, "userID")
 	if err != nil {
-		respond.Status(w, http.StatusInternalServerError)
+		respond.Fail(w, r, message.ErrBadRequest)
 		return
 	}
 
-	ok, err := h.repo.Logout(r.Context(), userID)
+	user, err := h.repo.User(r.Context(), userID)
 	if err != nil {
-		respond.Status(w, http.StatusInternalServerError)
+		respond.Fail(w, r, err)
 		return
 	}
 
-	if !ok {
-		respond.Json(w, http.StatusInternalServerError, map[string]string{"message": "unable to log out"})
+	if err := h.lockout.Unlock(r.Context(), user); err != nil {
+		respond.Fail(w, r, err)
+		return
 	}
+
+	respond.Status(w, http.StatusNoContent)
 }
 
-// Csrf stores a new csrf token in the database.
-// For a Data modifying requests in <form action="" method="POST"> including PUT and PATCH,
-// this csrf token needs to be attached along in the HTML along.
-// Then check in this API for its existence.
+// Csrf issues a csrf token for the session. Requests that change data with
+// the session cookie send it in the X-CSRF-Token header, or in the csrf_token
+// field of a <form action="" method="POST">, see middleware.CSRF. Depending on
+// the mode of the store, the same token is issued for the whole session or a
+// new one works once.
 func (h *Handler) Csrf(w http.ResponseWriter, r *http.Request) {
 	_, ok := h.session.Get(r.Context(), string(middleware.KeyID)).(uint64)
 	if !ok {
-		respond.Error(w, http.StatusBadRequest, errors.New("you need to be logged in"))
+		respond.Fail(w, r, ErrLoginRequired)
 		return
 	}
 
-	token, err := h.repo.Csrf(r.Context())
+	token, err := h.csrf.Issue(r.Context())
 	if err != nil {
-		respond.Status(w, http.StatusInternalServerError)
+		respond.Fail(w, r, err)
 		return
 	}
 
 	respond.Json(w, http.StatusOK, &RespondCsrf{CsrfToken: token})
 }
 
-func NewHandler(session *scs.SessionManager, repo Repo) *Handler {
+func NewHandler(session *scs.SessionManager, v *validator.Validate, repo Repo, mail mailer.Mailer, account config.Account, lockout *Lockout, providers map[string]oauth.Provider, csrf csrf.Store) *Handler {
 	return &Handler{
-		repo:    repo,
-		session: session,
+		repo:     repo,
+		session:  session,
+		validate: v,
+		mailer:   mail,
+		tokens:   signedtoken.New(account.Secret),
+		account:  account,
+		lockout:  lockout,
+
+		providers: providers,
+		csrf:      csrf,
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -26,10 +27,16 @@ import (
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/stretchr/testify/assert"
 
+	"micro/config"
 	"micro/database"
 	"micro/ent/gen"
+	"micro/internal/domain/authorization"
 	"micro/internal/middleware"
+	"micro/internal/utility/message"
+	"micro/internal/utility/respond"
+	"micro/third_party/mailer"
 	"micro/third_party/postgresstore"
+	"micro/third_party/validate"
 )
 
 const (
@@ -40,6 +47,14 @@ const (
 
 var (
 	migrator *database.Migrate
+
+	account = config.Account{
+		Secret:       "secret",
+		BaseURL:      "http://localhost:3000",
+		VerifyExpiry: time.Hour,
+		ResetExpiry:  time.Hour,
+		TOTPIssuer:   "go8",
+	}
 )
 
 func TestMain(m *testing.M) {
@@ -108,6 +123,15 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	_, err = migrator.DB.ExecContext(context.Background(), `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT users.id, roles.id FROM users, roles
+		WHERE users.email = $1 AND roles.name = 'admin'
+		ON CONFLICT DO NOTHING
+		`, "admin@gmhafiz.com")
+	if err != nil {
+		log.Fatalln(err)
+	}
 
 	// We can access database with m.hostAndPort or m.databaseUrl
 	// port changes everytime a new docker instance is run
@@ -131,6 +155,8 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 	}
 	type want struct {
 		error
+		field  string
+		rule   string
 		status int
 	}
 	tests := []struct {
@@ -161,7 +187,7 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 			},
 			want: want{
 				error:  ErrEmailNotAvailable,
-				status: http.StatusBadRequest,
+				status: http.StatusConflict,
 			},
 		},
 		{
@@ -173,7 +199,24 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 				},
 			},
 			want: want{
-				error:  ErrEmailRequired,
+				error:  errors.New(message.ValidationFailed),
+				field:  "email",
+				rule:   "required",
+				status: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid email",
+			args: args{
+				RegisterRequest: &RegisterRequest{
+					Email:    "email.example.com",
+					Password: "highEntropyPassword",
+				},
+			},
+			want: want{
+				error:  errors.New(message.ValidationFailed),
+				field:  "email",
+				rule:   "email",
 				status: http.StatusBadRequest,
 			},
 		},
@@ -186,7 +229,24 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 				},
 			},
 			want: want{
-				error:  ErrPasswordLength,
+				error:  errors.New(message.ValidationFailed),
+				field:  "password",
+				rule:   "required",
+				status: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "weak password",
+			args: args{
+				RegisterRequest: &RegisterRequest{
+					Email:    "email@example.com",
+					Password: "weakpasswordonly",
+				},
+			},
+			want: want{
+				error:  errors.New(message.ValidationFailed),
+				field:  "password",
+				rule:   "strong_password",
 				status: http.StatusBadRequest,
 			},
 		},
@@ -211,7 +271,7 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 
 			router.ServeHTTP(ww, rr)
 
@@ -221,16 +281,20 @@ func TestHandler_RegisterIntegration(t *testing.T) {
 			assert.Nil(t, err)
 
 			if len(b) > 0 {
-				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+				var problem respond.Problem
 
-				err = json.Unmarshal(b, &errStruct)
+				err = json.Unmarshal(b, &problem)
 				assert.Nil(t, err)
 
-				assert.Equal(t, tt.want.error.Error(), errStruct.Message)
+				assert.Equal(t, tt.want.status, problem.Status)
+				assert.Equal(t, tt.want.error.Error(), problem.Detail)
+				assert.Equal(t, "/api/v1/register", problem.Instance)
+
+				if tt.want.field != "" {
+					assert.Len(t, problem.Errors, 1)
+					assert.Equal(t, tt.want.field, problem.Errors[0].Field)
+					assert.Equal(t, tt.want.rule, problem.Errors[0].Rule)
+				}
 			}
 		})
 	}
@@ -320,7 +384,7 @@ func TestHandler_LoginIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 
 			router.ServeHTTP(ww, rr)
 
@@ -391,7 +455,7 @@ func TestHandler_ProtectedIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 
 			router.ServeHTTP(ww, rr)
 
@@ -411,7 +475,7 @@ func TestHandler_ProtectedIntegration(t *testing.T) {
 
 			router = chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -479,7 +543,7 @@ func TestHandler_MeIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 
 			router.ServeHTTP(ww, rr)
 
@@ -499,7 +563,7 @@ func TestHandler_MeIntegration(t *testing.T) {
 
 			router = chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -583,7 +647,7 @@ func TestHandler_LogoutIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -681,8 +745,9 @@ func TestHandler_Force_LogoutIntegration(t *testing.T) {
 
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
+			router.Use(middleware.Authorize(authorization.NewRepo(client)))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -720,6 +785,19 @@ func TestHandler_Force_LogoutIntegration(t *testing.T) {
 			adminToken, err := extractToken(ww.Header().Get("Set-Cookie"))
 			assert.Nil(t, err)
 
+			rr = httptest.NewRequest(http.MethodGet, "/api/v1/restricted/csrf", nil)
+			ww = httptest.NewRecorder()
+			rr.AddCookie(&http.Cookie{
+				Name:  sessionName,
+				Value: adminToken,
+			})
+
+			router.ServeHTTP(ww, rr)
+
+			var csrfResp RespondCsrf
+			err = json.NewDecoder(ww.Body).Decode(&csrfResp)
+			assert.Nil(t, err)
+
 			// ID 2 is our normal user to be forced-log out
 			rr = httptest.NewRequest(http.MethodPost, "/api/v1/restricted/logout/2", nil)
 			ww = httptest.NewRecorder()
@@ -727,6 +805,7 @@ func TestHandler_Force_LogoutIntegration(t *testing.T) {
 				Name:  sessionName,
 				Value: adminToken,
 			})
+			rr.Header.Set(middleware.CSRFHeader, csrfResp.CsrfToken)
 
 			router.ServeHTTP(ww, rr)
 
@@ -811,7 +890,7 @@ func TestHandler_Csrf_Valid_TokenIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -920,7 +999,7 @@ func TestHandler_Csrf_Valid_And_Delete_TokenIntegration(t *testing.T) {
 			router := chi.NewRouter()
 			router.Use(middleware.LoadAndSave(session))
 
-			RegisterHTTPEndPoints(router, session, repo)
+			RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
 			router.ServeHTTP(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
@@ -960,6 +1039,54 @@ func TestHandler_Csrf_Valid_And_Delete_TokenIntegration(t *testing.T) {
 	}
 }
 
+func TestOnceStoreIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	session := newSession(migrator.DB, time.Hour)
+	repo := NewRepo(dbClient(), migrator.DB, session)
+	store := csrf.New(csrf.ModeOnce, session, migrator.DB)
+
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	_, err = repo.Register(ctx, "Once", "Only", "csrf-once@example.com", hashedPassword)
+	assert.Nil(t, err)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, store, middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
+
+	// Two sessions of the same user.
+	login := func() context.Context {
+		c := &client{t: t, router: router}
+		ww := c.post("/api/v1/login", `{"email": "csrf-once@example.com", "password": "highEntropyPassword"}`)
+		assert.Equal(t, http.StatusOK, ww.Code)
+
+		ctx, err := session.Load(ctx, c.cookie.Value)
+		assert.Nil(t, err)
+		return ctx
+	}
+	issuedTo := login()
+	other := login()
+
+	token, err := store.Issue(issuedTo)
+	assert.Nil(t, err)
+
+	ok, err := store.Verify(other, token)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.Verify(issuedTo, token)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Verify(issuedTo, token)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
 func extractToken(cookie string) (string, error) {
 	parts := strings.Split(cookie, ";")
 	if len(parts) == 0 {
@@ -997,3 +1124,214 @@ func newSession(db *sql.DB, duration time.Duration) *scs.SessionManager {
 
 	return manager
 }
+
+func TestPostgresAttemptsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	attempts := NewPostgresAttempts(migrator.DB)
+	key := accountKey("attempts@example.com")
+
+	failures, last, err := attempts.Failures(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Zero(t, failures)
+	assert.True(t, last.IsZero())
+
+	for i := 1; i <= 3; i++ {
+		var previous time.Time
+		failures, previous, err = attempts.Attempt(ctx, key, time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, i, failures)
+		assert.True(t, last.Equal(previous))
+		_, last, err = attempts.Failures(ctx, key, time.Hour)
+		assert.Nil(t, err)
+	}
+
+	failures, last, err = attempts.Failures(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, failures)
+	assert.WithinDuration(t, time.Now(), last, time.Minute)
+
+	// An attempt that did not fail is taken back, with its time.
+	_, previous, err := attempts.Attempt(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, attempts.Undo(ctx, key))
+	failures, last, err = attempts.Failures(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, failures)
+	assert.True(t, previous.Equal(last))
+
+	// Failures outside the window are forgotten, and counting starts again.
+	_, err = migrator.DB.ExecContext(ctx, "UPDATE login_attempts SET last_failed_at = now() - interval '2 hours' WHERE key = $1", key)
+	assert.Nil(t, err)
+	failures, _, err = attempts.Failures(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Zero(t, failures)
+	failures, _, err = attempts.Attempt(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, failures)
+
+	assert.Nil(t, attempts.Reset(ctx, key))
+	failures, _, err = attempts.Failures(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.Zero(t, failures)
+}
+
+func TestPersonalTokensIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	client := dbClient()
+	repo := NewRepo(client, migrator.DB, newSession(migrator.DB, time.Hour))
+
+	u, err := repo.Register(ctx, "Token", "Owner", "tokens@example.com", "hash")
+	assert.Nil(t, err)
+
+	token, prefix, err := newPersonalToken()
+	assert.Nil(t, err)
+	req := &CreatePersonalTokenRequest{Name: "ci", Scopes: []string{"books:write"}}
+	pt, err := repo.CreatePersonalToken(ctx, u.ID, req, prefix, hashPersonalToken(token))
+	assert.Nil(t, err)
+	assert.Nil(t, pt.ExpiresAt)
+
+	userID, scopes, err := NewPersonalTokens(repo).AuthenticateToken(ctx, token)
+	assert.Nil(t, err)
+	assert.Equal(t, u.ID, userID)
+	assert.Equal(t, []string{"books:write"}, scopes)
+
+	tokens, err := repo.PersonalTokens(ctx, u.ID)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	assert.ErrorIs(t, repo.RevokePersonalToken(ctx, u.ID+1, pt.ID), ErrPersonalTokenNotFound)
+	assert.Nil(t, repo.RevokePersonalToken(ctx, u.ID, pt.ID))
+
+	_, _, err = NewPersonalTokens(repo).AuthenticateToken(ctx, token)
+	assert.ErrorIs(t, err, middleware.ErrInvalidToken)
+}
+
+func TestHandler_SessionsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	session := newSession(migrator.DB, time.Hour)
+	repo := NewRepo(dbClient(), migrator.DB, session)
+
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	_, err = repo.Register(ctx, "Many", "Devices", "sessions@example.com", hashedPassword)
+	assert.Nil(t, err)
+	_, err = repo.Register(ctx, "Someone", "Else", "other-sessions@example.com", hashedPassword)
+	assert.Nil(t, err)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
+
+	// Each client is another device, with its own session.
+	login := func(email string) *client {
+		c := &client{t: t, router: router}
+		ww := c.post("/api/v1/login", `{"email": "`+email+`", "password": "highEntropyPassword"}`)
+		assert.Equal(t, http.StatusOK, ww.Code)
+		return c
+	}
+	list := func(c *client) []*SessionResponse {
+		ww := c.do(http.MethodGet, "/api/v1/restricted/sessions", "")
+		assert.Equal(t, http.StatusOK, ww.Code)
+		var sessions []*SessionResponse
+		assert.Nil(t, json.NewDecoder(ww.Body).Decode(&sessions))
+		return sessions
+	}
+	loggedIn := func(c *client) bool {
+		return c.do(http.MethodGet, "/api/v1/restricted", "").Code == http.StatusOK
+	}
+
+	laptop := login("sessions@example.com")
+	phone := login("sessions@example.com")
+	tablet := login("sessions@example.com")
+	someone := login("other-sessions@example.com")
+
+	sessions := list(laptop)
+	assert.Len(t, sessions, 3)
+	var current []string
+	for _, s := range sessions {
+		if s.Current {
+			current = append(current, s.ID)
+		}
+	}
+	assert.Equal(t, []string{postgresstore.ID(laptop.cookie.Value)}, current)
+
+	// The sessions of others cannot be revoked.
+	ww := laptop.withCSRF().do(http.MethodDelete, "/api/v1/restricted/sessions/"+postgresstore.ID(someone.cookie.Value), "")
+	assert.Equal(t, http.StatusNotFound, ww.Code)
+	assert.True(t, loggedIn(someone))
+
+	ww = laptop.withCSRF().do(http.MethodDelete, "/api/v1/restricted/sessions/"+postgresstore.ID(phone.cookie.Value), "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.False(t, loggedIn(phone))
+	assert.True(t, loggedIn(tablet))
+	assert.Len(t, list(laptop), 2)
+
+	ww = laptop.withCSRF().do(http.MethodDelete, "/api/v1/restricted/sessions/others", "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.False(t, loggedIn(tablet))
+	assert.True(t, loggedIn(laptop))
+	assert.True(t, loggedIn(someone))
+	sessions = list(laptop)
+	assert.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+
+	// Revoking the current session logs out.
+	ww = laptop.withCSRF().do(http.MethodDelete, "/api/v1/restricted/sessions/"+sessions[0].ID, "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.False(t, loggedIn(laptop))
+}
+
+func TestHandler_RestrictedCSRFIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	session := newSession(migrator.DB, time.Hour)
+	repo := NewRepo(dbClient(), migrator.DB, session)
+
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	u, err := repo.Register(ctx, "Cross", "Site", "csrf-restricted@example.com", hashedPassword)
+	assert.Nil(t, err)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	RegisterHTTPEndPoints(router, session, validate.New(), repo, mailer.NewLog("no-reply@example.com"), account, nil, nil, csrf.New(csrf.ModeOnce, session, migrator.DB), middleware.Require("sessions:manage"), middleware.Require("accounts:unlock"))
+
+	c := &client{t: t, router: router}
+	ww := c.post("/api/v1/login", `{"email": "csrf-restricted@example.com", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	ww = c.post("/api/v1/restricted/tokens", `{"name": "script"}`)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	ww = c.withCSRF().post("/api/v1/restricted/tokens", `{"name": "script"}`)
+	assert.Equal(t, http.StatusCreated, ww.Code)
+	var pt PersonalTokenResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&pt))
+	path := "/api/v1/restricted/tokens/" + strconv.FormatUint(pt.ID, 10)
+
+	// The session cookie alone does not revoke the token.
+	ww = c.do(http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+	tokens, err := repo.PersonalTokens(ctx, u.ID)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+
+	ww = c.withCSRF().do(http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+}
@@ -0,0 +1,191 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"micro/config"
+	"micro/ent/gen"
+	"micro/internal/domain/audit"
+	"micro/internal/middleware"
+)
+
+// Lockout slows down logins after failures, and then refuses them for a
+// while. Failures are counted by email address, whether or not an account
+// has it, and by IP address. See config.Lockout.
+//
+// A nil Lockout never refuses a login.
+type Lockout struct {
+	attempts Attempts
+	recorder audit.Recorder
+	cfg      config.Lockout
+}
+
+func NewLockout(attempts Attempts, recorder audit.Recorder, cfg config.Lockout) *Lockout {
+	return &Lockout{
+		attempts: attempts,
+		recorder: recorder,
+		cfg:      cfg,
+	}
+}
+
+// lockEvent is recorded in the audit log of the user.
+type lockEvent struct {
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// Attempt counts a login to email from ip before it is checked, and is how
+// long it has to wait. Counting first keeps concurrent logins from all being
+// checked before any of them fails. A login that has to wait is taken back,
+// and must be refused.
+func (l *Lockout) Attempt(ctx context.Context, email, ip string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	account, err := l.attempt(ctx, accountKey(email), l.cfg.FreeAttempts, l.cfg.Threshold)
+	if err != nil {
+		return 0, err
+	}
+	address, err := l.attempt(ctx, ipKey(ip), l.cfg.IPFreeAttempts, l.cfg.IPThreshold)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := max(account, address)
+	if wait > 0 {
+		if err := l.Pass(ctx, email, ip); err != nil {
+			return 0, err
+		}
+	}
+
+	return wait, nil
+}
+
+// Fail records a lockout of the account in its audit log, when the attempt
+// that failed reached the threshold. The user is nil when no account has the
+// email.
+func (l *Lockout) Fail(ctx context.Context, email string, user *gen.User) error {
+	if l == nil || user == nil {
+		return nil
+	}
+
+	failures, _, err := l.attempts.Failures(ctx, accountKey(email), l.window())
+	if err != nil {
+		return err
+	}
+
+	if failures == l.cfg.Threshold {
+		until := time.Now().Add(l.cfg.Duration).UTC()
+		audit.Log(ctx, l.recorder, "users", middleware.ActionLock, user.ID, "", values(lockEvent{Failures: failures, LockedUntil: &until}))
+	}
+
+	return nil
+}
+
+// Pass takes back an attempt that did not fail, such as a right password
+// that still needs the second factor. Earlier failures are kept.
+func (l *Lockout) Pass(ctx context.Context, email, ip string) error {
+	if l == nil {
+		return nil
+	}
+
+	if err := l.attempts.Undo(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	return l.attempts.Undo(ctx, ipKey(ip))
+}
+
+// Succeed forgets the failures of the account. The IP address only gets the
+// attempt back, and keeps its failures, so that a known password does not
+// help to guess others.
+func (l *Lockout) Succeed(ctx context.Context, email, ip string) error {
+	if l == nil {
+		return nil
+	}
+
+	if err := l.attempts.Reset(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	return l.attempts.Undo(ctx, ipKey(ip))
+}
+
+// Unlock forgets the failures of the account of user, which lifts a lockout
+// or backoff. It is recorded in the audit log of the user.
+func (l *Lockout) Unlock(ctx context.Context, user *gen.User) error {
+	if l == nil {
+		return nil
+	}
+
+	failures, _, err := l.attempts.Failures(ctx, accountKey(user.Email), l.window())
+	if err != nil {
+		return err
+	}
+	if failures == 0 {
+		return nil
+	}
+
+	if err := l.attempts.Reset(ctx, accountKey(user.Email)); err != nil {
+		return err
+	}
+
+	audit.Log(ctx, l.recorder, "users", middleware.ActionUnlock, user.ID, values(lockEvent{Failures: failures}), "")
+
+	return nil
+}
+
+// attempt counts an attempt for key, which waits for the delay of the
+// failures before it, from the last of them.
+func (l *Lockout) attempt(ctx context.Context, key string, free, threshold int) (time.Duration, error) {
+	attempts, previous, err := l.attempts.Attempt(ctx, key, l.window())
+	if err != nil {
+		return 0, err
+	}
+
+	return max(time.Until(previous.Add(l.delay(attempts-1, free, threshold))), 0), nil
+}
+
+// delay doubles with each failure past the free ones, until the threshold
+// locks the key for the whole lockout duration.
+func (l *Lockout) delay(failures, free, threshold int) time.Duration {
+	switch {
+	case failures >= threshold:
+		return l.cfg.Duration
+	case failures <= free:
+		return 0
+	}
+
+	delay := l.cfg.BaseDelay
+	for range failures - free - 1 {
+		delay *= 2
+		if delay >= l.cfg.MaxDelay {
+			return l.cfg.MaxDelay
+		}
+	}
+
+	return min(delay, l.cfg.MaxDelay)
+}
+
+// window keeps the failures at least for as long as the lockout lasts.
+func (l *Lockout) window() time.Duration {
+	return max(l.cfg.Window, l.cfg.Duration)
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func values(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
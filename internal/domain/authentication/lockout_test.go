@@ -0,0 +1,236 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gmhafiz/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/config"
+	"micro/ent/gen"
+	"micro/internal/domain/audit"
+	"micro/internal/middleware"
+	"micro/third_party/validate"
+)
+
+// memoryAttempts keeps the counts in memory, and never forgets them.
+type memoryAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+	last     map[string]time.Time
+	previous map[string]time.Time
+}
+
+func newMemoryAttempts() *memoryAttempts {
+	return &memoryAttempts{failures: map[string]int{}, last: map[string]time.Time{}, previous: map[string]time.Time{}}
+}
+
+func (a *memoryAttempts) Failures(_ context.Context, key string, _ time.Duration) (int, time.Time, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failures[key], a.last[key], nil
+}
+
+func (a *memoryAttempts) Attempt(_ context.Context, key string, _ time.Duration) (int, time.Time, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures[key]++
+	a.previous[key], a.last[key] = a.last[key], time.Now()
+	return a.failures[key], a.previous[key], nil
+}
+
+func (a *memoryAttempts) Undo(_ context.Context, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures[key]--
+	a.last[key] = a.previous[key]
+	return nil
+}
+
+func (a *memoryAttempts) Reset(_ context.Context, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.failures, key)
+	delete(a.last, key)
+	delete(a.previous, key)
+	return nil
+}
+
+var testLockout = config.Lockout{
+	FreeAttempts:   3,
+	BaseDelay:      time.Second,
+	MaxDelay:       5 * time.Second,
+	Threshold:      10,
+	Duration:       15 * time.Minute,
+	IPFreeAttempts: 20,
+	IPThreshold:    100,
+	Window:         time.Hour,
+}
+
+func TestLockout_delay(t *testing.T) {
+	l := NewLockout(nil, nil, testLockout)
+
+	want := map[int]time.Duration{
+		0:  0,
+		3:  0,
+		4:  time.Second,
+		5:  2 * time.Second,
+		6:  4 * time.Second,
+		7:  5 * time.Second,
+		9:  5 * time.Second,
+		10: 15 * time.Minute,
+		11: 15 * time.Minute,
+	}
+	for failures, delay := range want {
+		assert.Equal(t, delay, l.delay(failures, testLockout.FreeAttempts, testLockout.Threshold), failures)
+	}
+}
+
+func TestHandler_Login_Lockout(t *testing.T) {
+	u := &gen.User{ID: 2, Email: "jane@example.com"}
+	repo := &RepoMock{
+		LoginFunc: func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+			if !strings.EqualFold(req.Email, u.Email) {
+				return nil, false, nil
+			}
+			return u, req.Password == "highEntropyPassword", nil
+		},
+		UserFunc: func(ctx context.Context, userID uint64) (*gen.User, error) {
+			if userID != u.ID {
+				return nil, ErrUserNotFound
+			}
+			return u, nil
+		},
+	}
+	var recorded []middleware.Event
+	recorder := &audit.RepoMock{
+		RecordFunc: func(ctx context.Context, events ...middleware.Event) error {
+			recorded = append(recorded, events...)
+			return nil
+		},
+	}
+
+	// No backoff, so that the lockout is reached without waiting.
+	cfg := testLockout
+	cfg.BaseDelay, cfg.MaxDelay = 0, 0
+	attempts := newMemoryAttempts()
+	session := scs.New()
+	h := NewHandler(session, validate.New(), repo, &mailbox{}, testAccount, NewLockout(attempts, recorder, cfg), nil, nil)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	router.Use(middleware.Audit)
+	router.Post("/api/v1/login", h.Login)
+	router.Post("/api/v1/restricted/unlock/{userID}", h.Unlock)
+
+	wrong := `{"email": "Jane@example.com", "password": "wrong"}`
+	for range cfg.Threshold {
+		ww := post(router, "/api/v1/login", wrong)
+		assert.Equal(t, http.StatusUnauthorized, ww.Code)
+		assert.Empty(t, ww.Header().Get("Retry-After"))
+	}
+	assert.Equal(t, cfg.Threshold, attempts.failures["account:jane@example.com"])
+	assert.Len(t, recorded, 1)
+	assert.Equal(t, middleware.ActionLock, recorded[0].Action)
+	assert.Equal(t, "users", recorded[0].Table)
+	assert.Equal(t, uint64(2), recorded[0].TableRowID)
+	assert.Contains(t, recorded[0].NewValues, `"failures":10`)
+
+	// Locked out, even with the right password, and without telling.
+	ww := post(router, "/api/v1/login", `{"email": "jane@example.com", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+	assert.Equal(t, "900", ww.Header().Get("Retry-After"))
+
+	// Unknown emails are counted and answered the same.
+	for range cfg.Threshold {
+		post(router, "/api/v1/login", `{"email": "john@example.com", "password": "wrong"}`)
+	}
+	ww = post(router, "/api/v1/login", `{"email": "john@example.com", "password": "wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+	assert.Equal(t, "900", ww.Header().Get("Retry-After"))
+	assert.Len(t, recorded, 1)
+
+	ww = post(router, "/api/v1/restricted/unlock/3", "")
+	assert.Equal(t, http.StatusNotFound, ww.Code)
+
+	ww = post(router, "/api/v1/restricted/unlock/2", "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Len(t, recorded, 2)
+	assert.Equal(t, middleware.ActionUnlock, recorded[1].Action)
+	assert.Equal(t, `{"failures":10}`, recorded[1].OldValues)
+
+	ww = post(router, "/api/v1/login", `{"email": "jane@example.com", "password": "highEntropyPassword"}`)
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	// The address keeps its failures after a successful login.
+	assert.Equal(t, 2*cfg.Threshold, attempts.failures["ip:192.0.2.1"])
+	assert.Zero(t, attempts.failures["account:jane@example.com"])
+
+	// Unlocking an account that is not locked records nothing.
+	ww = post(router, "/api/v1/restricted/unlock/2", "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Len(t, recorded, 2)
+}
+
+func TestHandler_Login_Backoff(t *testing.T) {
+	repo := &RepoMock{
+		LoginFunc: func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+			return nil, false, nil
+		},
+	}
+	attempts := newMemoryAttempts()
+	h := NewHandler(nil, validate.New(), repo, &mailbox{}, testAccount, NewLockout(attempts, nil, testLockout), nil, nil)
+
+	body := `{"email": "jane@example.com", "password": "wrong"}`
+	for range testLockout.FreeAttempts + 1 {
+		ww := httptest.NewRecorder()
+		h.Login(ww, httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body)))
+		assert.Equal(t, http.StatusUnauthorized, ww.Code)
+		assert.Empty(t, ww.Header().Get("Retry-After"))
+	}
+
+	// The password is not checked while waiting, so the count stays.
+	ww := httptest.NewRecorder()
+	h.Login(ww, httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+	assert.Equal(t, "1", ww.Header().Get("Retry-After"))
+	assert.Equal(t, testLockout.FreeAttempts+1, attempts.failures["account:jane@example.com"])
+}
+
+func TestHandler_Login_Concurrent(t *testing.T) {
+	var checked atomic.Int32
+	repo := &RepoMock{
+		LoginFunc: func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+			checked.Add(1)
+			return nil, false, nil
+		},
+	}
+	attempts := newMemoryAttempts()
+	h := NewHandler(nil, validate.New(), repo, &mailbox{}, testAccount, NewLockout(attempts, nil, testLockout), nil, nil)
+
+	// Logins at the same time are each counted before any password is
+	// checked, so only the free attempts and the first delayed one are.
+	body := `{"email": "jane@example.com", "password": "wrong"}`
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ww := httptest.NewRecorder()
+			h.Login(ww, httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body)))
+			assert.Equal(t, http.StatusUnauthorized, ww.Code)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(testLockout.FreeAttempts+1), checked.Load())
+	assert.Equal(t, testLockout.FreeAttempts+1, attempts.failures["account:jane@example.com"])
+}
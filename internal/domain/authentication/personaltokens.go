@@ -0,0 +1,225 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/internal/utility/param"
+	"micro/internal/utility/respond"
+)
+
+// Personal access tokens look like mt_k3mvq8tz_<43 characters>. The prefix
+// finds the token, and only a hash of the whole token is stored. Each token
+// has 256 bits of entropy after the prefix, so a fast hash is enough.
+const (
+	personalTokenScheme       = "mt_"
+	personalTokenPrefixLength = 8
+
+	// personalTokenTouchInterval limits how often the last use of a token
+	// is written.
+	personalTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrScopeNotGranted = apperror.Forbidden("a token can only have permissions that you have")
+	ErrExpiryInPast    = apperror.Validation("the expiry needs to be in the future")
+)
+
+// PersonalTokens authenticates requests of scripts with the personal access
+// tokens of their users.
+type PersonalTokens struct {
+	repo Repo
+}
+
+func NewPersonalTokens(repo Repo) *PersonalTokens {
+	return &PersonalTokens{repo: repo}
+}
+
+// AuthenticateToken finds the user of the token, and the permissions that
+// the token is limited to.
+func (t *PersonalTokens) AuthenticateToken(ctx context.Context, token string) (uint64, []string, error) {
+	prefix, ok := personalTokenPrefix(token)
+	if !ok {
+		return 0, nil, middleware.ErrInvalidToken
+	}
+
+	pt, err := t.repo.PersonalTokenByPrefix(ctx, prefix)
+	if errors.Is(err, ErrPersonalTokenNotFound) {
+		return 0, nil, middleware.ErrInvalidToken
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashPersonalToken(token)), []byte(pt.TokenHash)) != 1 {
+		return 0, nil, middleware.ErrInvalidToken
+	}
+	if pt.ExpiresAt != nil && !time.Now().Before(*pt.ExpiresAt) {
+		return 0, nil, middleware.ErrInvalidToken
+	}
+
+	if pt.LastUsedAt == nil || time.Since(*pt.LastUsedAt) > personalTokenTouchInterval {
+		if err := t.repo.TouchPersonalToken(ctx, pt.ID); err != nil {
+			slog.ErrorContext(ctx, "recording use of personal token", "token_id", pt.ID, "error", err)
+		}
+	}
+
+	return pt.UserID, pt.Scopes, nil
+}
+
+// PersonalTokens lists the tokens of the logged-in user, without the tokens
+// themselves.
+func (h *Handler) PersonalTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	tokens, err := h.repo.PersonalTokens(r.Context(), userID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, personalTokenResponses(tokens))
+}
+
+// CreatePersonalToken makes a token for the logged-in user, limited to some
+// of their permissions. The token is only shown in this response.
+func (h *Handler) CreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := ctx.Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	var req CreatePersonalTokenRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		respond.Fail(w, r, ErrExpiryInPast)
+		return
+	}
+
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+	if req.Scopes == nil {
+		req.Scopes = []string{}
+	}
+	if err := grantable(ctx, userID, req.Scopes); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	token, prefix, err := newPersonalToken()
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	pt, err := h.repo.CreatePersonalToken(ctx, userID, &req, prefix, hashPersonalToken(token))
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	res := personalTokenResponse(pt)
+	res.Token = token
+
+	respond.Json(w, http.StatusCreated, res)
+}
+
+// RevokePersonalToken deletes one of the tokens of the logged-in user.
+func (h *Handler) RevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	id, err := param.UInt64(r, "tokenID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	if err := h.repo.RevokePersonalToken(r.Context(), userID, id); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// grantable checks that the user holds every permission in scopes. Require
+// checks it again whenever the token is used, in case it was taken away.
+func grantable(ctx context.Context, userID uint64, scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	a, ok := ctx.Value(middleware.KeyAuthorizer).(middleware.Authorizer)
+	if !ok {
+		return ErrScopeNotGranted
+	}
+
+	for _, scope := range scopes {
+		allowed, err := a.HasPermission(ctx, userID, scope)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrScopeNotGranted
+		}
+	}
+
+	return nil
+}
+
+// newPersonalToken makes a token, and the prefix that it is found by.
+func newPersonalToken() (string, string, error) {
+	b := make([]byte, personalTokenPrefixLength+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	prefix := make([]byte, personalTokenPrefixLength)
+	for i, c := range b[:personalTokenPrefixLength] {
+		prefix[i] = recoveryAlphabet[c%32]
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b[personalTokenPrefixLength:])
+
+	return personalTokenScheme + string(prefix) + "_" + secret, string(prefix), nil
+}
+
+// personalTokenPrefix is the prefix of what looks like a personal access
+// token.
+func personalTokenPrefix(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, personalTokenScheme)
+	if !ok || len(rest) <= personalTokenPrefixLength+1 || rest[personalTokenPrefixLength] != '_' {
+		return "", false
+	}
+	return rest[:personalTokenPrefixLength], true
+}
+
+func hashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
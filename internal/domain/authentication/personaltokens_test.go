@@ -0,0 +1,206 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/gmhafiz/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/ent/gen"
+	"micro/internal/middleware"
+	"micro/third_party/validate"
+)
+
+type authorizerFunc func(ctx context.Context, userID uint64, permission string) (bool, error)
+
+func (f authorizerFunc) HasPermission(ctx context.Context, userID uint64, permission string) (bool, error) {
+	return f(ctx, userID, permission)
+}
+
+func TestPersonalTokenPrefix(t *testing.T) {
+	token, prefix, err := newPersonalToken()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(token, "mt_"+prefix+"_"))
+	assert.Len(t, prefix, personalTokenPrefixLength)
+
+	got, ok := personalTokenPrefix(token)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, got)
+
+	for _, token := range []string{"", "mt_", "mt_k3mvq8tz", "mt_k3mvq8tz_", "mt_k3mvq8tzx_secret", "xx_k3mvq8tz_secret"} {
+		_, ok := personalTokenPrefix(token)
+		assert.False(t, ok, token)
+	}
+}
+
+func TestHandler_PersonalTokens(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword}
+
+	var tokens []*gen.PersonalToken
+	var touched int
+	repo := twoFactorRepo(t, u)
+	repo.CreatePersonalTokenFunc = func(ctx context.Context, userID uint64, req *CreatePersonalTokenRequest, prefix string, tokenHash string) (*gen.PersonalToken, error) {
+		pt := &gen.PersonalToken{
+			ID:        uint64(len(tokens) + 1),
+			UserID:    userID,
+			Name:      req.Name,
+			Prefix:    prefix,
+			TokenHash: tokenHash,
+			Scopes:    req.Scopes,
+			ExpiresAt: req.ExpiresAt,
+			CreatedAt: time.Now(),
+		}
+		tokens = append(tokens, pt)
+		return pt, nil
+	}
+	repo.PersonalTokensFunc = func(ctx context.Context, userID uint64) ([]*gen.PersonalToken, error) {
+		return tokens, nil
+	}
+	repo.PersonalTokenByPrefixFunc = func(ctx context.Context, prefix string) (*gen.PersonalToken, error) {
+		for _, pt := range tokens {
+			if pt.Prefix == prefix {
+				return pt, nil
+			}
+		}
+		return nil, ErrPersonalTokenNotFound
+	}
+	repo.TouchPersonalTokenFunc = func(ctx context.Context, id uint64) error {
+		touched++
+		now := time.Now()
+		tokens[id-1].LastUsedAt = &now
+		return nil
+	}
+	repo.RevokePersonalTokenFunc = func(ctx context.Context, userID uint64, id uint64) error {
+		for i, pt := range tokens {
+			if pt.ID == id && pt.UserID == userID {
+				tokens = append(tokens[:i], tokens[i+1:]...)
+				return nil
+			}
+		}
+		return ErrPersonalTokenNotFound
+	}
+
+	// The user may write books, but not authors.
+	authorizer := authorizerFunc(func(ctx context.Context, userID uint64, permission string) (bool, error) {
+		return userID == u.ID && permission == "books:write", nil
+	})
+
+	session := scs.New()
+	h := NewHandler(session, validate.New(), repo, &mailbox{}, testAccount, nil, nil, nil)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	router.Use(middleware.Authorize(authorizer))
+	router.Post("/api/v1/login", h.Login)
+	router.Group(func(router chi.Router) {
+		router.Use(middleware.RequireSession)
+		router.Get("/api/v1/restricted/tokens", h.PersonalTokens)
+		router.Post("/api/v1/restricted/tokens", h.CreatePersonalToken)
+		router.Delete("/api/v1/restricted/tokens/{tokenID}", h.RevokePersonalToken)
+	})
+	// Only requests with a token are sent here, as the session store of the
+	// test does not support Authenticate.
+	router.Route("/api/v1/token", func(router chi.Router) {
+		router.Use(middleware.Authenticate(session, NewPersonalTokens(repo)))
+		router.Get("/me", h.Me)
+		router.With(middleware.Require("books:write")).Post("/books", ok)
+		router.With(middleware.Require("authors:write")).Post("/authors", ok)
+		router.With(middleware.RequireSession).Get("/tokens", h.PersonalTokens)
+	})
+
+	withToken := func(method, path, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRequest(method, path, nil)
+		rr.Header.Set("Authorization", "Bearer "+token)
+		ww := httptest.NewRecorder()
+		router.ServeHTTP(ww, rr)
+		return ww
+	}
+
+	c := &client{t: t, router: router, user: u}
+	ww := c.post("/api/v1/restricted/tokens", `{"name": "ci"}`)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	// Only permissions of the user.
+	ww = c.post("/api/v1/restricted/tokens", `{"name": "ci", "scopes": ["authors:write"]}`)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	ww = c.post("/api/v1/restricted/tokens", `{"name": "ci", "expires_at": "2001-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	ww = c.post("/api/v1/restricted/tokens", `{"name": "ci", "scopes": ["books:write", "books:write"]}`)
+	assert.Equal(t, http.StatusCreated, ww.Code)
+	var created PersonalTokenResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&created))
+	assert.Equal(t, []string{"books:write"}, created.Scopes)
+	assert.True(t, strings.HasPrefix(created.Token, "mt_"+created.Prefix+"_"))
+	assert.NotContains(t, tokens[0].TokenHash, created.Token)
+
+	ww = c.do(http.MethodGet, "/api/v1/restricted/tokens", "")
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.NotContains(t, ww.Body.String(), created.Token)
+	var listed []*PersonalTokenResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&listed))
+	assert.Len(t, listed, 1)
+	assert.Empty(t, listed[0].Token)
+
+	// The token is the user, limited to its scopes.
+	ww = withToken(http.MethodGet, "/api/v1/token/me", created.Token)
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.JSONEq(t, `{"user_id": 2}`, ww.Body.String())
+	assert.Equal(t, 1, touched)
+
+	ww = withToken(http.MethodPost, "/api/v1/token/books", created.Token)
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.Equal(t, 1, touched)
+
+	ww = withToken(http.MethodPost, "/api/v1/token/authors", created.Token)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	ww = withToken(http.MethodGet, "/api/v1/token/tokens", created.Token)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	// A token without scopes can only prove who it is.
+	ww = c.post("/api/v1/restricted/tokens", `{"name": "whoami"}`)
+	assert.Equal(t, http.StatusCreated, ww.Code)
+	var unscoped PersonalTokenResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&unscoped))
+	ww = withToken(http.MethodGet, "/api/v1/token/me", unscoped.Token)
+	assert.Equal(t, http.StatusOK, ww.Code)
+	ww = withToken(http.MethodPost, "/api/v1/token/books", unscoped.Token)
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	for _, token := range []string{"", "not a token", created.Token + "x", "mt_" + created.Prefix + "_guessed"} {
+		ww = withToken(http.MethodGet, "/api/v1/token/me", token)
+		assert.Equal(t, http.StatusUnauthorized, ww.Code, token)
+		assert.Equal(t, `Bearer error="invalid_token"`, ww.Header().Get("WWW-Authenticate"))
+	}
+
+	// Expired.
+	past := time.Now().Add(-time.Second)
+	tokens[1].ExpiresAt = &past
+	ww = withToken(http.MethodGet, "/api/v1/token/me", unscoped.Token)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/tokens/"+strconv.FormatUint(created.ID, 10), "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/tokens/"+strconv.FormatUint(created.ID, 10), "")
+	assert.Equal(t, http.StatusNotFound, ww.Code)
+
+	ww = withToken(http.MethodGet, "/api/v1/token/me", created.Token)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+}
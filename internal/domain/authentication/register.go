@@ -1,27 +1,68 @@
 package authentication
 
 import (
+	"net/http"
+
 	"github.com/gmhafiz/scs/v2"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 
+	"micro/config"
 	"micro/internal/middleware"
+	"micro/internal/utility/csrf"
+	"micro/third_party/mailer"
+	"micro/third_party/oauth"
 )
 
-func RegisterHTTPEndPoints(router *chi.Mux, session *scs.SessionManager, repo Repo) {
-	h := NewHandler(session, repo)
+// RegisterHTTPEndPoints mounts the authentication endpoints. Forcing another
+// user to log out goes through forceLogout first, and unlocking their account
+// through unlock. Users can also log in at the providers, keyed by the name
+// in their URLs. Restricted endpoints also take personal access tokens, and
+// need a csrf token from csrf to change data with the session cookie.
+func RegisterHTTPEndPoints(router *chi.Mux, session *scs.SessionManager, v *validator.Validate, repo Repo, mail mailer.Mailer, account config.Account, lockout *Lockout, providers map[string]oauth.Provider, csrf csrf.Store, forceLogout, unlock func(http.Handler) http.Handler) {
+	h := NewHandler(session, v, repo, mail, account, lockout, providers, csrf)
 
 	router.Post("/api/v1/login", h.Login)
+	router.Post("/api/v1/login/2fa", h.LoginTwoFactor)
 	router.Post("/api/v1/register", h.Register)
 
+	router.Post("/api/v1/verify", h.Verify)
+	router.Post("/api/v1/verify/resend", h.ResendVerification)
+	router.Post("/api/v1/password/forgot", h.ForgotPassword)
+	router.Post("/api/v1/password/reset", h.ResetPassword)
+
+	router.Get("/api/v1/oauth/{provider}", h.OAuthLogin)
+	router.Get("/api/v1/oauth/{provider}/callback", h.OAuthCallback)
+
 	router.Route("/api/v1/logout", func(router chi.Router) {
 		router.Post("/", h.Logout)
 	})
 
 	router.Route("/api/v1/restricted", func(router chi.Router) {
-		router.Use(middleware.Authenticate(session))
+		router.Use(middleware.Authenticate(session, NewPersonalTokens(repo)))
+		router.Use(middleware.CSRF(csrf))
 		router.Get("/csrf", h.Csrf)
 		router.Get("/", h.Protected)
 		router.Get("/me", h.Me)
-		router.Post("/logout/{userID}", h.ForceLogout)
+		router.With(forceLogout).Post("/logout/{userID}", h.ForceLogout)
+		router.With(unlock).Post("/unlock/{userID}", h.Unlock)
+
+		// Personal access tokens cannot manage the account they belong to.
+		router.Group(func(router chi.Router) {
+			router.Use(middleware.RequireSession)
+
+			router.Get("/sessions", h.Sessions)
+			router.Delete("/sessions/others", h.RevokeOtherSessions)
+			router.Delete("/sessions/{sessionID}", h.RevokeSession)
+
+			router.Get("/tokens", h.PersonalTokens)
+			router.Post("/tokens", h.CreatePersonalToken)
+			router.Delete("/tokens/{tokenID}", h.RevokePersonalToken)
+
+			router.Post("/2fa", h.Enroll)
+			router.Post("/2fa/enable", h.EnableTwoFactor)
+			router.Post("/2fa/disable", h.DisableTwoFactor)
+			router.Post("/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+		})
 	})
 }
@@ -2,18 +2,22 @@ package authentication
 
 import (
 	"context"
-	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
-	"errors"
+	"sync"
 	"time"
 
+	entsql "entgo.io/ent/dialect/sql"
 	"github.com/alexedwards/argon2id"
 	"github.com/gmhafiz/scs/v2"
 
 	"micro/ent/gen"
+	"micro/ent/gen/personaltoken"
+	"micro/ent/gen/recoverycode"
 	"micro/ent/gen/session"
 	"micro/ent/gen/user"
+	"micro/ent/gen/useridentity"
+	"micro/internal/utility/apperror"
+	"micro/third_party/oauth"
 )
 
 type repo struct {
@@ -23,43 +27,90 @@ type repo struct {
 }
 
 var (
-	ErrEmailNotAvailable = errors.New("email is not available")
-	ErrNotLoggedIn       = errors.New("you are not logged in yet")
+	ErrEmailNotAvailable = apperror.Conflict("email is not available")
+	ErrNotLoggedIn       = apperror.Unauthorized("you are not logged in yet")
+	ErrUserNotFound      = apperror.NotFound("user not found")
+	ErrTwoFactorEnabled  = apperror.Conflict("two-factor authentication is already enabled")
+	ErrSessionNotFound   = apperror.NotFound("session not found")
+
+	ErrPersonalTokenNotFound = apperror.NotFound("token not found")
 )
 
+//go:generate mirip -rm -pkg authentication -out repository_mock.go . Repo
 type Repo interface {
-	Register(ctx context.Context, firstName, lastName, email, hashedPassword string) error
+	Register(ctx context.Context, firstName, lastName, email, hashedPassword string) (*gen.User, error)
 	Login(ctx context.Context, req LoginRequest) (*gen.User, bool, error)
 	Logout(ctx context.Context, userID uint64) (bool, error)
-	Csrf(ctx context.Context) (string, error)
+	User(ctx context.Context, userID uint64) (*gen.User, error)
+	UserByEmail(ctx context.Context, email string) (*gen.User, error)
+	Verify(ctx context.Context, userID uint64) error
+	ResetPassword(ctx context.Context, userID uint64, hashedPassword string) error
+	SetTOTPSecret(ctx context.Context, userID uint64, secret string) error
+	EnableTOTP(ctx context.Context, userID uint64, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID uint64) error
+	UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint64, codeHash string) (bool, error)
+	Sessions(ctx context.Context, userID uint64) ([]*gen.Session, error)
+	RevokeSession(ctx context.Context, userID uint64, id string) error
+	RevokeOtherSessions(ctx context.Context, userID uint64, keepID string) (int, error)
+	UserByIdentity(ctx context.Context, provider, subject string) (*gen.User, error)
+	LinkIdentity(ctx context.Context, userID uint64, identity *oauth.Identity) error
+	RegisterIdentity(ctx context.Context, identity *oauth.Identity) (*gen.User, error)
+	CreatePersonalToken(ctx context.Context, userID uint64, req *CreatePersonalTokenRequest, prefix, tokenHash string) (*gen.PersonalToken, error)
+	PersonalTokens(ctx context.Context, userID uint64) ([]*gen.PersonalToken, error)
+	PersonalTokenByPrefix(ctx context.Context, prefix string) (*gen.PersonalToken, error)
+	TouchPersonalToken(ctx context.Context, id uint64) error
+	RevokePersonalToken(ctx context.Context, userID, id uint64) error
 }
 
-func (r *repo) Register(ctx context.Context, firstName, lastName, email, hashedPassword string) error {
-	err := r.ent.User.Create().
+func (r *repo) Register(ctx context.Context, firstName, lastName, email, hashedPassword string) (*gen.User, error) {
+	u, err := r.ent.User.Create().
 		SetFirstName(firstName).
 		SetLastName(lastName).
 		SetEmail(email).
 		SetPassword(hashedPassword).
-		Exec(ctx)
+		Save(ctx)
 	if err != nil {
 		if gen.IsConstraintError(err) {
-			return ErrEmailNotAvailable
+			return nil, ErrEmailNotAvailable
 		}
-		return err
+		return nil, err
 	}
 
-	return nil
+	return u, nil
 }
 
+// dummyHash is compared against when no user has the email, so that a login
+// takes as long whether or not the email is registered.
+var dummyHash = sync.OnceValue(func() string {
+	hash, err := argon2id.CreateHash("", argon2id.DefaultParams)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// Login finds the user with the email and checks their password. An unknown
+// email is no match and no user, like a wrong password.
 func (r *repo) Login(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
 	u, err := r.ent.User.Query().Where(user.EmailEqualFold(req.Email)).First(ctx)
+	if gen.IsNotFound(err) {
+		_, _ = argon2id.ComparePasswordAndHash(req.Password, dummyHash())
+		return nil, false, nil
+	}
 	if err != nil {
 		return nil, false, err
 	}
+	// Users that signed up with an external provider have no password.
+	if u.Password == "" {
+		_, _ = argon2id.ComparePasswordAndHash(req.Password, dummyHash())
+		return u, false, nil
+	}
 
 	match, err := argon2id.ComparePasswordAndHash(req.Password, u.Password)
 	if err != nil {
-		return nil, false, errors.New("wrong password is provided")
+		return nil, false, err
 	}
 
 	return u, match, nil
@@ -94,18 +145,311 @@ func (r *repo) Logout(ctx context.Context, userID uint64) (bool, error) {
 	return true, nil
 }
 
-func (r *repo) Csrf(ctx context.Context) (string, error) {
-	token, err := generateToken()
+// Sessions are the sessions of the user that have not expired, the most
+// recently seen first.
+func (r *repo) Sessions(ctx context.Context, userID uint64) ([]*gen.Session, error) {
+	return r.ent.Session.Query().
+		Where(session.UserIDEQ(userID), session.ExpiryGT(time.Now())).
+		Order(session.ByLastSeenAt(entsql.OrderDesc()), session.ByCreatedAt(entsql.OrderDesc())).
+		All(ctx)
+}
+
+// RevokeSession logs the user out of one of their sessions. Sessions of other
+// users are not found.
+func (r *repo) RevokeSession(ctx context.Context, userID uint64, id string) error {
+	n, err := r.ent.Session.Delete().
+		Where(session.ID(id), session.UserIDEQ(userID)).
+		Exec(ctx)
 	if err != nil {
-		return "", err
+		return err
 	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
 
-	err = r.session.CtxStore.CommitCtx(ctx, token, []byte("csrf_token"), time.Now().Add(r.session.Lifetime))
+// RevokeOtherSessions logs the user out of every session but the one with
+// keepID, and returns how many there were.
+func (r *repo) RevokeOtherSessions(ctx context.Context, userID uint64, keepID string) (int, error) {
+	return r.ent.Session.Delete().
+		Where(session.UserIDEQ(userID), session.IDNEQ(keepID)).
+		Exec(ctx)
+}
+
+// UserByIdentity is the user that the account at the provider is linked to.
+func (r *repo) UserByIdentity(ctx context.Context, provider, subject string) (*gen.User, error) {
+	u, err := r.ent.UserIdentity.Query().
+		Where(useridentity.Provider(provider), useridentity.Subject(subject)).
+		QueryUser().
+		Only(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+// LinkIdentity lets the user log in with the account at the provider.
+func (r *repo) LinkIdentity(ctx context.Context, userID uint64, identity *oauth.Identity) error {
+	return r.ent.UserIdentity.Create().
+		SetUserID(userID).
+		SetProvider(identity.Provider).
+		SetSubject(identity.Subject).
+		SetEmail(identity.Email).
+		Exec(ctx)
+}
+
+// RegisterIdentity creates a user for the account at the provider. The user
+// has no password, and their email address is verified by the provider.
+func (r *repo) RegisterIdentity(ctx context.Context, identity *oauth.Identity) (*gen.User, error) {
+	tx, err := r.ent.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	u, err := tx.User.Create().
+		SetFirstName(identity.Name).
+		SetEmail(identity.Email).
+		SetPassword("").
+		SetVerifiedAt(time.Now()).
+		Save(ctx)
+	if gen.IsConstraintError(err) {
+		return nil, ErrEmailNotAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.UserIdentity.Create().
+		SetUserID(u.ID).
+		SetProvider(identity.Provider).
+		SetSubject(identity.Subject).
+		SetEmail(identity.Email).
+		Exec(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
+	return u, tx.Commit()
+}
+
+// CreatePersonalToken stores the hash of a new token of the user.
+func (r *repo) CreatePersonalToken(ctx context.Context, userID uint64, req *CreatePersonalTokenRequest, prefix, tokenHash string) (*gen.PersonalToken, error) {
+	return r.ent.PersonalToken.Create().
+		SetUserID(userID).
+		SetName(req.Name).
+		SetPrefix(prefix).
+		SetTokenHash(tokenHash).
+		SetScopes(req.Scopes).
+		SetNillableExpiresAt(req.ExpiresAt).
+		Save(ctx)
+}
+
+// PersonalTokens are the tokens of the user, expired ones included, the
+// newest first.
+func (r *repo) PersonalTokens(ctx context.Context, userID uint64) ([]*gen.PersonalToken, error) {
+	return r.ent.PersonalToken.Query().
+		Where(personaltoken.UserID(userID)).
+		Order(personaltoken.ByCreatedAt(entsql.OrderDesc()), personaltoken.ByID(entsql.OrderDesc())).
+		All(ctx)
+}
+
+func (r *repo) PersonalTokenByPrefix(ctx context.Context, prefix string) (*gen.PersonalToken, error) {
+	t, err := r.ent.PersonalToken.Query().Where(personaltoken.Prefix(prefix)).Only(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrPersonalTokenNotFound
+	}
+	return t, err
+}
+
+// TouchPersonalToken records that the token was just used.
+func (r *repo) TouchPersonalToken(ctx context.Context, id uint64) error {
+	return r.ent.PersonalToken.UpdateOneID(id).SetLastUsedAt(time.Now()).Exec(ctx)
+}
+
+// RevokePersonalToken deletes one of the tokens of the user. Tokens of other
+// users are not found.
+func (r *repo) RevokePersonalToken(ctx context.Context, userID, id uint64) error {
+	n, err := r.ent.PersonalToken.Delete().
+		Where(personaltoken.ID(id), personaltoken.UserID(userID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPersonalTokenNotFound
+	}
+
+	return nil
+}
+
+func (r *repo) User(ctx context.Context, userID uint64) (*gen.User, error) {
+	u, err := r.ent.User.Get(ctx, userID)
+	if gen.IsNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (r *repo) UserByEmail(ctx context.Context, email string) (*gen.User, error) {
+	u, err := r.ent.User.Query().Where(user.EmailEqualFold(email)).First(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+// Verify marks the email address of the user as verified. Verifying it
+// again keeps the time of the first verification.
+func (r *repo) Verify(ctx context.Context, userID uint64) error {
+	return r.ent.User.Update().
+		Where(user.ID(userID), user.VerifiedAtIsNil()).
+		SetVerifiedAt(time.Now()).
+		Exec(ctx)
+}
+
+// ResetPassword replaces the password of the user and logs them out
+// everywhere.
+func (r *repo) ResetPassword(ctx context.Context, userID uint64, hashedPassword string) error {
+	tx, err := r.ent.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.User.UpdateOneID(userID).SetPassword(hashedPassword).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Session.Delete().Where(session.UserIDEQ(userID)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetTOTPSecret keeps the secret of an enrollment that is yet to be
+// confirmed, replacing any earlier one.
+func (r *repo) SetTOTPSecret(ctx context.Context, userID uint64, secret string) error {
+	n, err := r.ent.User.Update().
+		Where(user.ID(userID), user.TotpEnabledAtIsNil()).
+		SetTotpSecret(secret).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTOTP confirms the enrollment with the step of the code that was
+// used, and stores the hashes of the recovery codes.
+func (r *repo) EnableTOTP(ctx context.Context, userID uint64, step int64, codeHashes []string) error {
+	tx, err := r.ent.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	n, err := tx.User.Update().
+		Where(user.ID(userID), user.TotpEnabledAtIsNil(), user.TotpSecretNotNil()).
+		SetTotpEnabledAt(time.Now()).
+		SetTotpLastStep(step).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) DisableTOTP(ctx context.Context, userID uint64) error {
+	tx, err := r.ent.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.User.UpdateOneID(userID).
+		ClearTotpSecret().
+		ClearTotpEnabledAt().
+		ClearTotpLastStep().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code of the step was used. It is false when a
+// code of the same or a later step was used before, so that a code cannot be
+// replayed.
+func (r *repo) UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	n, err := r.ent.User.Update().
+		Where(
+			user.ID(userID),
+			user.Or(user.TotpLastStepIsNil(), user.TotpLastStepLT(step)),
+		).
+		SetTotpLastStep(step).
+		Save(ctx)
+	return n == 1, err
+}
+
+func (r *repo) ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes []string) error {
+	tx, err := r.ent.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode deletes the recovery code, and is false when the user has
+// no such code.
+func (r *repo) UseRecoveryCode(ctx context.Context, userID uint64, codeHash string) (bool, error) {
+	n, err := r.ent.RecoveryCode.Delete().
+		Where(recoverycode.UserID(userID), recoverycode.CodeHash(codeHash)).
+		Exec(ctx)
+	return n == 1, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *gen.Tx, userID uint64, codeHashes []string) error {
+	_, err := tx.RecoveryCode.Delete().Where(recoverycode.UserID(userID)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	builders := make([]*gen.RecoveryCodeCreate, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		builders = append(builders, tx.RecoveryCode.Create().SetUserID(userID).SetCodeHash(hash))
+	}
+	return tx.RecoveryCode.CreateBulk(builders...).Exec(ctx)
 }
 
 func NewRepo(ent *gen.Client, db *sql.DB, manager *scs.SessionManager) *repo {
@@ -115,12 +459,3 @@ func NewRepo(ent *gen.Client, db *sql.DB, manager *scs.SessionManager) *repo {
 		session: manager,
 	}
 }
-
-func generateToken() (string, error) {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.RawURLEncoding.EncodeToString(b), nil
-}
@@ -1,13 +1,40 @@
 package authentication
 
+import "time"
+
 type RegisterRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required,strong_password"`
 }
 
 type LoginRequest struct {
 	Email    string
 	Password string
 }
+
+type VerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,strong_password"`
+}
+
+type TwoFactorRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type CreatePersonalTokenRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+	// Scopes are the permissions that the token is limited to. Without any,
+	// it can only be used where being logged in is enough.
+	Scopes    []string   `json:"scopes" validate:"dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -1,5 +1,82 @@
 package authentication
 
+import (
+	"time"
+
+	"micro/ent/gen"
+)
+
 type RespondCsrf struct {
 	CsrfToken string `json:"csrf_token"`
 }
+
+type LoginResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
+
+type EnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func sessionResponses(sessions []*gen.Session, currentID string) []*SessionResponse {
+	res := make([]*SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, &SessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.Expiry,
+			Current:    s.ID == currentID,
+		})
+	}
+	return res
+}
+
+type PersonalTokenResponse struct {
+	ID         uint64     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Token is only shown once, when it is created.
+	Token string `json:"token,omitempty"`
+}
+
+func personalTokenResponse(t *gen.PersonalToken) *PersonalTokenResponse {
+	return &PersonalTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func personalTokenResponses(tokens []*gen.PersonalToken) []*PersonalTokenResponse {
+	res := make([]*PersonalTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, personalTokenResponse(t))
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package authentication
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"micro/internal/middleware"
+	"micro/internal/utility/respond"
+	"micro/third_party/postgresstore"
+)
+
+// Sessions lists the sessions of the logged-in user, such as one for each
+// device they logged in from. The session of the request is marked current.
+func (h *Handler) Sessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	sessions, err := h.repo.Sessions(r.Context(), userID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, sessionResponses(sessions, h.currentSession(r)))
+}
+
+// RevokeSession logs the user out of one of their sessions. Revoking the
+// current session logs out like Logout does.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	id := chi.URLParam(r, "sessionID")
+	if id == h.currentSession(r) {
+		if err := h.session.Destroy(r.Context()); err != nil {
+			respond.Fail(w, r, err)
+			return
+		}
+		respond.Status(w, http.StatusNoContent)
+		return
+	}
+
+	if err := h.repo.RevokeSession(r.Context(), userID, id); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// RevokeOtherSessions logs the user out everywhere else, keeping the current
+// session.
+func (h *Handler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return
+	}
+
+	if _, err := h.repo.RevokeOtherSessions(r.Context(), userID, h.currentSession(r)); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// currentSession is the ID of the session of the request, as it is known in
+// the sessions table.
+func (h *Handler) currentSession(r *http.Request) string {
+	return postgresstore.ID(h.session.Token(r.Context()))
+}
@@ -0,0 +1,123 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/gmhafiz/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/ent/gen"
+	"micro/internal/middleware"
+	"micro/third_party/postgresstore"
+	"micro/third_party/validate"
+)
+
+func TestHandler_Sessions(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword}
+
+	// Another device of the user, and a session of someone else.
+	now := time.Now()
+	other := &gen.Session{ID: "0123456789abcdef", UserID: &u.ID, UserAgent: "phone", CreatedAt: now, LastSeenAt: now, Expiry: now.Add(time.Hour)}
+	session := scs.New()
+	var sessions []*gen.Session
+
+	repo := twoFactorRepo(t, u)
+	repo.SessionsFunc = func(ctx context.Context, userID uint64) ([]*gen.Session, error) {
+		assert.Equal(t, u.ID, userID)
+		return sessions, nil
+	}
+	repo.RevokeSessionFunc = func(ctx context.Context, userID uint64, id string) error {
+		assert.Equal(t, u.ID, userID)
+		for i, s := range sessions {
+			if s.ID == id {
+				sessions = append(sessions[:i], sessions[i+1:]...)
+				return nil
+			}
+		}
+		return ErrSessionNotFound
+	}
+	repo.RevokeOtherSessionsFunc = func(ctx context.Context, userID uint64, keepID string) (int, error) {
+		var kept []*gen.Session
+		for _, s := range sessions {
+			if s.ID == keepID {
+				kept = append(kept, s)
+			}
+		}
+		n := len(sessions) - len(kept)
+		sessions = kept
+		return n, nil
+	}
+
+	h := NewHandler(session, validate.New(), repo, &mailbox{}, testAccount, nil, nil, nil)
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	router.Post("/api/v1/login", h.Login)
+	router.Get("/api/v1/restricted/sessions", h.Sessions)
+	router.Delete("/api/v1/restricted/sessions/others", h.RevokeOtherSessions)
+	router.Delete("/api/v1/restricted/sessions/{sessionID}", h.RevokeSession)
+	c := &client{t: t, router: router, user: u}
+
+	ww := c.do(http.MethodGet, "/api/v1/restricted/sessions", "")
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusOK, ww.Code)
+	current := postgresstore.ID(c.cookie.Value)
+	sessions = []*gen.Session{
+		{ID: current, UserID: &u.ID, UserAgent: "laptop", CreatedAt: now, LastSeenAt: now, Expiry: now.Add(time.Hour)},
+		other,
+	}
+
+	ww = c.do(http.MethodGet, "/api/v1/restricted/sessions", "")
+	assert.Equal(t, http.StatusOK, ww.Code)
+	var listed []*SessionResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&listed))
+	assert.Len(t, listed, 2)
+	assert.Equal(t, current, listed[0].ID)
+	assert.True(t, listed[0].Current)
+	assert.Equal(t, "phone", listed[1].UserAgent)
+	assert.False(t, listed[1].Current)
+
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/sessions/fedcba9876543210", "")
+	assert.Equal(t, http.StatusNotFound, ww.Code)
+
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/sessions/"+other.ID, "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Len(t, sessions, 1)
+
+	sessions = append(sessions, other)
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/sessions/others", "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, current, sessions[0].ID)
+
+	// Revoking the current session logs out.
+	ww = c.do(http.MethodDelete, "/api/v1/restricted/sessions/"+current, "")
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	ww = c.do(http.MethodGet, "/api/v1/restricted/sessions", "")
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+}
+
+func TestHandler_ForceLogout_NotLoggedIn(t *testing.T) {
+	repo := &RepoMock{
+		LogoutFunc: func(ctx context.Context, userID uint64) (bool, error) {
+			assert.Equal(t, uint64(2), userID)
+			return false, ErrNotLoggedIn
+		},
+	}
+
+	h := NewHandler(scs.New(), validate.New(), repo, &mailbox{}, testAccount, nil, nil, nil)
+	router := chi.NewRouter()
+	router.Post("/api/v1/logout/{userID}", h.ForceLogout)
+
+	ww := post(router, "/api/v1/logout/2", "")
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+}
@@ -0,0 +1,186 @@
+package authentication
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"micro/ent/gen"
+	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/respond"
+	"micro/third_party/oauth"
+)
+
+// A login at an external provider waits in the session for the user to come
+// back under these keys.
+const (
+	keyOAuthProvider = "oauth_provider"
+	keyOAuthState    = "oauth_state"
+	keyOAuthNonce    = "oauth_nonce"
+	keyOAuthVerifier = "oauth_verifier"
+	keyOAuthAt       = "oauth_at"
+
+	oauthExpiry = 10 * time.Minute
+)
+
+var (
+	ErrUnknownProvider    = apperror.NotFound("the provider is not supported")
+	ErrOAuthFailed        = apperror.Unauthorized("logging in with the provider failed, try again")
+	ErrUnverifiedIdentity = apperror.Forbidden("verify your email address with the provider before logging in")
+	ErrUnverifiedAccount  = apperror.Conflict("an account has this email address, log in to it and link the provider from there")
+	ErrIdentityLinked     = apperror.Conflict("the account at the provider is linked to another user")
+)
+
+// OAuthLogin sends the user to log in at the provider, which sends them back
+// to OAuthCallback.
+func (h *Handler) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := h.providers[name]
+	if !ok {
+		respond.Fail(w, r, ErrUnknownProvider)
+		return
+	}
+
+	ctx := r.Context()
+
+	var values [3]string
+	for i := range values {
+		v, err := oauth.NewVerifier()
+		if err != nil {
+			respond.Fail(w, r, err)
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oauth.Challenge(verifier))
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	h.session.Put(ctx, keyOAuthProvider, name)
+	h.session.Put(ctx, keyOAuthState, state)
+	h.session.Put(ctx, keyOAuthNonce, nonce)
+	h.session.Put(ctx, keyOAuthVerifier, verifier)
+	h.session.Put(ctx, keyOAuthAt, time.Now().Unix())
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OAuthCallback logs in the user that the provider sent back, the same way
+// as Login does after the password matches. The user is linked to an account
+// with the same email address, or registered, when they log in with the
+// provider for the first time. A user who is already logged in links the
+// provider to their account instead.
+func (h *Handler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := h.providers[name]
+	if !ok {
+		respond.Fail(w, r, ErrUnknownProvider)
+		return
+	}
+
+	ctx := r.Context()
+
+	// The login can only be completed once, in the session that started it.
+	state := h.session.PopString(ctx, keyOAuthState)
+	nonce := h.session.PopString(ctx, keyOAuthNonce)
+	verifier := h.session.PopString(ctx, keyOAuthVerifier)
+	started := h.session.PopString(ctx, keyOAuthProvider)
+	at := time.Unix(h.session.GetInt64(ctx, keyOAuthAt), 0)
+	h.session.Remove(ctx, keyOAuthAt)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		slog.InfoContext(ctx, "login at provider failed", "provider", name, "error", query.Get("error"))
+		respond.Fail(w, r, ErrOAuthFailed)
+		return
+	}
+	if state == "" || started != name || time.Since(at) > oauthExpiry ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		respond.Fail(w, r, ErrOAuthFailed)
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, query.Get("code"), verifier, nonce)
+	if err != nil {
+		slog.ErrorContext(ctx, "exchanging code", "provider", name, "error", err)
+		respond.Fail(w, r, ErrOAuthFailed)
+		return
+	}
+
+	if userID, ok := ctx.Value(middleware.KeyID).(uint64); ok {
+		if err := h.linkIdentity(ctx, userID, identity); err != nil {
+			respond.Fail(w, r, err)
+			return
+		}
+		respond.Status(w, http.StatusNoContent)
+		return
+	}
+
+	user, err := h.identityUser(ctx, identity)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	h.logIn(w, r, user)
+}
+
+// linkIdentity lets the logged-in user log in with the identity too. The
+// email address at the provider does not matter, as the user has shown they
+// own both accounts.
+func (h *Handler) linkIdentity(ctx context.Context, userID uint64, identity *oauth.Identity) error {
+	user, err := h.repo.UserByIdentity(ctx, identity.Provider, identity.Subject)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return h.repo.LinkIdentity(ctx, userID, identity)
+	case err != nil:
+		return err
+	case user.ID != userID:
+		return ErrIdentityLinked
+	}
+
+	return nil
+}
+
+// identityUser is the user linked to the identity. An identity that is not
+// linked yet is linked by its email address, which both the provider and the
+// account must have verified, as anyone can claim an address they do not
+// own. An unverified account may have been registered by someone else,
+// waiting for its owner to link their identity to it.
+func (h *Handler) identityUser(ctx context.Context, identity *oauth.Identity) (*gen.User, error) {
+	user, err := h.repo.UserByIdentity(ctx, identity.Provider, identity.Subject)
+	if !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrUnverifiedIdentity
+	}
+
+	user, err = h.repo.UserByEmail(ctx, identity.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		return h.repo.RegisterIdentity(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.VerifiedAt == nil {
+		return nil, ErrUnverifiedAccount
+	}
+
+	if err := h.repo.LinkIdentity(ctx, user.ID, identity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,261 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gmhafiz/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/ent/gen"
+	"micro/internal/middleware"
+	"micro/third_party/oauth"
+	"micro/third_party/oauth/oauthtest"
+	"micro/third_party/validate"
+)
+
+// identityRepo keeps users and their identities in memory.
+func identityRepo(users ...*gen.User) *RepoMock {
+	identities := map[string]uint64{}
+	byEmail := func(email string) *gen.User {
+		for _, u := range users {
+			if strings.EqualFold(u.Email, email) {
+				return u
+			}
+		}
+		return nil
+	}
+
+	return &RepoMock{
+		UserByIdentityFunc: func(ctx context.Context, provider string, subject string) (*gen.User, error) {
+			userID, ok := identities[provider+":"+subject]
+			if !ok {
+				return nil, ErrUserNotFound
+			}
+			for _, u := range users {
+				if u.ID == userID {
+					return u, nil
+				}
+			}
+			return nil, ErrUserNotFound
+		},
+		UserByEmailFunc: func(ctx context.Context, email string) (*gen.User, error) {
+			if u := byEmail(email); u != nil {
+				return u, nil
+			}
+			return nil, ErrUserNotFound
+		},
+		LinkIdentityFunc: func(ctx context.Context, userID uint64, identity *oauth.Identity) error {
+			identities[identity.Provider+":"+identity.Subject] = userID
+			return nil
+		},
+		RegisterIdentityFunc: func(ctx context.Context, identity *oauth.Identity) (*gen.User, error) {
+			if byEmail(identity.Email) != nil {
+				return nil, ErrEmailNotAvailable
+			}
+			now := time.Now()
+			u := &gen.User{ID: uint64(len(users) + 1), FirstName: identity.Name, Email: identity.Email, VerifiedAt: &now}
+			users = append(users, u)
+			identities[identity.Provider+":"+identity.Subject] = u.ID
+			return u, nil
+		},
+	}
+}
+
+// socialLogin logs in at the provider with the session of the client, and
+// returns the response of the callback.
+func socialLogin(c *client, query func(url.Values)) (*http.Response, error) {
+	ww := c.do(http.MethodGet, "/api/v1/oauth/mock", "")
+	if ww.Code != http.StatusFound {
+		return ww.Result(), nil
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := noRedirect.Get(ww.Header().Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+	q := callback.Query()
+	if query != nil {
+		query(q)
+	}
+
+	return c.do(http.MethodGet, callback.Path+"?"+q.Encode(), "").Result(), nil
+}
+
+func TestHandler_OAuth(t *testing.T) {
+	provider := oauthtest.NewProvider()
+	defer provider.Close()
+
+	now := time.Now()
+	jane := &gen.User{ID: 1, Email: "jane@example.com", VerifiedAt: &now}
+	totpAt := time.Now()
+	john := &gen.User{ID: 2, Email: "john@example.com", VerifiedAt: &now, TotpEnabledAt: &totpAt}
+	// Registered by someone who does not own the address, or not verified yet.
+	bob := &gen.User{ID: 3, Email: "bob@example.com"}
+	repo := identityRepo(jane, john, bob)
+	repo.LoginFunc = func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+		return bob, req.Email == bob.Email && req.Password == "highEntropyPassword", nil
+	}
+
+	session := scs.New()
+	providers := map[string]oauth.Provider{
+		"mock": oauth.NewOIDC("mock", provider.Config(), "http://localhost:3080/api/v1/oauth/mock/callback"),
+	}
+	h := NewHandler(session, validate.New(), repo, &mailbox{}, testAccount, nil, providers, nil)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	router.Post("/api/v1/login", h.Login)
+	router.Get("/api/v1/oauth/{provider}", h.OAuthLogin)
+	router.Get("/api/v1/oauth/{provider}/callback", h.OAuthCallback)
+	router.Get("/api/v1/restricted/me", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := session.Get(r.Context(), string(middleware.KeyID)).(uint64)
+		_, _ = w.Write([]byte(strings.Repeat("x", int(userID))))
+	})
+	loggedIn := func(c *client) int {
+		return c.do(http.MethodGet, "/api/v1/restricted/me", "").Body.Len()
+	}
+
+	t.Run("unknown provider", func(t *testing.T) {
+		c := &client{t: t, router: router}
+		ww := c.do(http.MethodGet, "/api/v1/oauth/another", "")
+		assert.Equal(t, http.StatusNotFound, ww.Code)
+	})
+
+	t.Run("links an existing user by email", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "Jane@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 1, loggedIn(c))
+
+		// The identity is linked, so a change of email at the provider keeps
+		// logging in the same user.
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "jane@example.org"})
+		c = &client{t: t, router: router}
+		res, err = socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 1, loggedIn(c))
+	})
+
+	t.Run("registers a new user", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true, Name: "Alice"})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 4, loggedIn(c))
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "impostor", Email: "jane@example.com", EmailVerified: false})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Zero(t, loggedIn(c))
+	})
+
+	t.Run("two-factor authentication", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "john-at-provider", Email: "john@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		assert.Zero(t, loggedIn(c))
+	})
+
+	t.Run("state does not match", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "jane@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, func(q url.Values) { q.Set("state", "forged") })
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Zero(t, loggedIn(c))
+	})
+
+	t.Run("another session", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "jane@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		ww := c.do(http.MethodGet, "/api/v1/oauth/mock", "")
+		assert.Equal(t, http.StatusFound, ww.Code)
+		authURL, err := url.Parse(ww.Header().Get("Location"))
+		assert.Nil(t, err)
+
+		// The callback without the session that started the login.
+		other := &client{t: t, router: router}
+		ww = other.do(http.MethodGet, "/api/v1/oauth/mock/callback?code=x&state="+authURL.Query().Get("state"), "")
+		assert.Equal(t, http.StatusUnauthorized, ww.Code)
+		assert.Zero(t, loggedIn(other))
+	})
+
+	t.Run("state is used once", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "jane@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		var replay string
+		res, err := socialLogin(c, func(q url.Values) { replay = q.Encode() })
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		ww := c.do(http.MethodGet, "/api/v1/oauth/mock/callback?"+replay, "")
+		assert.Equal(t, http.StatusUnauthorized, ww.Code)
+	})
+
+	t.Run("unverified account", func(t *testing.T) {
+		provider.LogIn(oauthtest.User{Subject: "bob-at-provider", Email: "bob@example.com", EmailVerified: true})
+		c := &client{t: t, router: router}
+
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.Zero(t, loggedIn(c))
+	})
+
+	t.Run("logged in user links the provider", func(t *testing.T) {
+		c := &client{t: t, router: router}
+		ww := c.post("/api/v1/login", `{"email": "bob@example.com", "password": "highEntropyPassword"}`)
+		assert.Equal(t, http.StatusOK, ww.Code)
+
+		// Not to an identity of someone else.
+		provider.LogIn(oauthtest.User{Subject: "jane-at-provider", Email: "jane@example.com", EmailVerified: true})
+		res, err := socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.Equal(t, 3, loggedIn(c))
+
+		provider.LogIn(oauthtest.User{Subject: "bob-at-provider", Email: "bob@example.org"})
+		res, err = socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, 3, loggedIn(c))
+
+		c = &client{t: t, router: router}
+		res, err = socialLogin(c, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 3, loggedIn(c))
+	})
+}
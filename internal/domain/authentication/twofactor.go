@@ -0,0 +1,346 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"micro/ent/gen"
+	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/respond"
+	"micro/internal/utility/totp"
+)
+
+// A login that passed the password check waits in the session for the second
+// factor under these keys.
+const (
+	keyPendingUser     = "two_factor_user_id"
+	keyPendingAt       = "two_factor_at"
+	keyPendingAttempts = "two_factor_attempts"
+
+	pendingExpiry      = 5 * time.Minute
+	maxPendingAttempts = 5
+
+	recoveryCodeCount = 10
+	// recoveryAlphabet leaves out characters that are easily confused, such
+	// as 0 and o. It has 32 characters so that every byte maps evenly.
+	recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrInvalidCode       = apperror.Validation("the code is incorrect")
+	ErrNoPendingLogin    = apperror.Unauthorized("log in with your email and password first")
+	ErrTwoFactorDisabled = apperror.Conflict("two-factor authentication is not enabled")
+	ErrNoEnrollment      = apperror.Conflict("start enrolling in two-factor authentication first")
+)
+
+// LoginTwoFactor completes a login that is waiting for the second factor,
+// with a code from the authenticator app or a recovery code. The pending
+// login is dropped after a few wrong codes, or when it is too old. Wrong codes
+// also count as failed logins of the account, see Lockout.
+func (h *Handler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, ok := h.session.Get(ctx, keyPendingUser).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrNoPendingLogin)
+		return
+	}
+	if time.Since(time.Unix(h.session.GetInt64(ctx, keyPendingAt), 0)) > pendingExpiry {
+		h.clearPending(ctx)
+		respond.Fail(w, r, ErrNoPendingLogin)
+		return
+	}
+
+	user, err := h.repo.User(ctx, userID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	// Codes are counted and refused like passwords.
+	ip := middleware.ClientIP(r)
+	wait, err := h.lockout.Attempt(ctx, user.Email, ip)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	if wait > 0 {
+		retryAfter(w, wait)
+		respond.Fail(w, r, ErrInvalidCode)
+		return
+	}
+
+	valid, err := h.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	if !valid {
+		if err := h.lockout.Fail(ctx, user.Email, user); err != nil {
+			slog.ErrorContext(ctx, "recording failed login", "error", err)
+		}
+
+		attempts := h.session.GetInt(ctx, keyPendingAttempts) + 1
+		if attempts >= maxPendingAttempts {
+			h.clearPending(ctx)
+		} else {
+			h.session.Put(ctx, keyPendingAttempts, attempts)
+		}
+		respond.Fail(w, r, ErrInvalidCode)
+		return
+	}
+	if err := h.lockout.Succeed(ctx, user.Email, ip); err != nil {
+		slog.ErrorContext(ctx, "resetting failed logins", "user_id", user.ID, "error", err)
+	}
+
+	if err := h.session.RenewToken(ctx); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	h.clearPending(ctx)
+	h.session.Put(ctx, string(middleware.KeyID), user.ID)
+
+	respond.Status(w, http.StatusOK)
+}
+
+// Enroll starts enrolling the logged-in user in two-factor authentication.
+// The secret is added to an authenticator app, usually by scanning the URI
+// as a QR code, and confirmed with EnableTwoFactor.
+func (h *Handler) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		respond.Fail(w, r, ErrTwoFactorEnabled)
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	if err := h.repo.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, &EnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(h.account.TOTPIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor confirms the enrollment with a code from the authenticator
+// app. The recovery codes in the response are not shown again.
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		respond.Fail(w, r, ErrTwoFactorEnabled)
+		return
+	}
+	if user.TotpSecret == "" {
+		respond.Fail(w, r, ErrNoEnrollment)
+		return
+	}
+
+	step, valid := totp.Validate(user.TotpSecret, req.Code, time.Now())
+	if !valid {
+		respond.Fail(w, r, ErrInvalidCode)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	if err := h.repo.EnableTOTP(r.Context(), user.ID, step, hashes); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, &RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off, given a current code
+// or a recovery code.
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DisableTOTP(r.Context(), user.ID); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, given a current code
+// or a recovery code.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	if err := h.repo.ReplaceRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, &RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*gen.User, bool) {
+	userID, ok := r.Context().Value(middleware.KeyID).(uint64)
+	if !ok {
+		respond.Fail(w, r, ErrLoginRequired)
+		return nil, false
+	}
+
+	user, err := h.repo.User(r.Context(), userID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// confirmSecondFactor is the logged-in user, once they have proven that they
+// still hold their second factor. Wrong codes count as failed logins of the
+// account, as in LoginTwoFactor, so that a session cannot guess them.
+func (h *Handler) confirmSecondFactor(w http.ResponseWriter, r *http.Request) (*gen.User, bool) {
+	var req TwoFactorRequest
+	if !h.decode(w, r, &req) {
+		return nil, false
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.TotpEnabledAt == nil {
+		respond.Fail(w, r, ErrTwoFactorDisabled)
+		return nil, false
+	}
+
+	ctx := r.Context()
+	ip := middleware.ClientIP(r)
+	wait, err := h.lockout.Attempt(ctx, user.Email, ip)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return nil, false
+	}
+	if wait > 0 {
+		retryAfter(w, wait)
+		respond.Fail(w, r, ErrInvalidCode)
+		return nil, false
+	}
+
+	valid, err := h.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return nil, false
+	}
+	if !valid {
+		if err := h.lockout.Fail(ctx, user.Email, user); err != nil {
+			slog.ErrorContext(ctx, "recording failed login", "error", err)
+		}
+		respond.Fail(w, r, ErrInvalidCode)
+		return nil, false
+	}
+	if err := h.lockout.Succeed(ctx, user.Email, ip); err != nil {
+		slog.ErrorContext(ctx, "resetting failed logins", "user_id", user.ID, "error", err)
+	}
+
+	return user, true
+}
+
+// checkSecondFactor accepts a code from the authenticator app that has not
+// been used before, or else an unused recovery code, which is used up.
+func (h *Handler) checkSecondFactor(ctx context.Context, user *gen.User, code string) (bool, error) {
+	if user.TotpEnabledAt == nil {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(user.TotpSecret, code, time.Now()); ok {
+		return h.repo.UseTOTPStep(ctx, user.ID, step)
+	}
+
+	return h.repo.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+}
+
+func (h *Handler) clearPending(ctx context.Context) {
+	h.session.Remove(ctx, keyPendingUser)
+	h.session.Remove(ctx, keyPendingAt)
+	h.session.Remove(ctx, keyPendingAttempts)
+}
+
+// newRecoveryCodes makes codes such as k3mvq-8tzrw, and the hashes that are
+// stored in their place. Each code has 50 bits of entropy, so a fast hash
+// is enough.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	b := make([]byte, 10)
+	for range recoveryCodeCount {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := make([]byte, 0, 11)
+		for i, c := range b {
+			if i == 5 {
+				code = append(code, '-')
+			}
+			code = append(code, recoveryAlphabet[c%32])
+		}
+
+		codes = append(codes, string(code))
+		hashes = append(hashes, hashRecoveryCode(string(code)))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, which people tend to get
+// wrong when typing a code.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,361 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/gmhafiz/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/ent/gen"
+	"micro/internal/domain/audit"
+	"micro/internal/middleware"
+	"micro/internal/utility/totp"
+	"micro/third_party/validate"
+)
+
+// twoFactorRepo keeps a single user in memory.
+func twoFactorRepo(t *testing.T, u *gen.User) *RepoMock {
+	codes := map[string]bool{}
+
+	replace := func(hashes []string) {
+		clear(codes)
+		for _, hash := range hashes {
+			codes[hash] = true
+		}
+	}
+
+	return &RepoMock{
+		LoginFunc: func(ctx context.Context, req LoginRequest) (*gen.User, bool, error) {
+			match, err := argon2id.ComparePasswordAndHash(req.Password, u.Password)
+			assert.Nil(t, err)
+			return u, match, nil
+		},
+		UserFunc: func(ctx context.Context, userID uint64) (*gen.User, error) {
+			assert.Equal(t, u.ID, userID)
+			return u, nil
+		},
+		SetTOTPSecretFunc: func(ctx context.Context, userID uint64, secret string) error {
+			u.TotpSecret = secret
+			return nil
+		},
+		EnableTOTPFunc: func(ctx context.Context, userID uint64, step int64, codeHashes []string) error {
+			now := time.Now()
+			u.TotpEnabledAt = &now
+			u.TotpLastStep = step
+			replace(codeHashes)
+			return nil
+		},
+		DisableTOTPFunc: func(ctx context.Context, userID uint64) error {
+			u.TotpSecret, u.TotpEnabledAt, u.TotpLastStep = "", nil, 0
+			replace(nil)
+			return nil
+		},
+		UseTOTPStepFunc: func(ctx context.Context, userID uint64, step int64) (bool, error) {
+			if step <= u.TotpLastStep {
+				return false, nil
+			}
+			u.TotpLastStep = step
+			return true, nil
+		},
+		ReplaceRecoveryCodesFunc: func(ctx context.Context, userID uint64, codeHashes []string) error {
+			replace(codeHashes)
+			return nil
+		},
+		UseRecoveryCodeFunc: func(ctx context.Context, userID uint64, codeHash string) (bool, error) {
+			used := codes[codeHash]
+			delete(codes, codeHash)
+			return used, nil
+		},
+	}
+}
+
+// client keeps the session cookie across requests, and makes codes with the
+// secret of the user.
+type client struct {
+	t      *testing.T
+	router http.Handler
+	user   *gen.User
+	cookie *http.Cookie
+	// csrfToken is sent with the next request only, see withCSRF.
+	csrfToken string
+}
+
+func (c *client) post(path, body string) *httptest.ResponseRecorder {
+	return c.do(http.MethodPost, path, body)
+}
+
+func (c *client) do(method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRequest(method, path, strings.NewReader(body))
+	if c.cookie != nil {
+		rr.AddCookie(c.cookie)
+	}
+	if c.csrfToken != "" {
+		rr.Header.Set(middleware.CSRFHeader, c.csrfToken)
+		c.csrfToken = ""
+	}
+	ww := httptest.NewRecorder()
+	c.router.ServeHTTP(ww, rr)
+
+	for _, cookie := range ww.Result().Cookies() {
+		if cookie.Name == "session" {
+			c.cookie = cookie
+		}
+	}
+	return ww
+}
+
+// withCSRF gets a csrf token of the session for the next request, which
+// needs one to change data under /api/v1/restricted.
+func (c *client) withCSRF() *client {
+	c.t.Helper()
+	ww := c.do(http.MethodGet, "/api/v1/restricted/csrf", "")
+	assert.Equal(c.t, http.StatusOK, ww.Code)
+
+	var res RespondCsrf
+	assert.Nil(c.t, json.NewDecoder(ww.Body).Decode(&res))
+	c.csrfToken = res.CsrfToken
+	return c
+}
+
+func (c *client) code(step int64) string {
+	c.t.Helper()
+	code, err := totp.Code(c.user.TotpSecret, step)
+	assert.Nil(c.t, err)
+	return `{"code": "` + code + `"}`
+}
+
+func newTwoFactorRouter(repo Repo, lockout *Lockout) *chi.Mux {
+	session := scs.New()
+	h := NewHandler(session, validate.New(), repo, &mailbox{}, testAccount, lockout, nil, nil)
+
+	router := chi.NewRouter()
+	router.Use(middleware.LoadAndSave(session))
+	router.Post("/api/v1/login", h.Login)
+	router.Post("/api/v1/login/2fa", h.LoginTwoFactor)
+	router.Post("/api/v1/restricted/2fa", h.Enroll)
+	router.Post("/api/v1/restricted/2fa/enable", h.EnableTwoFactor)
+	router.Post("/api/v1/restricted/2fa/disable", h.DisableTwoFactor)
+	router.Post("/api/v1/restricted/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+	return router
+}
+
+const loginBody = `{"email": "jane@example.com", "password": "highEntropyPassword"}`
+
+func TestHandler_TwoFactor(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword}
+
+	router := newTwoFactorRouter(twoFactorRepo(t, u), nil)
+	c := &client{t: t, router: router, user: u}
+
+	// Enroll while logged in with a password only.
+	ww := c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	ww = c.post("/api/v1/restricted/2fa/enable", `{"code": "000000"}`)
+	assert.Equal(t, http.StatusConflict, ww.Code)
+
+	ww = c.post("/api/v1/restricted/2fa", "")
+	assert.Equal(t, http.StatusOK, ww.Code)
+	var enroll EnrollResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&enroll))
+	assert.Equal(t, u.TotpSecret, enroll.Secret)
+	assert.Equal(t, totp.URI("go8", "jane@example.com", enroll.Secret), enroll.URI)
+
+	step := totp.Step(time.Now())
+	ww = c.post("/api/v1/restricted/2fa/enable", c.code(step))
+	assert.Equal(t, http.StatusOK, ww.Code)
+	var recovery RecoveryCodesResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&recovery))
+	assert.Len(t, recovery.RecoveryCodes, recoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, recovery.RecoveryCodes[0])
+
+	ww = c.post("/api/v1/restricted/2fa", "")
+	assert.Equal(t, http.StatusConflict, ww.Code)
+
+	// The password alone no longer logs in.
+	c.cookie = nil
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	var login LoginResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&login))
+	assert.True(t, login.TwoFactorRequired)
+
+	ww = c.post("/api/v1/restricted/2fa", "")
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	// The code used to enable it cannot be replayed.
+	ww = c.post("/api/v1/login/2fa", c.code(step))
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	ww = c.post("/api/v1/login/2fa", `{"code": "`+strings.ToUpper(recovery.RecoveryCodes[0])+`"}`)
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	// Logged in, and the recovery code is used up.
+	ww = c.post("/api/v1/restricted/2fa/recovery-codes", `{"code": "`+recovery.RecoveryCodes[0]+`"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	ww = c.post("/api/v1/restricted/2fa/recovery-codes", c.code(step+1))
+	assert.Equal(t, http.StatusOK, ww.Code)
+	var regenerated RecoveryCodesResponse
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&regenerated))
+	assert.NotEqual(t, recovery.RecoveryCodes, regenerated.RecoveryCodes)
+
+	ww = c.post("/api/v1/restricted/2fa/disable", `{"code": "`+recovery.RecoveryCodes[1]+`"}`)
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	ww = c.post("/api/v1/restricted/2fa/disable", `{"code": "`+regenerated.RecoveryCodes[1]+`"}`)
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Nil(t, u.TotpEnabledAt)
+
+	c.cookie = nil
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusOK, ww.Code)
+}
+
+func TestHandler_LoginTwoFactor_Attempts(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	secret, err := totp.NewSecret()
+	assert.Nil(t, err)
+	enabledAt := time.Now()
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword, TotpSecret: secret, TotpEnabledAt: &enabledAt}
+
+	router := newTwoFactorRouter(twoFactorRepo(t, u), nil)
+	c := &client{t: t, router: router, user: u}
+
+	ww := c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+
+	for range maxPendingAttempts {
+		ww = c.post("/api/v1/login/2fa", `{"code": "wrong"}`)
+		assert.Equal(t, http.StatusBadRequest, ww.Code)
+	}
+
+	// Too many wrong codes drop the pending login.
+	ww = c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	ww = c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusOK, ww.Code)
+}
+
+func TestHandler_LoginTwoFactor_Lockout(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	secret, err := totp.NewSecret()
+	assert.Nil(t, err)
+	enabledAt := time.Now()
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword, TotpSecret: secret, TotpEnabledAt: &enabledAt}
+
+	// No backoff, so that the lockout is reached without waiting, and while
+	// the second login is still pending.
+	cfg := testLockout
+	cfg.BaseDelay, cfg.MaxDelay = 0, 0
+	cfg.Threshold = maxPendingAttempts + 2
+	attempts := newMemoryAttempts()
+	recorder := &audit.RepoMock{
+		RecordFunc: func(ctx context.Context, events ...middleware.Event) error {
+			return nil
+		},
+	}
+
+	router := newTwoFactorRouter(twoFactorRepo(t, u), NewLockout(attempts, recorder, cfg))
+	c := &client{t: t, router: router, user: u}
+
+	// Wrong codes are failed logins, which the password alone does not reset.
+	failures := 0
+	for failures < cfg.Threshold {
+		ww := c.post("/api/v1/login", loginBody)
+		assert.Equal(t, http.StatusAccepted, ww.Code)
+
+		for range min(maxPendingAttempts, cfg.Threshold-failures) {
+			ww = c.post("/api/v1/login/2fa", `{"code": "wrong"}`)
+			assert.Equal(t, http.StatusBadRequest, ww.Code)
+			failures++
+		}
+		assert.Equal(t, failures, attempts.failures["account:jane@example.com"])
+	}
+
+	// Locked out, with the right code of a pending login.
+	ww := c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	assert.Equal(t, "900", ww.Header().Get("Retry-After"))
+
+	// And with the right password.
+	c.cookie = nil
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusUnauthorized, ww.Code)
+	assert.Equal(t, "900", ww.Header().Get("Retry-After"))
+
+	// Below the threshold, only the right code resets the failures.
+	attempts.failures["account:jane@example.com"] = 1
+	ww = c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	assert.Equal(t, 1, attempts.failures["account:jane@example.com"])
+	ww = c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.Zero(t, attempts.failures["account:jane@example.com"])
+}
+
+func TestHandler_DisableTwoFactor_Lockout(t *testing.T) {
+	hashedPassword, err := argon2id.CreateHash("highEntropyPassword", argon2id.DefaultParams)
+	assert.Nil(t, err)
+	secret, err := totp.NewSecret()
+	assert.Nil(t, err)
+	enabledAt := time.Now()
+	u := &gen.User{ID: 2, Email: "jane@example.com", Password: hashedPassword, TotpSecret: secret, TotpEnabledAt: &enabledAt}
+
+	cfg := testLockout
+	cfg.BaseDelay, cfg.MaxDelay = 0, 0
+	attempts := newMemoryAttempts()
+	recorder := &audit.RepoMock{
+		RecordFunc: func(ctx context.Context, events ...middleware.Event) error {
+			return nil
+		},
+	}
+
+	router := newTwoFactorRouter(twoFactorRepo(t, u), NewLockout(attempts, recorder, cfg))
+	c := &client{t: t, router: router, user: u}
+
+	ww := c.post("/api/v1/login", loginBody)
+	assert.Equal(t, http.StatusAccepted, ww.Code)
+	ww = c.post("/api/v1/login/2fa", c.code(totp.Step(time.Now())))
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	// A logged in session guessing codes is locked out like a login.
+	for range cfg.Threshold {
+		ww = c.post("/api/v1/restricted/2fa/disable", `{"code": "wrong"}`)
+		assert.Equal(t, http.StatusBadRequest, ww.Code)
+	}
+	assert.Equal(t, cfg.Threshold, attempts.failures["account:jane@example.com"])
+
+	ww = c.post("/api/v1/restricted/2fa/disable", c.code(totp.Step(time.Now())+1))
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+	assert.Equal(t, "900", ww.Header().Get("Retry-After"))
+	assert.NotNil(t, u.TotpEnabledAt)
+
+	ww = c.post("/api/v1/restricted/2fa/recovery-codes", c.code(totp.Step(time.Now())+1))
+	assert.Equal(t, http.StatusBadRequest, ww.Code)
+
+	// Below the threshold, the right code works and resets the failures.
+	attempts.failures["account:jane@example.com"] = 1
+	ww = c.post("/api/v1/restricted/2fa/disable", c.code(totp.Step(time.Now())+1))
+	assert.Equal(t, http.StatusNoContent, ww.Code)
+	assert.Zero(t, attempts.failures["account:jane@example.com"])
+	assert.Nil(t, u.TotpEnabledAt)
+}
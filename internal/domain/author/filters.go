@@ -9,21 +9,21 @@ import (
 type Filter struct {
 	Base filter.Filter
 
-	FirstName  string `json:"first_name"`
-	MiddleName string `json:"middle_name"`
-	LastName   string `json:"last_name"`
+	// Query is a full-text search in web search engine syntax, e.g.
+	// `john or jane -doe`. It matches first, middle and last names.
+	Query string `json:"q"`
 }
 
 func Filters(queries url.Values) *Filter {
 	f := filter.New(queries)
-	if queries.Has("first_name") || queries.Has("last_name") {
+	if queries.Get("q") != "" {
 		f.Search = true
+		// Searches are ranked by relevance and paged by offset only.
+		f.CursorPaging = false
 	}
 	return &Filter{
 		Base: *f,
 
-		FirstName:  queries.Get("first_name"),
-		MiddleName: queries.Get("middle_name"),
-		LastName:   queries.Get("last_name"),
+		Query: queries.Get("q"),
 	}
 }
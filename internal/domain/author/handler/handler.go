@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"go.opentelemetry.io/otel"
@@ -15,12 +17,19 @@ import (
 	"micro/internal/domain/author"
 	"micro/internal/domain/author/usecase"
 	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/export"
+	"micro/internal/utility/filter"
+	"micro/internal/utility/mergepatch"
 	"micro/internal/utility/message"
 	"micro/internal/utility/param"
 	"micro/internal/utility/respond"
 	"micro/internal/utility/validate"
 )
 
+var errIDRequired = apperror.Validation("id is required")
+
 type Handler struct {
 	useCase  usecase.Author
 	validate *validator.Validate
@@ -40,20 +49,20 @@ func NewHandler(useCase usecase.Author, v *validator.Validate) *Handler {
 // @Produce json
 // @Param Author body author.CreateRequest true "Create an author using the following format"
 // @Success 201 {object} author.GetResponse
-// @Failure 400 {string} Bad Request
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/author [post]
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	var req author.CreateRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, err)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 
 	errs := validate.Validate(h.validate, req)
 	if errs != nil {
-		respond.Errors(w, http.StatusBadRequest, errs)
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
 		return
 	}
 
@@ -61,10 +70,14 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Println(err)
 		if errors.Is(err, sql.ErrNoRows) {
-			respond.Error(w, http.StatusBadRequest, message.ErrBadRequest)
+			respond.Fail(w, r, message.ErrBadRequest)
 			return
 		}
-		respond.Error(w, http.StatusInternalServerError, err)
+		if errors.Is(err, message.ErrNoRecord) {
+			respond.Fail(w, r, apperror.Validation("one or more linked books are not found"))
+			return
+		}
+		respond.Fail(w, r, err)
 		return
 	}
 
@@ -79,11 +92,12 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 // @Param page query string false "page number"
 // @Param limit query string false "limit of result"
 // @Param offset query string false "result offset"
-// @Param first_name query string false "search by first_name"
-// @Param last_name query string false "search by last_name"
+// @Param q query string false "full-text search on names in web search syntax. E.g. john or jane -doe"
 // @Param sort query string false "sort by fields name. E.g. first_name,asc"
+// @Param cursor query string false "keyset pagination. Pass empty for the first page, then next_cursor"
 // @Success 200 {object} respond.Standard
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/author [get]
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 	tracer := otel.Tracer("")
@@ -97,33 +111,39 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 	authors, total, err := h.useCase.List(ctx, filters)
 	if err != nil {
 		slog.ErrorContext(ctx, err.Error())
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
 		return
 	}
 
+	var next string
+	if filters.Base.CursorPaging && len(authors) > 0 && len(authors) == filters.Base.Limit {
+		last := authors[len(authors)-1]
+		next = filter.NewCursor(last.CreatedAt, last.ID).Encode()
+	}
+
 	respond.Json(w, http.StatusOK, respond.Standard{
 		Data: author.Resources(authors),
-		Meta: respond.Meta{
-			Size:  len(authors),
-			Total: total,
-		},
+		Meta: respond.Paginate(w, r, filters.Base, len(authors), total, next),
 	})
 }
 
 // Get an author by its ID
 // @Summary Get an Author
-// @Description Get an author by its id.
+// @Description Get an author by its id. The ETag response header is the version of the author.
 // @Accept json
 // @Produce json
 // @Param id path int true "author ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} gen.Author
-// @Failure 400 {string} Bad Request
-// @Failure 500 {string} Internal Server Error
+// @Success 304 "Not Modified"
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/author/{id} [get]
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	authorID, err := param.UInt64(r, "id")
 	if authorID == 0 || err != nil {
-		respond.Error(w, http.StatusBadRequest, errors.New("id is required"))
+		respond.Fail(w, r, errIDRequired)
 		return
 	}
 
@@ -132,7 +152,14 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	res, err := h.useCase.Read(ctx, authorID)
 	if err != nil {
 		log.Println(err)
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
+		return
+	}
+
+	tag := etag.New(res.UpdatedAt)
+	w.Header().Set("ETag", tag)
+	if etag.NotModified(r, tag) {
+		respond.Status(w, http.StatusNotModified)
 		return
 	}
 
@@ -141,18 +168,27 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 
 // Update an author
 // @Summary Update an Author
-// @Description Update an author by its model.
+// @Description Update an author by its model. With If-Match, the author is only updated if it is still at that version.
 // @Accept json
 // @Produce json
 // @Param Author body author.UpdateRequest true "Author Request"
+// @Param If-Match header string false "ETag of the author being updated"
 // @Success 200 {object} gen.Author
-// @Failure 400 {string} Bad Request
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/author/{id} [put]
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := param.UInt64(r, "id")
 	if id == 0 || err != nil {
-		respond.Error(w, http.StatusBadRequest, errors.New("id is required"))
+		respond.Fail(w, r, errIDRequired)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
 		return
 	}
 
@@ -161,47 +197,201 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	var req author.UpdateRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, err)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 	req.ID = id
+	req.IfMatch = ifMatch
 
 	updated, err := h.useCase.Update(ctx, &req)
 	if err != nil {
 		log.Println(err)
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag.New(updated.UpdatedAt))
+	respond.Json(w, http.StatusOK, author.Resource(updated))
+}
+
+// Patch updates some fields of an author
+// @Summary Patch an Author
+// @Description Update the fields of an author that are present in a JSON merge patch (RFC 7396). A null member clears that field. The fields present are validated like an update.
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "author ID"
+// @Param Author body author.UpdateRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the author being patched"
+// @Success 200 {object} author.GetResponse
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 415 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/author/{id} [patch]
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := param.UInt64(r, "id")
+	if id == 0 || err != nil {
+		respond.Fail(w, r, errIDRequired)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
+		return
+	}
+
+	if !mergepatch.Accepts(r) {
+		respond.Error(w, http.StatusUnsupportedMediaType, message.ErrMediaType)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), middleware.CacheURL, r.URL.String())
+
+	var updated *author.Schema
+	for attempt := 1; ; attempt++ {
+		updated, err = h.patch(ctx, id, patch, ifMatch)
+		// Without If-Match, the client did not ask for a version, so the
+		// patch is merged again into an author that was changed meanwhile.
+		if ifMatch != nil || attempt == maxPatchAttempts || !errors.Is(err, message.ErrModified) {
+			break
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		respond.Fail(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag.New(updated.UpdatedAt))
 	respond.Json(w, http.StatusOK, author.Resource(updated))
 }
 
+// maxPatchAttempts is how many times a patch without If-Match is merged into
+// an author that keeps being modified before giving up.
+const maxPatchAttempts = 3
+
+// patch merges patch into the author as it is now and updates it. The update
+// only goes ahead if the author is still at the version that was merged into,
+// so a concurrent update is never overwritten.
+func (h *Handler) patch(ctx context.Context, id uint64, patch []byte, ifMatch []time.Time) (*author.Schema, error) {
+	current, err := h.useCase.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(&author.UpdateRequest{
+		FirstName:  current.FirstName,
+		MiddleName: current.MiddleName,
+		LastName:   current.LastName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		return nil, message.ErrNotMergePatch
+	}
+
+	var req author.UpdateRequest
+	if err = json.Unmarshal(merged, &req); err != nil {
+		return nil, message.ErrInvalidJSON
+	}
+	req.ID = id
+	req.IfMatch = ifMatch
+	if req.IfMatch == nil {
+		req.IfMatch = []time.Time{current.UpdatedAt}
+	}
+
+	// Only the fields in the patch are validated, so that a value stored
+	// before a rule existed does not block changes to other fields.
+	members, err := mergepatch.Members(patch)
+	if err != nil {
+		return nil, message.ErrNotMergePatch
+	}
+	errs := validate.ValidateFields(h.validate, req, members)
+	if errs != nil {
+		return nil, apperror.Validation(message.ValidationFailed, errs...)
+	}
+
+	return h.useCase.Update(ctx, &req)
+}
+
 // Delete an author by its ID
 // @Summary Delete an Author
-// @Description Delete an author by its id.
+// @Description Delete an author by its id. With If-Match, the author is only deleted if it is still at that version.
 // @Accept json
 // @Produce json
 // @Param id path int true "author ID"
+// @Param If-Match header string false "ETag of the author being deleted"
 // @Success 200 "Ok"
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/author/{id} [delete]
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := param.UInt64(r, "id")
 	if id == 0 || err != nil {
-		respond.Error(w, http.StatusBadRequest, errors.New("id is required"))
+		respond.Fail(w, r, errIDRequired)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
 		return
 	}
 
 	ctx := context.WithValue(r.Context(), middleware.CacheURL, r.URL.String())
 
-	err = h.useCase.Delete(ctx, id)
+	err = h.useCase.Delete(ctx, id, ifMatch...)
 	if err != nil {
 		log.Println(err)
-		if errors.Is(err, message.ErrNoRecord) {
-			respond.Error(w, http.StatusBadRequest, err)
+		respond.Fail(w, r, err)
+		return
+	}
+}
+
+// Export streams every author
+// @Summary Export Authors
+// @Description Stream every author that is not deleted as CSV or newline delimited JSON. Authors are sent while they are read from the database.
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or ndjson"
+// @Success 200 {array} author.ExportRes
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/author/export [get]
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	out, err := export.NewWriter(w, r.URL.Query().Get("format"), "authors", author.ExportColumns)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	err = h.useCase.Export(r.Context(), func(a *author.Schema) error {
+		return out.Write(author.ExportResource(a))
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		if out.Started() {
+			// The status is sent already, so the client only sees a
+			// truncated export.
+			log.Println(err)
 			return
 		}
-		respond.Error(w, http.StatusInternalServerError, err)
-		return
+		w.Header().Del("Content-Disposition")
+		respond.Fail(w, r, err)
 	}
 }
@@ -10,18 +10,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 
 	"micro/internal/domain/author"
 	"micro/internal/domain/author/usecase"
 	"micro/internal/domain/book"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/filter"
 	"micro/internal/utility/message"
 	"micro/internal/utility/respond"
+	"micro/third_party/validate"
 )
 
 var (
@@ -29,7 +33,21 @@ var (
 )
 
 type Errs struct {
-	Message []string `json:"message"`
+	Errors []apperror.FieldError `json:"errors"`
+}
+
+// detail is the problem detail that a handler responds with for err. Internal
+// errors are logged, but not exposed to the client.
+func detail(status int, err error) string {
+	if status == http.StatusInternalServerError || err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// allow lets every request through the guard of write endpoints.
+func allow(next http.Handler) http.Handler {
+	return next
 }
 
 func TestHandler_Create(t *testing.T) {
@@ -113,7 +131,9 @@ func TestHandler_Create(t *testing.T) {
 				},
 				response: &author.GetResponse{},
 				Errs: Errs{
-					Message: []string{"FirstName is required with type string"},
+					Errors: []apperror.FieldError{
+						{Field: "first_name", Rule: "required", Message: "first_name is a required field"},
+					},
 				},
 				status: http.StatusBadRequest,
 			},
@@ -211,7 +231,7 @@ func TestHandler_Create(t *testing.T) {
 			ww := httptest.NewRecorder()
 
 			router := chi.NewRouter()
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.AuthorMock{
 				CreateFunc: func(ctx context.Context, a *author.CreateRequest) (*author.Schema, error) {
@@ -219,7 +239,7 @@ func TestHandler_Create(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 			h.Create(ww, rr)
 
 			if test.args.CreateRequest == nil {
@@ -244,14 +264,12 @@ func TestHandler_Create(t *testing.T) {
 					assert.Nil(t, err)
 
 					errStruct := struct {
-						Message string `json:"message"`
-					}{
-						Message: string(b),
-					}
+						Message string `json:"detail"`
+					}{}
 
 					err = json.Unmarshal(b, &errStruct)
 					assert.Nil(t, err)
-					assert.Equal(t, test.want.err.Error(), errStruct.Message)
+					assert.Equal(t, detail(ww.Code, test.want.err), errStruct.Message)
 				}
 			}
 		})
@@ -269,9 +287,12 @@ func TestHandler_List(t *testing.T) {
 			total   int
 			error
 		}
-		status int
-		size   int
-		total  int
+		status     int
+		size       int
+		total      int
+		nextCursor bool
+		next       string
+		prev       string
 		error
 	}
 
@@ -335,6 +356,81 @@ func TestHandler_List(t *testing.T) {
 				total:  1,
 			},
 		},
+		{
+			name: "middle page links to its neighbours",
+			args: args{
+				uri: "/api/v1/author?page=2&limit=1",
+			},
+			want: want{
+				usecase: struct {
+					authors []*author.Schema
+					total   int
+					error
+				}{
+					authors: []*author.Schema{
+						{
+							ID:        2,
+							FirstName: "First",
+							LastName:  "Last",
+						},
+					},
+					total: 3,
+					error: nil,
+				},
+				status: http.StatusOK,
+				size:   1,
+				total:  3,
+				next:   "/api/v1/author?limit=1&page=3",
+				prev:   "/api/v1/author?limit=1&page=1",
+			},
+		},
+		{
+			name: "cursor paging returns next cursor on a full page",
+			args: args{
+				uri: "/api/v1/author?cursor=&limit=1",
+			},
+			want: want{
+				usecase: struct {
+					authors []*author.Schema
+					total   int
+					error
+				}{
+					authors: []*author.Schema{
+						{
+							ID:        2,
+							FirstName: "First",
+							LastName:  "Last",
+							CreatedAt: time.Now(),
+						},
+					},
+					total: 2,
+					error: nil,
+				},
+				status:     http.StatusOK,
+				size:       1,
+				total:      2,
+				nextCursor: true,
+			},
+		},
+		{
+			name: "invalid cursor",
+			args: args{
+				uri: "/api/v1/author?cursor=garbage",
+			},
+			want: want{
+				usecase: struct {
+					authors []*author.Schema
+					total   int
+					error
+				}{
+					authors: nil,
+					total:   0,
+					error:   filter.ErrInvalidCursor,
+				},
+				status: http.StatusBadRequest,
+				error:  filter.ErrInvalidCursor,
+			},
+		},
 		{
 			name: "simulate lower layer error",
 			args: args{
@@ -366,7 +462,7 @@ func TestHandler_List(t *testing.T) {
 
 			router := chi.NewRouter()
 
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.AuthorMock{
 				ListFunc: func(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
@@ -374,32 +470,41 @@ func TestHandler_List(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 			h.List(ww, rr)
 
 			assert.Equal(t, test.want.status, ww.Code)
 
 			if ww.Code >= 200 && ww.Code < 300 {
+				body := ww.Body.String()
 				var got respond.Standard
-				if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+				if err := json.Unmarshal([]byte(body), &got); err != nil {
 					t.Fatal(err)
 				}
 
 				assert.Equal(t, test.want.size, got.Meta.Size)
 				assert.Equal(t, test.want.total, got.Meta.Total)
+				assert.Equal(t, test.want.nextCursor, got.Meta.NextCursor != "")
+				if test.want.next != "" {
+					assert.Equal(t, test.want.next, got.Meta.Next)
+					assert.Equal(t, test.want.prev, got.Meta.Prev)
+					assert.Contains(t, ww.Header().Get("Link"), `<`+test.want.next+`>; rel="next"`)
+				}
+				// Pages that do not exist are left out, as in the Link header.
+				if test.want.prev == "" {
+					assert.NotContains(t, body, `"prev"`)
+				}
 			} else {
 				b, err := io.ReadAll(ww.Body)
 				assert.Nil(t, err)
 
 				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+					Message string `json:"detail"`
+				}{}
 
 				err = json.Unmarshal(b, &errStruct)
 				assert.Nil(t, err)
-				assert.Equal(t, test.want.error.Error(), errStruct.Message)
+				assert.Equal(t, detail(ww.Code, test.want.error), errStruct.Message)
 			}
 		})
 	}
@@ -487,7 +592,7 @@ func TestHandler_Read(t *testing.T) {
 
 			router := chi.NewRouter()
 
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.AuthorMock{
 				ReadFunc: func(ctx context.Context, authorID uint64) (*author.Schema, error) {
@@ -495,7 +600,7 @@ func TestHandler_Read(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 			h.Get(ww, rr)
 
 			assert.Equal(t, test.status, ww.Code)
@@ -513,14 +618,12 @@ func TestHandler_Read(t *testing.T) {
 				assert.Nil(t, err)
 
 				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+					Message string `json:"detail"`
+				}{}
 
 				err = json.Unmarshal(b, &errStruct)
 				assert.Nil(t, err)
-				assert.Equal(t, test.want.err.Error(), errStruct.Message)
+				assert.Equal(t, detail(ww.Code, test.want.err), errStruct.Message)
 			}
 
 		})
@@ -628,14 +731,14 @@ func TestHandler_Update(t *testing.T) {
 
 			router := chi.NewRouter()
 
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.AuthorMock{
 				UpdateFunc: func(ctx context.Context, author *author.UpdateRequest) (*author.Schema, error) {
 					return test.want.usecase, test.want.err
 				}}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 			h.Update(ww, rr)
 
 			assert.Equal(t, test.status, ww.Code)
@@ -652,14 +755,12 @@ func TestHandler_Update(t *testing.T) {
 				assert.Nil(t, err)
 
 				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+					Message string `json:"detail"`
+				}{}
 
 				err = json.Unmarshal(b, &errStruct)
 				assert.Nil(t, err)
-				assert.Equal(t, test.want.err.Error(), errStruct.Message)
+				assert.Equal(t, detail(ww.Code, test.want.err), errStruct.Message)
 			}
 
 		})
@@ -710,7 +811,7 @@ func TestHandler_Delete(t *testing.T) {
 			},
 			want: want{
 				error:  message.ErrNoRecord,
-				status: http.StatusBadRequest,
+				status: http.StatusNotFound,
 			},
 		},
 		{
@@ -737,18 +838,265 @@ func TestHandler_Delete(t *testing.T) {
 			rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
 
 			router := chi.NewRouter()
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.AuthorMock{
-				DeleteFunc: func(ctx context.Context, authorID uint64) error {
+				DeleteFunc: func(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
 					return test.want.error
 				},
 			}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 			h.Delete(ww, rr)
 
 			assert.Equal(t, test.status, ww.Code)
 		})
 	}
 }
+
+func TestHandler_Preconditions(t *testing.T) {
+	updatedAt := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	tag := etag.New(updatedAt)
+	body := `{"first_name": "First", "last_name": "Last"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		header      string
+		value       string
+		err         error
+		wantStatus  int
+		wantIfMatch []time.Time
+	}{
+		{name: "get sets etag", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "get not modified", method: http.MethodGet, header: "If-None-Match", value: tag, wantStatus: http.StatusNotModified},
+		{name: "get any not modified", method: http.MethodGet, header: "If-None-Match", value: "*", wantStatus: http.StatusNotModified},
+		{name: "get stale cache", method: http.MethodGet, header: "If-None-Match", value: `"other"`, wantStatus: http.StatusOK},
+		{name: "update unconditional", method: http.MethodPut, wantStatus: http.StatusOK},
+		{name: "update if match any", method: http.MethodPut, header: "If-Match", value: "*", wantStatus: http.StatusOK},
+		{name: "update if match", method: http.MethodPut, header: "If-Match", value: `"not-a-version", ` + tag, wantStatus: http.StatusOK, wantIfMatch: []time.Time{updatedAt}},
+		{name: "update unparseable if match", method: http.MethodPut, header: "If-Match", value: "garbage", wantStatus: http.StatusPreconditionFailed},
+		{name: "update modified", method: http.MethodPut, header: "If-Match", value: tag, err: message.ErrModified, wantStatus: http.StatusPreconditionFailed, wantIfMatch: []time.Time{updatedAt}},
+		{name: "delete if match", method: http.MethodDelete, header: "If-Match", value: tag, wantStatus: http.StatusOK, wantIfMatch: []time.Time{updatedAt}},
+		{name: "delete modified", method: http.MethodDelete, header: "If-Match", value: tag, err: message.ErrModified, wantStatus: http.StatusPreconditionFailed, wantIfMatch: []time.Time{updatedAt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotIfMatch []time.Time
+
+			uc := &usecase.AuthorMock{
+				ReadFunc: func(ctx context.Context, authorID uint64) (*author.Schema, error) {
+					return &author.Schema{ID: authorID, UpdatedAt: updatedAt}, nil
+				},
+				UpdateFunc: func(ctx context.Context, req *author.UpdateRequest) (*author.Schema, error) {
+					gotIfMatch = req.IfMatch
+					return &author.Schema{ID: req.ID, UpdatedAt: updatedAt}, tt.err
+				},
+				DeleteFunc: func(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
+					gotIfMatch = ifMatch
+					return tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(tt.method, "/api/v1/author/1", bytes.NewBufferString(body))
+			if tt.header != "" {
+				rr.Header.Set(tt.header, tt.value)
+			}
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.wantStatus, ww.Code)
+			assert.Equal(t, tt.wantIfMatch, gotIfMatch)
+			if tt.method != http.MethodDelete && tt.wantStatus != http.StatusPreconditionFailed {
+				assert.Equal(t, tag, ww.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestHandler_Patch(t *testing.T) {
+	updatedAt := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	current := &author.Schema{
+		ID:         1,
+		FirstName:  "First",
+		MiddleName: "Middle",
+		LastName:   "Last",
+		UpdatedAt:  updatedAt,
+	}
+
+	type want struct {
+		status  int
+		req     *author.UpdateRequest
+		updates int
+	}
+	tests := []struct {
+		name       string
+		patch      string
+		readErr    error
+		updateErrs []error
+		want       want
+	}{
+		{
+			name:  "keeps the fields not present",
+			patch: `{"last_name": "New Last"}`,
+			want: want{
+				status: http.StatusOK,
+				req: &author.UpdateRequest{
+					ID:         1,
+					FirstName:  "First",
+					MiddleName: "Middle",
+					LastName:   "New Last",
+					IfMatch:    []time.Time{updatedAt},
+				},
+				updates: 1,
+			},
+		},
+		{
+			name:  "null clears an optional field",
+			patch: `{"middle_name": null, "id": 2}`,
+			want: want{
+				status: http.StatusOK,
+				req: &author.UpdateRequest{
+					ID:        1,
+					FirstName: "First",
+					LastName:  "Last",
+					IfMatch:   []time.Time{updatedAt},
+				},
+				updates: 1,
+			},
+		},
+		{
+			name:  "not an object",
+			patch: `"First"`,
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:    "no record",
+			patch:   `{"last_name": "New Last"}`,
+			readErr: message.ErrNoRecord,
+			want:    want{status: http.StatusNotFound},
+		},
+		{
+			name:       "merges again when modified meanwhile",
+			patch:      `{"last_name": "New Last"}`,
+			updateErrs: []error{message.ErrModified, message.ErrModified, nil},
+			want:       want{status: http.StatusOK, updates: 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				got     *author.UpdateRequest
+				updates int
+			)
+
+			uc := &usecase.AuthorMock{
+				ReadFunc: func(ctx context.Context, authorID uint64) (*author.Schema, error) {
+					return current, test.readErr
+				},
+				UpdateFunc: func(ctx context.Context, req *author.UpdateRequest) (*author.Schema, error) {
+					got = req
+					updates++
+
+					var err error
+					if updates <= len(test.updateErrs) {
+						err = test.updateErrs[updates-1]
+					}
+					return &author.Schema{ID: req.ID, UpdatedAt: updatedAt}, err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(http.MethodPatch, "/api/v1/author/1", bytes.NewBufferString(test.patch))
+			rr.Header.Set("Content-Type", "application/merge-patch+json")
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, test.want.status, ww.Code)
+			assert.Equal(t, test.want.updates, updates)
+			if test.want.req != nil {
+				assert.Equal(t, test.want.req, got)
+			}
+		})
+	}
+}
+
+func TestHandler_Export(t *testing.T) {
+	created := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	authors := []*author.Schema{
+		{ID: 1, FirstName: "First", MiddleName: "Middle", LastName: "Last", CreatedAt: created, UpdatedAt: created},
+		{ID: 2, FirstName: "Second", LastName: "Last", CreatedAt: created, UpdatedAt: created},
+	}
+
+	uc := &usecase.AuthorMock{
+		ExportFunc: func(ctx context.Context, fn func(a *author.Schema) error) error {
+			for _, a := range authors {
+				if err := fn(a); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	router := chi.NewRouter()
+	RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+	rr := httptest.NewRequest(http.MethodGet, "/api/v1/author/export", nil)
+	ww := httptest.NewRecorder()
+	router.ServeHTTP(ww, rr)
+
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.Equal(t, `attachment; filename="authors.csv"`, ww.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,first_name,middle_name,last_name,created_at,updated_at\n"+
+		"1,First,Middle,Last,2022-02-12T15:04:05.123456Z,2022-02-12T15:04:05.123456Z\n"+
+		"2,Second,,Last,2022-02-12T15:04:05.123456Z,2022-02-12T15:04:05.123456Z\n", ww.Body.String())
+
+	rr = httptest.NewRequest(http.MethodGet, "/api/v1/author/export?format=ndjson", nil)
+	ww = httptest.NewRecorder()
+	router.ServeHTTP(ww, rr)
+
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.Equal(t, "application/x-ndjson", ww.Header().Get("Content-Type"))
+	lines := bytes.Split(bytes.TrimSpace(ww.Body.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var got author.ExportRes
+	assert.Nil(t, json.Unmarshal(lines[1], &got))
+	assert.Equal(t, *author.ExportResource(authors[1]), got)
+}
+
+func TestRegisterHTTPEndPoints_Write(t *testing.T) {
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+
+	uc := &usecase.AuthorMock{
+		ListFunc: func(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
+			return nil, 0, nil
+		},
+	}
+
+	router := chi.NewRouter()
+	RegisterHTTPEndPoints(router, validate.New(), uc, deny)
+
+	ww := httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodGet, "/api/v1/author", nil))
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	ww = httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodPost, "/api/v1/author", strings.NewReader("{}")))
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	ww = httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodDelete, "/api/v1/author/1", nil))
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 
@@ -8,19 +10,26 @@ import (
 	"micro/internal/middleware"
 )
 
-func RegisterHTTPEndPoints(router *chi.Mux, validate *validator.Validate, useCase usecase.Author) *Handler {
+// RegisterHTTPEndPoints mounts the author endpoints. Reads are public, and
+// writes go through write first.
+func RegisterHTTPEndPoints(router *chi.Mux, validate *validator.Validate, useCase usecase.Author, write func(http.Handler) http.Handler) *Handler {
 	h := NewHandler(useCase, validate)
 
 	router.Route("/api/v1/author", func(router chi.Router) {
-		router.Post("/", h.Create)
-
 		cacheGroup := router.Group(nil)
 		cacheGroup.Use(middleware.CacheByURL)
 		cacheGroup.Get("/", h.List)
 
+		router.Get("/export", h.Export)
 		router.Get("/{id}", h.Get)
-		router.Put("/{id}", h.Update)
-		router.Delete("/{id}", h.Delete)
+
+		router.Group(func(router chi.Router) {
+			router.Use(write)
+			router.Post("/", h.Create)
+			router.Put("/{id}", h.Update)
+			router.Patch("/{id}", h.Patch)
+			router.Delete("/{id}", h.Delete)
+		})
 	})
 
 	return h
@@ -15,4 +15,9 @@ type Schema struct {
 	UpdatedAt  time.Time
 	DeletedAt  *time.Time
 	Books      []*book.Schema
+
+	// Rank and Highlight are only set by a full-text search. Highlight is
+	// the full name with the matching words wrapped in <b></b>.
+	Rank      float64
+	Highlight string
 }
@@ -0,0 +1,734 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/sql"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"go.opentelemetry.io/otel"
+
+	"micro/ent/gen"
+	entAuthor "micro/ent/gen/author"
+	entBook "micro/ent/gen/book"
+	"micro/ent/gen/hook"
+	"micro/internal/domain/author"
+	"micro/internal/domain/book"
+)
+
+// AuthorMapping is the index definition of author documents. Names are
+// analysed with the standard analyzer, and each author carries the titles and
+// descriptions of their live books.
+const AuthorMapping = `{
+  "mappings": {
+    "properties": {
+      "id":          {"type": "long"},
+      "first_name":  {"type": "text"},
+      "middle_name": {"type": "text"},
+      "last_name":   {"type": "text"},
+      "created_at":  {"type": "date"},
+      "updated_at":  {"type": "date"},
+      "books": {
+        "properties": {
+          "id":             {"type": "long"},
+          "title":          {"type": "text"},
+          "description":    {"type": "text"},
+          "image_url":      {"type": "keyword", "index": false},
+          "published_date": {"type": "date"}
+        }
+      }
+    }
+  }
+}`
+
+// maxResultWindow is the largest from + size that Elasticsearch returns by
+// default. It bounds searches that disable paging.
+const maxResultWindow = 10000
+
+// reindexBatch is the number of authors loaded and bulk indexed at a time.
+const reindexBatch = 500
+
+type elasticSearch struct {
+	es *elasticsearch.Client
+	// index is an alias, which Reindex moves from one index to the next.
+	index string
+}
+
+// NewElasticsearch returns a Searcher that queries the given index alias.
+// Keep the index up to date by registering AuthorHook and BookHook on the ent
+// client.
+func NewElasticsearch(es *elasticsearch.Client, index string) *elasticSearch {
+	return &elasticSearch{
+		es:    es,
+		index: index,
+	}
+}
+
+type document struct {
+	ID         uint64         `json:"id"`
+	FirstName  string         `json:"first_name"`
+	MiddleName string         `json:"middle_name"`
+	LastName   string         `json:"last_name"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Books      []bookDocument `json:"books"`
+}
+
+type bookDocument struct {
+	ID            uint64    `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	ImageURL      string    `json:"image_url"`
+	PublishedDate time.Time `json:"published_date"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Score     float64             `json:"_score"`
+			Source    document            `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+type bulkResponse struct {
+	Errors bool                         `json:"errors"`
+	Items  []map[string]bulkItemOutcome `json:"items"`
+}
+
+type bulkItemOutcome struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// Search matches names and book titles against f.Query. The query takes the
+// same web search syntax as the PostgreSQL searcher: words are all required,
+// "quoted phrases" match in order, `or` gives alternatives and a leading `-`
+// excludes a word. Last names weigh the most, then first names.
+func (s *elasticSearch) Search(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
+	tracer := otel.Tracer("")
+	ctx, span := tracer.Start(ctx, "AuthorElasticsearch")
+	defer span.End()
+
+	from, size := f.Base.Offset, f.Base.Limit
+	if f.Base.DisablePaging {
+		from, size = 0, maxResultWindow
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"from":             from,
+		"size":             size,
+		"track_total_hits": true,
+		"query": map[string]any{
+			"simple_query_string": map[string]any{
+				"query":            simpleQuery(f.Query),
+				"fields":           []string{"last_name^3", "first_name^2", "middle_name", "books.title"},
+				"default_operator": "and",
+			},
+		},
+		"sort": []any{
+			"_score",
+			map[string]string{"id": "desc"},
+		},
+		"highlight": map[string]any{
+			"pre_tags":            []string{"<b>"},
+			"post_tags":           []string{"</b>"},
+			"number_of_fragments": 0,
+			"fields": map[string]any{
+				"first_name":  map[string]any{},
+				"middle_name": map[string]any{},
+				"last_name":   map[string]any{},
+			},
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(s.index),
+		s.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error retrieving Author list: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("error retrieving Author list: %w", responseError(res))
+	}
+
+	var found searchResponse
+	if err = json.NewDecoder(res.Body).Decode(&found); err != nil {
+		return nil, 0, fmt.Errorf("error decoding Author list: %w", err)
+	}
+
+	resp := make([]*author.Schema, 0, len(found.Hits.Hits))
+	for _, hit := range found.Hits.Hits {
+		doc := hit.Source
+
+		books := make([]*book.Schema, 0, len(doc.Books))
+		for _, b := range doc.Books {
+			books = append(books, &book.Schema{
+				ID:            b.ID,
+				Title:         b.Title,
+				PublishedDate: b.PublishedDate,
+				ImageURL:      b.ImageURL,
+				Description:   b.Description,
+			})
+		}
+
+		resp = append(resp, &author.Schema{
+			ID:         doc.ID,
+			FirstName:  doc.FirstName,
+			MiddleName: doc.MiddleName,
+			LastName:   doc.LastName,
+			CreatedAt:  doc.CreatedAt,
+			UpdatedAt:  doc.UpdatedAt,
+			Books:      books,
+			Rank:       hit.Score,
+			Highlight:  highlight(doc, hit.Highlight),
+		})
+	}
+
+	return resp, found.Hits.Total.Value, nil
+}
+
+// CreateIndex creates an index with AuthorMapping behind the alias unless
+// the alias, or an index of the same name, already exists.
+func (s *elasticSearch) CreateIndex(ctx context.Context) error {
+	res, err := s.es.Indices.Exists([]string{s.index}, s.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return s.createIndex(ctx, s.versionedIndex(), s.index)
+	default:
+		return fmt.Errorf("checking index %s: %s", s.index, res.Status())
+	}
+}
+
+// Reindex builds a new index out of every live author in the database, then
+// points the alias at it and deletes the indices it pointed at before. The
+// swap is atomic, so searches keep using the complete old index until the
+// new one is ready. Returns the number of authors indexed.
+//
+// Writes made while it runs reach the old index only. Run it when writes are
+// quiet, or again afterwards.
+func (s *elasticSearch) Reindex(ctx context.Context, client *gen.Client) (int, error) {
+	index := s.versionedIndex()
+	if err := s.createIndex(ctx, index, ""); err != nil {
+		return 0, err
+	}
+
+	indexed, err := s.fill(ctx, client, index)
+	if err == nil {
+		err = s.swapAlias(ctx, index)
+	}
+	if err != nil {
+		// Leave the alias where it was, and drop the half built index.
+		if deleteErr := s.deleteIndices(ctx, []string{index}); deleteErr != nil {
+			slog.ErrorContext(ctx, "deleting unused index", "index", index, "error", deleteErr)
+		}
+		return indexed, err
+	}
+
+	return indexed, nil
+}
+
+// fill bulk indexes every live author into index, and refreshes it so that
+// they are searchable.
+func (s *elasticSearch) fill(ctx context.Context, client *gen.Client, index string) (int, error) {
+	var (
+		indexed int
+		lastID  uint64
+	)
+	for {
+		authors, err := client.Author.Query().
+			WithBooks(liveBooks).
+			Where(entAuthor.DeletedAtIsNil()).
+			Where(entAuthor.IDGT(lastID)).
+			Order(entAuthor.ByID(sql.OrderAsc())).
+			Limit(reindexBatch).
+			All(ctx)
+		if err != nil {
+			return indexed, err
+		}
+		if len(authors) == 0 {
+			break
+		}
+
+		if err = s.bulk(ctx, index, authors, nil); err != nil {
+			return indexed, err
+		}
+
+		indexed += len(authors)
+		lastID = authors[len(authors)-1].ID
+	}
+
+	res, err := s.es.Indices.Refresh(
+		s.es.Indices.Refresh.WithContext(ctx),
+		s.es.Indices.Refresh.WithIndex(index),
+	)
+	if err != nil {
+		return indexed, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return indexed, fmt.Errorf("refreshing index %s: %w", index, responseError(res))
+	}
+
+	return indexed, nil
+}
+
+// swapAlias points the alias at index alone, in one request. An index that
+// carries the name of the alias, as created before indices were versioned,
+// is deleted by the same request. The indices the alias pointed at are
+// deleted afterwards.
+func (s *elasticSearch) swapAlias(ctx context.Context, index string) error {
+	previous, concrete, err := s.aliasIndices(ctx)
+	if err != nil {
+		return err
+	}
+
+	actions := []map[string]any{
+		{"add": map[string]string{"index": index, "alias": s.index}},
+	}
+	for _, old := range previous {
+		actions = append(actions, map[string]any{"remove": map[string]string{"index": old, "alias": s.index}})
+	}
+	if concrete {
+		actions = append(actions, map[string]any{"remove_index": map[string]string{"index": s.index}})
+	}
+
+	body, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.Indices.UpdateAliases(bytes.NewReader(body), s.es.Indices.UpdateAliases.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("swapping alias %s: %w", s.index, responseError(res))
+	}
+
+	// The alias has moved, so a failure here only leaves unused indices.
+	if err = s.deleteIndices(ctx, previous); err != nil {
+		slog.ErrorContext(ctx, "deleting previous indices", "alias", s.index, "error", err)
+	}
+
+	return nil
+}
+
+// aliasIndices returns the indices behind the alias. concrete reports that
+// there is no such alias, but an index with its name.
+func (s *elasticSearch) aliasIndices(ctx context.Context) (indices []string, concrete bool, err error) {
+	res, err := s.es.Indices.GetAlias(
+		s.es.Indices.GetAlias.WithContext(ctx),
+		s.es.Indices.GetAlias.WithName(s.index),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		exists, err := s.es.Indices.Exists([]string{s.index}, s.es.Indices.Exists.WithContext(ctx))
+		if err != nil {
+			return nil, false, err
+		}
+		_ = exists.Body.Close()
+		return nil, exists.StatusCode == http.StatusOK, nil
+	case res.IsError():
+		return nil, false, fmt.Errorf("reading alias %s: %w", s.index, responseError(res))
+	}
+
+	var found map[string]json.RawMessage
+	if err = json.NewDecoder(res.Body).Decode(&found); err != nil {
+		return nil, false, err
+	}
+	for index := range found {
+		indices = append(indices, index)
+	}
+
+	return indices, false, nil
+}
+
+func (s *elasticSearch) deleteIndices(ctx context.Context, indices []string) error {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	res, err := s.es.Indices.Delete(indices, s.es.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("deleting indices %s: %w", strings.Join(indices, ","), responseError(res))
+	}
+
+	return nil
+}
+
+// versionedIndex names a new index to put behind the alias.
+func (s *elasticSearch) versionedIndex() string {
+	return s.index + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// AuthorHook re-indexes the authors touched by an author mutation, and
+// removes deleted ones from the index.
+//
+// Indexing happens after the mutation is committed, see syncAfterCommit. A
+// failure is logged rather than returned so that a search outage does not
+// block writes; run a reindex to recover.
+func (s *elasticSearch) AuthorHook() ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return hook.AuthorFunc(func(ctx context.Context, m *gen.AuthorMutation) (ent.Value, error) {
+			var ids []uint64
+			if !m.Op().Is(ent.OpCreate) {
+				before, err := m.IDs(ctx)
+				if err != nil {
+					return nil, err
+				}
+				ids = before
+			}
+
+			v, err := next.Mutate(ctx, m)
+			if err != nil {
+				return v, err
+			}
+
+			if created, ok := v.(*gen.Author); ok {
+				ids = append(ids, created.ID)
+			}
+			tx, _ := m.Tx()
+			s.syncAfterCommit(ctx, m.Client(), tx, ids)
+
+			return v, nil
+		})
+	}
+}
+
+// BookHook re-indexes the authors of the books touched by a book mutation,
+// since author documents embed their books. Books written outside of ent, by
+// the sqlx book repository, are only picked up by a reindex.
+func (s *elasticSearch) BookHook() ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return hook.BookFunc(func(ctx context.Context, m *gen.BookMutation) (ent.Value, error) {
+			var bookIDs []uint64
+			if !m.Op().Is(ent.OpCreate) {
+				before, err := m.IDs(ctx)
+				if err != nil {
+					return nil, err
+				}
+				bookIDs = before
+			}
+
+			// Authors linked before the mutation, so that deleting a book
+			// or detaching an author also updates them.
+			authorIDs, err := booksAuthors(ctx, m.Client(), bookIDs)
+			if err != nil {
+				return nil, err
+			}
+			authorIDs = append(authorIDs, m.AuthorsIDs()...)
+			authorIDs = append(authorIDs, m.RemovedAuthorsIDs()...)
+
+			v, err := next.Mutate(ctx, m)
+			if err != nil {
+				return v, err
+			}
+
+			tx, _ := m.Tx()
+			s.syncAfterCommit(ctx, m.Client(), tx, authorIDs)
+
+			return v, nil
+		})
+	}
+}
+
+func booksAuthors(ctx context.Context, client *gen.Client, bookIDs []uint64) ([]uint64, error) {
+	if len(bookIDs) == 0 {
+		return nil, nil
+	}
+	return client.Book.Query().
+		Where(entBook.IDIn(bookIDs...)).
+		QueryAuthors().
+		IDs(ctx)
+}
+
+// syncAfterCommit indexes the given authors once the mutation is committed.
+// tx is nil outside of a transaction, where ent has committed the mutation
+// already. Inside one, the authors are read through the transaction right
+// before it commits, and indexed only if the commit succeeds, so that the
+// index never holds changes that were rolled back.
+func (s *elasticSearch) syncAfterCommit(ctx context.Context, client *gen.Client, tx *gen.Tx, ids []uint64) {
+	if tx == nil {
+		s.sync(ctx, client, ids)
+		return
+	}
+
+	tx.OnCommit(func(next gen.Committer) gen.Committer {
+		return gen.CommitFunc(func(ctx context.Context, tx *gen.Tx) error {
+			live, removed, loadErr := s.load(ctx, tx.Client(), ids)
+			if err := next.Commit(ctx, tx); err != nil {
+				return err
+			}
+
+			if loadErr != nil {
+				slog.ErrorContext(ctx, "loading authors to index", "error", loadErr)
+				return nil
+			}
+			if err := s.bulk(ctx, s.index, live, removed); err != nil {
+				slog.ErrorContext(ctx, "indexing authors", "error", err)
+			}
+			return nil
+		})
+	})
+}
+
+// sync indexes the given authors as they are in the database now.
+func (s *elasticSearch) sync(ctx context.Context, client *gen.Client, ids []uint64) {
+	live, removed, err := s.load(ctx, client, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "loading authors to index", "error", err)
+		return
+	}
+
+	if err = s.bulk(ctx, s.index, live, removed); err != nil {
+		slog.ErrorContext(ctx, "indexing authors", "error", err)
+	}
+}
+
+// load reads the given authors, and splits them into those to index and the
+// IDs of those to remove from the index. Soft-deleted authors are removed, as
+// are those that no longer exist.
+func (s *elasticSearch) load(ctx context.Context, client *gen.Client, ids []uint64) (live []*gen.Author, removed []uint64, err error) {
+	unique := uniqueIDs(ids)
+	if len(unique) == 0 {
+		return nil, nil, nil
+	}
+	ids = make([]uint64, 0, len(unique))
+	for id := range unique {
+		ids = append(ids, id)
+	}
+
+	authors, err := client.Author.Query().
+		WithBooks(liveBooks).
+		Where(entAuthor.IDIn(ids...)).
+		All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, a := range authors {
+		delete(unique, a.ID)
+		if a.DeletedAt != nil {
+			removed = append(removed, a.ID)
+			continue
+		}
+		live = append(live, a)
+	}
+	for id := range unique {
+		removed = append(removed, id)
+	}
+
+	return live, removed, nil
+}
+
+// bulk indexes authors into index, and deletes the documents of removed, in
+// one request.
+func (s *elasticSearch) bulk(ctx context.Context, index string, authors []*gen.Author, removed []uint64) error {
+	if len(authors) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, a := range authors {
+		if err := enc.Encode(map[string]any{"index": map[string]string{"_id": docID(a.ID)}}); err != nil {
+			return err
+		}
+		if err := enc.Encode(toDocument(a)); err != nil {
+			return err
+		}
+	}
+	for _, id := range removed {
+		if err := enc.Encode(map[string]any{"delete": map[string]string{"_id": docID(id)}}); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.es.Bulk(&body,
+		s.es.Bulk.WithContext(ctx),
+		s.es.Bulk.WithIndex(index),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return responseError(res)
+	}
+
+	var outcome bulkResponse
+	if err = json.NewDecoder(res.Body).Decode(&outcome); err != nil {
+		return err
+	}
+	if !outcome.Errors {
+		return nil
+	}
+
+	var failed []string
+	for _, item := range outcome.Items {
+		for action, o := range item {
+			// Deleting a document that is not indexed is not an error.
+			if o.Status >= 300 && !(action == "delete" && o.Status == http.StatusNotFound) {
+				failed = append(failed, fmt.Sprintf("%s %s: %s", action, o.ID, o.Error.Reason))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("bulk indexing: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// createIndex creates index with AuthorMapping, and puts it behind alias
+// unless alias is empty.
+func (s *elasticSearch) createIndex(ctx context.Context, index string, alias string) error {
+	var body map[string]any
+	if err := json.Unmarshal([]byte(AuthorMapping), &body); err != nil {
+		return err
+	}
+	if alias != "" {
+		body["aliases"] = map[string]any{alias: map[string]any{}}
+	}
+	mapping, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.Indices.Create(index,
+		s.es.Indices.Create.WithContext(ctx),
+		s.es.Indices.Create.WithBody(bytes.NewReader(mapping)),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("creating index %s: %w", index, responseError(res))
+	}
+
+	return nil
+}
+
+func toDocument(a *gen.Author) document {
+	books := make([]bookDocument, 0, len(a.Edges.Books))
+	for _, b := range a.Edges.Books {
+		books = append(books, bookDocument{
+			ID:            b.ID,
+			Title:         b.Title,
+			Description:   b.Description,
+			ImageURL:      b.ImageURL,
+			PublishedDate: b.PublishedDate,
+		})
+	}
+
+	return document{
+		ID:         a.ID,
+		FirstName:  a.FirstName,
+		MiddleName: a.MiddleName,
+		LastName:   a.LastName,
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+		Books:      books,
+	}
+}
+
+func docID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// highlight returns the full name of doc with the words that matched wrapped
+// in <b></b>, the same shape as ts_headline in the PostgreSQL searcher.
+func highlight(doc document, fragments map[string][]string) string {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"first_name", doc.FirstName},
+		{"middle_name", doc.MiddleName},
+		{"last_name", doc.LastName},
+	}
+
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value := field.value
+		if highlighted, ok := fragments[field.name]; ok && len(highlighted) > 0 {
+			value = highlighted[0]
+		}
+		if value != "" {
+			names = append(names, value)
+		}
+	}
+
+	return strings.Join(names, " ")
+}
+
+// simpleQuery translates web search syntax into simple_query_string syntax.
+// The two agree on quotes and on `-`, but alternatives are spelt `|`.
+func simpleQuery(q string) string {
+	words := strings.Fields(q)
+	quoted := false
+	for i, word := range words {
+		if !quoted && strings.EqualFold(word, "or") {
+			words[i] = "|"
+		}
+		if strings.Count(word, `"`)%2 == 1 {
+			quoted = !quoted
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func responseError(res *esapi.Response) error {
+	msg, err := io.ReadAll(res.Body)
+	if err != nil || len(msg) == 0 {
+		return errors.New(res.Status())
+	}
+	return fmt.Errorf("%s: %s", res.Status(), msg)
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/domain/author"
+	"micro/internal/utility/filter"
+)
+
+const testIndex = "authors"
+
+// fakeES mimics the parts of the Elasticsearch API used by the author
+// searcher. Indices and their documents are kept in memory, along with the
+// alias of each index, and searches answer with searchResponse.
+type fakeES struct {
+	mu sync.Mutex
+
+	indices        map[string]map[string]json.RawMessage
+	aliases        map[string]string
+	searchStatus   int
+	searchResponse string
+	searchRequest  map[string]any
+	requests       []string
+}
+
+func newFakeES(t *testing.T) (*fakeES, *elasticsearch.Client) {
+	fake := &fakeES{
+		indices:      map[string]map[string]json.RawMessage{},
+		aliases:      map[string]string{},
+		searchStatus: http.StatusOK,
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, client
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		_, _ = io.WriteString(w, `{"version": {"number": "7.17.10", "build_flavor": "default"}, "tagline": "You Know, for Search"}`)
+	case r.Method == http.MethodGet && name == "_alias":
+		f.getAlias(w, endpoint)
+	case r.Method == http.MethodPost && name == "_aliases":
+		f.updateAliases(w, r.Body)
+	case endpoint == "_search":
+		_ = json.NewDecoder(r.Body).Decode(&f.searchRequest)
+		w.WriteHeader(f.searchStatus)
+		_, _ = io.WriteString(w, f.searchResponse)
+	case endpoint == "_bulk":
+		f.bulk(w, name, r.Body)
+	case endpoint == "_refresh":
+		_, _ = io.WriteString(w, `{"_shards": {"failed": 0}}`)
+	case endpoint != "":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodHead:
+		if f.resolve(name) == "" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut:
+		f.createIndex(w, name, r.Body)
+	case r.Method == http.MethodDelete:
+		for _, index := range strings.Split(name, ",") {
+			if _, ok := f.indices[index]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"error": {"type": "index_not_found_exception"}, "status": 404}`)
+				return
+			}
+			f.deleteIndex(index)
+		}
+		_, _ = io.WriteString(w, `{"acknowledged": true}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// resolve returns the index behind name, which is an alias or an index, or
+// "" when there is neither.
+func (f *fakeES) resolve(name string) string {
+	if index, ok := f.aliases[name]; ok {
+		return index
+	}
+	if _, ok := f.indices[name]; ok {
+		return name
+	}
+	return ""
+}
+
+func (f *fakeES) createIndex(w http.ResponseWriter, index string, body io.Reader) {
+	if f.resolve(index) != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error": {"type": "resource_already_exists_exception"}, "status": 400}`)
+		return
+	}
+
+	var req struct {
+		Aliases map[string]any `json:"aliases"`
+	}
+	_ = json.NewDecoder(body).Decode(&req)
+
+	f.indices[index] = map[string]json.RawMessage{}
+	for alias := range req.Aliases {
+		f.aliases[alias] = index
+	}
+	_, _ = io.WriteString(w, `{"acknowledged": true}`)
+}
+
+func (f *fakeES) deleteIndex(index string) {
+	delete(f.indices, index)
+	for alias, target := range f.aliases {
+		if target == index {
+			delete(f.aliases, alias)
+		}
+	}
+}
+
+func (f *fakeES) getAlias(w http.ResponseWriter, alias string) {
+	index, ok := f.aliases[alias]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error": "alias [`+alias+`] missing", "status": 404}`)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		index: map[string]any{"aliases": map[string]any{alias: map[string]any{}}},
+	})
+}
+
+func (f *fakeES) updateAliases(w http.ResponseWriter, body io.Reader) {
+	var req struct {
+		Actions []map[string]struct {
+			Index string `json:"index"`
+			Alias string `json:"alias"`
+		} `json:"actions"`
+	}
+	_ = json.NewDecoder(body).Decode(&req)
+
+	for _, action := range req.Actions {
+		for name, a := range action {
+			switch name {
+			case "add":
+				f.aliases[a.Alias] = a.Index
+			case "remove":
+				if f.aliases[a.Alias] == a.Index {
+					delete(f.aliases, a.Alias)
+				}
+			case "remove_index":
+				f.deleteIndex(a.Index)
+			}
+		}
+	}
+	_, _ = io.WriteString(w, `{"acknowledged": true}`)
+}
+
+func (f *fakeES) bulk(w http.ResponseWriter, name string, body io.Reader) {
+	docs := f.indices[f.resolve(name)]
+	if docs == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error": {"type": "index_not_found_exception"}, "status": 404}`)
+		return
+	}
+
+	var items []map[string]any
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		_ = json.Unmarshal(scanner.Bytes(), &action)
+
+		for name, meta := range action {
+			status := http.StatusOK
+			switch name {
+			case "index":
+				scanner.Scan()
+				docs[meta.ID] = append(json.RawMessage{}, scanner.Bytes()...)
+			case "delete":
+				if _, ok := docs[meta.ID]; !ok {
+					status = http.StatusNotFound
+				}
+				delete(docs, meta.ID)
+			}
+			items = append(items, map[string]any{name: map[string]any{"_id": meta.ID, "status": status}})
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": false, "items": items})
+}
+
+// docs returns the documents searches on testIndex see.
+func (f *fakeES) docs() map[string]json.RawMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.indices[f.resolve(testIndex)]
+}
+
+func (f *fakeES) doc(t *testing.T, id string) (document, bool) {
+	raw, ok := f.docs()[id]
+	if !ok {
+		return document{}, false
+	}
+	var doc document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc, true
+}
+
+func TestElasticsearch_Search(t *testing.T) {
+	fake, client := newFakeES(t)
+	fake.searchResponse = `{
+	  "hits": {
+	    "total": {"value": 12, "relation": "eq"},
+	    "hits": [
+	      {
+	        "_score": 2.5,
+	        "_source": {
+	          "id": 7,
+	          "first_name": "Jane",
+	          "middle_name": "",
+	          "last_name": "Roe",
+	          "created_at": "2022-02-12T15:04:05Z",
+	          "updated_at": "2022-02-12T15:04:05Z",
+	          "books": [{"id": 3, "title": "Title", "description": "Description", "published_date": "2020-01-01T00:00:00Z"}]
+	        },
+	        "highlight": {"first_name": ["<b>Jane</b>"]}
+	      },
+	      {
+	        "_score": 1.5,
+	        "_source": {"id": 5, "first_name": "John", "middle_name": "M", "last_name": "Roe", "books": []},
+	        "highlight": {"first_name": ["<b>John</b>"]}
+	      }
+	    ]
+	  }
+	}`
+
+	searcher := NewElasticsearch(client, testIndex)
+
+	got, total, err := searcher.Search(context.Background(), &author.Filter{
+		Base:  filter.Filter{Offset: 10, Limit: 10, Search: true},
+		Query: `jane or john -"jane doe"`,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 12, total)
+
+	published, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	assert.Len(t, got, 2)
+	assert.Equal(t, uint64(7), got[0].ID)
+	assert.Equal(t, "Jane", got[0].FirstName)
+	assert.Equal(t, "Roe", got[0].LastName)
+	assert.Equal(t, 2.5, got[0].Rank)
+	assert.Equal(t, "<b>Jane</b> Roe", got[0].Highlight)
+	assert.Len(t, got[0].Books, 1)
+	assert.Equal(t, "Title", got[0].Books[0].Title)
+	assert.Equal(t, published, got[0].Books[0].PublishedDate.UTC())
+	assert.Equal(t, "<b>John</b> M Roe", got[1].Highlight)
+
+	assert.Equal(t, float64(10), fake.searchRequest["from"])
+	assert.Equal(t, float64(10), fake.searchRequest["size"])
+	query := fake.searchRequest["query"].(map[string]any)["simple_query_string"].(map[string]any)
+	assert.Equal(t, `jane | john -"jane doe"`, query["query"])
+	assert.Equal(t, "and", query["default_operator"])
+}
+
+func TestElasticsearch_SearchError(t *testing.T) {
+	fake, client := newFakeES(t)
+	fake.searchStatus = http.StatusBadRequest
+	fake.searchResponse = `{"error": {"type": "search_phase_execution_exception"}, "status": 400}`
+
+	searcher := NewElasticsearch(client, testIndex)
+
+	got, total, err := searcher.Search(context.Background(), &author.Filter{
+		Base:  filter.Filter{Limit: 10, Search: true},
+		Query: "jane",
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "search_phase_execution_exception")
+	assert.Nil(t, got)
+	assert.Equal(t, 0, total)
+}
+
+func TestElasticsearch_CreateIndex(t *testing.T) {
+	fake, client := newFakeES(t)
+	searcher := NewElasticsearch(client, testIndex)
+	ctx := context.Background()
+
+	err := searcher.CreateIndex(ctx)
+	assert.Nil(t, err)
+	index := fake.aliases[testIndex]
+	assert.True(t, strings.HasPrefix(index, testIndex+"_"))
+	assert.Contains(t, fake.requests, "PUT /"+index)
+
+	// An existing index is left alone.
+	fake.requests = nil
+	err = searcher.CreateIndex(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, index, fake.aliases[testIndex])
+	for _, req := range fake.requests {
+		assert.False(t, strings.HasPrefix(req, "PUT "), req)
+	}
+}
+
+func TestElasticsearch_SwapAlias(t *testing.T) {
+	fake, client := newFakeES(t)
+	searcher := NewElasticsearch(client, testIndex)
+	ctx := context.Background()
+
+	// An index created before indices were versioned carries the name of
+	// the alias. It is replaced in the same request that adds the alias.
+	fake.indices[testIndex] = map[string]json.RawMessage{"1": json.RawMessage(`{"id": 1}`)}
+
+	first := searcher.versionedIndex()
+	assert.Nil(t, searcher.createIndex(ctx, first, ""))
+	assert.Nil(t, searcher.swapAlias(ctx, first))
+	assert.Equal(t, first, fake.aliases[testIndex])
+	assert.NotContains(t, fake.indices, testIndex)
+
+	second := searcher.versionedIndex()
+	assert.Nil(t, searcher.createIndex(ctx, second, ""))
+	assert.Nil(t, searcher.swapAlias(ctx, second))
+	assert.Equal(t, second, fake.aliases[testIndex])
+	assert.NotContains(t, fake.indices, first)
+	assert.Contains(t, fake.indices, second)
+}
+
+func TestSimpleQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{name: "words", q: "jane doe", want: "jane doe"},
+		{name: "or", q: "jane OR john", want: "jane | john"},
+		{name: "exclusion", q: "jane -doe", want: "jane -doe"},
+		{name: "or inside a phrase", q: `"war or peace" or tolstoy`, want: `"war or peace" | tolstoy`},
+		{name: "extra spaces", q: "  jane   or  john ", want: "jane | john"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, simpleQuery(tt.q))
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	doc := document{FirstName: "Jane", MiddleName: "", LastName: "Roe"}
+
+	assert.Equal(t, "Jane Roe", highlight(doc, nil))
+	assert.Equal(t, "Jane <b>Roe</b>", highlight(doc, map[string][]string{"last_name": {"<b>Roe</b>"}}))
+	assert.Equal(t, "<b>Jane</b> Roe", highlight(doc, map[string][]string{"first_name": {"<b>Jane</b>"}}))
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"micro/internal/domain/author"
+)
+
+// ExportAuthors selects every live author in the order of their IDs.
+const ExportAuthors = "SELECT id, first_name, COALESCE(middle_name, ''), last_name, created_at, updated_at FROM authors WHERE deleted_at IS NULL ORDER BY id"
+
+type Exporter interface {
+	Export(ctx context.Context, fn func(a *author.Schema) error) error
+}
+
+// exporter reads authors through database/sql rather than ent, because ent
+// loads the whole result of a query before returning it.
+type exporter struct {
+	db *sql.DB
+}
+
+func NewExport(db *sql.DB) *exporter {
+	return &exporter{db: db}
+}
+
+// Export calls fn with every live author. Authors are read from the cursor one
+// at a time, so that they are never all in memory.
+func (e *exporter) Export(ctx context.Context, fn func(a *author.Schema) error) error {
+	rows, err := e.db.QueryContext(ctx, ExportAuthors)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a author.Schema
+		err = rows.Scan(&a.ID, &a.FirstName, &a.MiddleName, &a.LastName, &a.CreatedAt, &a.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if err = fn(&a); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
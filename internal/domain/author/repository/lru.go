@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/golang-lru/v2"
 
@@ -18,7 +19,7 @@ type AuthorLRU struct {
 type AuthorLRUService interface {
 	Read(ctx context.Context, id uint64) (*author.Schema, error)
 	Update(ctx context.Context, toAuthor *author.UpdateRequest) (*author.Schema, error)
-	Delete(ctx context.Context, id uint64) error
+	Delete(ctx context.Context, id uint64, ifMatch ...time.Time) error
 }
 
 func NewLRUCache(service Author) *AuthorLRU {
@@ -63,10 +64,10 @@ func (c *AuthorLRU) Update(ctx context.Context, toAuthor *author.UpdateRequest)
 	return c.service.Update(ctx, toAuthor)
 }
 
-func (c *AuthorLRU) Delete(ctx context.Context, id uint64) error {
+func (c *AuthorLRU) Delete(ctx context.Context, id uint64, ifMatch ...time.Time) error {
 	c.invalidate(ctx)
 
-	return c.service.Delete(ctx, id)
+	return c.service.Delete(ctx, id, ifMatch...)
 }
 
 func (c *AuthorLRU) invalidate(ctx context.Context) {
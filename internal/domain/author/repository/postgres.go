@@ -11,9 +11,12 @@ import (
 
 	"micro/ent/gen"
 	entAuthor "micro/ent/gen/author"
+	entBook "micro/ent/gen/book"
 	"micro/ent/gen/predicate"
 	"micro/internal/domain/author"
 	"micro/internal/domain/book"
+	"micro/internal/utility/filter"
+	"micro/internal/utility/message"
 	parseTime "micro/internal/utility/time"
 )
 
@@ -21,13 +24,13 @@ type repository struct {
 	ent *gen.Client
 }
 
-//go:generate mirip -rm -out postgres_mock.go . Author Searcher
+//go:generate mirip -rm -out postgres_mock.go . Author Searcher Exporter
 type Author interface {
 	Create(ctx context.Context, a *author.CreateRequest) (*author.Schema, error)
 	List(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error)
 	Read(ctx context.Context, id uint64) (*author.Schema, error)
 	Update(ctx context.Context, toAuthor *author.UpdateRequest) (*author.Schema, error)
-	Delete(ctx context.Context, authorID uint64) error
+	Delete(ctx context.Context, authorID uint64, ifMatch ...time.Time) error
 }
 
 type Searcher interface {
@@ -44,17 +47,39 @@ func (r *repository) Create(ctx context.Context, request *author.CreateRequest)
 	if request == nil {
 		return nil, errors.New("request cannot be nil")
 	}
-	bulk := make([]*gen.BookCreate, len(request.Books))
-	for i, b := range request.Books {
-		bulk[i] = r.ent.Book.Create().
+	var (
+		bulk    []*gen.BookCreate
+		linkIDs []uint64
+	)
+	for _, b := range request.Books {
+		if b.BookID != 0 {
+			linkIDs = append(linkIDs, b.BookID)
+			continue
+		}
+		bulk = append(bulk, r.ent.Book.Create().
 			SetTitle(b.Title).
 			SetDescription(b.Description).
-			SetPublishedDate(parseTime.Parse(b.PublishedDate))
+			SetPublishedDate(parseTime.Parse(b.PublishedDate)))
+	}
+
+	// Existing books are linked rather than created. All of them must be
+	// live, otherwise nothing is written.
+	linked, err := r.ent.Book.Query().
+		Where(entBook.IDIn(linkIDs...)).
+		Where(entBook.DeletedAtIsNil()).
+		All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("author.repository.Create link books: %w", err)
 	}
+	if len(linked) != len(uniqueIDs(linkIDs)) {
+		return nil, fmt.Errorf("author.repository.Create link books: %w", message.ErrNoRecord)
+	}
+
 	books, err := r.ent.Book.CreateBulk(bulk...).Save(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("author.repository.Create bulk books: %w", err)
 	}
+	books = append(books, linked...)
 
 	create, err := r.ent.Author.Create().
 		SetFirstName(request.FirstName).
@@ -108,18 +133,6 @@ func (r *repository) List(ctx context.Context, f *author.Filter) ([]*author.Sche
 	ctx, span := tracer.Start(ctx, "AuthorRepoList")
 	defer span.End()
 
-	// filter by first and last names, if exists
-	var predicateUser []predicate.Author
-	if f.FirstName != "" {
-		predicateUser = append(predicateUser, entAuthor.FirstNameContainsFold(f.FirstName))
-	}
-	if f.MiddleName != "" {
-		predicateUser = append(predicateUser, entAuthor.MiddleNameContainsFold(f.MiddleName))
-	}
-	if f.LastName != "" {
-		predicateUser = append(predicateUser, entAuthor.LastNameContainsFold(f.LastName))
-	}
-
 	// sort by field
 	orderFunc := authorOrder(f.Base.Sort)
 
@@ -130,14 +143,37 @@ func (r *repository) List(ctx context.Context, f *author.Filter) ([]*author.Sche
 		return nil, 0, fmt.Errorf("get total author records: %w", err)
 	}
 
-	authors, err := r.ent.Author.Query().
-		WithBooks().
-		Where(predicateUser...).
+	query := r.ent.Author.Query().
+		WithBooks(liveBooks).
 		Where(entAuthor.DeletedAtIsNil()).
-		Limit(f.Base.Limit).
-		Offset(f.Base.Offset).
-		Order(orderFunc...).
-		All(ctx)
+		Limit(f.Base.Limit)
+
+	if f.Base.CursorPaging {
+		// Keyset pagination ignores sort and offset; it always walks
+		// (created_at, id) in descending order.
+		cursor, err := filter.DecodeCursor(f.Base.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		if cursor != nil {
+			query.Where(entAuthor.Or(
+				entAuthor.CreatedAtLT(cursor.CreatedAt),
+				entAuthor.And(
+					entAuthor.CreatedAtEQ(cursor.CreatedAt),
+					entAuthor.IDLT(cursor.ID),
+				),
+			))
+		}
+		query.Order(
+			entAuthor.ByCreatedAt(sql.OrderDesc()),
+			entAuthor.ByID(sql.OrderDesc()),
+		)
+	} else {
+		query.Offset(f.Base.Offset).
+			Order(orderFunc...)
+	}
+
+	authors, err := query.All(ctx)
 	if err != nil {
 		return nil, 0, fmt.Errorf("get author records: %w", err)
 	}
@@ -176,11 +212,14 @@ func (r *repository) List(ctx context.Context, f *author.Filter) ([]*author.Sche
 
 func (r *repository) Read(ctx context.Context, id uint64) (*author.Schema, error) {
 	found, err := r.ent.Author.Query().
-		WithBooks().
+		WithBooks(liveBooks).
 		Where(entAuthor.ID(id)).
 		Where(entAuthor.DeletedAtIsNil()).
 		First(ctx)
 	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, fmt.Errorf("author %d: %w", id, message.ErrNoRecord)
+		}
 		return nil, fmt.Errorf("error retrieving book: %w", err)
 	}
 
@@ -212,11 +251,15 @@ func (r *repository) Read(ctx context.Context, id uint64) (*author.Schema, error
 
 func (r *repository) Update(ctx context.Context, a *author.UpdateRequest) (*author.Schema, error) {
 	updated, err := r.ent.Author.UpdateOneID(a.ID).
+		Where(versionIn(a.IfMatch)...).
 		SetFirstName(a.FirstName).
 		SetMiddleName(a.MiddleName).
 		SetLastName(a.LastName).
 		Save(ctx)
 	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, r.missing(ctx, a.ID, a.IfMatch)
+		}
 		return nil, err
 	}
 
@@ -245,14 +288,64 @@ func (r *repository) Update(ctx context.Context, a *author.UpdateRequest) (*auth
 	}, nil
 }
 
-func (r *repository) Delete(ctx context.Context, authorID uint64) error {
+// Delete soft-deletes an author. When ifMatch is given, the author must still
+// be at one of those versions.
+func (r *repository) Delete(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
 	_, err := r.ent.Author.UpdateOneID(authorID).
+		Where(versionIn(ifMatch)...).
 		SetDeletedAt(time.Now()).
 		Save(ctx)
+	if gen.IsNotFound(err) {
+		return r.missing(ctx, authorID, ifMatch)
+	}
 
 	return err
 }
 
+// versionIn makes an update conditional on the versions of an If-Match
+// header. A conditional update only applies to an author that is not deleted.
+func versionIn(ifMatch []time.Time) []predicate.Author {
+	if ifMatch == nil {
+		return nil
+	}
+	return []predicate.Author{
+		entAuthor.DeletedAtIsNil(),
+		entAuthor.UpdatedAtIn(ifMatch...),
+	}
+}
+
+// missing tells why a conditional update of an author matched no row. Either
+// the author does not exist, or it has been modified since the versions in
+// ifMatch.
+func (r *repository) missing(ctx context.Context, authorID uint64, ifMatch []time.Time) error {
+	if len(ifMatch) > 0 {
+		exists, err := r.ent.Author.Query().
+			Where(entAuthor.ID(authorID), entAuthor.DeletedAtIsNil()).
+			Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("author %d: %w", authorID, message.ErrModified)
+		}
+	}
+
+	return fmt.Errorf("author %d: %w", authorID, message.ErrNoRecord)
+}
+
+func uniqueIDs(ids []uint64) map[uint64]struct{} {
+	unique := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	return unique
+}
+
+// liveBooks leaves soft-deleted books out of an author's book edge.
+func liveBooks(q *gen.BookQuery) {
+	q.Where(entBook.DeletedAtIsNil())
+}
+
 func authorOrder(sorts map[string]string) []entAuthor.OrderOption {
 	var orderFunc []entAuthor.OrderOption
 	for col, ord := range sorts {
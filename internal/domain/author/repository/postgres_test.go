@@ -18,6 +18,7 @@ import (
 
 	"micro/database"
 	"micro/ent/gen"
+	entAuthor "micro/ent/gen/author"
 	"micro/internal/domain/author"
 	"micro/internal/domain/book"
 	"micro/internal/utility/filter"
@@ -430,9 +431,6 @@ func TestRepository_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					FirstName:  "nonexistent",
-					MiddleName: "nonexistent",
-					LastName:   "nonexistent",
 				},
 			},
 			want:    make([]*author.Schema, 0),
@@ -595,7 +593,213 @@ func TestRepository_Delete(t *testing.T) {
 	}
 }
 
-func TestRepository_Search(t *testing.T) {}
+func TestRepository_Search(t *testing.T) {
+	client := dbClient()
+	repo := New(client)
+	search := NewSearch(client)
+	ctx := context.Background()
+
+	for _, req := range []*author.CreateRequest{
+		{FirstName: "Zebediah", MiddleName: "Quill", LastName: "Marsh"},
+		{FirstName: "Quill", LastName: "Zebediah"},
+		{FirstName: "Quill", LastName: "Marsh"},
+	} {
+		_, err := repo.Create(ctx, req)
+		assert.Nil(t, err)
+	}
+
+	type want struct {
+		total      int
+		highlights []string
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{
+			name:  "last name ranks above first name",
+			query: "zebediah",
+			want: want{
+				total: 2,
+				highlights: []string{
+					"Quill <b>Zebediah</b>",
+					"<b>Zebediah</b> Quill Marsh",
+				},
+			},
+		},
+		{
+			name:  "excluded word",
+			query: "quill -zebediah",
+			want: want{
+				total:      1,
+				highlights: []string{"<b>Quill</b> Marsh"},
+			},
+		},
+		{
+			name:  "no match",
+			query: "nonexistent",
+			want: want{
+				total:      0,
+				highlights: []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := search.Search(ctx, &author.Filter{
+				Base:  filter.Filter{Limit: 10, Search: true},
+				Query: tt.query,
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want.total, total)
+
+			highlights := make([]string, 0, len(got))
+			for _, a := range got {
+				assert.Greater(t, a.Rank, float64(0))
+				highlights = append(highlights, a.Highlight)
+			}
+			assert.Equal(t, tt.want.highlights, highlights)
+		})
+	}
+}
+
+func TestRepository_SearchNullColumns(t *testing.T) {
+	search := NewSearch(dbClient())
+	ctx := context.Background()
+
+	_, err := migrator.DB.ExecContext(ctx, `
+		INSERT INTO authors (first_name, middle_name, last_name, created_at, updated_at)
+		VALUES ('Nullable', NULL, 'Xanthippe', NULL, NULL)
+	`)
+	assert.Nil(t, err)
+
+	got, total, err := search.Search(ctx, &author.Filter{
+		Base:  filter.Filter{Limit: 10, Search: true},
+		Query: "xanthippe",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "", got[0].MiddleName)
+	assert.True(t, got[0].CreatedAt.IsZero())
+	assert.Equal(t, "Nullable <b>Xanthippe</b>", got[0].Highlight)
+}
+
+func TestElasticsearch_Hooks(t *testing.T) {
+	fake, es := newFakeES(t)
+	searcher := NewElasticsearch(es, testIndex)
+	assert.Nil(t, searcher.CreateIndex(context.Background()))
+
+	client := dbClient()
+	client.Author.Use(searcher.AuthorHook())
+	client.Book.Use(searcher.BookHook())
+
+	repo := New(client)
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, &author.CreateRequest{
+		FirstName: "Indexed",
+		LastName:  "Author",
+		Books: []author.Book{
+			{
+				Title:         "Indexed Title",
+				PublishedDate: "2022-02-12T15:04:05Z",
+				Description:   "Description",
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	id := fmt.Sprint(created.ID)
+	doc, ok := fake.doc(t, id)
+	assert.True(t, ok)
+	assert.Equal(t, "Indexed", doc.FirstName)
+	assert.Len(t, doc.Books, 1)
+	assert.Equal(t, "Indexed Title", doc.Books[0].Title)
+
+	_, err = repo.Update(ctx, &author.UpdateRequest{
+		ID:        created.ID,
+		FirstName: "Renamed",
+		LastName:  "Author",
+	})
+	assert.Nil(t, err)
+	doc, _ = fake.doc(t, id)
+	assert.Equal(t, "Renamed", doc.FirstName)
+
+	// Author documents embed their books, so a book change re-indexes them.
+	err = client.Book.UpdateOneID(created.Books[0].ID).SetTitle("Retitled").Exec(ctx)
+	assert.Nil(t, err)
+	doc, _ = fake.doc(t, id)
+	assert.Equal(t, "Retitled", doc.Books[0].Title)
+
+	err = client.Book.UpdateOneID(created.Books[0].ID).SetDeletedAt(time.Now()).Exec(ctx)
+	assert.Nil(t, err)
+	doc, _ = fake.doc(t, id)
+	assert.Len(t, doc.Books, 0)
+
+	err = repo.Delete(ctx, created.ID)
+	assert.Nil(t, err)
+	_, ok = fake.doc(t, id)
+	assert.False(t, ok)
+}
+
+func TestElasticsearch_HooksInTransaction(t *testing.T) {
+	fake, es := newFakeES(t)
+	searcher := NewElasticsearch(es, testIndex)
+	assert.Nil(t, searcher.CreateIndex(context.Background()))
+
+	client := dbClient()
+	client.Author.Use(searcher.AuthorHook())
+	ctx := context.Background()
+
+	// Nothing is indexed before the commit, nor after a rollback.
+	tx, err := client.Tx(ctx)
+	assert.Nil(t, err)
+	rolledBack, err := tx.Author.Create().SetFirstName("Rolled").SetLastName("Back").Save(ctx)
+	assert.Nil(t, err)
+	_, ok := fake.doc(t, fmt.Sprint(rolledBack.ID))
+	assert.False(t, ok)
+	assert.Nil(t, tx.Rollback())
+	_, ok = fake.doc(t, fmt.Sprint(rolledBack.ID))
+	assert.False(t, ok)
+
+	tx, err = client.Tx(ctx)
+	assert.Nil(t, err)
+	committed, err := tx.Author.Create().SetFirstName("Committed").SetLastName("Author").Save(ctx)
+	assert.Nil(t, err)
+	_, ok = fake.doc(t, fmt.Sprint(committed.ID))
+	assert.False(t, ok)
+	assert.Nil(t, tx.Commit())
+
+	doc, ok := fake.doc(t, fmt.Sprint(committed.ID))
+	assert.True(t, ok)
+	assert.Equal(t, "Committed", doc.FirstName)
+}
+
+func TestElasticsearch_Reindex(t *testing.T) {
+	fake, es := newFakeES(t)
+	searcher := NewElasticsearch(es, testIndex)
+
+	client := dbClient()
+	ctx := context.Background()
+
+	live, err := client.Author.Query().
+		Where(entAuthor.DeletedAtIsNil()).
+		IDs(ctx)
+	assert.Nil(t, err)
+
+	indexed, err := searcher.Reindex(ctx, client)
+	assert.Nil(t, err)
+	assert.Equal(t, len(live), indexed)
+	assert.Len(t, fake.docs(), len(live))
+	assert.Contains(t, fake.aliases, testIndex)
+
+	for _, id := range live {
+		_, ok := fake.doc(t, fmt.Sprint(id))
+		assert.True(t, ok)
+	}
+}
 
 func dbClient() *gen.Client {
 	drv := entsql.OpenDB(DBDriver, migrator.DB)
@@ -23,7 +23,7 @@ type Cache struct {
 type AuthorRedisService interface {
 	List(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error)
 	Update(ctx context.Context, toAuthor *author.UpdateRequest) (*author.Schema, error)
-	Delete(ctx context.Context, id uint64) error
+	Delete(ctx context.Context, id uint64, ifMatch ...time.Time) error
 }
 
 func NewRedisCache(service Author, cache *redis.Client) *Cache {
@@ -87,10 +87,10 @@ func (c *Cache) Update(ctx context.Context, toAuthor *author.UpdateRequest) (*au
 	return c.service.Update(ctx, toAuthor)
 }
 
-func (c *Cache) Delete(ctx context.Context, id uint64) error {
+func (c *Cache) Delete(ctx context.Context, id uint64, ifMatch ...time.Time) error {
 	c.invalidate(ctx)
 
-	return c.service.Delete(ctx, id)
+	return c.service.Delete(ctx, id, ifMatch...)
 }
 
 func (c *Cache) invalidate(ctx context.Context) {
@@ -6,17 +6,18 @@ package repository
 import (
 	"context"
 	"micro/internal/domain/author"
+	"time"
 )
 
 // AuthorRedisServiceMock is a mock implementation of AuthorRedisService.
 type AuthorRedisServiceMock struct {
-	DeleteFunc func(ctx context.Context, id uint64) error
+	DeleteFunc func(ctx context.Context, id uint64, ifMatch ...time.Time) error
 	ListFunc   func(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error)
 	UpdateFunc func(ctx context.Context, toAuthor *author.UpdateRequest) (*author.Schema, error)
 }
 
-func (m *AuthorRedisServiceMock) Delete(ctx context.Context, id uint64) error {
-	return m.DeleteFunc(ctx, id)
+func (m *AuthorRedisServiceMock) Delete(ctx context.Context, id uint64, ifMatch ...time.Time) error {
+	return m.DeleteFunc(ctx, id, ifMatch...)
 }
 
 func (m *AuthorRedisServiceMock) List(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
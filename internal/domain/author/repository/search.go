@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"entgo.io/ent/dialect/sql"
 	"go.opentelemetry.io/otel"
 
 	"micro/ent/gen"
@@ -12,78 +14,126 @@ import (
 	"micro/internal/domain/author"
 )
 
+// searchConfig is the text search configuration of the generated search
+// column. Names are not stemmed, so it is 'simple' rather than a language.
+const searchConfig = "simple"
+
 func NewSearch(db *gen.Client) *repository {
 	return &repository{ent: db}
 }
 
+// searchRow is an author along with its full-text search rank and highlight.
+// Columns that are nullable in the table are pointers, as a NULL cannot be
+// scanned into a string or time.Time.
+type searchRow struct {
+	ID         uint64     `sql:"id"`
+	FirstName  string     `sql:"first_name"`
+	MiddleName *string    `sql:"middle_name"`
+	LastName   string     `sql:"last_name"`
+	CreatedAt  *time.Time `sql:"created_at"`
+	UpdatedAt  *time.Time `sql:"updated_at"`
+	DeletedAt  *time.Time `sql:"deleted_at"`
+	Rank       float64    `sql:"rank"`
+	Highlight  string     `sql:"highlight"`
+}
+
 // Search using the same store. May use other store e.g. elasticsearch/bleve as
 // the search repository.
+//
+// The query is in web search engine syntax and is matched against the
+// generated search column of authors, which is backed by a GIN index. Results
+// are ordered by ts_rank, and their full names highlighted with ts_headline.
 func (r *repository) Search(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
 	tracer := otel.Tracer("")
 	ctx, span := tracer.Start(ctx, "AuthorSearch")
 	defer span.End()
 
-	var predicateUser []predicate.Author
-	if f.FirstName != "" {
-		predicateUser = append(predicateUser, entAuthor.FirstNameContainsFold(f.FirstName))
-	}
-	if f.MiddleName != "" {
-		predicateUser = append(predicateUser, entAuthor.MiddleNameContainsFold(f.MiddleName))
-	}
-	if f.LastName != "" {
-		predicateUser = append(predicateUser, entAuthor.LastNameContainsFold(f.LastName))
-	}
-
 	total, err := r.ent.Author.Query().
 		Where(entAuthor.DeletedAtIsNil()).
+		Where(matches(f.Query)).
 		Count(ctx)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error retrieving Author list: %w", err)
 	}
 
-	// The section where the search is done
-	//
-	// ILIKE is to search for case-insensitive in postgres
-	//
-	// Speed optimization is possible by creating an index on lowered names
-	// Reference: https://www.postgresql.org/docs/current/indexes-expressional.html
-	// CREATE INDEX on authors (LOWER(first_name));
-	// CREATE INDEX on authors (LOWER(middle_name));
-	// CREATE INDEX on authors (LOWER(last_name));
-	//
-	// Second alternative is to use ~*
-	// mods = append(mods, qm.Or(models.AuthorColumns.MiddleName+" ~* ?", f.Name))
-	//
-	// postgres has a builtin full-text search: https://www.postgresql.org/docs/current/textsearch.html
-	//
-	// Also, may use term frequency-inverted index search (tf-idf) like
-	// elasticsearch or bleve.
-	authors, err := r.ent.Author.Query().
-		WithBooks().
-		Where(predicateUser...).
+	query := r.ent.Author.Query().
 		Where(entAuthor.DeletedAtIsNil()).
-		Limit(f.Base.Limit).
-		Offset(f.Base.Offset).
-		Order(authorOrder(f.Base.Sort)...).
-		All(ctx)
+		Where(matches(f.Query)).
+		Order(byRank(f.Query), entAuthor.ByID(sql.OrderDesc()))
+	if !f.Base.DisablePaging {
+		query.Limit(f.Base.Limit).
+			Offset(f.Base.Offset)
+	}
+
+	var rows []*searchRow
+	err = query.Select(entAuthor.Columns...).Scan(ctx, &rows)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error retrieving Author list: %w", err)
 	}
 
-	var resp []*author.Schema
-
-	for _, a := range authors {
+	resp := make([]*author.Schema, 0, len(rows))
+	for _, a := range rows {
 		resp = append(resp, &author.Schema{
 			ID:         a.ID,
 			FirstName:  a.FirstName,
-			MiddleName: a.MiddleName,
+			MiddleName: valueOf(a.MiddleName),
 			LastName:   a.LastName,
-			CreatedAt:  a.CreatedAt,
-			UpdatedAt:  a.UpdatedAt,
+			CreatedAt:  valueOf(a.CreatedAt),
+			UpdatedAt:  valueOf(a.UpdatedAt),
 			DeletedAt:  a.DeletedAt,
 			Books:      nil,
+			Rank:       a.Rank,
+			Highlight:  a.Highlight,
 		})
 	}
 
 	return resp, total, nil
 }
+
+// valueOf returns the value p points to, or the zero value for NULL.
+func valueOf[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+// tsQuery parses q with websearch_to_tsquery, which never fails on user input.
+func tsQuery(q string) sql.Querier {
+	return sql.ExprFunc(func(b *sql.Builder) {
+		b.WriteString("websearch_to_tsquery('" + searchConfig + "', ").
+			Arg(q).
+			WriteString(")")
+	})
+}
+
+// matches filters authors whose search column matches q.
+func matches(q string) predicate.Author {
+	return func(s *sql.Selector) {
+		s.Where(sql.P(func(b *sql.Builder) {
+			b.Ident(s.C("search")).WriteString(" @@ ").Join(tsQuery(q))
+		}))
+	}
+}
+
+// byRank orders by relevance to q. ent has no API for ranking, so the rank
+// and headline are added to the selected columns here and read back into
+// searchRow by name.
+func byRank(q string) entAuthor.OrderOption {
+	return func(s *sql.Selector) {
+		s.AppendSelectExprAs(sql.ExprFunc(func(b *sql.Builder) {
+			b.WriteString("ts_rank(").Ident(s.C("search")).WriteString(", ").Join(tsQuery(q)).WriteString(")")
+		}), "rank")
+		s.AppendSelectExprAs(sql.ExprFunc(func(b *sql.Builder) {
+			b.WriteString("ts_headline('" + searchConfig + "', concat_ws(' ', ").
+				Ident(s.C(entAuthor.FieldFirstName)).WriteString(", NULLIF(").
+				Ident(s.C(entAuthor.FieldMiddleName)).WriteString(", ''), ").
+				Ident(s.C(entAuthor.FieldLastName)).
+				WriteString("), ").
+				Join(tsQuery(q)).
+				WriteString(", 'HighlightAll=true')")
+		}), "highlight")
+		s.OrderExpr(sql.Expr(`"rank" DESC`))
+	}
+}
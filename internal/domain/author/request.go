@@ -1,17 +1,21 @@
 package author
 
+import "time"
+
 type CreateRequest struct {
 	FirstName  string `json:"first_name" validate:"required"`
 	MiddleName string `json:"middle_name"`
 	LastName   string `json:"last_name" validate:"required"`
-	Books      []Book `json:"books"`
+	Books      []Book `json:"books" validate:"dive"`
 }
 
+// Book either links an existing book by its ID, or creates a new one when
+// ID is omitted.
 type Book struct {
 	BookID        uint64 `json:"id"`
-	Title         string `json:"title" validate:"required"`
-	PublishedDate string `json:"published_date" validate:"required"`
-	Description   string `json:"description" validate:"required"`
+	Title         string `json:"title" validate:"required_without=BookID"`
+	PublishedDate string `json:"published_date" validate:"required_without=BookID,omitempty,iso_date"`
+	Description   string `json:"description" validate:"required_without=BookID"`
 }
 
 type UpdateRequest struct {
@@ -19,4 +23,8 @@ type UpdateRequest struct {
 	FirstName  string `json:"first_name"`
 	MiddleName string `json:"middle_name,omitempty"`
 	LastName   string `json:"last_name"`
+
+	// IfMatch lists the versions from If-Match that the author must still be
+	// at. The update is unconditional when it is nil.
+	IfMatch []time.Time `json:"-"`
 }
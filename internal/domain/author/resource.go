@@ -1,6 +1,9 @@
 package author
 
 import (
+	"strconv"
+	"time"
+
 	"micro/internal/domain/book"
 )
 
@@ -10,6 +13,8 @@ type GetResponse struct {
 	MiddleName string         `json:"middle_name"`
 	LastName   string         `json:"last_name"`
 	Books      []*book.Schema `json:"books"`
+	Rank       float64        `json:"rank,omitempty"`
+	Highlight  string         `json:"highlight,omitempty"`
 }
 
 func Resource(a *Schema) *GetResponse {
@@ -23,6 +28,8 @@ func Resource(a *Schema) *GetResponse {
 		MiddleName: a.MiddleName,
 		LastName:   a.LastName,
 		Books:      a.Books,
+		Rank:       a.Rank,
+		Highlight:  a.Highlight,
 	}
 }
 
@@ -38,3 +45,39 @@ func Resources(books []*Schema) []*GetResponse {
 	}
 	return resources
 }
+
+// ExportColumns is the header of a CSV export.
+var ExportColumns = []string{"id", "first_name", "middle_name", "last_name", "created_at", "updated_at"}
+
+// ExportRes is an author in an export.
+type ExportRes struct {
+	ID         uint64    `json:"id"`
+	FirstName  string    `json:"first_name"`
+	MiddleName string    `json:"middle_name"`
+	LastName   string    `json:"last_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func ExportResource(a *Schema) *ExportRes {
+	return &ExportRes{
+		ID:         a.ID,
+		FirstName:  a.FirstName,
+		MiddleName: a.MiddleName,
+		LastName:   a.LastName,
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+	}
+}
+
+// CSV is the row of the author under ExportColumns.
+func (a *ExportRes) CSV() []string {
+	return []string{
+		strconv.FormatUint(a.ID, 10),
+		a.FirstName,
+		a.MiddleName,
+		a.LastName,
+		a.CreatedAt.Format(time.RFC3339Nano),
+		a.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
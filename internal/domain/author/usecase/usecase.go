@@ -2,19 +2,21 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"go.opentelemetry.io/otel"
 
 	"micro/config"
 	"micro/internal/domain/author"
 	"micro/internal/domain/author/repository"
+	"micro/internal/utility/apperror"
 )
 
 type AuthorUseCase struct {
 	repo repository.Author
 
 	searchRepo repository.Searcher
+	exporter   repository.Exporter
 
 	cacheLRU   repository.AuthorLRUService
 	cacheRedis repository.AuthorRedisService
@@ -27,14 +29,16 @@ type Author interface {
 	List(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error)
 	Read(ctx context.Context, authorID uint64) (*author.Schema, error)
 	Update(ctx context.Context, author *author.UpdateRequest) (*author.Schema, error)
-	Delete(ctx context.Context, authorID uint64) error
+	Delete(ctx context.Context, authorID uint64, ifMatch ...time.Time) error
+	Export(ctx context.Context, fn func(a *author.Schema) error) error
 }
 
-func New(c config.Cache, repo repository.Author, searcher repository.Searcher, cache repository.AuthorLRUService, redisCache repository.AuthorRedisService) *AuthorUseCase {
+func New(c config.Cache, repo repository.Author, searcher repository.Searcher, cache repository.AuthorLRUService, redisCache repository.AuthorRedisService, exporter repository.Exporter) *AuthorUseCase {
 	return &AuthorUseCase{
 		cfg:        c,
 		repo:       repo,
 		searchRepo: searcher,
+		exporter:   exporter,
 		cacheLRU:   cache,
 		cacheRedis: redisCache,
 	}
@@ -65,7 +69,7 @@ func (u *AuthorUseCase) List(ctx context.Context, f *author.Filter) ([]*author.S
 
 func (u *AuthorUseCase) Read(ctx context.Context, authorID uint64) (*author.Schema, error) {
 	if authorID == 0 {
-		return nil, errors.New("ID cannot be 0")
+		return nil, apperror.Validation("ID cannot be 0")
 	}
 	return u.repo.Read(ctx, authorID)
 }
@@ -79,15 +83,21 @@ func (u *AuthorUseCase) Update(ctx context.Context, author *author.UpdateRequest
 	return u.repo.Update(ctx, author)
 }
 
-func (u *AuthorUseCase) Delete(ctx context.Context, authorID uint64) error {
+func (u *AuthorUseCase) Delete(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
 	if authorID <= 0 {
-		return errors.New("ID cannot be 0 or less")
+		return apperror.Validation("ID cannot be 0 or less")
 	}
 
 	if u.cfg.Enable {
 		// As above
-		return u.cacheRedis.Delete(ctx, authorID)
+		return u.cacheRedis.Delete(ctx, authorID, ifMatch...)
 	}
 
-	return u.repo.Delete(ctx, authorID)
+	return u.repo.Delete(ctx, authorID, ifMatch...)
+}
+
+// Export reads authors from the database rather than a cache, as an export
+// wants every author as it is now.
+func (u *AuthorUseCase) Export(ctx context.Context, fn func(a *author.Schema) error) error {
+	return u.exporter.Export(ctx, fn)
 }
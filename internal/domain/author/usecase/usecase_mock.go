@@ -6,12 +6,14 @@ package usecase
 import (
 	"context"
 	"micro/internal/domain/author"
+	"time"
 )
 
 // AuthorMock is a mock implementation of Author.
 type AuthorMock struct {
 	CreateFunc func(ctx context.Context, a *author.CreateRequest) (*author.Schema, error)
-	DeleteFunc func(ctx context.Context, authorID uint64) error
+	DeleteFunc func(ctx context.Context, authorID uint64, ifMatch ...time.Time) error
+	ExportFunc func(ctx context.Context, fn func(a *author.Schema) error) error
 	ListFunc   func(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error)
 	ReadFunc   func(ctx context.Context, authorID uint64) (*author.Schema, error)
 	UpdateFunc func(ctx context.Context, authorMiripParam *author.UpdateRequest) (*author.Schema, error)
@@ -21,8 +23,12 @@ func (m *AuthorMock) Create(ctx context.Context, a *author.CreateRequest) (*auth
 	return m.CreateFunc(ctx, a)
 }
 
-func (m *AuthorMock) Delete(ctx context.Context, authorID uint64) error {
-	return m.DeleteFunc(ctx, authorID)
+func (m *AuthorMock) Delete(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
+	return m.DeleteFunc(ctx, authorID, ifMatch...)
+}
+
+func (m *AuthorMock) Export(ctx context.Context, fn func(a *author.Schema) error) error {
+	return m.ExportFunc(ctx, fn)
 }
 
 func (m *AuthorMock) List(ctx context.Context, f *author.Filter) ([]*author.Schema, int, error) {
@@ -2,7 +2,6 @@ package usecase
 
 import (
 	"context"
-	"errors"
 	"micro/internal/domain/book"
 	"testing"
 	"time"
@@ -12,6 +11,7 @@ import (
 	"micro/config"
 	"micro/internal/domain/author"
 	"micro/internal/domain/author/repository"
+	"micro/internal/utility/apperror"
 	"micro/internal/utility/filter"
 )
 
@@ -75,7 +75,7 @@ func TestAuthorUseCase_Create(t *testing.T) {
 				},
 			}
 
-			uc := New(c, repoAuthor, nil, nil, nil)
+			uc := New(c, repoAuthor, nil, nil, nil, nil)
 
 			got, err := uc.Create(context.Background(), test.args.CreateRequest)
 			assert.Equal(t, test.want.err, err)
@@ -153,9 +153,7 @@ func TestAuthorUseCase_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					FirstName:  "",
-					MiddleName: "",
-					LastName:   "",
+					Query: "",
 				},
 			},
 			want: want{
@@ -177,9 +175,7 @@ func TestAuthorUseCase_List(t *testing.T) {
 						Sort:          nil,
 						Search:        true,
 					},
-					FirstName:  "2 First",
-					MiddleName: "",
-					LastName:   "",
+					Query: "2 First",
 				},
 			},
 			want: want{
@@ -211,7 +207,7 @@ func TestAuthorUseCase_List(t *testing.T) {
 				},
 			}
 
-			uc := New(c, repoAuthor, searchMock, nil, cacheMock)
+			uc := New(c, repoAuthor, searchMock, nil, cacheMock, nil)
 
 			got, total, err := uc.List(test.args.Context, test.args.filter)
 			assert.Equal(t, test.want.error, err)
@@ -265,7 +261,7 @@ func TestAuthorUseCase_Read(t *testing.T) {
 			},
 			want: want{
 				Author: nil,
-				err:    errors.New("ID cannot be 0"),
+				err:    apperror.Validation("ID cannot be 0"),
 			},
 		},
 	}
@@ -279,7 +275,7 @@ func TestAuthorUseCase_Read(t *testing.T) {
 				},
 			}
 
-			uc := New(c, repoAuthor, nil, nil, nil)
+			uc := New(c, repoAuthor, nil, nil, nil, nil)
 
 			got, err := uc.Read(context.Background(), test.args.ID)
 			assert.Equal(t, test.want.err, err)
@@ -359,7 +355,7 @@ func TestAuthorUseCase_Update(t *testing.T) {
 				},
 			}
 
-			uc := New(c, repoAuthor, nil, nil, cacheMock)
+			uc := New(c, repoAuthor, nil, nil, cacheMock, nil)
 
 			update, err := uc.Update(test.args.Context, test.args.UpdateRequest)
 			assert.Equal(t, test.want.error, err)
@@ -407,7 +403,7 @@ func TestAuthorUseCase_Delete(t *testing.T) {
 				ID:      0,
 			},
 			want: want{
-				error: errors.New("ID cannot be 0 or less"),
+				error: apperror.Validation("ID cannot be 0 or less"),
 			},
 		},
 	}
@@ -416,17 +412,17 @@ func TestAuthorUseCase_Delete(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 
 			repoAuthor := &AuthorMock{
-				DeleteFunc: func(ctx context.Context, authorID uint64) error {
+				DeleteFunc: func(ctx context.Context, authorID uint64, ifMatch ...time.Time) error {
 					return test.want.error
 				},
 			}
 			cacheMock := &repository.AuthorRedisServiceMock{
-				DeleteFunc: func(ctx context.Context, id uint64) error {
+				DeleteFunc: func(ctx context.Context, id uint64, ifMatch ...time.Time) error {
 					return test.want.error
 				},
 			}
 
-			uc := New(c, repoAuthor, nil, nil, cacheMock)
+			uc := New(c, repoAuthor, nil, nil, cacheMock, nil)
 
 			err := uc.Delete(test.args.Context, test.args.ID)
 			assert.Equal(t, test.want.error, err)
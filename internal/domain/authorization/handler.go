@@ -0,0 +1,122 @@
+package authorization
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"micro/internal/utility/message"
+	"micro/internal/utility/param"
+	"micro/internal/utility/respond"
+)
+
+type Handler struct {
+	repo Repo
+}
+
+func NewHandler(repo Repo) *Handler {
+	return &Handler{
+		repo: repo,
+	}
+}
+
+// Roles lists the roles
+// @Summary List the roles
+// @Description Lists every role with the permissions it grants.
+// @Produce json
+// @Success 200 {object} []RoleRes
+// @Failure 401 {object} respond.Problem
+// @Failure 403 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/roles [get]
+func (h *Handler) Roles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.repo.Roles(r.Context())
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, Resources(roles))
+}
+
+// UserRoles lists the roles of a user
+// @Summary List the roles of a user
+// @Description Lists the roles granted to a user, with the permissions each one grants.
+// @Produce json
+// @Param userID path int true "user ID"
+// @Success 200 {object} []RoleRes
+// @Failure 400 {object} respond.Problem
+// @Failure 401 {object} respond.Problem
+// @Failure 403 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/users/{userID}/roles [get]
+func (h *Handler) UserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := param.UInt64(r, "userID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	roles, err := h.repo.UserRoles(r.Context(), userID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, Resources(roles))
+}
+
+// Grant grants a role to a user
+// @Summary Grant a role to a user
+// @Description Grants a role to a user. Granting a role the user already has changes nothing.
+// @Param userID path int true "user ID"
+// @Param role path string true "role name"
+// @Success 204
+// @Failure 400 {object} respond.Problem
+// @Failure 401 {object} respond.Problem
+// @Failure 403 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/users/{userID}/roles/{role} [put]
+func (h *Handler) Grant(w http.ResponseWriter, r *http.Request) {
+	userID, err := param.UInt64(r, "userID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	if err := h.repo.Grant(r.Context(), userID, chi.URLParam(r, "role")); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
+
+// Revoke revokes a role from a user
+// @Summary Revoke a role from a user
+// @Description Takes a role away from a user, if they have it.
+// @Param userID path int true "user ID"
+// @Param role path string true "role name"
+// @Success 204
+// @Failure 400 {object} respond.Problem
+// @Failure 401 {object} respond.Problem
+// @Failure 403 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/users/{userID}/roles/{role} [delete]
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := param.UInt64(r, "userID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	if err := h.repo.Revoke(r.Context(), userID, chi.URLParam(r, "role")); err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Status(w, http.StatusNoContent)
+}
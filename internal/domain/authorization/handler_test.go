@@ -0,0 +1,135 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/ent/gen"
+)
+
+func allow(next http.Handler) http.Handler {
+	return next
+}
+
+func TestHandler_UserRoles(t *testing.T) {
+	admin := &gen.Role{ID: 1, Name: "admin", Edges: gen.RoleEdges{
+		Permissions: []*gen.Permission{{ID: 1, Name: "books:write"}, {ID: 2, Name: "roles:manage"}},
+	}}
+
+	tests := []struct {
+		name   string
+		path   string
+		roles  []*gen.Role
+		err    error
+		want   []*RoleRes
+		status int
+	}{
+		{
+			name:   "roles",
+			path:   "/api/v1/users/2/roles",
+			roles:  []*gen.Role{admin},
+			want:   []*RoleRes{{ID: 1, Name: "admin", Permissions: []string{"books:write", "roles:manage"}}},
+			status: http.StatusOK,
+		},
+		{name: "no roles", path: "/api/v1/users/2/roles", want: []*RoleRes{}, status: http.StatusOK},
+		{name: "invalid user id", path: "/api/v1/users/two/roles", status: http.StatusBadRequest},
+		{name: "user not found", path: "/api/v1/users/2/roles", err: ErrUserNotFound, status: http.StatusNotFound},
+		{name: "lower layer error", path: "/api/v1/users/2/roles", err: errors.New("lower layer error"), status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &RepoMock{
+				UserRolesFunc: func(ctx context.Context, userID uint64) ([]*gen.Role, error) {
+					assert.Equal(t, uint64(2), userID)
+					return tt.roles, tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, allow, repo)
+
+			ww := httptest.NewRecorder()
+			router.ServeHTTP(ww, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, ww.Code)
+			if tt.want != nil {
+				var got []*RoleRes
+				assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_GrantRevoke(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		err    error
+		status int
+	}{
+		{name: "grant", method: http.MethodPut, path: "/api/v1/users/2/roles/admin", status: http.StatusNoContent},
+		{name: "revoke", method: http.MethodDelete, path: "/api/v1/users/2/roles/admin", status: http.StatusNoContent},
+		{name: "grant unknown role", method: http.MethodPut, path: "/api/v1/users/2/roles/admin", err: ErrRoleNotFound, status: http.StatusNotFound},
+		{name: "revoke from unknown user", method: http.MethodDelete, path: "/api/v1/users/2/roles/admin", err: ErrUserNotFound, status: http.StatusNotFound},
+		{name: "invalid user id", method: http.MethodPut, path: "/api/v1/users/0x2/roles/admin", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called string
+			change := func(method string) func(ctx context.Context, userID uint64, roleName string) error {
+				return func(ctx context.Context, userID uint64, roleName string) error {
+					called = method
+					assert.Equal(t, uint64(2), userID)
+					assert.Equal(t, "admin", roleName)
+					return tt.err
+				}
+			}
+			repo := &RepoMock{
+				GrantFunc:  change(http.MethodPut),
+				RevokeFunc: change(http.MethodDelete),
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, allow, repo)
+
+			ww := httptest.NewRecorder()
+			router.ServeHTTP(ww, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.status, ww.Code)
+			if tt.status != http.StatusBadRequest {
+				assert.Equal(t, tt.method, called)
+			}
+		})
+	}
+}
+
+func TestRegisterHTTPEndPoints_Manage(t *testing.T) {
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+
+	router := chi.NewRouter()
+	RegisterHTTPEndPoints(router, deny, &RepoMock{})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/roles", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/users/2/roles", nil),
+		httptest.NewRequest(http.MethodPut, "/api/v1/users/2/roles/admin", nil),
+		httptest.NewRequest(http.MethodDelete, "/api/v1/users/2/roles/admin", nil),
+	} {
+		ww := httptest.NewRecorder()
+		router.ServeHTTP(ww, req)
+		assert.Equal(t, http.StatusForbidden, ww.Code, req.Method+" "+req.URL.Path)
+	}
+}
@@ -0,0 +1,34 @@
+package authorization
+
+import (
+	"micro/ent/gen"
+)
+
+type RoleRes struct {
+	ID          uint64   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+func Resource(r *gen.Role) *RoleRes {
+	permissions := make([]string, 0, len(r.Edges.Permissions))
+	for _, p := range r.Edges.Permissions {
+		permissions = append(permissions, p.Name)
+	}
+
+	return &RoleRes{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: permissions,
+	}
+}
+
+func Resources(roles []*gen.Role) []*RoleRes {
+	resources := make([]*RoleRes, 0, len(roles))
+	for _, r := range roles {
+		resources = append(resources, Resource(r))
+	}
+	return resources
+}
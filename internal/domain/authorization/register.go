@@ -0,0 +1,26 @@
+package authorization
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterHTTPEndPoints mounts the role administration behind manage.
+func RegisterHTTPEndPoints(router *chi.Mux, manage func(http.Handler) http.Handler, repo Repo) *Handler {
+	h := NewHandler(repo)
+
+	router.Route("/api/v1/roles", func(router chi.Router) {
+		router.Use(manage)
+		router.Get("/", h.Roles)
+	})
+
+	router.Route("/api/v1/users/{userID}/roles", func(router chi.Router) {
+		router.Use(manage)
+		router.Get("/", h.UserRoles)
+		router.Put("/{role}", h.Grant)
+		router.Delete("/{role}", h.Revoke)
+	})
+
+	return h
+}
@@ -0,0 +1,120 @@
+package authorization
+
+import (
+	"context"
+
+	"micro/ent/gen"
+	"micro/ent/gen/permission"
+	"micro/ent/gen/role"
+	"micro/ent/gen/user"
+	"micro/internal/middleware"
+	"micro/internal/utility/apperror"
+)
+
+var (
+	ErrRoleNotFound = apperror.NotFound("role not found")
+	ErrUserNotFound = apperror.NotFound("user not found")
+)
+
+//go:generate mirip -rm -pkg authorization -out repository_mock.go . Repo
+type Repo interface {
+	middleware.Authorizer
+	Roles(ctx context.Context) ([]*gen.Role, error)
+	UserRoles(ctx context.Context, userID uint64) ([]*gen.Role, error)
+	Grant(ctx context.Context, userID uint64, roleName string) error
+	Revoke(ctx context.Context, userID uint64, roleName string) error
+}
+
+type repo struct {
+	ent *gen.Client
+}
+
+func NewRepo(ent *gen.Client) *repo {
+	return &repo{ent: ent}
+}
+
+func (r *repo) HasPermission(ctx context.Context, userID uint64, name string) (bool, error) {
+	return r.ent.Permission.Query().
+		Where(
+			permission.Name(name),
+			permission.HasRolesWith(role.HasUsersWith(user.ID(userID))),
+		).
+		Exist(ctx)
+}
+
+// Roles lists every role with its permissions.
+func (r *repo) Roles(ctx context.Context) ([]*gen.Role, error) {
+	return r.ent.Role.Query().
+		WithPermissions().
+		Order(role.ByName()).
+		All(ctx)
+}
+
+func (r *repo) UserRoles(ctx context.Context, userID uint64) ([]*gen.Role, error) {
+	if err := r.userExists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return r.ent.Role.Query().
+		Where(role.HasUsersWith(user.ID(userID))).
+		WithPermissions().
+		Order(role.ByName()).
+		All(ctx)
+}
+
+// Grant gives the role to the user. Granting a role the user already has
+// changes nothing.
+func (r *repo) Grant(ctx context.Context, userID uint64, roleName string) error {
+	roleID, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	if err := r.userExists(ctx, userID); err != nil {
+		return err
+	}
+
+	granted, err := r.ent.User.Query().
+		Where(user.ID(userID), user.HasRolesWith(role.ID(roleID))).
+		Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if granted {
+		return nil
+	}
+
+	return r.ent.User.UpdateOneID(userID).AddRoleIDs(roleID).Exec(ctx)
+}
+
+// Revoke takes the role away from the user, if they have it.
+func (r *repo) Revoke(ctx context.Context, userID uint64, roleName string) error {
+	roleID, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+
+	err = r.ent.User.UpdateOneID(userID).RemoveRoleIDs(roleID).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (r *repo) roleID(ctx context.Context, name string) (uint64, error) {
+	id, err := r.ent.Role.Query().Where(role.Name(name)).OnlyID(ctx)
+	if gen.IsNotFound(err) {
+		return 0, ErrRoleNotFound
+	}
+	return id, err
+}
+
+func (r *repo) userExists(ctx context.Context, userID uint64) error {
+	exists, err := r.ent.User.Query().Where(user.ID(userID)).Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return nil
+}
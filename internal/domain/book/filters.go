@@ -2,30 +2,60 @@ package book
 
 import (
 	"net/url"
+	"strings"
 
 	"micro/internal/utility/filter"
 )
 
+const (
+	// TrashedOnly lists soft-deleted books only.
+	TrashedOnly = "only"
+	// TrashedWith lists soft-deleted books alongside live ones.
+	TrashedWith = "with"
+)
+
 type Filter struct {
-	Base          filter.Filter
-	Title         string `json:"title"`
-	Description   string `json:"description"`
+	Base filter.Filter
+	// Query is a full-text search in web search engine syntax, e.g.
+	// `"go programming" -python`. It matches titles and descriptions.
+	Query         string `json:"q"`
 	PublishedDate string `json:"published_date"`
+	Trashed       string `json:"trashed"`
 }
 
 func Filters(queries url.Values) *Filter {
 	f := filter.New(queries)
-	switch {
-	case queries.Has("title"):
-		fallthrough
-	case queries.Has("description"):
+	if queries.Get("q") != "" {
 		f.Search = true
+		// Searches are ranked by relevance and paged by offset only.
+		f.CursorPaging = false
+	}
+
+	var trashed string
+	switch queries.Get("trashed") {
+	case TrashedOnly:
+		trashed = TrashedOnly
+	case TrashedWith:
+		trashed = TrashedWith
 	}
 
 	return &Filter{
 		Base:          *f,
-		Title:         queries.Get("title"),
-		Description:   queries.Get("description"),
+		Query:         queries.Get("q"),
 		PublishedDate: queries.Get("published_date"),
+		Trashed:       trashed,
+	}
+}
+
+// IncludeAuthors reports whether `?include=authors` was asked for. Include
+// takes a comma separated list of relations.
+func IncludeAuthors(queries url.Values) bool {
+	for _, include := range queries["include"] {
+		for _, relation := range strings.Split(include, ",") {
+			if strings.TrimSpace(relation) == "authors" {
+				return true
+			}
+		}
 	}
+	return false
 }
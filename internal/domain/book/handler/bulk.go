@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"micro/internal/domain/book"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/internal/utility/respond"
+	"micro/internal/utility/validate"
+)
+
+// errUndone is the detail of an item of an atomic bulk request that was not
+// written because another item failed.
+var errUndone = errors.New("not written because another item failed")
+
+// BulkCreate creates many books
+// @Summary Create many Books
+// @Description Create up to 1000 books with one statement. Each book is validated on its own and the results are in the order of the request. Without atomic, valid books are created even if others are not, and the response is 207 when only some are created.
+// @Accept json
+// @Produce json
+// @Param Books body book.BulkCreateRequest true "Books to create"
+// @Success 201 {object} book.BulkRes
+// @Success 207 {object} book.BulkRes
+// @Failure 400 {object} book.BulkRes
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/bulk [post]
+func (h *Handler) BulkCreate(w http.ResponseWriter, r *http.Request) {
+	var req book.BulkCreateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+
+	errs := validate.Validate(h.validate, req)
+	if errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
+		return
+	}
+
+	results := make([]book.BulkResult, len(req.Books))
+	var (
+		valid   []*book.CreateRequest
+		indexes []int
+	)
+	for i, b := range req.Books {
+		results[i].Index = i
+		if b == nil {
+			b = &book.CreateRequest{}
+		}
+
+		errs := validate.Validate(h.validate, b)
+		if errs != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Detail = message.ValidationFailed
+			results[i].Errors = errs
+			continue
+		}
+
+		valid = append(valid, b)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) == 0 || (req.Atomic && len(valid) < len(req.Books)) {
+		respondBulk(w, http.StatusCreated, results)
+		return
+	}
+
+	ids, err := h.useCase.CreateMany(r.Context(), valid)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	for j, i := range indexes {
+		results[i].ID = ids[j]
+		results[i].Status = http.StatusCreated
+	}
+
+	respondBulk(w, http.StatusCreated, results)
+}
+
+// BulkUpdate sets fields of many books
+// @Summary Update many Books
+// @Description Set the same fields on up to 1000 books with one statement. Fields left out of set are kept. Without atomic, the books that are found are updated even if others are not, and the response is 207 when only some are updated.
+// @Accept json
+// @Produce json
+// @Param Books body book.BulkUpdateRequest true "IDs of the books and the fields to set"
+// @Success 200 {object} book.BulkRes
+// @Success 207 {object} book.BulkRes
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} book.BulkRes
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/bulk [patch]
+func (h *Handler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	var req book.BulkUpdateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+
+	errs := validate.Validate(h.validate, req)
+	if req.Set.Empty() {
+		errs = append(errs, apperror.FieldError{
+			Field:   "set",
+			Rule:    "required",
+			Message: "set must have at least one field",
+		})
+	}
+	if errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
+		return
+	}
+
+	ids, err := h.useCase.UpdateMany(r.Context(), &req)
+	if err != nil && !errors.Is(err, message.ErrNoRecord) {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respondBulk(w, http.StatusOK, written(req.IDs, ids, err != nil))
+}
+
+// BulkDelete deletes many books
+// @Summary Delete many Books
+// @Description Move up to 1000 books to the trash with one statement. Without atomic, the books that are found are deleted even if others are not, and the response is 207 when only some are deleted.
+// @Accept json
+// @Produce json
+// @Param Books body book.BulkDeleteRequest true "IDs of the books"
+// @Success 200 {object} book.BulkRes
+// @Success 207 {object} book.BulkRes
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} book.BulkRes
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/bulk [delete]
+func (h *Handler) BulkDelete(w http.ResponseWriter, r *http.Request) {
+	var req book.BulkDeleteRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+
+	errs := validate.Validate(h.validate, req)
+	if errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
+		return
+	}
+
+	ids, err := h.useCase.DeleteMany(r.Context(), &req)
+	if err != nil && !errors.Is(err, message.ErrNoRecord) {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respondBulk(w, http.StatusOK, written(req.IDs, ids, err != nil))
+}
+
+// written gives the result of each of bookIDs from the IDs that a bulk write
+// wrote. When the write was rolled back, the books that were found are left
+// undone.
+func written(bookIDs, ids []uint64, rolledBack bool) []book.BulkResult {
+	found := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		found[id] = struct{}{}
+	}
+
+	results := make([]book.BulkResult, len(bookIDs))
+	for i, id := range bookIDs {
+		results[i] = book.BulkResult{Index: i, ID: id}
+		if _, ok := found[id]; !ok {
+			results[i].Status = http.StatusNotFound
+			results[i].Detail = message.ErrNoRecord.Error()
+		} else if !rolledBack {
+			results[i].Status = http.StatusOK
+		}
+	}
+
+	return results
+}
+
+// respondBulk answers with ok when every item succeeded, with 207 Multi-Status
+// when only some did, and otherwise with the status of the first item that
+// failed. Items without a status were left undone.
+func respondBulk(w http.ResponseWriter, ok int, results []book.BulkResult) {
+	var status, succeeded int
+	for i := range results {
+		switch results[i].Status {
+		case ok:
+			succeeded++
+		case 0:
+			results[i].Status = http.StatusFailedDependency
+			results[i].Detail = errUndone.Error()
+		default:
+			if status == 0 {
+				status = results[i].Status
+			}
+		}
+	}
+
+	switch {
+	case succeeded == len(results):
+		status = ok
+	case succeeded > 0:
+		status = http.StatusMultiStatus
+	}
+
+	respond.Json(w, status, &book.BulkRes{Results: results})
+}
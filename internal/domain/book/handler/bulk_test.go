@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/domain/book"
+	"micro/internal/domain/book/usecase"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/third_party/validate"
+)
+
+func TestHandler_BulkCreate(t *testing.T) {
+	valid := `{"title": "title", "published_date": "2020-01-01", "image_url": "https://example.com/cover.png", "description": "description"}`
+	invalid := `{"title": "title", "published_date": "2020-01-01", "image_url": "https://example.com/cover.png"}`
+
+	type want struct {
+		status   int
+		created  int
+		statuses []int
+	}
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want want
+	}{
+		{
+			name: "all valid",
+			body: `{"books": [` + valid + `, ` + valid + `]}`,
+			want: want{status: http.StatusCreated, created: 2, statuses: []int{http.StatusCreated, http.StatusCreated}},
+		},
+		{
+			name: "some invalid",
+			body: `{"books": [` + valid + `, ` + invalid + `, ` + valid + `]}`,
+			want: want{
+				status:   http.StatusMultiStatus,
+				created:  2,
+				statuses: []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated},
+			},
+		},
+		{
+			name: "some invalid in atomic mode",
+			body: `{"atomic": true, "books": [` + valid + `, ` + invalid + `]}`,
+			want: want{
+				status:   http.StatusBadRequest,
+				statuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+			},
+		},
+		{
+			name: "all invalid",
+			body: `{"books": [` + invalid + `, null]}`,
+			want: want{
+				status:   http.StatusBadRequest,
+				statuses: []int{http.StatusBadRequest, http.StatusBadRequest},
+			},
+		},
+		{
+			name: "no books",
+			body: `{"books": []}`,
+			want: want{status: http.StatusBadRequest},
+		},
+		{
+			name: "too many books",
+			body: `{"books": [` + strings.Repeat(valid+",", 1000) + valid + `]}`,
+			want: want{status: http.StatusBadRequest},
+		},
+		{
+			name: "lower layer error",
+			body: `{"books": [` + valid + `]}`,
+			err:  errors.New("lower layer error"),
+			want: want{status: http.StatusInternalServerError, created: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created int
+
+			uc := &usecase.BookMock{
+				CreateManyFunc: func(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+					created = len(books)
+					ids := make([]uint64, len(books))
+					for i := range books {
+						ids[i] = uint64(i + 1)
+					}
+					return ids, tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(http.MethodPost, "/api/v1/book/bulk", strings.NewReader(tt.body))
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			assert.Equal(t, tt.want.created, created)
+			if tt.want.statuses == nil {
+				return
+			}
+
+			var got book.BulkRes
+			assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+			assert.Len(t, got.Results, len(tt.want.statuses))
+			for i, res := range got.Results {
+				assert.Equal(t, i, res.Index)
+				assert.Equal(t, tt.want.statuses[i], res.Status, fmt.Sprintf("result %d", i))
+				switch res.Status {
+				case http.StatusCreated:
+					assert.NotZero(t, res.ID)
+				case http.StatusBadRequest:
+					assert.NotEmpty(t, res.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_BulkCreate_FieldErrors(t *testing.T) {
+	uc := &usecase.BookMock{
+		CreateManyFunc: func(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+			return []uint64{7}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+	RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+	body := `{"books": [
+		{"title": "title", "published_date": "2020-01-01", "image_url": "https://example.com/cover.png", "description": "description"},
+		{"title": "title", "published_date": "01/01/2020", "image_url": "https://example.com/cover.png", "description": "description"}
+	]}`
+	rr := httptest.NewRequest(http.MethodPost, "/api/v1/book/bulk", strings.NewReader(body))
+	ww := httptest.NewRecorder()
+
+	router.ServeHTTP(ww, rr)
+
+	var got book.BulkRes
+	assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+	assert.Equal(t, []book.BulkResult{
+		{Index: 0, ID: 7, Status: http.StatusCreated},
+		{
+			Index:  1,
+			Status: http.StatusBadRequest,
+			Detail: message.ValidationFailed,
+			Errors: []apperror.FieldError{{
+				Field:   "published_date",
+				Rule:    "iso_date",
+				Message: "published_date must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z",
+			}},
+		},
+	}, got.Results)
+}
+
+func TestHandler_BulkWrite(t *testing.T) {
+	type want struct {
+		status   int
+		statuses []int
+	}
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		written []uint64
+		err     error
+		want    want
+	}{
+		{
+			name:    "update all found",
+			method:  http.MethodPatch,
+			body:    `{"ids": [1, 2], "set": {"description": "new"}}`,
+			written: []uint64{2, 1},
+			want:    want{status: http.StatusOK, statuses: []int{http.StatusOK, http.StatusOK}},
+		},
+		{
+			name:    "update some found",
+			method:  http.MethodPatch,
+			body:    `{"ids": [1, 2], "set": {"image_url": "https://example.com/cover.png"}}`,
+			written: []uint64{1},
+			want:    want{status: http.StatusMultiStatus, statuses: []int{http.StatusOK, http.StatusNotFound}},
+		},
+		{
+			name:    "update some found in atomic mode",
+			method:  http.MethodPatch,
+			body:    `{"ids": [1, 2], "set": {"title": "new"}, "atomic": true}`,
+			written: []uint64{1},
+			err:     fmt.Errorf("book 2: %w", message.ErrNoRecord),
+			want:    want{status: http.StatusNotFound, statuses: []int{http.StatusFailedDependency, http.StatusNotFound}},
+		},
+		{
+			name:   "update nothing to set",
+			method: http.MethodPatch,
+			body:   `{"ids": [1, 2], "set": {}}`,
+			want:   want{status: http.StatusBadRequest},
+		},
+		{
+			name:   "update invalid field",
+			method: http.MethodPatch,
+			body:   `{"ids": [1], "set": {"published_date": "yesterday"}}`,
+			want:   want{status: http.StatusBadRequest},
+		},
+		{
+			name:   "update lower layer error",
+			method: http.MethodPatch,
+			body:   `{"ids": [1], "set": {"title": "new"}}`,
+			err:    errors.New("lower layer error"),
+			want:   want{status: http.StatusInternalServerError},
+		},
+		{
+			name:    "delete all found",
+			method:  http.MethodDelete,
+			body:    `{"ids": [3]}`,
+			written: []uint64{3},
+			want:    want{status: http.StatusOK, statuses: []int{http.StatusOK}},
+		},
+		{
+			name:   "delete none found",
+			method: http.MethodDelete,
+			body:   `{"ids": [3, 4]}`,
+			want:   want{status: http.StatusNotFound, statuses: []int{http.StatusNotFound, http.StatusNotFound}},
+		},
+		{
+			name:   "delete no ids",
+			method: http.MethodDelete,
+			body:   `{"ids": []}`,
+			want:   want{status: http.StatusBadRequest},
+		},
+		{
+			name:   "delete zero id",
+			method: http.MethodDelete,
+			body:   `{"ids": [0]}`,
+			want:   want{status: http.StatusBadRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &usecase.BookMock{
+				UpdateManyFunc: func(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+					return tt.written, tt.err
+				},
+				DeleteManyFunc: func(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error) {
+					return tt.written, tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(tt.method, "/api/v1/book/bulk", strings.NewReader(tt.body))
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			if tt.want.statuses == nil {
+				return
+			}
+
+			var got book.BulkRes
+			assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+			assert.Len(t, got.Results, len(tt.want.statuses))
+			for i, res := range got.Results {
+				assert.Equal(t, tt.want.statuses[i], res.Status, fmt.Sprintf("result %d", i))
+				assert.NotZero(t, res.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"micro/internal/domain/book"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/message"
+	"micro/internal/utility/param"
+	"micro/internal/utility/respond"
+)
+
+// maxCoverSize is the largest cover that can be uploaded, in bytes.
+const maxCoverSize = 5 << 20
+
+// coverTypes are the content types that are accepted for a cover. The type
+// is sniffed from the file, not taken from the client.
+var coverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+var (
+	errCoverForm = errors.New("request must be multipart/form-data with a cover file")
+	errCoverType = errors.New("cover must be a JPEG, PNG or GIF image")
+	errCoverSize = fmt.Errorf("cover must be at most %d bytes", maxCoverSize)
+	errNoCover   = apperror.Validation("multipart form has no cover file")
+)
+
+// SetCover uploads the cover of a book
+// @Summary Upload a Book cover
+// @Description Upload a JPEG, PNG or GIF image of at most 5 MiB in the cover field of a multipart form. Thumbnails are made from it, and the image_url of the book is set to the stored cover.
+// @Accept multipart/form-data
+// @Produce json
+// @Param bookID path int true "book ID"
+// @Param cover formData file true "Cover image"
+// @Success 200 {object} book.CoverRes
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 413 {object} respond.Problem
+// @Failure 415 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/{bookID}/cover [post]
+func (h *Handler) SetCover(w http.ResponseWriter, r *http.Request) {
+	bookID, err := param.UInt64(r, "bookID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	// Leave room for the multipart headers around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxCoverSize+64<<10)
+
+	data, err := readCover(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, http.ErrNotMultipart), errors.Is(err, http.ErrMissingBoundary):
+			respond.Error(w, http.StatusUnsupportedMediaType, errCoverForm)
+		case errors.Is(err, errCoverSize), errors.As(err, &tooLarge):
+			respond.Error(w, http.StatusRequestEntityTooLarge, errCoverSize)
+		case errors.Is(err, errNoCover):
+			respond.Fail(w, r, errNoCover)
+		default:
+			respond.Fail(w, r, message.ErrBadRequest)
+		}
+		return
+	}
+
+	if !coverTypes[http.DetectContentType(data)] {
+		respond.Error(w, http.StatusUnsupportedMediaType, errCoverType)
+		return
+	}
+
+	cover, err := h.useCase.SetCover(r.Context(), &book.CoverRequest{BookID: bookID, Data: data})
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag.New(cover.Book.UpdatedAt))
+	respond.Json(w, http.StatusOK, book.CoverResource(cover))
+}
+
+// readCover reads the file in the cover field of a multipart form. Other
+// fields are skipped.
+func readCover(r *http.Request) ([]byte, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoCover
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != "cover" {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxCoverSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxCoverSize {
+			return nil, errCoverSize
+		}
+		return data, nil
+	}
+}
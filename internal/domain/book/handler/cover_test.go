@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/domain/book"
+	"micro/internal/domain/book/usecase"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/message"
+	"micro/third_party/validate"
+)
+
+// multipartBody is a form with a file in field.
+func multipartBody(t *testing.T, field string, data []byte) (string, *bytes.Buffer) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	assert.Nil(t, mw.WriteField("note", "skipped"))
+	fw, err := mw.CreateFormFile(field, "cover.png")
+	assert.Nil(t, err)
+	_, err = fw.Write(data)
+	assert.Nil(t, err)
+	assert.Nil(t, mw.Close())
+	return mw.FormDataContentType(), &body
+}
+
+func TestHandler_SetCover(t *testing.T) {
+	var cover bytes.Buffer
+	assert.Nil(t, png.Encode(&cover, image.NewGray(image.Rect(0, 0, 2, 2))))
+	updated := time.Date(2022, 2, 12, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		field       string
+		data        []byte
+		contentType string
+		err         error
+		status      int
+	}{
+		{name: "png", field: "cover", data: cover.Bytes(), status: http.StatusOK},
+		{name: "not an image", field: "cover", data: []byte("<html></html>"), status: http.StatusUnsupportedMediaType},
+		{name: "no cover field", field: "file", data: cover.Bytes(), status: http.StatusBadRequest},
+		{name: "too large", field: "cover", data: append(cover.Bytes(), make([]byte, maxCoverSize)...), status: http.StatusRequestEntityTooLarge},
+		{name: "not multipart", contentType: "image/png", data: cover.Bytes(), status: http.StatusUnsupportedMediaType},
+		{name: "book not found", field: "cover", data: cover.Bytes(), err: message.ErrNoRecord, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			uc := &usecase.BookMock{
+				SetCoverFunc: func(ctx context.Context, req *book.CoverRequest) (*book.Cover, error) {
+					called = true
+					assert.Equal(t, uint64(1), req.BookID)
+					assert.Equal(t, tt.data, req.Data)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &book.Cover{
+						Book: &book.Schema{ID: 1, ImageURL: "http://localhost:3080/storage/books/1/cover.png", UpdatedAt: updated},
+						Thumbnails: []book.Thumbnail{
+							{Width: 2, Height: 2, URL: "http://localhost:3080/storage/books/1/cover-160.jpg"},
+						},
+					}, nil
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			contentType, body := tt.contentType, bytes.NewBuffer(tt.data)
+			if contentType == "" {
+				contentType, body = multipartBody(t, tt.field, tt.data)
+			}
+			rr := httptest.NewRequest(http.MethodPost, "/api/v1/book/1/cover", body)
+			rr.Header.Set("Content-Type", contentType)
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+			assert.Equal(t, tt.status == http.StatusOK || tt.err != nil, called)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, etag.New(updated), ww.Header().Get("ETag"))
+			var got book.CoverRes
+			assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+			assert.Equal(t, "http://localhost:3080/storage/books/1/cover.png", got.ImageURL)
+			assert.Equal(t, []book.ThumbnailRes{
+				{Width: 2, Height: 2, URL: "http://localhost:3080/storage/books/1/cover-160.jpg"},
+			}, got.Thumbnails)
+		})
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
 	"micro/internal/domain/book"
 	"micro/internal/domain/book/usecase"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/filter"
+	"micro/internal/utility/mergepatch"
 	"micro/internal/utility/message"
 	"micro/internal/utility/param"
 	"micro/internal/utility/respond"
@@ -36,30 +42,30 @@ func NewHandler(useCase usecase.Book, validate *validator.Validate) *Handler {
 // @Produce json
 // @Param Book body book.CreateRequest true "Create a book using the following format"
 // @Success 201 {object} book.Res
-// @Failure 400 {string} Bad book.CreateRequest
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/book [post]
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	var bookRequest book.CreateRequest
 	err := json.NewDecoder(r.Body).Decode(&bookRequest)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, nil)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 
 	errs := validate.Validate(h.validate, bookRequest)
 	if errs != nil {
-		respond.Errors(w, http.StatusBadRequest, errs)
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
 		return
 	}
 
 	bk, err := h.useCase.Create(r.Context(), &bookRequest)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respond.Error(w, http.StatusBadRequest, message.ErrBadRequest)
+		if errors.Is(err, sql.ErrNoRows) {
+			respond.Fail(w, r, message.ErrBadRequest)
 			return
 		}
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
 		return
 	}
 
@@ -70,30 +76,45 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 
 // Get a book by its ID
 // @Summary Get a Book
-// @Description Get a book by its id.
+// @Description Get a book by its id. The ETag response header is the version of the book.
 // @Accept json
 // @Produce json
 // @Param bookID path int true "book ID"
+// @Param include query string false "embed relations. E.g. authors"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} book.Res
-// @Failure 400 {string} Bad book.CreateRequest
-// @Failure 500 {string} Internal Server Error
+// @Success 304 "Not Modified"
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/book/{bookID} [get]
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	bookID, err := param.UInt64(r, "bookID")
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, message.ErrBadRequest)
+		respond.Fail(w, r, message.ErrBadRequest)
 		return
 	}
 
 	b, err := h.useCase.Read(context.Background(), bookID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respond.Error(w, http.StatusBadRequest, errors.New("no book is found for this ID"))
+		respond.Fail(w, r, err)
+		return
+	}
+
+	tag := etag.New(b.UpdatedAt)
+	w.Header().Set("ETag", tag)
+	if etag.NotModified(r, tag) {
+		respond.Status(w, http.StatusNotModified)
+		return
+	}
+
+	if book.IncludeAuthors(r.URL.Query()) {
+		if err = h.useCase.Authors(r.Context(), []*book.Schema{b}); err != nil {
+			respond.Fail(w, r, err)
 			return
 		}
-		respond.Error(w, http.StatusInternalServerError, nil)
-		return
 	}
+
 	list := book.Resource(b)
 
 	respond.Json(w, http.StatusOK, list)
@@ -105,115 +126,328 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param page query string false "page number"
-// @Param size query string false "size of result"
-// @Param title query string false "search by title"
-// @Param description query string false "search by description"
-// @Success 200 {object} []book.Res
-// @Failure 500 {string} Internal Server Error
+// @Param limit query string false "limit of result"
+// @Param q query string false "full-text search on title and description in web search syntax. E.g. go or rust -python"
+// @Param cursor query string false "keyset pagination. Pass empty for the first page, then next_cursor"
+// @Param trashed query string false "include soft-deleted books: only or with"
+// @Param include query string false "embed relations. E.g. authors"
+// @Success 200 {object} respond.Standard
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/book [get]
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 	filters := book.Filters(r.URL.Query())
 
-	var books []*book.Schema
+	var (
+		books []*book.Schema
+		total int
+	)
 	ctx := r.Context()
 
 	switch filters.Base.Search {
 	case true:
-		resp, err := h.useCase.Search(ctx, filters)
+		resp, num, err := h.useCase.Search(ctx, filters)
 		if err != nil {
-			if errors.Is(err, message.ErrFetchingBook) {
-				respond.Error(w, http.StatusInternalServerError, err)
-				return
-			}
-			respond.Error(w, http.StatusInternalServerError, err)
+			respond.Fail(w, r, err)
 			return
 		}
-		books = resp
+		books, total = resp, num
 	default:
-		resp, err := h.useCase.List(ctx, filters)
+		resp, num, err := h.useCase.List(ctx, filters)
 		if err != nil {
-			if errors.Is(err, message.ErrFetchingBook) {
-				respond.Error(w, http.StatusInternalServerError, err)
-				return
-			}
-			respond.Error(w, http.StatusInternalServerError, err)
+			respond.Fail(w, r, err)
+			return
+		}
+		books, total = resp, num
+	}
+
+	if book.IncludeAuthors(r.URL.Query()) {
+		if err := h.useCase.Authors(ctx, books); err != nil {
+			respond.Fail(w, r, err)
 			return
 		}
-		books = resp
 	}
 
 	list, err := book.Resources(books)
 	if err != nil {
-		respond.Error(w, http.StatusInternalServerError, message.ErrFormingResponse)
+		respond.Fail(w, r, err)
 		return
 	}
 
-	respond.Json(w, http.StatusOK, list)
+	var next string
+	if filters.Base.CursorPaging && len(books) > 0 && len(books) == filters.Base.Limit {
+		last := books[len(books)-1]
+		next = filter.NewCursor(last.CreatedAt, last.ID).Encode()
+	}
+
+	respond.Json(w, http.StatusOK, respond.Standard{
+		Data: list,
+		Meta: respond.Paginate(w, r, filters.Base, len(list), total, next),
+	})
 }
 
 // Update a book
 // @Summary Update a Book
-// @Description Update a book by its model.
+// @Description Update a book by its model. With If-Match, the book is only updated if it is still at that version.
 // @Accept json
 // @Produce json
 // @Param Book body book.UpdateRequest true "Book UpdateRequest"
+// @Param If-Match header string false "ETag of the book being updated"
 // @Success 200 {object} book.Res
-// @Failure 400 {string} Bad Request
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/book/{bookID} [put]
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	bookID, err := param.UInt64(r, "bookID")
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, message.ErrBadRequest)
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
 		return
 	}
 
 	var req book.UpdateRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, nil)
+		respond.Fail(w, r, message.ErrInvalidJSON)
 		return
 	}
 	req.ID = bookID
+	req.IfMatch = ifMatch
 
 	errs := validate.Validate(h.validate, req)
 	if errs != nil {
-		respond.Errors(w, http.StatusBadRequest, errs)
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
 		return
 	}
 
 	resp, err := h.useCase.Update(r.Context(), &req)
 	if err != nil {
-		respond.Error(w, http.StatusInternalServerError, err)
+		respond.Fail(w, r, err)
 		return
 	}
 
 	res := book.Resource(resp)
 
+	w.Header().Set("ETag", etag.New(resp.UpdatedAt))
 	respond.Json(w, http.StatusOK, res)
 }
 
+// Patch updates some fields of a book
+// @Summary Patch a Book
+// @Description Update the fields of a book that are present in a JSON merge patch (RFC 7396). A null member clears that field. The fields present are validated like an update.
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param bookID path int true "book ID"
+// @Param Book body book.UpdateRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the book being patched"
+// @Success 200 {object} book.Res
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 415 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/{bookID} [patch]
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
+	bookID, err := param.UInt64(r, "bookID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
+		return
+	}
+
+	if !mergepatch.Accepts(r) {
+		respond.Error(w, http.StatusUnsupportedMediaType, message.ErrMediaType)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+
+	var resp *book.Schema
+	for attempt := 1; ; attempt++ {
+		resp, err = h.patch(r.Context(), bookID, patch, ifMatch)
+		// Without If-Match, the client did not ask for a version, so the
+		// patch is merged again into a book that was changed meanwhile.
+		if ifMatch != nil || attempt == maxPatchAttempts || !errors.Is(err, message.ErrModified) {
+			break
+		}
+	}
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag.New(resp.UpdatedAt))
+	respond.Json(w, http.StatusOK, book.Resource(resp))
+}
+
+// maxPatchAttempts is how many times a patch without If-Match is merged into
+// a book that keeps being modified before giving up.
+const maxPatchAttempts = 3
+
+// patch merges patch into the book as it is now and updates it. The update only
+// goes ahead if the book is still at the version that was merged into, so a
+// concurrent update is never overwritten.
+func (h *Handler) patch(ctx context.Context, bookID uint64, patch []byte, ifMatch []time.Time) (*book.Schema, error) {
+	current, err := h.useCase.Read(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(&book.UpdateRequest{
+		Title:         current.Title,
+		PublishedDate: current.PublishedDate.Format(time.RFC3339Nano),
+		ImageURL:      current.ImageURL,
+		Description:   current.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		return nil, message.ErrNotMergePatch
+	}
+
+	var req book.UpdateRequest
+	if err = json.Unmarshal(merged, &req); err != nil {
+		return nil, message.ErrInvalidJSON
+	}
+	req.ID = bookID
+	req.IfMatch = ifMatch
+	if req.IfMatch == nil {
+		req.IfMatch = []time.Time{current.UpdatedAt}
+	}
+
+	// Only the fields in the patch are validated, so that a value stored
+	// before a rule existed does not block changes to other fields.
+	members, err := mergepatch.Members(patch)
+	if err != nil {
+		return nil, message.ErrNotMergePatch
+	}
+	errs := validate.ValidateFields(h.validate, req, members)
+	if errs != nil {
+		return nil, apperror.Validation(message.ValidationFailed, errs...)
+	}
+
+	return h.useCase.Update(ctx, &req)
+}
+
 // Delete a book by its ID
 // @Summary Delete a Book
-// @Description Delete a book by its id.
+// @Description Move a book to the trash by its id. It can be restored until purged. With If-Match, the book is only deleted if it is still at that version.
 // @Accept json
 // @Produce json
 // @Param id path int true "book ID"
+// @Param If-Match header string false "ETag of the book being deleted"
 // @Success 200 "Ok"
-// @Failure 500 {string} Internal Server Error
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 412 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
 // @router /api/v1/book/{bookID} [delete]
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	bookID, err := param.UInt64(r, "bookID")
 	if err != nil {
-		respond.Error(w, http.StatusBadRequest, err)
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	ifMatch, ok := etag.IfMatch(r)
+	if !ok {
+		respond.Fail(w, r, message.ErrModified)
 		return
 	}
 
-	err = h.useCase.Delete(r.Context(), bookID)
+	err = h.useCase.Delete(r.Context(), bookID, ifMatch...)
 	if err != nil {
-		respond.Error(w, http.StatusInternalServerError, message.ErrInternalError)
+		respond.Fail(w, r, err)
 		return
 	}
 
 	respond.Json(w, http.StatusOK, nil)
 }
+
+// Restore a soft-deleted book by its ID
+// @Summary Restore a Book
+// @Description Restore a book from the trash by its id.
+// @Accept json
+// @Produce json
+// @Param bookID path int true "book ID"
+// @Success 200 {object} book.Res
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/{bookID}/restore [post]
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	bookID, err := param.UInt64(r, "bookID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	b, err := h.useCase.Restore(r.Context(), bookID)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, book.Resource(b))
+}
+
+// SetAuthors replaces the authors of a book
+// @Summary Set the Authors of a Book
+// @Description Attach and detach existing authors so that the book is linked to exactly the given author IDs.
+// @Accept json
+// @Produce json
+// @Param bookID path int true "book ID"
+// @Param Authors body book.AuthorsRequest true "Author IDs"
+// @Success 200 {object} book.Res
+// @Failure 400 {object} respond.Problem
+// @Failure 404 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/{bookID}/authors [put]
+func (h *Handler) SetAuthors(w http.ResponseWriter, r *http.Request) {
+	bookID, err := param.UInt64(r, "bookID")
+	if err != nil {
+		respond.Fail(w, r, message.ErrBadRequest)
+		return
+	}
+
+	var req book.AuthorsRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respond.Fail(w, r, message.ErrInvalidJSON)
+		return
+	}
+	req.BookID = bookID
+
+	errs := validate.Validate(h.validate, req)
+	if errs != nil {
+		respond.Fail(w, r, apperror.Validation(message.ValidationFailed, errs...))
+		return
+	}
+
+	b, err := h.useCase.SetAuthors(r.Context(), &req)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	respond.Json(w, http.StatusOK, book.Resource(b))
+}
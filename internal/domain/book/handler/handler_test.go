@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,11 +21,30 @@ import (
 
 	"micro/internal/domain/book"
 	"micro/internal/domain/book/usecase"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/etag"
+	"micro/internal/utility/filter"
 	"micro/internal/utility/message"
+	"micro/internal/utility/respond"
+	"micro/third_party/validate"
 )
 
 type Errs struct {
-	Message []string `json:"message"`
+	Errors []apperror.FieldError `json:"errors"`
+}
+
+// detail is the problem detail that a handler responds with for err. Internal
+// errors are logged, but not exposed to the client.
+func detail(status int, err error) string {
+	if status == http.StatusInternalServerError || err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// allow lets every request through the guard of write endpoints.
+func allow(next http.Handler) http.Handler {
+	return next
 }
 
 func TestHandler_Create(t *testing.T) {
@@ -68,7 +88,7 @@ func TestHandler_Create(t *testing.T) {
 					Description:   "Test Description",
 				},
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -104,7 +124,36 @@ func TestHandler_Create(t *testing.T) {
 					PublishedDate: "2022-03-07T00:00:00Z",
 				},
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
+			},
+			want: want{
+				usecase: struct {
+					book *book.Schema
+					err  error
+				}{
+					book: &book.Schema{},
+					err:  nil,
+				},
+				res: &book.Res{},
+				errs: Errs{Errors: []apperror.FieldError{
+					{Field: "title", Rule: "required", Message: "title is a required field"},
+					{Field: "image_url", Rule: "url", Message: "image_url must be a valid URL"},
+					{Field: "description", Rule: "required", Message: "description is a required field"},
+				}},
+				status: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "published date is not ISO 8601",
+			args: args{
+				CreateRequest: &book.CreateRequest{
+					Title:         "Title",
+					PublishedDate: "07/03/2022",
+					ImageURL:      "https://example.com/image-test.png",
+					Description:   "Description",
+				},
+				router:    chi.NewRouter(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -115,10 +164,12 @@ func TestHandler_Create(t *testing.T) {
 					err:  nil,
 				},
 				res: &book.Res{},
-				errs: Errs{Message: []string{
-					"Title is required with type string",
-					"ImageURL is url with type string",
-					"Description is required with type string",
+				errs: Errs{Errors: []apperror.FieldError{
+					{
+						Field:   "published_date",
+						Rule:    "iso_date",
+						Message: "published_date must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z",
+					},
 				}},
 				status: http.StatusBadRequest,
 			},
@@ -133,7 +184,7 @@ func TestHandler_Create(t *testing.T) {
 					Description:   "Description",
 				},
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -158,7 +209,7 @@ func TestHandler_Create(t *testing.T) {
 					Description:   "Description",
 				},
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -195,7 +246,7 @@ func TestHandler_Create(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(tt.args.router, tt.args.validator, uc)
+			h := RegisterHTTPEndPoints(tt.args.router, tt.args.validator, uc, allow)
 
 			h.Create(ww, rr)
 
@@ -225,26 +276,22 @@ func TestHandler_Create(t *testing.T) {
 				b, err := io.ReadAll(ww.Body)
 				assert.Nil(t, err)
 
-				if len(tt.want.errs.Message) > 0 {
+				if len(tt.want.errs.Errors) > 0 {
 					errStruct := Errs{}
 
 					err = json.Unmarshal(b, &errStruct)
 					assert.Nil(t, err)
 
-					for i := range errStruct.Message {
-						assert.Equal(t, tt.want.errs.Message[i], errStruct.Message[i])
-					}
+					assert.Equal(t, tt.want.errs, errStruct)
 
 				} else {
 					errStruct := struct {
-						Message string `json:"message"`
-					}{
-						Message: string(b),
-					}
+						Message string `json:"detail"`
+					}{}
 
 					err = json.Unmarshal(b, &errStruct)
 					assert.Nil(t, err)
-					assert.Equal(t, tt.want.err.Error(), errStruct.Message)
+					assert.Equal(t, detail(ww.Code, tt.want.err), errStruct.Message)
 				}
 			}
 
@@ -282,7 +329,7 @@ func TestHandler_Get(t *testing.T) {
 				bookID:    1,
 				param:     "bookID",
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -307,7 +354,7 @@ func TestHandler_Get(t *testing.T) {
 				bookID:    1,
 				param:     "id",
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -328,7 +375,7 @@ func TestHandler_Get(t *testing.T) {
 				bookID:    1,
 				param:     "bookID",
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -336,11 +383,11 @@ func TestHandler_Get(t *testing.T) {
 					err  error
 				}{
 					&book.Schema{},
-					sql.ErrNoRows,
+					fmt.Errorf("book 1: %w", message.ErrNoRecord),
 				},
 				res:    &book.Res{},
-				err:    errors.New("no book is found for this ID"),
-				status: http.StatusBadRequest,
+				err:    errors.New("book 1: no record found"),
+				status: http.StatusNotFound,
 			},
 		},
 		{
@@ -349,7 +396,7 @@ func TestHandler_Get(t *testing.T) {
 				bookID:    1,
 				param:     "bookID",
 				router:    chi.NewRouter(),
-				validator: validator.New(),
+				validator: validate.New(),
 			},
 			want: want{
 				usecase: struct {
@@ -382,7 +429,7 @@ func TestHandler_Get(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(tt.args.router, tt.args.validator, uc)
+			h := RegisterHTTPEndPoints(tt.args.router, tt.args.validator, uc, allow)
 
 			h.Get(ww, rr)
 
@@ -404,10 +451,8 @@ func TestHandler_Get(t *testing.T) {
 				assert.Nil(t, err)
 
 				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+					Message string `json:"detail"`
+				}{}
 
 				if len(b) == 0 {
 					return
@@ -415,7 +460,7 @@ func TestHandler_Get(t *testing.T) {
 
 				err = json.Unmarshal(b, &errStruct)
 				assert.Nil(t, err)
-				assert.Equal(t, tt.want.err, errors.New(errStruct.Message))
+				assert.Equal(t, detail(ww.Code, tt.want.err), errStruct.Message)
 			}
 		})
 	}
@@ -530,22 +575,28 @@ func TestHandler_List(t *testing.T) {
 			ww := httptest.NewRecorder()
 
 			uc := &usecase.BookMock{
-				ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
-					return tt.want.usecase.books, tt.want.usecase.err
+				ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
+					return tt.want.usecase.books, len(tt.want.usecase.books), tt.want.usecase.err
 				},
 			}
 
-			h := RegisterHTTPEndPoints(chi.NewRouter(), validator.New(), uc)
+			h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
 
 			h.List(ww, rr)
 
 			assert.Equal(t, tt.want.status, ww.Code)
 
 			if ww.Code >= 200 && ww.Code < 300 {
-				var got []*book.Res
-				if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+				var res struct {
+					Data []*book.Res  `json:"data"`
+					Meta respond.Meta `json:"meta"`
+				}
+				if err := json.NewDecoder(ww.Body).Decode(&res); err != nil {
 					t.Fatal(err)
 				}
+				got := res.Data
+				assert.Equal(t, len(tt.want.books), res.Meta.Size)
+				assert.Equal(t, len(tt.want.books), res.Meta.Total)
 
 				for i := 0; i < len(got); i++ {
 					for j := 0; j < len(tt.want.books); j++ {
@@ -563,20 +614,100 @@ func TestHandler_List(t *testing.T) {
 				assert.Nil(t, err)
 
 				errStruct := struct {
-					Message string `json:"message"`
-				}{
-					Message: string(b),
-				}
+					Message string `json:"detail"`
+				}{}
 
 				err = json.Unmarshal(b, &errStruct)
 				assert.Nil(t, err)
-				assert.Equal(t, tt.want.err.Error(), errStruct.Message)
+				assert.Equal(t, detail(ww.Code, tt.want.err), errStruct.Message)
 			}
 
 		})
 	}
 }
 
+func TestHandler_ListCursor(t *testing.T) {
+	type want struct {
+		status     int
+		size       int
+		nextCursor bool
+	}
+
+	tests := []struct {
+		name  string
+		uri   string
+		books []*book.Schema
+		err   error
+		want  want
+	}{
+		{
+			name: "first page is full",
+			uri:  "/api/v1/book?cursor=&limit=1",
+			books: []*book.Schema{
+				{ID: 2, Title: "2", CreatedAt: time.Now()},
+			},
+			want: want{
+				status:     http.StatusOK,
+				size:       1,
+				nextCursor: true,
+			},
+		},
+		{
+			name: "last page",
+			uri:  "/api/v1/book?cursor=&limit=2",
+			books: []*book.Schema{
+				{ID: 1, Title: "1", CreatedAt: time.Now()},
+			},
+			want: want{
+				status:     http.StatusOK,
+				size:       1,
+				nextCursor: false,
+			},
+		},
+		{
+			name: "invalid cursor",
+			uri:  "/api/v1/book?cursor=garbage",
+			err:  filter.ErrInvalidCursor,
+			want: want{
+				status: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+			ww := httptest.NewRecorder()
+
+			uc := &usecase.BookMock{
+				ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
+					assert.True(t, f.Base.CursorPaging)
+					return tt.books, 2, tt.err
+				},
+			}
+
+			h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
+			h.List(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			if ww.Code != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data []*book.Res  `json:"data"`
+				Meta respond.Meta `json:"meta"`
+			}
+			if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want.size, got.Meta.Size)
+			assert.Equal(t, tt.want.nextCursor, got.Meta.NextCursor != "")
+			assert.Equal(t, tt.want.nextCursor, strings.Contains(ww.Header().Get("Link"), `rel="next"`))
+		})
+	}
+}
+
 func TestHandler_Update(t *testing.T) {
 	parsedTime, err := time.Parse(time.RFC3339, "2022-03-09T00:00:00Z")
 	assert.Nil(t, err)
@@ -686,10 +817,10 @@ func TestHandler_Update(t *testing.T) {
 				},
 				status: http.StatusBadRequest,
 				book:   &book.Res{},
-				errs: Errs{Message: []string{
-					"PublishedDate is required with type string",
-					"ImageURL is url with type string",
-					"Description is required with type string",
+				errs: Errs{Errors: []apperror.FieldError{
+					{Field: "published_date", Rule: "required", Message: "published_date is a required field"},
+					{Field: "image_url", Rule: "url", Message: "image_url must be a valid URL"},
+					{Field: "description", Rule: "required", Message: "description is a required field"},
 				}},
 			},
 		},
@@ -747,7 +878,7 @@ func TestHandler_Update(t *testing.T) {
 				},
 			}
 
-			h := RegisterHTTPEndPoints(chi.NewRouter(), validator.New(), uc)
+			h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
 
 			h.Update(ww, rr)
 
@@ -770,26 +901,22 @@ func TestHandler_Update(t *testing.T) {
 				b, err := io.ReadAll(ww.Body)
 				assert.Nil(t, err)
 
-				if len(tt.want.errs.Message) > 0 {
+				if len(tt.want.errs.Errors) > 0 {
 					errStruct := Errs{}
 
 					err = json.Unmarshal(b, &errStruct)
 					assert.Nil(t, err)
 
-					for i := range errStruct.Message {
-						assert.Equal(t, tt.want.errs.Message[i], errStruct.Message[i])
-					}
+					assert.Equal(t, tt.want.errs, errStruct)
 
 				} else {
 					errStruct := struct {
-						Message string `json:"message"`
-					}{
-						Message: string(b),
-					}
+						Message string `json:"detail"`
+					}{}
 
 					err = json.Unmarshal(b, &errStruct)
 					assert.Nil(t, err)
-					assert.Equal(t, tt.want.err.Error(), errStruct.Message)
+					assert.Equal(t, detail(ww.Code, tt.want.err), errStruct.Message)
 				}
 
 			}
@@ -858,15 +985,15 @@ func TestHandler_Delete(t *testing.T) {
 			rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
 
 			router := chi.NewRouter()
-			val := validator.New()
+			val := validate.New()
 
 			uc := &usecase.BookMock{
-				DeleteFunc: func(ctx context.Context, bookID uint64) error {
+				DeleteFunc: func(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
 					return tt.want.error
 				},
 			}
 
-			h := RegisterHTTPEndPoints(router, val, uc)
+			h := RegisterHTTPEndPoints(router, val, uc, allow)
 
 			h.Delete(ww, rr)
 
@@ -874,3 +1001,590 @@ func TestHandler_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Restore(t *testing.T) {
+	type args struct {
+		bookID int
+		param  string
+	}
+	type want struct {
+		status int
+		book   *book.Schema
+		error
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "ok",
+			args: args{
+				bookID: 1,
+				param:  "bookID",
+			},
+			want: want{
+				status: http.StatusOK,
+				book:   &book.Schema{ID: 1, Title: "restored"},
+				error:  nil,
+			},
+		},
+		{
+			name: "wrong query param",
+			args: args{
+				bookID: 1,
+				param:  "id",
+			},
+			want: want{
+				status: http.StatusBadRequest,
+				error:  nil,
+			},
+		},
+		{
+			name: "not in trash",
+			args: args{
+				bookID: 1,
+				param:  "bookID",
+			},
+			want: want{
+				status: http.StatusNotFound,
+				error:  message.ErrNoRecord,
+			},
+		},
+		{
+			name: "some internal error",
+			args: args{
+				bookID: 1,
+				param:  "bookID",
+			},
+			want: want{
+				status: http.StatusInternalServerError,
+				error:  errors.New("some internal error"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/book/{%s}/restore", tt.args.param), nil)
+			ww := httptest.NewRecorder()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add(tt.args.param, strconv.Itoa(tt.args.bookID))
+			rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
+
+			uc := &usecase.BookMock{
+				RestoreFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+					return tt.want.book, tt.want.error
+				},
+			}
+
+			h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
+			h.Restore(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			if ww.Code == http.StatusOK {
+				var got book.Res
+				if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.want.book.ID, got.ID)
+				assert.Equal(t, tt.want.book.Title, got.Title)
+			}
+		})
+	}
+}
+
+func TestHandler_SetAuthors(t *testing.T) {
+	type args struct {
+		bookID int
+		body   string
+	}
+	type want struct {
+		status int
+		book   *book.Schema
+		error
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "ok",
+			args: args{
+				bookID: 1,
+				body:   `{"author_ids": [1, 2]}`,
+			},
+			want: want{
+				status: http.StatusOK,
+				book: &book.Schema{
+					ID:    1,
+					Title: "title",
+					Authors: []*book.Author{
+						{ID: 1, FirstName: "First"},
+						{ID: 2, FirstName: "Second"},
+					},
+				},
+				error: nil,
+			},
+		},
+		{
+			name: "missing author ids",
+			args: args{
+				bookID: 1,
+				body:   `{}`,
+			},
+			want: want{
+				status: http.StatusBadRequest,
+				error:  nil,
+			},
+		},
+		{
+			name: "malformed body",
+			args: args{
+				bookID: 1,
+				body:   `{"author_ids": "1"}`,
+			},
+			want: want{
+				status: http.StatusBadRequest,
+				error:  nil,
+			},
+		},
+		{
+			name: "unknown author",
+			args: args{
+				bookID: 1,
+				body:   `{"author_ids": [99]}`,
+			},
+			want: want{
+				status: http.StatusNotFound,
+				error:  fmt.Errorf("author 99: %w", message.ErrNoRecord),
+			},
+		},
+		{
+			name: "some internal error",
+			args: args{
+				bookID: 1,
+				body:   `{"author_ids": [1]}`,
+			},
+			want: want{
+				status: http.StatusInternalServerError,
+				error:  errors.New("some internal error"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRequest(http.MethodPut, "/api/v1/book/{bookID}/authors", strings.NewReader(tt.args.body))
+			ww := httptest.NewRecorder()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("bookID", strconv.Itoa(tt.args.bookID))
+			rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
+
+			uc := &usecase.BookMock{
+				SetAuthorsFunc: func(ctx context.Context, req *book.AuthorsRequest) (*book.Schema, error) {
+					assert.Equal(t, uint64(tt.args.bookID), req.BookID)
+					return tt.want.book, tt.want.error
+				},
+			}
+
+			h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
+			h.SetAuthors(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			if ww.Code == http.StatusOK {
+				var got book.Res
+				if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.want.book.ID, got.ID)
+				assert.Len(t, got.Authors, len(tt.want.book.Authors))
+				for i, a := range tt.want.book.Authors {
+					assert.Equal(t, a.ID, got.Authors[i].ID)
+					assert.Equal(t, a.FirstName, got.Authors[i].FirstName)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_GetIncludeAuthors(t *testing.T) {
+	rr := httptest.NewRequest(http.MethodGet, "/api/v1/book/1?include=authors", nil)
+	ww := httptest.NewRecorder()
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("bookID", "1")
+	rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
+
+	uc := &usecase.BookMock{
+		ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+			return &book.Schema{ID: bookID, Title: "title"}, nil
+		},
+		AuthorsFunc: func(ctx context.Context, books []*book.Schema) error {
+			for _, b := range books {
+				b.Authors = []*book.Author{{ID: 7, FirstName: "First", LastName: "Last"}}
+			}
+			return nil
+		},
+	}
+
+	h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
+	h.Get(ww, rr)
+
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	var got book.Res
+	if err := json.NewDecoder(ww.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, got.Authors, 1)
+	assert.Equal(t, uint64(7), got.Authors[0].ID)
+	assert.Equal(t, "Last", got.Authors[0].LastName)
+}
+
+func TestHandler_GetIncludeNoAuthors(t *testing.T) {
+	rr := httptest.NewRequest(http.MethodGet, "/api/v1/book/1?include=authors", nil)
+	ww := httptest.NewRecorder()
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("bookID", "1")
+	rr = rr.WithContext(context.WithValue(rr.Context(), chi.RouteCtxKey, rctx))
+
+	uc := &usecase.BookMock{
+		ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+			return &book.Schema{ID: bookID, Title: "title"}, nil
+		},
+		AuthorsFunc: func(ctx context.Context, books []*book.Schema) error {
+			for _, b := range books {
+				b.Authors = make([]*book.Author, 0)
+			}
+			return nil
+		},
+	}
+
+	h := RegisterHTTPEndPoints(chi.NewRouter(), validate.New(), uc, allow)
+	h.Get(ww, rr)
+
+	assert.Equal(t, http.StatusOK, ww.Code)
+	assert.Contains(t, ww.Body.String(), `"authors":[]`)
+}
+
+func TestHandler_Preconditions(t *testing.T) {
+	updatedAt := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	tag := etag.New(updatedAt)
+	body := `{"title": "title", "published_date": "2020-01-01", "image_url": "https://example.com/cover.png", "description": "description"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		header      string
+		value       string
+		err         error
+		wantStatus  int
+		wantIfMatch []time.Time
+	}{
+		{name: "get sets etag", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "get not modified", method: http.MethodGet, header: "If-None-Match", value: tag, wantStatus: http.StatusNotModified},
+		{name: "get weak not modified", method: http.MethodGet, header: "If-None-Match", value: `"other", W/` + tag, wantStatus: http.StatusNotModified},
+		{name: "get stale cache", method: http.MethodGet, header: "If-None-Match", value: `"other"`, wantStatus: http.StatusOK},
+		{name: "update unconditional", method: http.MethodPut, wantStatus: http.StatusOK},
+		{name: "update if match", method: http.MethodPut, header: "If-Match", value: tag, wantStatus: http.StatusOK, wantIfMatch: []time.Time{updatedAt}},
+		{name: "update unparseable if match", method: http.MethodPut, header: "If-Match", value: `W/` + tag, wantStatus: http.StatusPreconditionFailed},
+		{name: "update modified", method: http.MethodPut, header: "If-Match", value: tag, err: message.ErrModified, wantStatus: http.StatusPreconditionFailed, wantIfMatch: []time.Time{updatedAt}},
+		{name: "delete if match", method: http.MethodDelete, header: "If-Match", value: tag, wantStatus: http.StatusOK, wantIfMatch: []time.Time{updatedAt}},
+		{name: "delete unparseable if match", method: http.MethodDelete, header: "If-Match", value: `"not a version!"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "delete modified", method: http.MethodDelete, header: "If-Match", value: tag, err: message.ErrModified, wantStatus: http.StatusPreconditionFailed, wantIfMatch: []time.Time{updatedAt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotIfMatch []time.Time
+
+			uc := &usecase.BookMock{
+				ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+					return &book.Schema{ID: bookID, UpdatedAt: updatedAt}, nil
+				},
+				UpdateFunc: func(ctx context.Context, req *book.UpdateRequest) (*book.Schema, error) {
+					gotIfMatch = req.IfMatch
+					return &book.Schema{ID: req.ID, UpdatedAt: updatedAt}, tt.err
+				},
+				DeleteFunc: func(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
+					gotIfMatch = ifMatch
+					return tt.err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(tt.method, "/api/v1/book/1", strings.NewReader(body))
+			if tt.header != "" {
+				rr.Header.Set(tt.header, tt.value)
+			}
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.wantStatus, ww.Code)
+			assert.Equal(t, tt.wantIfMatch, gotIfMatch)
+			if tt.method != http.MethodDelete && tt.wantStatus != http.StatusPreconditionFailed {
+				assert.Equal(t, tag, ww.Header().Get("ETag"))
+			}
+			if tt.wantStatus == http.StatusNotModified {
+				assert.Empty(t, ww.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Patch(t *testing.T) {
+	updatedAt := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	current := &book.Schema{
+		ID:            1,
+		Title:         "title",
+		PublishedDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		ImageURL:      "https://example.com/cover.png",
+		Description:   "description",
+		UpdatedAt:     updatedAt,
+	}
+
+	type want struct {
+		status  int
+		req     *book.UpdateRequest
+		updates int
+		errors  []apperror.FieldError
+	}
+	noImage := *current
+	noImage.ImageURL = ""
+
+	tests := []struct {
+		name        string
+		contentType string
+		ifMatch     string
+		current     *book.Schema
+		patch       string
+		readErr     error
+		updateErrs  []error
+		want        want
+	}{
+		{
+			name:  "changes only the fields present",
+			patch: `{"title": "new title"}`,
+			want: want{
+				status: http.StatusOK,
+				req: &book.UpdateRequest{
+					ID:            1,
+					Title:         "new title",
+					PublishedDate: "2020-01-01T00:00:00Z",
+					ImageURL:      "https://example.com/cover.png",
+					Description:   "description",
+					IfMatch:       []time.Time{updatedAt},
+				},
+				updates: 1,
+			},
+		},
+		{
+			name:        "plain json",
+			contentType: "application/json",
+			patch:       `{"description": "new description", "unknown": 1}`,
+			want: want{
+				status: http.StatusOK,
+				req: &book.UpdateRequest{
+					ID:            1,
+					Title:         "title",
+					PublishedDate: "2020-01-01T00:00:00Z",
+					ImageURL:      "https://example.com/cover.png",
+					Description:   "new description",
+					IfMatch:       []time.Time{updatedAt},
+				},
+				updates: 1,
+			},
+		},
+		{
+			name:  "null removes a required field",
+			patch: `{"description": null, "published_date": "01/01/2020"}`,
+			want: want{
+				status: http.StatusBadRequest,
+				errors: []apperror.FieldError{
+					{Field: "published_date", Rule: "iso_date", Message: "published_date must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z"},
+					{Field: "description", Rule: "required", Message: "description is a required field"},
+				},
+			},
+		},
+		{
+			name:    "stored value that fails a rule is not validated",
+			current: &noImage,
+			patch:   `{"title": "new title"}`,
+			want: want{
+				status: http.StatusOK,
+				req: &book.UpdateRequest{
+					ID:            1,
+					Title:         "new title",
+					PublishedDate: "2020-01-01T00:00:00Z",
+					Description:   "description",
+					IfMatch:       []time.Time{updatedAt},
+				},
+				updates: 1,
+			},
+		},
+		{
+			name:    "value in the patch is validated",
+			current: &noImage,
+			patch:   `{"image_url": "cover.png"}`,
+			want: want{
+				status: http.StatusBadRequest,
+				errors: []apperror.FieldError{
+					{Field: "image_url", Rule: "url", Message: "image_url must be a valid URL"},
+				},
+			},
+		},
+		{
+			name:  "not an object",
+			patch: `["title"]`,
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:  "not json",
+			patch: `{"title": `,
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:  "wrong type",
+			patch: `{"title": 5}`,
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			patch:       `{"title": "new title"}`,
+			want:        want{status: http.StatusUnsupportedMediaType},
+		},
+		{
+			name:    "no record",
+			patch:   `{"title": "new title"}`,
+			readErr: message.ErrNoRecord,
+			want:    want{status: http.StatusNotFound},
+		},
+		{
+			name:       "merges again when modified meanwhile",
+			patch:      `{"title": "new title"}`,
+			updateErrs: []error{message.ErrModified, nil},
+			want:       want{status: http.StatusOK, updates: 2},
+		},
+		{
+			name:       "gives up when modified every time",
+			patch:      `{"title": "new title"}`,
+			updateErrs: []error{message.ErrModified, message.ErrModified, message.ErrModified, nil},
+			want:       want{status: http.StatusPreconditionFailed, updates: maxPatchAttempts},
+		},
+		{
+			name:       "if match is not retried",
+			ifMatch:    `"not-a-version", ` + etag.New(updatedAt.Add(-time.Second)),
+			patch:      `{"title": "new title"}`,
+			updateErrs: []error{message.ErrModified, nil},
+			want: want{
+				status: http.StatusPreconditionFailed,
+				req: &book.UpdateRequest{
+					ID:            1,
+					Title:         "new title",
+					PublishedDate: "2020-01-01T00:00:00Z",
+					ImageURL:      "https://example.com/cover.png",
+					Description:   "description",
+					IfMatch:       []time.Time{updatedAt.Add(-time.Second)},
+				},
+				updates: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got     *book.UpdateRequest
+				updates int
+			)
+
+			uc := &usecase.BookMock{
+				ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+					if tt.current != nil {
+						return tt.current, tt.readErr
+					}
+					return current, tt.readErr
+				},
+				UpdateFunc: func(ctx context.Context, req *book.UpdateRequest) (*book.Schema, error) {
+					got = req
+					updates++
+
+					var err error
+					if updates <= len(tt.updateErrs) {
+						err = tt.updateErrs[updates-1]
+					}
+					return &book.Schema{ID: req.ID, Title: req.Title, UpdatedAt: updatedAt}, err
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(http.MethodPatch, "/api/v1/book/1", strings.NewReader(tt.patch))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/merge-patch+json"
+			}
+			rr.Header.Set("Content-Type", contentType)
+			if tt.ifMatch != "" {
+				rr.Header.Set("If-Match", tt.ifMatch)
+			}
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.want.status, ww.Code)
+			assert.Equal(t, tt.want.updates, updates)
+			if tt.want.req != nil {
+				assert.Equal(t, tt.want.req, got)
+			}
+
+			if ww.Code == http.StatusOK {
+				var res book.Res
+				assert.Nil(t, json.NewDecoder(ww.Body).Decode(&res))
+				assert.Equal(t, uint64(1), res.ID)
+				assert.Equal(t, etag.New(updatedAt), ww.Header().Get("ETag"))
+			} else if tt.want.errors != nil {
+				var errs Errs
+				assert.Nil(t, json.NewDecoder(ww.Body).Decode(&errs))
+				assert.ElementsMatch(t, tt.want.errors, errs.Errors)
+			}
+		})
+	}
+}
+
+func TestRegisterHTTPEndPoints_Write(t *testing.T) {
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+
+	uc := &usecase.BookMock{
+		ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
+			return nil, 0, nil
+		},
+	}
+
+	router := chi.NewRouter()
+	RegisterHTTPEndPoints(router, validate.New(), uc, deny)
+
+	ww := httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodGet, "/api/v1/book", nil))
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	ww = httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodPost, "/api/v1/book", strings.NewReader("{}")))
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+
+	ww = httptest.NewRecorder()
+	router.ServeHTTP(ww, httptest.NewRequest(http.MethodDelete, "/api/v1/book/1", nil))
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+}
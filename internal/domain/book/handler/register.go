@@ -1,21 +1,38 @@
 package handler
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 
 	"micro/internal/domain/book/usecase"
 )
 
-func RegisterHTTPEndPoints(router *chi.Mux, validator *validator.Validate, uc usecase.Book) *Handler {
+// RegisterHTTPEndPoints mounts the book endpoints. Reads are public, and
+// writes go through write first.
+func RegisterHTTPEndPoints(router *chi.Mux, validator *validator.Validate, uc usecase.Book, write func(http.Handler) http.Handler) *Handler {
 	h := NewHandler(uc, validator)
 
 	router.Route("/api/v1/book", func(router chi.Router) {
 		router.Get("/", h.List)
+		router.Get("/export", h.Export)
 		router.Get("/{bookID}", h.Get)
-		router.Post("/", h.Create)
-		router.Put("/{bookID}", h.Update)
-		router.Delete("/{bookID}", h.Delete)
+
+		router.Group(func(router chi.Router) {
+			router.Use(write)
+			router.Post("/import", h.Import)
+			router.Post("/", h.Create)
+			router.Post("/bulk", h.BulkCreate)
+			router.Patch("/bulk", h.BulkUpdate)
+			router.Delete("/bulk", h.BulkDelete)
+			router.Put("/{bookID}", h.Update)
+			router.Patch("/{bookID}", h.Patch)
+			router.Delete("/{bookID}", h.Delete)
+			router.Post("/{bookID}/restore", h.Restore)
+			router.Put("/{bookID}/authors", h.SetAuthors)
+			router.Post("/{bookID}/cover", h.SetCover)
+		})
 	})
 	return h
 }
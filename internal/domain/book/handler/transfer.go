@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"micro/internal/domain/book"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/export"
+	"micro/internal/utility/message"
+	"micro/internal/utility/respond"
+	"micro/internal/utility/validate"
+)
+
+// maxImportLine is the longest line of an NDJSON import.
+const maxImportLine = 1 << 20
+
+// importColumns are the columns that the header of a CSV import must have.
+var importColumns = []string{"title", "published_date", "image_url", "description"}
+
+var errImportType = errors.New("content type must be text/csv or application/x-ndjson")
+
+// Export streams every book
+// @Summary Export Books
+// @Description Stream every book that is not in the trash as CSV or newline delimited JSON. Books are sent while they are read from the database.
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or ndjson"
+// @Success 200 {array} book.ExportRes
+// @Failure 400 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/export [get]
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	out, err := export.NewWriter(w, r.URL.Query().Get("format"), "books", book.ExportColumns)
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	err = h.useCase.Export(r.Context(), func(b *book.Schema) error {
+		return out.Write(book.ExportResource(b))
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		if out.Started() {
+			// The status is sent already, so the client only sees a
+			// truncated export.
+			log.Println(err)
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		respond.Fail(w, r, err)
+	}
+}
+
+// Import creates or updates books from a file
+// @Summary Import Books
+// @Description Import books from CSV with a header row, or from newline delimited JSON. Books are matched on title and published date, so importing the same file again changes nothing. Each line is validated on its own, and lines that fail are listed with their line number and not imported.
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Success 200 {object} book.ImportRes
+// @Success 207 {object} book.ImportRes
+// @Failure 400 {object} book.ImportRes
+// @Failure 415 {object} respond.Problem
+// @Failure 500 {object} respond.Problem
+// @router /api/v1/book/import [post]
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var (
+		rows rowReader
+		err  error
+	)
+	switch mediaType {
+	case "text/csv":
+		rows, err = newCSVRows(r.Body)
+	case "application/x-ndjson", "application/ndjson":
+		rows = newNDJSONRows(r.Body)
+	default:
+		respond.Error(w, http.StatusUnsupportedMediaType, errImportType)
+		return
+	}
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	res := &book.ImportRes{}
+	err = h.useCase.Import(r.Context(), func(upsert book.Upsert) error {
+		return h.importRows(r.Context(), rows, upsert, res)
+	})
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+
+	imported := res.Created + res.Updated + res.Unchanged
+	switch {
+	case len(res.Errors) == 0:
+		respond.Json(w, http.StatusOK, res)
+	case imported > 0:
+		respond.Json(w, http.StatusMultiStatus, res)
+	default:
+		respond.Json(w, http.StatusBadRequest, res)
+	}
+}
+
+// importRows validates and upserts each row in turn. A row that fails is added
+// to the errors of res, and the import goes on.
+func (h *Handler) importRows(ctx context.Context, rows rowReader, upsert book.Upsert, res *book.ImportRes) error {
+	for {
+		line, b, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			res.Errors = append(res.Errors, book.ImportError{Line: lineErr.line, Detail: lineErr.err.Error()})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		errs := validate.Validate(h.validate, b)
+		if errs != nil {
+			res.Errors = append(res.Errors, book.ImportError{Line: line, Detail: message.ValidationFailed, Errors: errs})
+			continue
+		}
+
+		_, imported, err := upsert(ctx, b)
+		if err != nil {
+			return fmt.Errorf("import line %d: %w", line, err)
+		}
+
+		switch imported {
+		case book.ImportCreated:
+			res.Created++
+		case book.ImportUpdated:
+			res.Updated++
+		case book.ImportUnchanged:
+			res.Unchanged++
+		}
+	}
+}
+
+// rowReader reads the books of an import one line at a time. next returns
+// io.EOF after the last book, and a *lineError for a line that cannot be read
+// but can be skipped.
+type rowReader interface {
+	next() (line int, b *book.CreateRequest, err error)
+}
+
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+// csvRows reads CSV with a header. Columns are found by their name in the
+// header, and columns that are not in importColumns are ignored.
+type csvRows struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRows(body io.Reader) (*csvRows, error) {
+	r := csv.NewReader(body)
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, apperror.Validation("csv has no header")
+	}
+	if err != nil {
+		return nil, apperror.Validation(fmt.Sprintf("csv header: %v", err))
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark.
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, apperror.Validation(fmt.Sprintf("csv header has no %s column", name))
+		}
+	}
+
+	return &csvRows{r: r, columns: columns}, nil
+}
+
+func (c *csvRows) next() (int, *book.CreateRequest, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr.StartLine, nil, &lineError{line: parseErr.StartLine, err: parseErr.Err}
+		}
+		return 0, nil, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	return line, &book.CreateRequest{
+		Title:         record[c.columns["title"]],
+		PublishedDate: record[c.columns["published_date"]],
+		ImageURL:      record[c.columns["image_url"]],
+		Description:   record[c.columns["description"]],
+	}, nil
+}
+
+// ndjsonRows reads a JSON object on each line. Blank lines are skipped.
+type ndjsonRows struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONRows(body io.Reader) *ndjsonRows {
+	s := bufio.NewScanner(body)
+	s.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	return &ndjsonRows{s: s}
+}
+
+func (n *ndjsonRows) next() (int, *book.CreateRequest, error) {
+	for n.s.Scan() {
+		n.line++
+
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var b book.CreateRequest
+		if err := json.Unmarshal(data, &b); err != nil {
+			return n.line, nil, &lineError{line: n.line, err: message.ErrInvalidJSON}
+		}
+		return n.line, &b, nil
+	}
+
+	err := n.s.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return n.line + 1, nil, apperror.Validation(fmt.Sprintf("line %d is longer than %d bytes", n.line+1, maxImportLine))
+	}
+	if err != nil {
+		return n.line, nil, err
+	}
+	return n.line, nil, io.EOF
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/domain/book"
+	"micro/internal/domain/book/usecase"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/third_party/validate"
+)
+
+func TestHandler_Export(t *testing.T) {
+	published := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2022, 2, 12, 15, 4, 5, 123456000, time.UTC)
+	books := []*book.Schema{
+		{ID: 1, Title: "first", PublishedDate: published, ImageURL: "https://example.com/1.png", Description: "with, comma", CreatedAt: updated, UpdatedAt: updated},
+		{ID: 2, Title: "second", PublishedDate: published, ImageURL: "https://example.com/2.png", Description: "line\nbreak", CreatedAt: updated, UpdatedAt: updated},
+	}
+
+	tests := []struct {
+		name        string
+		format      string
+		books       []*book.Schema
+		err         error
+		status      int
+		contentType string
+	}{
+		{name: "csv by default", books: books, status: http.StatusOK, contentType: "text/csv; charset=utf-8"},
+		{name: "ndjson", format: "ndjson", books: books, status: http.StatusOK, contentType: "application/x-ndjson"},
+		{name: "empty csv", format: "csv", status: http.StatusOK, contentType: "text/csv; charset=utf-8"},
+		{name: "unknown format", format: "xml", status: http.StatusBadRequest, contentType: "application/problem+json"},
+		{name: "error before the first book", err: errors.New("lower layer error"), status: http.StatusInternalServerError, contentType: "application/problem+json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &usecase.BookMock{
+				ExportFunc: func(ctx context.Context, fn func(b *book.Schema) error) error {
+					if tt.err != nil {
+						return tt.err
+					}
+					for _, b := range tt.books {
+						if err := fn(b); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(http.MethodGet, "/api/v1/book/export?format="+tt.format, nil)
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+			assert.Equal(t, tt.contentType, ww.Header().Get("Content-Type"))
+			if tt.status != http.StatusOK {
+				assert.Empty(t, ww.Header().Get("Content-Disposition"))
+				return
+			}
+
+			switch tt.format {
+			case "ndjson":
+				assert.Equal(t, `attachment; filename="books.ndjson"`, ww.Header().Get("Content-Disposition"))
+
+				var got []book.ExportRes
+				s := bufio.NewScanner(ww.Body)
+				for s.Scan() {
+					var b book.ExportRes
+					assert.Nil(t, json.Unmarshal(s.Bytes(), &b))
+					got = append(got, b)
+				}
+				assert.Len(t, got, len(tt.books))
+				for i, b := range tt.books {
+					assert.Equal(t, *book.ExportResource(b), got[i])
+				}
+			default:
+				assert.Equal(t, `attachment; filename="books.csv"`, ww.Header().Get("Content-Disposition"))
+
+				records, err := csv.NewReader(ww.Body).ReadAll()
+				assert.Nil(t, err)
+				assert.Equal(t, book.ExportColumns, records[0])
+				assert.Len(t, records, len(tt.books)+1)
+				for i, b := range tt.books {
+					assert.Equal(t, book.ExportResource(b).CSV(), records[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Import(t *testing.T) {
+	const header = "id,title,published_date,image_url,description,created_at,updated_at\n"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		existing    map[string]book.Imported
+		upsertErr   error
+		status      int
+		want        *book.ImportRes
+	}{
+		{
+			name:        "csv export is imported back",
+			contentType: "text/csv",
+			body: header +
+				"1,first,2020-01-01T00:00:00Z,https://example.com/1.png,\"with, comma\",2022-02-12T15:04:05Z,2022-02-12T15:04:05Z\n" +
+				"2,second,2020-01-01,https://example.com/2.png,\"line\nbreak\",,\n",
+			existing: map[string]book.Imported{"second": book.ImportUnchanged},
+			status:   http.StatusOK,
+			want:     &book.ImportRes{Created: 1, Unchanged: 1},
+		},
+		{
+			name:        "csv columns in any order with a byte order mark",
+			contentType: "text/csv; charset=utf-8",
+			body:        "\ufeffdescription,image_url,published_date,title\ndescription,https://example.com/1.png,2020-01-01,first\n",
+			existing:    map[string]book.Imported{"first": book.ImportUpdated},
+			status:      http.StatusOK,
+			want:        &book.ImportRes{Updated: 1},
+		},
+		{
+			name:        "csv line errors",
+			contentType: "text/csv",
+			body: "title,published_date,image_url,description\n" +
+				"first,2020-01-01,https://example.com/1.png,description\n" +
+				"second,01/01/2020,https://example.com/2.png,description\n" +
+				"third,2020-01-01\n",
+			status: http.StatusMultiStatus,
+			want: &book.ImportRes{
+				Created: 1,
+				Errors: []book.ImportError{
+					{
+						Line:   3,
+						Detail: message.ValidationFailed,
+						Errors: []apperror.FieldError{{
+							Field:   "published_date",
+							Rule:    "iso_date",
+							Message: "published_date must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z",
+						}},
+					},
+					{Line: 4, Detail: csv.ErrFieldCount.Error()},
+				},
+			},
+		},
+		{
+			name:        "csv without a column",
+			contentType: "text/csv",
+			body:        "title,published_date,description\nfirst,2020-01-01,description\n",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "empty csv",
+			contentType: "text/csv",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body: `{"title": "first", "published_date": "2020-01-01", "image_url": "https://example.com/1.png", "description": "description"}` + "\n\n" +
+				`{"title": "second", "published_date": "2020-01-01", "image_url": "https://example.com/2.png"}` + "\n" +
+				`{"title": ` + "\n",
+			status: http.StatusMultiStatus,
+			want: &book.ImportRes{
+				Created: 1,
+				Errors: []book.ImportError{
+					{
+						Line:   3,
+						Detail: message.ValidationFailed,
+						Errors: []apperror.FieldError{{Field: "description", Rule: "required", Message: "description is a required field"}},
+					},
+					{Line: 4, Detail: message.ErrInvalidJSON.Error()},
+				},
+			},
+		},
+		{
+			name:        "nothing imported",
+			contentType: "application/x-ndjson",
+			body:        `{"title": "first"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			body:        `[]`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "lower layer error",
+			contentType: "application/x-ndjson",
+			body:        `{"title": "first", "published_date": "2020-01-01", "image_url": "https://example.com/1.png", "description": "description"}`,
+			upsertErr:   errors.New("lower layer error"),
+			status:      http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &usecase.BookMock{
+				ImportFunc: func(ctx context.Context, fn func(upsert book.Upsert) error) error {
+					return fn(func(ctx context.Context, b *book.CreateRequest) (uint64, book.Imported, error) {
+						if tt.upsertErr != nil {
+							return 0, "", tt.upsertErr
+						}
+						if imported, ok := tt.existing[b.Title]; ok {
+							return 1, imported, nil
+						}
+						return 2, book.ImportCreated, nil
+					})
+				},
+			}
+
+			router := chi.NewRouter()
+			RegisterHTTPEndPoints(router, validate.New(), uc, allow)
+
+			rr := httptest.NewRequest(http.MethodPost, "/api/v1/book/import", strings.NewReader(tt.body))
+			rr.Header.Set("Content-Type", tt.contentType)
+			ww := httptest.NewRecorder()
+
+			router.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+			if tt.want != nil {
+				var got book.ImportRes
+				assert.Nil(t, json.NewDecoder(ww.Body).Decode(&got))
+				assert.Equal(t, *tt.want, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package book
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
@@ -14,4 +15,49 @@ type Schema struct {
 	CreatedAt     time.Time    `db:"created_at"`
 	UpdatedAt     time.Time    `db:"updated_at"`
 	DeletedAt     sql.NullTime `db:"deleted_at" swaggertype:"string"`
+
+	// Rank and the highlights are only set by a full-text search. The
+	// highlights wrap matching words in <b></b>.
+	Rank                 float64 `db:"rank" json:"-"`
+	TitleHighlight       string  `db:"title_highlight" json:"-"`
+	DescriptionHighlight string  `db:"description_highlight" json:"-"`
+
+	// Authors is only loaded when asked for, see IncludeAuthors.
+	Authors []*Author `db:"-" json:"-"`
+}
+
+// Author is the part of an author that is embedded into a book.
+type Author struct {
+	ID         uint64 `db:"id"`
+	FirstName  string `db:"first_name"`
+	MiddleName string `db:"middle_name"`
+	LastName   string `db:"last_name"`
+}
+
+// Imported is what an import did with a book. Books are matched on their
+// natural key, the title and published date, so that importing the same file
+// twice changes nothing.
+type Imported string
+
+const (
+	ImportCreated   Imported = "created"
+	ImportUpdated   Imported = "updated"
+	ImportUnchanged Imported = "unchanged"
+)
+
+// Upsert creates a book, or updates the book with the same natural key. It
+// returns the ID of that book.
+type Upsert func(ctx context.Context, b *CreateRequest) (uint64, Imported, error)
+
+// Cover is a book after a cover is uploaded for it, with the thumbnails that
+// were made from the cover.
+type Cover struct {
+	Book       *Schema
+	Thumbnails []Thumbnail
+}
+
+type Thumbnail struct {
+	Width  int
+	Height int
+	URL    string
 }
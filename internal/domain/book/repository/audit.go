@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro/internal/domain/audit"
+	"micro/internal/domain/book"
+	"micro/internal/middleware"
+)
+
+const (
+	auditTable        = "books"
+	auditAuthorsTable = "book_authors"
+)
+
+// BookAudit records the writes to books in the audit log. Reads go straight to
+// the repository.
+type BookAudit struct {
+	Book
+	recorder audit.Recorder
+}
+
+func NewAudit(repo Book, recorder audit.Recorder) *BookAudit {
+	return &BookAudit{
+		Book:     repo,
+		recorder: recorder,
+	}
+}
+
+func (a *BookAudit) Create(ctx context.Context, req *book.CreateRequest) (uint64, error) {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionCreate)
+	bookID, err := a.Book.Create(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	change.Commit(ctx, bookID)
+
+	return bookID, nil
+}
+
+func (a *BookAudit) Update(ctx context.Context, req *book.UpdateRequest) error {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionUpdate, req.ID)
+	if err := a.Book.Update(ctx, req); err != nil {
+		return err
+	}
+	change.Commit(ctx)
+
+	return nil
+}
+
+func (a *BookAudit) Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionDelete, bookID)
+	if err := a.Book.Delete(ctx, bookID, ifMatch...); err != nil {
+		return err
+	}
+	change.Commit(ctx)
+
+	return nil
+}
+
+func (a *BookAudit) CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionCreate)
+	ids, err := a.Book.CreateMany(ctx, books)
+	if err != nil {
+		return nil, err
+	}
+	change.Commit(ctx, ids...)
+
+	return ids, nil
+}
+
+func (a *BookAudit) UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionUpdate, req.IDs...)
+	ids, err := a.Book.UpdateMany(ctx, req)
+	if err != nil {
+		return ids, err
+	}
+	change.Commit(ctx, ids...)
+
+	return ids, nil
+}
+
+func (a *BookAudit) DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error) {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionDelete, req.IDs...)
+	ids, err := a.Book.DeleteMany(ctx, req)
+	if err != nil {
+		return ids, err
+	}
+	change.Commit(ctx, ids...)
+
+	return ids, nil
+}
+
+// Import records the books that an import created or updated once it is
+// committed. The values of the updated books are read before the commit, from
+// outside the transaction of the import, which still sees them as they were.
+func (a *BookAudit) Import(ctx context.Context, fn func(upsert book.Upsert) error) error {
+	var (
+		created, updated []uint64
+		updates          *audit.Change
+	)
+	err := a.Book.Import(ctx, func(upsert book.Upsert) error {
+		err := fn(func(ctx context.Context, b *book.CreateRequest) (uint64, book.Imported, error) {
+			bookID, imported, err := upsert(ctx, b)
+			switch {
+			case err != nil:
+			case imported == book.ImportCreated:
+				created = append(created, bookID)
+			case imported == book.ImportUpdated:
+				updated = append(updated, bookID)
+			}
+			return bookID, imported, err
+		})
+		if err == nil && len(updated) > 0 {
+			updates = audit.Begin(ctx, a.recorder, auditTable, middleware.ActionUpdate, updated...)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	audit.Begin(ctx, a.recorder, auditTable, middleware.ActionCreate).Commit(ctx, created...)
+	updates.Commit(ctx)
+
+	return nil
+}
+
+func (a *BookAudit) Restore(ctx context.Context, bookID uint64) error {
+	change := audit.Begin(ctx, a.recorder, auditTable, middleware.ActionRestore, bookID)
+	if err := a.Book.Restore(ctx, bookID); err != nil {
+		return err
+	}
+	change.Commit(ctx)
+
+	return nil
+}
+
+func (a *BookAudit) SetAuthors(ctx context.Context, bookID uint64, authorIDs []uint64) error {
+	change := audit.Begin(ctx, a.recorder, auditAuthorsTable, middleware.ActionUpdate, bookID)
+	if err := a.Book.SetAuthors(ctx, bookID, authorIDs); err != nil {
+		return err
+	}
+	change.Commit(ctx)
+
+	return nil
+}
@@ -5,35 +5,111 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"micro/internal/domain/book"
+	"micro/internal/utility/filter"
 	"micro/internal/utility/message"
 )
 
 //go:generate mirip -rm -pkg repository -out repo_mock.go . Book
 type Book interface {
 	Create(ctx context.Context, book *book.CreateRequest) (uint64, error)
-	List(ctx context.Context, f *book.Filter) ([]*book.Schema, error)
+	List(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error)
 	Read(ctx context.Context, bookID uint64) (*book.Schema, error)
 	Update(ctx context.Context, book *book.UpdateRequest) error
-	Delete(ctx context.Context, bookID uint64) error
-	Search(ctx context.Context, req *book.Filter) ([]*book.Schema, error)
+	Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error
+	CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error)
+	UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error)
+	DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error)
+	Export(ctx context.Context, fn func(b *book.Schema) error) error
+	Import(ctx context.Context, fn func(upsert book.Upsert) error) error
+	Restore(ctx context.Context, bookID uint64) error
+	Authors(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error)
+	SetAuthors(ctx context.Context, bookID uint64, authorIDs []uint64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	Search(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error)
 }
 
 type bookRepository struct {
 	db *sqlx.DB
 }
 
+// Query fragments shared by the statements below. bookColumns leaves out the
+// generated search column, which has no destination in book.Schema.
+const (
+	bookColumns   = "id, title, published_date, image_url, description, created_at, updated_at, deleted_at"
+	trashedClause = "CASE $1 WHEN 'with' THEN true WHEN 'only' THEN deleted_at IS NOT NULL ELSE deleted_at IS NULL END"
+	searchColumns = bookColumns + ", ts_rank(search, query) AS rank, ts_headline('english', title, query, 'HighlightAll=true') AS title_highlight, ts_headline('english', description, query) AS description_highlight"
+	searchFrom    = " FROM books, websearch_to_tsquery('english', $2) query WHERE " + trashedClause + " AND search @@ query"
+
+	// versionClause makes a write conditional on the versions of an If-Match
+	// header, passed with pq.Array. A NULL list of versions matches any.
+	versionClause       = "($6::timestamptz[] IS NULL OR updated_at = ANY($6))"
+	deleteVersionClause = "($2::timestamptz[] IS NULL OR updated_at = ANY($2))"
+)
+
+// Rows with deleted_at set are in the trash. List and search queries take
+// the book.Filter Trashed value as their first parameter and evaluate it as
+//
+//	CASE $1 WHEN 'with' THEN true WHEN 'only' THEN deleted_at IS NOT NULL ELSE deleted_at IS NULL END
+//
+// so that trashed rows are hidden unless asked for.
+//
+// Search queries take the book.Filter Query value as their second parameter,
+// parsed with websearch_to_tsquery. Matches are ranked by ts_rank against
+// the generated search column and highlighted with ts_headline.
 const (
 	InsertIntoBooks         = "INSERT INTO books (title, published_date, image_url, description) VALUES ($1, $2, $3, $4) RETURNING id"
-	SelectFromBooks         = "SELECT * FROM books ORDER BY created_at DESC"
-	SelectFromBooksPaginate = "SELECT * FROM books ORDER BY created_at DESC LIMIT $1 OFFSET $2"
-	SelectBookByID          = "SELECT * FROM books where id = $1"
-	UpdateBook              = "UPDATE books set title = $1, description = $2, published_date = $3, image_url = $4 where id = $5 RETURNING id"
-	DeleteByID              = "DELETE FROM books where id = ($1) RETURNING id"
-	SearchBooks             = "SELECT * FROM books where title like '%' || $1 || '%' and description like '%'|| $2 || '%' ORDER BY published_date DESC"
-	SearchBooksPaginate     = "SELECT * FROM books where title like '%' || '%' || $1 || '%' || '%' and description like '%'|| $2 || '%' ORDER BY published_date DESC LIMIT $3 OFFSET $4"
+	CountBooks              = "SELECT count(*) FROM books WHERE " + trashedClause
+	SelectFromBooks         = "SELECT " + bookColumns + " FROM books WHERE " + trashedClause + " ORDER BY created_at DESC"
+	SelectFromBooksPaginate = "SELECT " + bookColumns + " FROM books WHERE " + trashedClause + " ORDER BY created_at DESC LIMIT $2 OFFSET $3"
+	SelectFromBooksFirst    = "SELECT " + bookColumns + " FROM books WHERE " + trashedClause + " ORDER BY created_at DESC, id DESC LIMIT $2"
+	SelectFromBooksAfter    = "SELECT " + bookColumns + " FROM books WHERE " + trashedClause + " AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4"
+	SelectBookByID          = "SELECT " + bookColumns + " FROM books where id = $1 AND deleted_at IS NULL"
+	UpdateBook              = "UPDATE books set title = $1, description = $2, published_date = $3, image_url = $4 where id = $5 AND deleted_at IS NULL AND " + versionClause + " RETURNING id"
+	DeleteByID              = "UPDATE books set deleted_at = current_timestamp where id = ($1) AND deleted_at IS NULL AND " + deleteVersionClause + " RETURNING id"
+	LiveBookExists          = "SELECT EXISTS(SELECT 1 FROM books where id = $1 AND deleted_at IS NULL)"
+	RestoreByID             = "UPDATE books set deleted_at = NULL where id = ($1) AND deleted_at IS NOT NULL RETURNING id"
+	PurgeTrashed            = "DELETE FROM books where deleted_at < $1"
+	SelectBookAuthors       = "SELECT ba.book_id, a.id, a.first_name, COALESCE(a.middle_name, '') AS middle_name, a.last_name FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE a.deleted_at IS NULL AND ba.book_id IN (?) ORDER BY a.id"
+	LockLiveBook            = "SELECT id FROM books where id = $1 AND deleted_at IS NULL FOR UPDATE"
+	DetachBookAuthors       = "DELETE FROM book_authors where book_id = $1"
+	AttachBookAuthor        = "INSERT INTO book_authors (book_id, author_id) SELECT $1, id FROM authors where id = $2 AND deleted_at IS NULL"
+	CountSearchBooks        = "SELECT count(*)" + searchFrom
+	SearchBooks             = "SELECT " + searchColumns + searchFrom + " ORDER BY rank DESC, id DESC"
+	SearchBooksPaginate     = "SELECT " + searchColumns + searchFrom + " ORDER BY rank DESC, id DESC LIMIT $3 OFFSET $4"
+)
+
+// Bulk statements take their rows as arrays, so that any number of books is
+// written by a single statement. A NULL field of UpdateManyBooks keeps the
+// value of each book.
+//
+// RETURNING only sees the inserted row, so InsertManyBooks draws the IDs
+// from the sequence itself, next to the ordinality of each input row, and
+// returns both.
+const (
+	InsertManyBooks = "WITH input AS (SELECT nextval(pg_get_serial_sequence('books', 'id')) AS id, b.* FROM unnest($1::text[], $2::text[], $3::text[], $4::text[]) WITH ORDINALITY AS b(title, published_date, image_url, description, ordinality)), " +
+		"inserted AS (INSERT INTO books (id, title, published_date, image_url, description) SELECT id, title, published_date::timestamptz, image_url, description FROM input RETURNING id) " +
+		"SELECT input.ordinality, inserted.id FROM inserted JOIN input USING (id)"
+	UpdateManyBooks = "UPDATE books set title = COALESCE($2, title), published_date = COALESCE($3::timestamptz, published_date), image_url = COALESCE($4, image_url), description = COALESCE($5, description) where id = ANY($1::bigint[]) AND deleted_at IS NULL RETURNING id"
+	DeleteManyByID  = "UPDATE books set deleted_at = current_timestamp where id = ANY($1::bigint[]) AND deleted_at IS NULL RETURNING id"
+)
+
+// An import matches books on title and published date. UpsertBook creates the
+// book when no live book has that key, and otherwise updates the first one
+// unless it already has the same values. It returns the ID and a
+// book.Imported.
+const (
+	ExportBooks = "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL ORDER BY id"
+	LockImport  = "SELECT pg_advisory_xact_lock(hashtext('books import'))"
+	UpsertBook  = "WITH existing AS (SELECT id, image_url, description FROM books WHERE title = $1::text AND published_date = $2::timestamptz AND deleted_at IS NULL ORDER BY id LIMIT 1), " +
+		"updated AS (UPDATE books b set image_url = $3::text, description = $4::text FROM existing e WHERE b.id = e.id AND (e.image_url, e.description) IS DISTINCT FROM ($3::text, $4::text) RETURNING b.id), " +
+		"inserted AS (INSERT INTO books (title, published_date, image_url, description) SELECT $1::text, $2::timestamptz, $3::text, $4::text WHERE NOT EXISTS (SELECT 1 FROM existing) RETURNING id) " +
+		"SELECT id, 'created' FROM inserted UNION ALL SELECT id, 'updated' FROM updated UNION ALL SELECT id, 'unchanged' FROM existing WHERE NOT EXISTS (SELECT 1 FROM updated)"
 )
 
 func New(db *sqlx.DB) *bookRepository {
@@ -48,26 +124,59 @@ func (r *bookRepository) Create(ctx context.Context, req *book.CreateRequest) (b
 	return bookID, nil
 }
 
-func (r *bookRepository) List(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
+func (r *bookRepository) List(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
 	if f == nil {
-		return nil, errors.New("filter cannot be nil")
+		return nil, 0, errors.New("filter cannot be nil")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, CountBooks, f.Trashed); err != nil {
+		return nil, 0, message.ErrFetchingBook
 	}
+
 	if f.Base.DisablePaging {
 		var books []*book.Schema
-		err := r.db.SelectContext(ctx, &books, SelectFromBooks)
+		err := r.db.SelectContext(ctx, &books, SelectFromBooks, f.Trashed)
 		if err != nil {
-			return nil, message.ErrFetchingBook
+			return nil, 0, message.ErrFetchingBook
 		}
 
-		return books, nil
+		return books, total, nil
+	} else if f.Base.CursorPaging {
+		books, err := r.listAfter(ctx, f)
+		if err != nil {
+			return nil, 0, err
+		}
+		return books, total, nil
 	} else {
 		var books []*book.Schema
-		err := r.db.SelectContext(ctx, &books, SelectFromBooksPaginate, f.Base.Limit, f.Base.Offset)
+		err := r.db.SelectContext(ctx, &books, SelectFromBooksPaginate, f.Trashed, f.Base.Limit, f.Base.Offset)
 		if err != nil {
-			return nil, message.ErrFetchingBook
+			return nil, 0, message.ErrFetchingBook
 		}
-		return books, nil
+		return books, total, nil
+	}
+}
+
+// listAfter pages on (created_at, id) instead of OFFSET so that deep pages
+// stay fast and rows inserted in between do not shift the window.
+func (r *bookRepository) listAfter(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
+	cursor, err := filter.DecodeCursor(f.Base.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []*book.Schema
+	if cursor == nil {
+		err = r.db.SelectContext(ctx, &books, SelectFromBooksFirst, f.Trashed, f.Base.Limit)
+	} else {
+		err = r.db.SelectContext(ctx, &books, SelectFromBooksAfter, f.Trashed, cursor.CreatedAt, cursor.ID, f.Base.Limit)
 	}
+	if err != nil {
+		return nil, message.ErrFetchingBook
+	}
+
+	return books, nil
 }
 
 func (r *bookRepository) Read(ctx context.Context, bookID uint64) (*book.Schema, error) {
@@ -75,7 +184,7 @@ func (r *bookRepository) Read(ctx context.Context, bookID uint64) (*book.Schema,
 	err := r.db.GetContext(ctx, &b, SelectBookByID, bookID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, message.ErrBadRequest
+			return nil, fmt.Errorf("book %d: %w", bookID, message.ErrNoRecord)
 		}
 		return nil, err
 	}
@@ -92,38 +201,313 @@ func (r *bookRepository) Update(ctx context.Context, book *book.UpdateRequest) e
 		book.PublishedDate,
 		book.ImageURL,
 		book.ID,
+		pq.Array(book.IfMatch),
 	).Scan(&returnedID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.missing(ctx, book.ID, book.IfMatch)
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (r *bookRepository) Delete(ctx context.Context, bookID uint64) error {
+// Delete moves a book to the trash. When ifMatch is given, the book must
+// still be at one of those versions.
+func (r *bookRepository) Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
 	var returnedID int
-	err := r.db.QueryRowContext(ctx, DeleteByID, bookID).Scan(&returnedID)
+	err := r.db.QueryRowContext(ctx, DeleteByID, bookID, pq.Array(ifMatch)).Scan(&returnedID)
 	if err != nil {
-		return fmt.Errorf("ID not found: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.missing(ctx, bookID, ifMatch)
+		}
+		return err
 	}
 
 	return nil
 }
 
-func (r *bookRepository) Search(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
-	if f == nil {
-		return nil, errors.New("filter cannot be nil")
+// CreateMany inserts books with one statement. The IDs are returned in the
+// order of books.
+func (r *bookRepository) CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+	titles := make([]string, len(books))
+	publishedDates := make([]string, len(books))
+	imageURLs := make([]string, len(books))
+	descriptions := make([]string, len(books))
+	for i, b := range books {
+		titles[i] = b.Title
+		publishedDates[i] = b.PublishedDate
+		imageURLs[i] = b.ImageURL
+		descriptions[i] = b.Description
 	}
-	var books []*book.Schema
-	err := r.db.SelectContext(ctx, &books, SearchBooksPaginate,
-		f.Title,
-		f.Description,
-		f.Base.Limit,
-		f.Base.Offset,
+
+	var rows []struct {
+		Ordinality int    `db:"ordinality"`
+		ID         uint64 `db:"id"`
+	}
+	err := r.db.SelectContext(ctx, &rows, InsertManyBooks,
+		pq.Array(titles),
+		pq.Array(publishedDates),
+		pq.Array(imageURLs),
+		pq.Array(descriptions),
 	)
 	if err != nil {
 		return nil, err
 	}
+	if len(rows) != len(books) {
+		return nil, fmt.Errorf("inserted %d of %d books", len(rows), len(books))
+	}
 
-	return books, nil
+	// The ordinality counts the input rows from 1.
+	ids := make([]uint64, len(books))
+	for _, row := range rows {
+		ids[row.Ordinality-1] = row.ID
+	}
+
+	return ids, nil
+}
+
+// UpdateMany sets the fields of req.Set on every live book in req.IDs and
+// returns the IDs of the books that are updated.
+//
+// When req.Atomic is set and some books are not found, nothing is updated.
+// The IDs that were found are then returned along with message.ErrNoRecord.
+func (r *bookRepository) UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+	return r.writeMany(ctx, req.IDs, req.Atomic, UpdateManyBooks,
+		pq.Array(req.IDs),
+		req.Set.Title,
+		req.Set.PublishedDate,
+		req.Set.ImageURL,
+		req.Set.Description,
+	)
+}
+
+// DeleteMany moves every live book in req.IDs to the trash and returns the IDs
+// of the books that are deleted. req.Atomic works as in UpdateMany.
+func (r *bookRepository) DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error) {
+	return r.writeMany(ctx, req.IDs, req.Atomic, DeleteManyByID, pq.Array(req.IDs))
+}
+
+// writeMany runs a bulk statement that returns the IDs it wrote. In atomic
+// mode, the write is rolled back unless every one of bookIDs was written.
+func (r *bookRepository) writeMany(ctx context.Context, bookIDs []uint64, atomic bool, query string, args ...any) ([]uint64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var written []uint64
+	if err = tx.SelectContext(ctx, &written, query, args...); err != nil {
+		return nil, err
+	}
+
+	if atomic {
+		found := make(map[uint64]struct{}, len(written))
+		for _, id := range written {
+			found[id] = struct{}{}
+		}
+		for _, id := range bookIDs {
+			if _, ok := found[id]; !ok {
+				return written, fmt.Errorf("book %d: %w", id, message.ErrNoRecord)
+			}
+		}
+	}
+
+	return written, tx.Commit()
+}
+
+// Export calls fn with every live book in the order of their IDs. Books are
+// read from the cursor one at a time, so that they are never all in memory.
+func (r *bookRepository) Export(ctx context.Context, fn func(b *book.Schema) error) error {
+	rows, err := r.db.QueryxContext(ctx, ExportBooks)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b book.Schema
+		if err = rows.StructScan(&b); err != nil {
+			return err
+		}
+		if err = fn(&b); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Import runs fn in a transaction, where upsert writes one book at a time.
+// Nothing is imported if fn returns an error. Imports run one after another,
+// so that two of them do not both create the same book.
+func (r *bookRepository) Import(ctx context.Context, fn func(upsert book.Upsert) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, LockImport); err != nil {
+		return err
+	}
+
+	err = fn(func(ctx context.Context, b *book.CreateRequest) (uint64, book.Imported, error) {
+		var (
+			id       uint64
+			imported book.Imported
+		)
+		err := tx.QueryRowContext(ctx, UpsertBook, b.Title, b.PublishedDate, b.ImageURL, b.Description).Scan(&id, &imported)
+		return id, imported, err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// missing tells why a conditional write of a book matched no row. Either the
+// book does not exist, or it has been modified since the versions in ifMatch.
+func (r *bookRepository) missing(ctx context.Context, bookID uint64, ifMatch []time.Time) error {
+	if len(ifMatch) > 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, LiveBookExists, bookID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("book %d: %w", bookID, message.ErrModified)
+		}
+	}
+
+	return fmt.Errorf("book %d: %w", bookID, message.ErrNoRecord)
+}
+
+func (r *bookRepository) Restore(ctx context.Context, bookID uint64) error {
+	var returnedID int
+	err := r.db.QueryRowContext(ctx, RestoreByID, bookID).Scan(&returnedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("trashed book %d: %w", bookID, message.ErrNoRecord)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Purge permanently removes books that were moved to the trash before the
+// given time. Their book_authors rows go with them through the cascade.
+func (r *bookRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, PurgeTrashed, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Authors returns the live authors of each given book, keyed by book ID.
+func (r *bookRepository) Authors(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error) {
+	found := make(map[uint64][]*book.Author, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return found, nil
+	}
+
+	query, args, err := sqlx.In(SelectBookAuthors, bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		BookID uint64 `db:"book_id"`
+		book.Author
+	}
+	err = r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		a := row.Author
+		found[row.BookID] = append(found[row.BookID], &a)
+	}
+
+	return found, nil
+}
+
+// SetAuthors replaces the authors linked to a book in one transaction. It
+// fails with message.ErrNoRecord if the book or any of the authors does not
+// exist or is soft-deleted, leaving the existing links untouched.
+func (r *bookRepository) SetAuthors(ctx context.Context, bookID uint64, authorIDs []uint64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id uint64
+	if err = tx.GetContext(ctx, &id, LockLiveBook, bookID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("book %d: %w", bookID, message.ErrNoRecord)
+		}
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, DetachBookAuthors, bookID); err != nil {
+		return err
+	}
+
+	seen := make(map[uint64]struct{}, len(authorIDs))
+	for _, authorID := range authorIDs {
+		if _, ok := seen[authorID]; ok {
+			continue
+		}
+		seen[authorID] = struct{}{}
+
+		res, err := tx.ExecContext(ctx, AttachBookAuthor, bookID, authorID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected != 1 {
+			return fmt.Errorf("author %d: %w", authorID, message.ErrNoRecord)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *bookRepository) Search(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
+	if f == nil {
+		return nil, 0, errors.New("filter cannot be nil")
+	}
+
+	var total int
+	err := r.db.GetContext(ctx, &total, CountSearchBooks, f.Trashed, f.Query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var books []*book.Schema
+	if f.Base.DisablePaging {
+		err = r.db.SelectContext(ctx, &books, SearchBooks, f.Trashed, f.Query)
+	} else {
+		err = r.db.SelectContext(ctx, &books, SearchBooksPaginate,
+			f.Trashed,
+			f.Query,
+			f.Base.Limit,
+			f.Base.Offset,
+		)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return books, total, nil
 }
@@ -19,8 +19,10 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"micro/database"
+	"micro/internal/domain/audit"
 	"micro/internal/domain/book"
 	"micro/internal/utility/filter"
+	"micro/internal/middleware"
 	"micro/internal/utility/message"
 )
 
@@ -221,8 +223,7 @@ func TestRepository_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					Title:         "",
-					Description:   "",
+					Query:         "",
 					PublishedDate: "",
 				},
 			},
@@ -255,8 +256,7 @@ func TestRepository_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					Title:         "",
-					Description:   "",
+					Query:         "",
 					PublishedDate: "",
 				},
 			},
@@ -293,8 +293,7 @@ func TestRepository_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					Title:         "",
-					Description:   "",
+					Query:         "",
 					PublishedDate: "",
 				},
 			},
@@ -318,6 +317,48 @@ func TestRepository_List(t *testing.T) {
 				err: nil,
 			},
 		},
+		{
+			name: "cursor paging first page",
+			args: args{
+				ctx: context.Background(),
+				f: &book.Filter{
+					Base: filter.Filter{
+						Limit:        1,
+						CursorPaging: true,
+						Cursor:       "",
+					},
+				},
+			},
+			want: want{
+				books: []*book.Schema{
+					{
+						ID:            2,
+						Title:         "2",
+						PublishedDate: timeParsed,
+						ImageURL:      "https://example.com/image.png",
+						Description:   "description",
+					},
+				},
+				err: nil,
+			},
+		},
+		{
+			name: "invalid cursor",
+			args: args{
+				ctx: context.Background(),
+				f: &book.Filter{
+					Base: filter.Filter{
+						Limit:        1,
+						CursorPaging: true,
+						Cursor:       "garbage",
+					},
+				},
+			},
+			want: want{
+				books: nil,
+				err:   filter.ErrInvalidCursor,
+			},
+		},
 		{
 			name: "filter cannot be nil",
 			args: args{
@@ -336,13 +377,14 @@ func TestRepository_List(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got, err := repo.List(test.args.ctx, test.args.f)
+			got, total, err := repo.List(test.args.ctx, test.args.f)
 			assert.Equal(t, test.want.err, err)
 
 			if err != nil {
 				assert.Nil(t, test.want.books)
 				return
 			}
+			assert.Equal(t, 2, total)
 
 			for i, val := range got {
 				assert.Equal(t, test.want.books[i].ID, val.ID)
@@ -356,6 +398,24 @@ func TestRepository_List(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("cursor paging walks every row once", func(t *testing.T) {
+		f := &book.Filter{Base: filter.Filter{Limit: 1, CursorPaging: true}}
+
+		var ids []uint64
+		for {
+			page, _, err := repo.List(context.Background(), f)
+			assert.Nil(t, err)
+			if len(page) == 0 {
+				break
+			}
+			last := page[len(page)-1]
+			ids = append(ids, last.ID)
+			f.Base.Cursor = filter.NewCursor(last.CreatedAt, last.ID).Encode()
+		}
+
+		assert.Equal(t, []uint64{2, 1}, ids)
+	})
 }
 
 func TestRepository_Read(t *testing.T) {
@@ -412,7 +472,7 @@ func TestRepository_Read(t *testing.T) {
 			},
 			want: want{
 				book: nil,
-				err:  message.ErrBadRequest,
+				err:  message.ErrNoRecord,
 			},
 		},
 	}
@@ -426,7 +486,11 @@ func TestRepository_Read(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			got, err := repo.Read(test.args.Context, test.args.uint64)
-			assert.Equal(t, test.want.err, err)
+			if test.want.err != nil {
+				assert.ErrorIs(t, err, test.want.err)
+			} else {
+				assert.Nil(t, err)
+			}
 			if err != nil {
 				assert.Nil(t, test.want.book)
 				return
@@ -551,7 +615,7 @@ func TestRepository_Delete(t *testing.T) {
 				bookID:  math.MaxInt - 1,
 			},
 			want: want{
-				err: errors.New("ID not found: sql: no rows in result set"),
+				err: message.ErrNoRecord,
 			},
 		},
 	}
@@ -564,7 +628,7 @@ func TestRepository_Delete(t *testing.T) {
 			err := repo.Delete(test.args.Context, test.args.bookID)
 
 			if err != nil {
-				assert.Equal(t, test.want.err.Error(), err.Error())
+				assert.ErrorIs(t, err, test.want.err)
 				return
 			}
 			assert.Equal(t, test.want.err, err)
@@ -573,6 +637,102 @@ func TestRepository_Delete(t *testing.T) {
 	}
 }
 
+func TestRepository_Restore(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	repo := New(client)
+	ctx := context.Background()
+
+	// Book ID=1 was soft-deleted by TestRepository_Delete.
+	_, err := repo.Read(ctx, 1)
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+
+	trashed, total, err := repo.List(ctx, &book.Filter{
+		Base:    filter.Filter{Limit: 10},
+		Trashed: book.TrashedOnly,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, uint64(1), trashed[0].ID)
+	assert.True(t, trashed[0].DeletedAt.Valid)
+
+	err = repo.Restore(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err := repo.Read(ctx, 1)
+	assert.Nil(t, err)
+	assert.False(t, got.DeletedAt.Valid)
+
+	err = repo.Restore(ctx, 1)
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+}
+
+func TestRepository_Purge(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	repo := New(client)
+	ctx := context.Background()
+
+	err := repo.Delete(ctx, 1)
+	assert.Nil(t, err)
+
+	purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	purged, err = repo.Purge(ctx, time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, total, err := repo.List(ctx, &book.Filter{
+		Base:    filter.Filter{Limit: 10},
+		Trashed: book.TrashedWith,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, total)
+}
+
+func TestRepository_SetAuthors(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	repo := New(client)
+	ctx := context.Background()
+
+	books, _, err := repo.List(ctx, &book.Filter{Base: filter.Filter{Limit: 1}})
+	assert.Nil(t, err)
+	bookID := books[0].ID
+
+	var authorIDs []uint64
+	err = client.SelectContext(ctx, &authorIDs, `
+		INSERT INTO authors (first_name, middle_name, last_name)
+		VALUES ('First', NULL, 'Author'), ('Second', 'M', 'Author')
+		RETURNING id`)
+	assert.Nil(t, err)
+
+	err = repo.SetAuthors(ctx, bookID, authorIDs)
+	assert.Nil(t, err)
+
+	got, err := repo.Authors(ctx, bookID)
+	assert.Nil(t, err)
+	assert.Len(t, got[bookID], 2)
+
+	err = repo.SetAuthors(ctx, bookID, authorIDs[1:])
+	assert.Nil(t, err)
+
+	got, err = repo.Authors(ctx, bookID)
+	assert.Nil(t, err)
+	assert.Len(t, got[bookID], 1)
+	assert.Equal(t, "Second", got[bookID][0].FirstName)
+
+	err = repo.SetAuthors(ctx, bookID, []uint64{999999})
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+
+	// A failed replacement leaves the existing links untouched.
+	got, err = repo.Authors(ctx, bookID)
+	assert.Nil(t, err)
+	assert.Len(t, got[bookID], 1)
+
+	err = repo.SetAuthors(ctx, 999999, authorIDs)
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+}
+
 func TestRepository_Search(t *testing.T) {
 	type args struct {
 		context.Context
@@ -605,24 +765,41 @@ func TestRepository_Search(t *testing.T) {
 						Sort:          nil,
 						Search:        true,
 					},
-					Title:         "2",
-					Description:   "",
+					Query:         "2",
 					PublishedDate: "",
 				},
 			},
 			want: want{
 				books: []*book.Schema{
 					{
-						ID:            2,
-						Title:         "2",
-						PublishedDate: timeParsed,
-						ImageURL:      "https://example.com/image.png",
-						Description:   "description",
+						ID:             2,
+						Title:          "2",
+						PublishedDate:  timeParsed,
+						ImageURL:       "https://example.com/image.png",
+						Description:    "description",
+						TitleHighlight: "<b>2</b>",
 					},
 				},
 				err: nil,
 			},
 		},
+		{
+			name: "excluded word",
+			args: args{
+				Context: context.Background(),
+				f: &book.Filter{
+					Base: filter.Filter{
+						Limit:  10,
+						Search: true,
+					},
+					Query: "2 -description",
+				},
+			},
+			want: want{
+				books: []*book.Schema{},
+				err:   nil,
+			},
+		},
 		{
 			name: "nil filter",
 			args: args{
@@ -641,12 +818,13 @@ func TestRepository_Search(t *testing.T) {
 			client := sqlxDBClient(migrator.DB)
 			repo := New(client)
 
-			got, err := repo.Search(test.args.Context, test.args.f)
+			got, total, err := repo.Search(test.args.Context, test.args.f)
 			assert.Equal(t, test.want.err, err)
 
 			if err != nil {
 				return
 			}
+			assert.Equal(t, len(test.want.books), total)
 
 			for i, val := range got {
 				assert.Equal(t, test.want.books[i].ID, val.ID)
@@ -657,12 +835,194 @@ func TestRepository_Search(t *testing.T) {
 				assert.True(t, startTime.Before(got[i].CreatedAt) || startTime.Equal(got[i].CreatedAt))
 				assert.True(t, startTime.Before(got[i].UpdatedAt) || startTime.Equal(got[i].UpdatedAt))
 				assert.Equal(t, test.want.books[i].DeletedAt, got[i].DeletedAt)
+				assert.Equal(t, test.want.books[i].TitleHighlight, got[i].TitleHighlight)
+				assert.Greater(t, got[i].Rank, float64(0))
 			}
 
 		})
 	}
 }
 
+func TestRepository_Bulk(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	repo := New(client)
+	ctx := context.Background()
+
+	ids, err := repo.CreateMany(ctx, []*book.CreateRequest{
+		{Title: "bulk 1", PublishedDate: "2020-01-01", ImageURL: "https://example.com/1.png", Description: "first"},
+		{Title: "bulk 2", PublishedDate: "2021-02-03T04:05:06Z", ImageURL: "https://example.com/2.png", Description: "second"},
+		{Title: "bulk 3", PublishedDate: "2022-03-04", ImageURL: "https://example.com/3.png", Description: "third"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ids, 3)
+	for i, id := range ids {
+		got, err := repo.Read(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("bulk %d", i+1), got.Title)
+	}
+
+	missing := uint64(math.MaxInt - 1)
+	description := "updated in bulk"
+
+	// An atomic update with a missing book writes nothing.
+	updated, err := repo.UpdateMany(ctx, &book.BulkUpdateRequest{
+		IDs:    []uint64{ids[0], missing},
+		Set:    book.BulkFields{Description: &description},
+		Atomic: true,
+	})
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+	assert.Equal(t, []uint64{ids[0]}, updated)
+	got, err := repo.Read(ctx, ids[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "first", got.Description)
+
+	updated, err = repo.UpdateMany(ctx, &book.BulkUpdateRequest{
+		IDs: []uint64{ids[0], ids[1], missing},
+		Set: book.BulkFields{Description: &description},
+	})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uint64{ids[0], ids[1]}, updated)
+	got, err = repo.Read(ctx, ids[1])
+	assert.Nil(t, err)
+	assert.Equal(t, "bulk 2", got.Title)
+	assert.Equal(t, description, got.Description)
+
+	deleted, err := repo.DeleteMany(ctx, &book.BulkDeleteRequest{
+		IDs: []uint64{ids[1], ids[2], missing},
+	})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uint64{ids[1], ids[2]}, deleted)
+	_, err = repo.Read(ctx, ids[2])
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+
+	// Books in the trash are not found again.
+	deleted, err = repo.DeleteMany(ctx, &book.BulkDeleteRequest{
+		IDs:    []uint64{ids[0], ids[1]},
+		Atomic: true,
+	})
+	assert.ErrorIs(t, err, message.ErrNoRecord)
+	assert.Equal(t, []uint64{ids[0]}, deleted)
+	_, err = repo.Read(ctx, ids[0])
+	assert.Nil(t, err)
+}
+
+func TestRepository_ImportExport(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	repo := New(client)
+	ctx := context.Background()
+
+	rows := []*book.CreateRequest{
+		{Title: "import 1", PublishedDate: "2020-01-01", ImageURL: "https://example.com/1.png", Description: "first"},
+		{Title: "import 2", PublishedDate: "2021-02-03T04:05:06Z", ImageURL: "https://example.com/2.png", Description: "second"},
+	}
+	importAll := func(rows []*book.CreateRequest) ([]book.Imported, error) {
+		var got []book.Imported
+		err := repo.Import(ctx, func(upsert book.Upsert) error {
+			for _, b := range rows {
+				_, imported, err := upsert(ctx, b)
+				if err != nil {
+					return err
+				}
+				got = append(got, imported)
+			}
+			return nil
+		})
+		return got, err
+	}
+
+	got, err := importAll(rows)
+	assert.Nil(t, err)
+	assert.Equal(t, []book.Imported{book.ImportCreated, book.ImportCreated}, got)
+
+	// Importing again is matched on title and published date.
+	changed := *rows[1]
+	changed.Description = "changed"
+	got, err = importAll([]*book.CreateRequest{rows[0], &changed})
+	assert.Nil(t, err)
+	assert.Equal(t, []book.Imported{book.ImportUnchanged, book.ImportUpdated}, got)
+
+	// Nothing is written when the import fails.
+	failed := errors.New("failed")
+	err = repo.Import(ctx, func(upsert book.Upsert) error {
+		if _, _, err := upsert(ctx, &book.CreateRequest{Title: "import 3", PublishedDate: "2022-01-01", ImageURL: "https://example.com/3.png", Description: "third"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	exported := map[string]*book.Schema{}
+	var lastID uint64
+	err = repo.Export(ctx, func(b *book.Schema) error {
+		assert.Greater(t, b.ID, lastID)
+		assert.False(t, b.DeletedAt.Valid)
+		lastID = b.ID
+		exported[b.Title] = b
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "first", exported["import 1"].Description)
+	assert.Equal(t, "changed", exported["import 2"].Description)
+	assert.NotContains(t, exported, "import 3")
+}
+
+func TestBookAudit(t *testing.T) {
+	client := sqlxDBClient(migrator.DB)
+	recorder := audit.NewRepo(client)
+	repo := NewAudit(New(client), recorder)
+
+	ctx := context.WithValue(context.Background(), middleware.KeyAuditID, middleware.Event{
+		ActorID:    7,
+		HTTPMethod: "POST",
+		URL:        "/api/v1/book",
+	})
+
+	bookID, err := repo.Create(ctx, &book.CreateRequest{
+		Title:         "audited",
+		PublishedDate: "2020-01-01",
+		ImageURL:      "https://example.com/audited.png",
+		Description:   "before",
+	})
+	assert.Nil(t, err)
+
+	err = repo.Update(ctx, &book.UpdateRequest{
+		ID:            bookID,
+		Title:         "audited",
+		PublishedDate: "2020-01-01",
+		ImageURL:      "https://example.com/audited.png",
+		Description:   "after",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, repo.Delete(ctx, bookID))
+
+	// Writes outside a request are not recorded.
+	assert.Nil(t, repo.Restore(context.Background(), bookID))
+
+	logs, total, err := recorder.List(ctx, &audit.Filter{Base: filter.Filter{Limit: 10}, Table: "books", RowID: bookID})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, logs, 3)
+
+	// Newest first.
+	assert.Equal(t, "delete", logs[0].Action)
+	assert.Equal(t, "update", logs[1].Action)
+	assert.Equal(t, "create", logs[2].Action)
+	for _, l := range logs {
+		assert.Equal(t, uint64(7), l.ActorID)
+		assert.Equal(t, "/api/v1/book", l.URL)
+		assert.NotContains(t, l.NewValues, `"search"`)
+	}
+	assert.Empty(t, logs[2].OldValues)
+	assert.Contains(t, logs[2].NewValues, `"description": "before"`)
+	assert.Contains(t, logs[1].OldValues, `"description": "before"`)
+	assert.Contains(t, logs[1].NewValues, `"description": "after"`)
+	assert.Contains(t, logs[0].NewValues, `"deleted_at": "20`)
+
+	logs, _, err = recorder.List(ctx, &audit.Filter{Base: filter.Filter{Limit: 10}, ActorID: 8})
+	assert.Nil(t, err)
+	assert.Empty(t, logs)
+}
+
 func sqlxDBClient(db *sql.DB) *sqlx.DB {
 	return sqlx.NewDb(db, DBDriver)
 }
@@ -6,38 +6,84 @@ package repository
 import (
 	"context"
 	"micro/internal/domain/book"
+	"time"
 )
 
 // BookMock is a mock implementation of Book.
 type BookMock struct {
-	CreateFunc func(ctx context.Context, bookMiripParam *book.CreateRequest) (uint64, error)
-	DeleteFunc func(ctx context.Context, bookID uint64) error
-	ListFunc   func(ctx context.Context, f *book.Filter) ([]*book.Schema, error)
-	ReadFunc   func(ctx context.Context, bookID uint64) (*book.Schema, error)
-	SearchFunc func(ctx context.Context, req *book.Filter) ([]*book.Schema, error)
-	UpdateFunc func(ctx context.Context, bookMiripParam *book.UpdateRequest) error
+	AuthorsFunc    func(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error)
+	CreateFunc     func(ctx context.Context, bookMiripParam *book.CreateRequest) (uint64, error)
+	CreateManyFunc func(ctx context.Context, books []*book.CreateRequest) ([]uint64, error)
+	DeleteFunc     func(ctx context.Context, bookID uint64, ifMatch ...time.Time) error
+	DeleteManyFunc func(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error)
+	ExportFunc     func(ctx context.Context, fn func(b *book.Schema) error) error
+	ImportFunc     func(ctx context.Context, fn func(upsert book.Upsert) error) error
+	ListFunc       func(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error)
+	PurgeFunc      func(ctx context.Context, deletedBefore time.Time) (int64, error)
+	ReadFunc       func(ctx context.Context, bookID uint64) (*book.Schema, error)
+	RestoreFunc    func(ctx context.Context, bookID uint64) error
+	SearchFunc     func(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error)
+	SetAuthorsFunc func(ctx context.Context, bookID uint64, authorIDs []uint64) error
+	UpdateFunc     func(ctx context.Context, bookMiripParam *book.UpdateRequest) error
+	UpdateManyFunc func(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error)
+}
+
+func (m *BookMock) Authors(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error) {
+	return m.AuthorsFunc(ctx, bookIDs...)
 }
 
 func (m *BookMock) Create(ctx context.Context, bookMiripParam *book.CreateRequest) (uint64, error) {
 	return m.CreateFunc(ctx, bookMiripParam)
 }
 
-func (m *BookMock) Delete(ctx context.Context, bookID uint64) error {
-	return m.DeleteFunc(ctx, bookID)
+func (m *BookMock) CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+	return m.CreateManyFunc(ctx, books)
+}
+
+func (m *BookMock) Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
+	return m.DeleteFunc(ctx, bookID, ifMatch...)
+}
+
+func (m *BookMock) DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error) {
+	return m.DeleteManyFunc(ctx, req)
+}
+
+func (m *BookMock) Export(ctx context.Context, fn func(b *book.Schema) error) error {
+	return m.ExportFunc(ctx, fn)
+}
+
+func (m *BookMock) Import(ctx context.Context, fn func(upsert book.Upsert) error) error {
+	return m.ImportFunc(ctx, fn)
 }
 
-func (m *BookMock) List(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
+func (m *BookMock) List(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
 	return m.ListFunc(ctx, f)
 }
 
+func (m *BookMock) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return m.PurgeFunc(ctx, deletedBefore)
+}
+
 func (m *BookMock) Read(ctx context.Context, bookID uint64) (*book.Schema, error) {
 	return m.ReadFunc(ctx, bookID)
 }
 
-func (m *BookMock) Search(ctx context.Context, req *book.Filter) ([]*book.Schema, error) {
+func (m *BookMock) Restore(ctx context.Context, bookID uint64) error {
+	return m.RestoreFunc(ctx, bookID)
+}
+
+func (m *BookMock) Search(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error) {
 	return m.SearchFunc(ctx, req)
 }
 
+func (m *BookMock) SetAuthors(ctx context.Context, bookID uint64, authorIDs []uint64) error {
+	return m.SetAuthorsFunc(ctx, bookID, authorIDs)
+}
+
 func (m *BookMock) Update(ctx context.Context, bookMiripParam *book.UpdateRequest) error {
 	return m.UpdateFunc(ctx, bookMiripParam)
 }
+
+func (m *BookMock) UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+	return m.UpdateManyFunc(ctx, req)
+}
@@ -1,8 +1,10 @@
 package book
 
+import "time"
+
 type CreateRequest struct {
 	Title         string `json:"title" validate:"required"`
-	PublishedDate string `json:"published_date" validate:"required"`
+	PublishedDate string `json:"published_date" validate:"required,iso_date"`
 	ImageURL      string `json:"image_url" validate:"url"`
 	Description   string `json:"description" validate:"required"`
 }
@@ -10,7 +12,61 @@ type CreateRequest struct {
 type UpdateRequest struct {
 	ID            uint64 `json:"-"`
 	Title         string `json:"title" validate:"required"`
-	PublishedDate string `json:"published_date" validate:"required"`
+	PublishedDate string `json:"published_date" validate:"required,iso_date"`
 	ImageURL      string `json:"image_url" validate:"url"`
 	Description   string `json:"description" validate:"required"`
+
+	// IfMatch lists the versions from If-Match that the book must still be
+	// at. The update is unconditional when it is nil.
+	IfMatch []time.Time `json:"-"`
+}
+
+// AuthorsRequest replaces the set of authors of a book. An empty list
+// detaches every author.
+type AuthorsRequest struct {
+	BookID    uint64   `json:"-"`
+	AuthorIDs []uint64 `json:"author_ids" validate:"required"`
+}
+
+// BulkCreateRequest creates many books at once. Each book is validated on its
+// own. Unless Atomic is set, the valid ones are created even when others are
+// not.
+type BulkCreateRequest struct {
+	Books  []*CreateRequest `json:"books" validate:"required,min=1,max=1000"`
+	Atomic bool             `json:"atomic"`
+}
+
+// BulkUpdateRequest sets the same fields on every book in IDs. Unless Atomic
+// is set, the books that are found are updated even when others are not.
+type BulkUpdateRequest struct {
+	IDs    []uint64   `json:"ids" validate:"required,min=1,max=1000,dive,required"`
+	Set    BulkFields `json:"set"`
+	Atomic bool       `json:"atomic"`
+}
+
+// BulkFields are the fields that a bulk update sets. Fields left out keep
+// their value in each book.
+type BulkFields struct {
+	Title         *string `json:"title,omitempty" validate:"omitnil,required"`
+	PublishedDate *string `json:"published_date,omitempty" validate:"omitnil,iso_date"`
+	ImageURL      *string `json:"image_url,omitempty" validate:"omitnil,url"`
+	Description   *string `json:"description,omitempty" validate:"omitnil,required"`
+}
+
+// Empty reports whether no field is set.
+func (f BulkFields) Empty() bool {
+	return f.Title == nil && f.PublishedDate == nil && f.ImageURL == nil && f.Description == nil
+}
+
+// BulkDeleteRequest moves every book in IDs to the trash. Unless Atomic is
+// set, the books that are found are deleted even when others are not.
+type BulkDeleteRequest struct {
+	IDs    []uint64 `json:"ids" validate:"required,min=1,max=1000,dive,required"`
+	Atomic bool     `json:"atomic"`
+}
+
+// CoverRequest uploads the image in Data as the cover of a book.
+type CoverRequest struct {
+	BookID uint64
+	Data   []byte
 }
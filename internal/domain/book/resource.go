@@ -1,15 +1,35 @@
 package book
 
 import (
+	"strconv"
 	"time"
+
+	"micro/internal/utility/apperror"
 )
 
 type Res struct {
-	ID            uint64    `json:"id"`
-	Title         string    `json:"title"`
-	PublishedDate time.Time `json:"published_date"`
-	ImageURL      string    `json:"image_url" swaggertype:"string"`
-	Description   string    `json:"description" swaggertype:"string"`
+	ID            uint64       `json:"id"`
+	Title         string       `json:"title"`
+	PublishedDate time.Time    `json:"published_date"`
+	ImageURL      string       `json:"image_url" swaggertype:"string"`
+	Description   string       `json:"description" swaggertype:"string"`
+	Authors       []*AuthorRes `json:"authors"`
+	Rank          float64      `json:"rank,omitempty"`
+	Highlight     *Highlight   `json:"highlight,omitempty"`
+}
+
+// Highlight holds the fields of a search match with the matching words
+// wrapped in <b></b>.
+type Highlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type AuthorRes struct {
+	ID         uint64 `json:"id"`
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
 }
 
 func Resource(book *Schema) *Res {
@@ -22,6 +42,26 @@ func Resource(book *Schema) *Res {
 		PublishedDate: book.PublishedDate,
 		ImageURL:      book.ImageURL,
 		Description:   book.Description,
+		Rank:          book.Rank,
+	}
+
+	if book.TitleHighlight != "" || book.DescriptionHighlight != "" {
+		resource.Highlight = &Highlight{
+			Title:       book.TitleHighlight,
+			Description: book.DescriptionHighlight,
+		}
+	}
+
+	if book.Authors != nil {
+		resource.Authors = make([]*AuthorRes, 0, len(book.Authors))
+		for _, a := range book.Authors {
+			resource.Authors = append(resource.Authors, &AuthorRes{
+				ID:         a.ID,
+				FirstName:  a.FirstName,
+				MiddleName: a.MiddleName,
+				LastName:   a.LastName,
+			})
+		}
 	}
 
 	return resource
@@ -39,3 +79,100 @@ func Resources(books []*Schema) ([]*Res, error) {
 	}
 	return resources, nil
 }
+
+// BulkResult is the outcome of one item of a bulk request, in the order of the
+// request. Status is what the item would have been answered with on its own,
+// or 424 Failed Dependency when it was left undone because another item of an
+// atomic request failed.
+type BulkResult struct {
+	Index  int                   `json:"index"`
+	ID     uint64                `json:"id,omitempty"`
+	Status int                   `json:"status"`
+	Detail string                `json:"detail,omitempty"`
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
+
+type BulkRes struct {
+	Results []BulkResult `json:"results"`
+}
+
+// ExportColumns is the header of a CSV export. An export can be imported back,
+// as the columns that an import does not know are left out.
+var ExportColumns = []string{"id", "title", "published_date", "image_url", "description", "created_at", "updated_at"}
+
+// ExportRes is a book in an export.
+type ExportRes struct {
+	ID            uint64    `json:"id"`
+	Title         string    `json:"title"`
+	PublishedDate time.Time `json:"published_date"`
+	ImageURL      string    `json:"image_url"`
+	Description   string    `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func ExportResource(b *Schema) *ExportRes {
+	return &ExportRes{
+		ID:            b.ID,
+		Title:         b.Title,
+		PublishedDate: b.PublishedDate,
+		ImageURL:      b.ImageURL,
+		Description:   b.Description,
+		CreatedAt:     b.CreatedAt,
+		UpdatedAt:     b.UpdatedAt,
+	}
+}
+
+// CSV is the row of the book under ExportColumns.
+func (b *ExportRes) CSV() []string {
+	return []string{
+		strconv.FormatUint(b.ID, 10),
+		b.Title,
+		b.PublishedDate.Format(time.RFC3339),
+		b.ImageURL,
+		b.Description,
+		b.CreatedAt.Format(time.RFC3339Nano),
+		b.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// ImportRes counts what an import did with its books. Lines that fail are
+// listed in Errors, and are not imported.
+type ImportRes struct {
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Errors    []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is a line of an import that fails. Line counts from 1, and
+// includes the header of a CSV file.
+type ImportError struct {
+	Line   int                   `json:"line"`
+	Detail string                `json:"detail"`
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
+
+// CoverRes is a book with the thumbnails of the cover that was uploaded for
+// it. Its image_url is the cover at full size.
+type CoverRes struct {
+	*Res
+	Thumbnails []ThumbnailRes `json:"thumbnails"`
+}
+
+type ThumbnailRes struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+func CoverResource(c *Cover) *CoverRes {
+	res := &CoverRes{
+		Res:        Resource(c.Book),
+		Thumbnails: make([]ThumbnailRes, 0, len(c.Thumbnails)),
+	}
+	for _, t := range c.Thumbnails {
+		res.Thumbnails = append(res.Thumbnails, ThumbnailRes{Width: t.Width, Height: t.Height, URL: t.URL})
+	}
+	return res
+}
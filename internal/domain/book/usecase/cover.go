@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"micro/internal/domain/book"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/thumbnail"
+	"micro/third_party/blobstore"
+)
+
+// maxCoverPixels bounds the size of a decoded cover, as a small file can
+// claim to be a huge image.
+const maxCoverPixels = 5000 * 5000
+
+// thumbnailWidths are the widths of the thumbnails that are made of each
+// cover. A cover that is narrower than a width is not scaled up.
+var thumbnailWidths = []int{160, 480}
+
+var (
+	errCoverImage  = apperror.Validation("cover is not a valid JPEG, PNG or GIF image")
+	errCoverPixels = apperror.Validation(fmt.Sprintf("cover may have at most %d pixels", maxCoverPixels))
+)
+
+// SetCover stores an uploaded cover along with its thumbnails, and points the
+// image_url of the book at it. The previous cover is removed when it was
+// uploaded too.
+func (u *BookUseCase) SetCover(ctx context.Context, req *book.CoverRequest) (*book.Cover, error) {
+	current, err := u.bookRepo.Read(ctx, req.BookID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, errCoverImage
+	}
+	if cfg.Width*cfg.Height > maxCoverPixels {
+		return nil, errCoverPixels
+	}
+	img, _, err := image.Decode(bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, errCoverImage
+	}
+
+	// Each upload gets new keys, so that caches never serve a stale cover.
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	key := fmt.Sprintf("books/%d/cover-%s.%s", req.BookID, version, extension(format))
+
+	var keys []string
+	err = u.store.Put(ctx, key, "image/"+format, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, key)
+
+	thumbnails, err := u.putThumbnails(ctx, key, img, &keys)
+	if err != nil {
+		u.deleteBlobs(ctx, keys)
+		return nil, err
+	}
+
+	url := u.store.URL(key)
+	_, err = u.bookRepo.UpdateMany(ctx, &book.BulkUpdateRequest{
+		IDs:    []uint64{req.BookID},
+		Set:    book.BulkFields{ImageURL: &url},
+		Atomic: true,
+	})
+	if err != nil {
+		u.deleteBlobs(ctx, keys)
+		return nil, err
+	}
+
+	if old, ok := blobstore.Key(u.store, current.ImageURL); ok {
+		u.deleteBlobs(ctx, coverKeys(old))
+	}
+
+	b, err := u.bookRepo.Read(ctx, req.BookID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &book.Cover{Book: b, Thumbnails: thumbnails}, nil
+}
+
+// putThumbnails stores a JPEG thumbnail of img for each of thumbnailWidths,
+// and adds their keys to keys.
+func (u *BookUseCase) putThumbnails(ctx context.Context, key string, img image.Image, keys *[]string) ([]book.Thumbnail, error) {
+	flat := thumbnail.Flatten(img)
+
+	var thumbnails []book.Thumbnail
+	for i, width := range thumbnailWidths {
+		if i > 0 && thumbnailWidths[i-1] >= flat.Rect.Dx() {
+			// The previous thumbnail is the full cover already.
+			break
+		}
+
+		t := thumbnail.Resize(flat, width)
+		data, err := thumbnail.JPEG(t)
+		if err != nil {
+			return nil, err
+		}
+
+		thumbKey := thumbnailKey(key, width)
+		if err = u.store.Put(ctx, thumbKey, "image/jpeg", data); err != nil {
+			return nil, err
+		}
+		*keys = append(*keys, thumbKey)
+
+		thumbnails = append(thumbnails, book.Thumbnail{
+			Width:  t.Rect.Dx(),
+			Height: t.Rect.Dy(),
+			URL:    u.store.URL(thumbKey),
+		})
+	}
+
+	return thumbnails, nil
+}
+
+// deleteBlobs removes files that are no longer used. A file that cannot be
+// removed is only logged, as the book is correct without it.
+func (u *BookUseCase) deleteBlobs(ctx context.Context, keys []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := u.store.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "deleting cover", "key", key, "error", err)
+		}
+	}
+}
+
+// coverKeys are the keys of a cover and of all of its thumbnails.
+func coverKeys(key string) []string {
+	keys := []string{key}
+	for _, width := range thumbnailWidths {
+		keys = append(keys, thumbnailKey(key, width))
+	}
+	return keys
+}
+
+func thumbnailKey(key string, width int) string {
+	return fmt.Sprintf("%s-%d.jpg", strings.TrimSuffix(key, path.Ext(key)), width)
+}
+
+func extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
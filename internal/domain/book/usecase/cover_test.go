@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/domain/book"
+	"micro/internal/domain/book/repository"
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/message"
+	"micro/third_party/blobstore"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// storedFiles lists the files in dir by their key.
+func storedFiles(t *testing.T, dir string) []string {
+	var keys []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		key, err := filepath.Rel(dir, path)
+		keys = append(keys, filepath.ToSlash(key))
+		return err
+	})
+	assert.Nil(t, err)
+	return keys
+}
+
+func TestBookUseCase_SetCover(t *testing.T) {
+	const baseURL = "http://localhost:3080/storage"
+
+	tests := []struct {
+		name       string
+		data       []byte
+		imageURL   string
+		updateErr  error
+		err        error
+		thumbnails [][2]int
+	}{
+		{
+			name:       "thumbnails of every width",
+			data:       encodePNG(t, 800, 1200),
+			imageURL:   "https://example.com/image.png",
+			thumbnails: [][2]int{{160, 240}, {480, 720}},
+		},
+		{
+			name:       "narrow cover is not scaled up",
+			data:       encodePNG(t, 300, 200),
+			thumbnails: [][2]int{{160, 106}, {300, 200}},
+		},
+		{
+			name:       "tiny cover",
+			data:       encodePNG(t, 100, 100),
+			thumbnails: [][2]int{{100, 100}},
+		},
+		{
+			name: "not an image",
+			data: []byte("not an image"),
+			err:  errCoverImage,
+		},
+		{
+			name:      "book deleted meanwhile",
+			data:      encodePNG(t, 200, 200),
+			updateErr: message.ErrNoRecord,
+			err:       message.ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := blobstore.NewLocal(dir, baseURL)
+
+			imageURL := tt.imageURL
+			repo := &repository.BookMock{
+				ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+					return &book.Schema{ID: bookID, ImageURL: imageURL}, nil
+				},
+				UpdateManyFunc: func(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+					assert.Equal(t, []uint64{1}, req.IDs)
+					assert.True(t, req.Atomic)
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					imageURL = *req.Set.ImageURL
+					return req.IDs, nil
+				},
+			}
+
+			uc := New(repo, store)
+			got, err := uc.SetCover(context.Background(), &book.CoverRequest{BookID: 1, Data: tt.data})
+			assert.ErrorIs(t, err, tt.err)
+			if err != nil {
+				assert.Empty(t, storedFiles(t, dir))
+				return
+			}
+
+			key, ok := blobstore.Key(store, got.Book.ImageURL)
+			assert.True(t, ok)
+			stored, err := os.ReadFile(filepath.Join(dir, key))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.data, stored)
+
+			assert.Len(t, got.Thumbnails, len(tt.thumbnails))
+			for i, size := range tt.thumbnails {
+				thumb := got.Thumbnails[i]
+				assert.Equal(t, size, [2]int{thumb.Width, thumb.Height})
+
+				key, ok := blobstore.Key(store, thumb.URL)
+				assert.True(t, ok)
+				data, err := os.ReadFile(filepath.Join(dir, key))
+				assert.Nil(t, err)
+				cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+				assert.Nil(t, err)
+				assert.Equal(t, size, [2]int{cfg.Width, cfg.Height})
+			}
+			assert.Len(t, storedFiles(t, dir), len(tt.thumbnails)+1)
+
+			// A second upload replaces the files of the first.
+			again, err := uc.SetCover(context.Background(), &book.CoverRequest{BookID: 1, Data: tt.data})
+			assert.Nil(t, err)
+			assert.NotEqual(t, got.Book.ImageURL, again.Book.ImageURL)
+			assert.Len(t, storedFiles(t, dir), len(tt.thumbnails)+1)
+		})
+	}
+}
+
+func TestBookUseCase_SetCover_TooManyPixels(t *testing.T) {
+	// A PNG only needs a header that claims a huge image to make the decoder
+	// allocate it, so the size of the upload alone does not stop it.
+	data := encodePNG(t, 1, 1)
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:], 6000)
+	binary.BigEndian.PutUint32(ihdr[8:], 6000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+
+	repo := &repository.BookMock{
+		ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+			return &book.Schema{ID: bookID}, nil
+		},
+	}
+
+	uc := New(repo, blobstore.NewLocal(t.TempDir(), "http://localhost:3080/storage"))
+	_, err := uc.SetCover(context.Background(), &book.CoverRequest{BookID: 1, Data: data})
+	assert.True(t, errors.Is(err, errCoverPixels))
+	assert.True(t, apperror.Is(err, apperror.KindValidation))
+}
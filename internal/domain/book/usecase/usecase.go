@@ -2,28 +2,41 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"micro/internal/domain/book"
 	"micro/internal/domain/book/repository"
+	"micro/third_party/blobstore"
 )
 
 //go:generate mirip -rm -pkg usecase -out usecase_mock.go . Book
 type Book interface {
 	Create(ctx context.Context, book *book.CreateRequest) (*book.Schema, error)
-	List(ctx context.Context, f *book.Filter) ([]*book.Schema, error)
+	List(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error)
 	Read(ctx context.Context, bookID uint64) (*book.Schema, error)
 	Update(ctx context.Context, book *book.UpdateRequest) (*book.Schema, error)
-	Delete(ctx context.Context, bookID uint64) error
-	Search(ctx context.Context, req *book.Filter) ([]*book.Schema, error)
+	Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error
+	CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error)
+	UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error)
+	DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error)
+	Export(ctx context.Context, fn func(b *book.Schema) error) error
+	Import(ctx context.Context, fn func(upsert book.Upsert) error) error
+	Restore(ctx context.Context, bookID uint64) (*book.Schema, error)
+	Authors(ctx context.Context, books []*book.Schema) error
+	SetAuthors(ctx context.Context, req *book.AuthorsRequest) (*book.Schema, error)
+	SetCover(ctx context.Context, req *book.CoverRequest) (*book.Cover, error)
+	Search(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error)
 }
 
 type BookUseCase struct {
 	bookRepo repository.Book
+	store    blobstore.BlobStore
 }
 
-func New(bookRepo repository.Book) *BookUseCase {
+func New(bookRepo repository.Book, store blobstore.BlobStore) *BookUseCase {
 	return &BookUseCase{
 		bookRepo: bookRepo,
+		store:    store,
 	}
 }
 
@@ -39,7 +52,7 @@ func (u *BookUseCase) Create(ctx context.Context, book *book.CreateRequest) (*bo
 	return bookFound, err
 }
 
-func (u *BookUseCase) List(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
+func (u *BookUseCase) List(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
 	return u.bookRepo.List(ctx, f)
 }
 
@@ -55,10 +68,78 @@ func (u *BookUseCase) Update(ctx context.Context, book *book.UpdateRequest) (*bo
 	return u.bookRepo.Read(ctx, book.ID)
 }
 
-func (u *BookUseCase) Delete(ctx context.Context, bookID uint64) error {
-	return u.bookRepo.Delete(ctx, bookID)
+func (u *BookUseCase) Delete(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
+	return u.bookRepo.Delete(ctx, bookID, ifMatch...)
 }
 
-func (u *BookUseCase) Search(ctx context.Context, req *book.Filter) ([]*book.Schema, error) {
+func (u *BookUseCase) CreateMany(ctx context.Context, books []*book.CreateRequest) ([]uint64, error) {
+	return u.bookRepo.CreateMany(ctx, books)
+}
+
+func (u *BookUseCase) UpdateMany(ctx context.Context, req *book.BulkUpdateRequest) ([]uint64, error) {
+	return u.bookRepo.UpdateMany(ctx, req)
+}
+
+func (u *BookUseCase) DeleteMany(ctx context.Context, req *book.BulkDeleteRequest) ([]uint64, error) {
+	return u.bookRepo.DeleteMany(ctx, req)
+}
+
+func (u *BookUseCase) Export(ctx context.Context, fn func(b *book.Schema) error) error {
+	return u.bookRepo.Export(ctx, fn)
+}
+
+func (u *BookUseCase) Import(ctx context.Context, fn func(upsert book.Upsert) error) error {
+	return u.bookRepo.Import(ctx, fn)
+}
+
+func (u *BookUseCase) Restore(ctx context.Context, bookID uint64) (*book.Schema, error) {
+	err := u.bookRepo.Restore(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return u.bookRepo.Read(ctx, bookID)
+}
+
+// Authors loads the authors of each book into its Authors field.
+func (u *BookUseCase) Authors(ctx context.Context, books []*book.Schema) error {
+	ids := make([]uint64, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+
+	found, err := u.bookRepo.Authors(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range books {
+		b.Authors = found[b.ID]
+		if b.Authors == nil {
+			b.Authors = make([]*book.Author, 0)
+		}
+	}
+
+	return nil
+}
+
+func (u *BookUseCase) SetAuthors(ctx context.Context, req *book.AuthorsRequest) (*book.Schema, error) {
+	err := u.bookRepo.SetAuthors(ctx, req.BookID, req.AuthorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := u.bookRepo.Read(ctx, req.BookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.Authors(ctx, []*book.Schema{b}); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (u *BookUseCase) Search(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error) {
 	return u.bookRepo.Search(ctx, req)
 }
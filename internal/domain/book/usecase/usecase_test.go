@@ -11,6 +11,7 @@ import (
 	"micro/internal/domain/book"
 	"micro/internal/domain/book/repository"
 	"micro/internal/utility/filter"
+	"micro/internal/utility/message"
 )
 
 func TestBookUseCase_Create(t *testing.T) {
@@ -75,7 +76,7 @@ func TestBookUseCase_Create(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			uc := New(test.BookMock)
+			uc := New(test.BookMock, nil)
 
 			created, err := uc.Create(test.args.ctx, test.args.req)
 			assert.Equal(t, test.want.err, err)
@@ -122,8 +123,8 @@ func TestBookUseCase_List(t *testing.T) {
 			name: "simple",
 			fields: fields{
 				bookRepo: repository.BookMock{
-					ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, error) {
-						return oneBook, nil
+					ListFunc: func(ctx context.Context, f *book.Filter) ([]*book.Schema, int, error) {
+						return oneBook, len(oneBook), nil
 					},
 				},
 			},
@@ -138,8 +139,7 @@ func TestBookUseCase_List(t *testing.T) {
 						Sort:          nil,
 						Search:        false,
 					},
-					Title:         "",
-					Description:   "",
+					Query:         "",
 					PublishedDate: "",
 				},
 			},
@@ -152,9 +152,10 @@ func TestBookUseCase_List(t *testing.T) {
 			u := &BookUseCase{
 				bookRepo: &tt.fields.bookRepo,
 			}
-			got, err := u.List(tt.args.ctx, tt.args.f)
+			got, total, err := u.List(tt.args.ctx, tt.args.f)
 			assert.Equal(t, tt.wantErr, err)
 			assert.Equalf(t, tt.want, got, "List(%v, %v)", tt.args.ctx, tt.args.f)
+			assert.Equal(t, len(tt.want), total)
 		})
 	}
 }
@@ -306,7 +307,7 @@ func TestBookUseCase_Delete(t *testing.T) {
 			name: "simple",
 			fields: fields{
 				bookRepo: &repository.BookMock{
-					DeleteFunc: func(ctx context.Context, bookID uint64) error {
+					DeleteFunc: func(ctx context.Context, bookID uint64, ifMatch ...time.Time) error {
 						return nil
 					},
 				},
@@ -329,6 +330,158 @@ func TestBookUseCase_Delete(t *testing.T) {
 	}
 }
 
+func TestBookUseCase_Restore(t *testing.T) {
+	type fields struct {
+		bookRepo repository.Book
+	}
+	type args struct {
+		ctx    context.Context
+		bookID uint64
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    *book.Schema
+		wantErr error
+	}{
+		{
+			name: "simple",
+			fields: fields{
+				bookRepo: &repository.BookMock{
+					RestoreFunc: func(ctx context.Context, bookID uint64) error {
+						return nil
+					},
+					ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+						return &book.Schema{ID: bookID, Title: "restored"}, nil
+					},
+				},
+			},
+			args: args{
+				ctx:    context.Background(),
+				bookID: 1,
+			},
+			want:    &book.Schema{ID: 1, Title: "restored"},
+			wantErr: nil,
+		},
+		{
+			name: "not in trash",
+			fields: fields{
+				bookRepo: &repository.BookMock{
+					RestoreFunc: func(ctx context.Context, bookID uint64) error {
+						return message.ErrNoRecord
+					},
+				},
+			},
+			args: args{
+				ctx:    context.Background(),
+				bookID: 1,
+			},
+			want:    nil,
+			wantErr: message.ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &BookUseCase{
+				bookRepo: tt.fields.bookRepo,
+			}
+			got, err := u.Restore(tt.args.ctx, tt.args.bookID)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBookUseCase_Authors(t *testing.T) {
+	books := []*book.Schema{{ID: 1}, {ID: 2}}
+
+	u := &BookUseCase{
+		bookRepo: &repository.BookMock{
+			AuthorsFunc: func(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error) {
+				assert.Equal(t, []uint64{1, 2}, bookIDs)
+				return map[uint64][]*book.Author{
+					1: {{ID: 10, FirstName: "First"}},
+				}, nil
+			},
+		},
+	}
+
+	err := u.Authors(context.Background(), books)
+	assert.Nil(t, err)
+	assert.Equal(t, []*book.Author{{ID: 10, FirstName: "First"}}, books[0].Authors)
+	assert.NotNil(t, books[1].Authors)
+	assert.Len(t, books[1].Authors, 0)
+}
+
+func TestBookUseCase_SetAuthors(t *testing.T) {
+	type fields struct {
+		bookRepo repository.Book
+	}
+	type args struct {
+		ctx context.Context
+		req *book.AuthorsRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    *book.Schema
+		wantErr error
+	}{
+		{
+			name: "simple",
+			fields: fields{
+				bookRepo: &repository.BookMock{
+					SetAuthorsFunc: func(ctx context.Context, bookID uint64, authorIDs []uint64) error {
+						return nil
+					},
+					ReadFunc: func(ctx context.Context, bookID uint64) (*book.Schema, error) {
+						return &book.Schema{ID: bookID}, nil
+					},
+					AuthorsFunc: func(ctx context.Context, bookIDs ...uint64) (map[uint64][]*book.Author, error) {
+						return map[uint64][]*book.Author{
+							1: {{ID: 2}},
+						}, nil
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: &book.AuthorsRequest{BookID: 1, AuthorIDs: []uint64{2}},
+			},
+			want:    &book.Schema{ID: 1, Authors: []*book.Author{{ID: 2}}},
+			wantErr: nil,
+		},
+		{
+			name: "unknown author",
+			fields: fields{
+				bookRepo: &repository.BookMock{
+					SetAuthorsFunc: func(ctx context.Context, bookID uint64, authorIDs []uint64) error {
+						return message.ErrNoRecord
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: &book.AuthorsRequest{BookID: 1, AuthorIDs: []uint64{99}},
+			},
+			want:    nil,
+			wantErr: message.ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &BookUseCase{
+				bookRepo: tt.fields.bookRepo,
+			}
+			got, err := u.SetAuthors(tt.args.ctx, tt.args.req)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestBookUseCase_Search(t *testing.T) {
 	type fields struct {
 		bookRepo repository.Book
@@ -352,7 +505,7 @@ func TestBookUseCase_Search(t *testing.T) {
 			name: "simple",
 			fields: fields{
 				bookRepo: &repository.BookMock{
-					SearchFunc: func(ctx context.Context, req *book.Filter) ([]*book.Schema, error) {
+					SearchFunc: func(ctx context.Context, req *book.Filter) ([]*book.Schema, int, error) {
 						return []*book.Schema{
 							{
 								ID:            1,
@@ -361,7 +514,7 @@ func TestBookUseCase_Search(t *testing.T) {
 								ImageURL:      "https://example.com/image1.png",
 								Description:   "description",
 							},
-						}, nil
+						}, 1, nil
 					},
 				},
 			},
@@ -376,8 +529,7 @@ func TestBookUseCase_Search(t *testing.T) {
 						Sort:          nil,
 						Search:        true,
 					},
-					Title:         "searched",
-					Description:   "",
+					Query:         "searched",
 					PublishedDate: "",
 				},
 			},
@@ -398,9 +550,10 @@ func TestBookUseCase_Search(t *testing.T) {
 			u := &BookUseCase{
 				bookRepo: tt.fields.bookRepo,
 			}
-			got, err := u.Search(tt.args.ctx, tt.args.req)
+			got, total, err := u.Search(tt.args.ctx, tt.args.req)
 			assert.Equal(t, tt.wantErr, err)
 			assert.Equalf(t, tt.want, got, "Search(%v, %v)", tt.args.ctx, tt.args.req)
+			assert.Equal(t, len(tt.want), total)
 		})
 	}
 }
@@ -4,23 +4,61 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gmhafiz/scs/v2"
+
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/respond"
 )
 
 const (
 	KeyID      key = "id"
 	KeySession key = "session"
+	KeyClient  key = "client"
+	// KeyScopes holds the permissions that a personal access token is limited
+	// to. It is only in the context of requests authenticated with one.
+	KeyScopes key = "scopes"
+)
+
+var (
+	ErrInvalidToken    = apperror.Unauthorized("the token is invalid or has expired")
+	ErrSessionRequired = apperror.Forbidden("log in to do this, a token is not enough")
 )
 
+// TokenAuthenticator finds the user of a personal access token, and the
+// permissions that the token is limited to. Unknown, revoked and expired
+// tokens are ErrInvalidToken.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (userID uint64, scopes []string, err error)
+}
+
+// Client is the device that a request comes from. LoadAndSave puts it into
+// the context, for the session store to record.
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
 // Authenticate simply checks is current user is logged in by checking token validity in
-// cookie
-func Authenticate(m *scs.SessionManager) func(http.Handler) http.Handler {
+// cookie, and that LoadAndSave found a user ID in the session. Sessions of logins that
+// still wait for a second factor have none.
+//
+// Requests with an Authorization: Bearer header are authenticated with a personal
+// access token instead, which puts its user ID into the context the same way a
+// session does, along with its scopes for Require.
+func Authenticate(m *scs.SessionManager, tokens TokenAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token, ok := bearer(r); ok {
+				authenticateToken(w, r, tokens, token, next)
+				return
+			}
+
 			ctx := r.Context()
 			token := m.Token(ctx)
 			if token == "" {
@@ -37,11 +75,71 @@ func Authenticate(m *scs.SessionManager) func(http.Handler) http.Handler {
 				return
 			}
 
+			if _, ok := ctx.Value(KeyID).(uint64); !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+func authenticateToken(w http.ResponseWriter, r *http.Request, tokens TokenAuthenticator, token string, next http.Handler) {
+	if tokens == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		respond.Fail(w, r, ErrInvalidToken)
+		return
+	}
+
+	ctx := r.Context()
+	userID, scopes, err := tokens.AuthenticateToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		respond.Fail(w, r, err)
+		return
+	}
+	if err != nil {
+		respond.Fail(w, r, err)
+		return
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	ctx = context.WithValue(ctx, KeyID, userID)
+	ctx = context.WithValue(ctx, KeyScopes, scopes)
+	// Audit ran before the user was known.
+	if ev, ok := ctx.Value(KeyAuditID).(Event); ok {
+		ev.ActorID = userID
+		ctx = context.WithValue(ctx, KeyAuditID, ev)
+	}
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// bearer is the token of the Authorization header, if it has one.
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// RequireSession turns away requests that are authenticated with a personal
+// access token, for endpoints that manage the account itself, such as its
+// tokens. Otherwise, a token could make another one with more scopes.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(KeyScopes).([]string); ok {
+			respond.Fail(w, r, ErrSessionRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // LoadAndSave is a custom middleware adapted from scs library that saves logged in user ID
 // into request context. To access user ID:
 //
@@ -58,13 +156,20 @@ func LoadAndSave(s *scs.SessionManager) func(http.Handler) http.Handler {
 				token = cookie.Value
 			}
 
-			ctx, err := s.Load(r.Context(), token)
+			ctx := context.WithValue(r.Context(), KeyClient, Client{UserAgent: r.UserAgent(), IPAddress: ClientIP(r)})
+			ctx, err = s.Load(ctx, token)
 			if err != nil {
 				s.ErrorFunc(w, r, err)
 				return
 			}
 
-			sr := r.WithContext(ctx)
+			var userID any
+			userID, ok := s.Get(ctx, string(KeyID)).(uint64)
+			if !ok {
+				userID = nil
+			}
+
+			sr := r.WithContext(context.WithValue(ctx, KeyID, userID))
 			bw := &bufferedResponseWriter{ResponseWriter: w}
 			next.ServeHTTP(bw, sr)
 
@@ -72,8 +177,9 @@ func LoadAndSave(s *scs.SessionManager) func(http.Handler) http.Handler {
 				_ = sr.MultipartForm.RemoveAll()
 			}
 
-			var userID any
-			userID, ok := s.Get(ctx, string(KeyID)).(uint64)
+			// The handler may have logged the user in or out, and the store
+			// saves the user ID of the session from the context.
+			userID, ok = s.Get(ctx, string(KeyID)).(uint64)
 			if !ok {
 				userID = nil
 			}
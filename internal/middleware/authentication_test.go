@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenAuthenticatorFunc func(ctx context.Context, token string) (uint64, []string, error)
+
+func (f tokenAuthenticatorFunc) AuthenticateToken(ctx context.Context, token string) (uint64, []string, error) {
+	return f(ctx, token)
+}
+
+func TestAuthenticate_Bearer(t *testing.T) {
+	tokens := tokenAuthenticatorFunc(func(ctx context.Context, token string) (uint64, []string, error) {
+		switch token {
+		case "scoped":
+			return 2, []string{"books:write"}, nil
+		case "unscoped":
+			return 3, nil, nil
+		case "broken":
+			return 0, nil, errors.New("lower layer error")
+		default:
+			return 0, nil, ErrInvalidToken
+		}
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		tokens        TokenAuthenticator
+		status        int
+		userID        uint64
+		scopes        []string
+	}{
+		{name: "scoped", authorization: "Bearer scoped", tokens: tokens, status: http.StatusOK, userID: 2, scopes: []string{"books:write"}},
+		{name: "unscoped", authorization: "bearer unscoped", tokens: tokens, status: http.StatusOK, userID: 3, scopes: []string{}},
+		{name: "invalid", authorization: "Bearer guessed", tokens: tokens, status: http.StatusUnauthorized},
+		{name: "lower layer error", authorization: "Bearer broken", tokens: tokens, status: http.StatusInternalServerError},
+		{name: "no tokens", authorization: "Bearer scoped", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				userID uint64
+				scopes []string
+				actor  uint64
+			)
+			handler := Authenticate(nil, tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = r.Context().Value(KeyID).(uint64)
+				scopes, _ = r.Context().Value(KeyScopes).([]string)
+				actor = r.Context().Value(KeyAuditID).(Event).ActorID
+				w.WriteHeader(http.StatusOK)
+			}))
+			handler = Audit(handler)
+
+			rr := httptest.NewRequest(http.MethodGet, "/api/v1/restricted/me", nil)
+			rr.Header.Set("Authorization", tt.authorization)
+			ww := httptest.NewRecorder()
+
+			handler.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+			assert.Equal(t, tt.userID, userID)
+			assert.Equal(t, tt.scopes, scopes)
+			assert.Equal(t, tt.userID, actor)
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer error="invalid_token"`, ww.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	handler := RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRequest(http.MethodGet, "/api/v1/restricted/tokens", nil)
+	ww := httptest.NewRecorder()
+	handler.ServeHTTP(ww, rr.WithContext(context.WithValue(rr.Context(), KeyID, uint64(2))))
+	assert.Equal(t, http.StatusOK, ww.Code)
+
+	ctx := context.WithValue(rr.Context(), KeyScopes, []string{})
+	ww = httptest.NewRecorder()
+	handler.ServeHTTP(ww, rr.WithContext(context.WithValue(ctx, KeyID, uint64(2))))
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+}
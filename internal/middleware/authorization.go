@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"micro/internal/utility/apperror"
+	"micro/internal/utility/respond"
+)
+
+const (
+	KeyAuthorizer key = "authorizer"
+)
+
+var (
+	ErrLoginRequired    = apperror.Unauthorized("you need to be logged in")
+	ErrPermissionDenied = apperror.Forbidden("you do not have permission to do this")
+)
+
+// Authorizer tells whether a user is granted a permission by any of their
+// roles.
+type Authorizer interface {
+	HasPermission(ctx context.Context, userID uint64, permission string) (bool, error)
+}
+
+// Authorize makes the Authorizer available to Require further down the chain.
+// It is mounted after LoadAndSave.
+func Authorize(a Authorizer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), KeyAuthorizer, a)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Require only lets logged-in users that hold the permission through. Guests
+// get a 401 and everyone else a 403. An empty permission only requires a
+// login. Requests authenticated with a personal access token also need the
+// permission to be one of its scopes.
+func Require(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			userID, ok := ctx.Value(KeyID).(uint64)
+			if !ok {
+				respond.Fail(w, r, ErrLoginRequired)
+				return
+			}
+
+			if permission != "" {
+				if scopes, ok := ctx.Value(KeyScopes).([]string); ok && !slices.Contains(scopes, permission) {
+					respond.Fail(w, r, ErrPermissionDenied)
+					return
+				}
+
+				a, ok := ctx.Value(KeyAuthorizer).(Authorizer)
+				if !ok {
+					respond.Fail(w, r, ErrPermissionDenied)
+					return
+				}
+
+				allowed, err := a.HasPermission(ctx, userID, permission)
+				if err != nil {
+					respond.Fail(w, r, err)
+					return
+				}
+				if !allowed {
+					respond.Fail(w, r, ErrPermissionDenied)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
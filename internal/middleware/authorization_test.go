@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type authorizerFunc func(ctx context.Context, userID uint64, permission string) (bool, error)
+
+func (f authorizerFunc) HasPermission(ctx context.Context, userID uint64, permission string) (bool, error) {
+	return f(ctx, userID, permission)
+}
+
+func TestRequire(t *testing.T) {
+	authorizer := authorizerFunc(func(ctx context.Context, userID uint64, permission string) (bool, error) {
+		assert.Equal(t, "books:write", permission)
+		switch userID {
+		case 1:
+			return true, nil
+		case 2:
+			return false, nil
+		default:
+			return false, errors.New("lower layer error")
+		}
+	})
+
+	tests := []struct {
+		name       string
+		userID     any
+		permission string
+		scopes     []string
+		authorizer Authorizer
+		status     int
+	}{
+		{name: "granted", userID: uint64(1), permission: "books:write", authorizer: authorizer, status: http.StatusOK},
+		{name: "not granted", userID: uint64(2), permission: "books:write", authorizer: authorizer, status: http.StatusForbidden},
+		{name: "guest", userID: nil, permission: "books:write", authorizer: authorizer, status: http.StatusUnauthorized},
+		{name: "lower layer error", userID: uint64(3), permission: "books:write", authorizer: authorizer, status: http.StatusInternalServerError},
+		{name: "no authorizer", userID: uint64(1), permission: "books:write", status: http.StatusForbidden},
+		{name: "login only", userID: uint64(2), status: http.StatusOK},
+		{name: "token in scope", userID: uint64(1), permission: "books:write", scopes: []string{"books:write"}, authorizer: authorizer, status: http.StatusOK},
+		{name: "token out of scope", userID: uint64(1), permission: "books:write", scopes: []string{"authors:write"}, authorizer: authorizer, status: http.StatusForbidden},
+		{name: "token in scope, not granted", userID: uint64(2), permission: "books:write", scopes: []string{"books:write"}, authorizer: authorizer, status: http.StatusForbidden},
+		{name: "token login only", userID: uint64(2), scopes: []string{}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler = Require(tt.permission)(handler)
+			if tt.authorizer != nil {
+				handler = Authorize(tt.authorizer)(handler)
+			}
+
+			rr := httptest.NewRequest(http.MethodPost, "/api/v1/book", nil)
+			rr = rr.WithContext(context.WithValue(rr.Context(), KeyID, tt.userID))
+			if tt.scopes != nil {
+				rr = rr.WithContext(context.WithValue(rr.Context(), KeyScopes, tt.scopes))
+			}
+			ww := httptest.NewRecorder()
+
+			handler.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+		})
+	}
+}
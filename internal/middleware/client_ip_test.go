@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:1::/48"),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "192.0.2.1:51234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:         "untrusted connection cannot forward",
+			remoteAddr:   "192.0.2.1:51234",
+			forwardedFor: []string{"198.51.100.7"},
+			want:         "192.0.2.1",
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: []string{"198.51.100.7"},
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "spoofed addresses left of the client are ignored",
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: []string{"203.0.113.9, 198.51.100.7, 10.0.0.3"},
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "repeated header",
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: []string{"203.0.113.9", "198.51.100.7"},
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "garbage stops at the last trusted proxy",
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: []string{"198.51.100.7, unknown, 10.0.0.3"},
+			want:         "10.0.0.3",
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "10.0.0.2:443",
+			want:       "10.0.0.2",
+		},
+		{
+			name:         "ipv6",
+			remoteAddr:   "[2001:db8:1::2]:443",
+			forwardedFor: []string{"2001:db8:2::7"},
+			want:         "2001:db8:2::7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			rr := httptest.NewRequest(http.MethodGet, "/", nil)
+			rr.RemoteAddr = test.remoteAddr
+			rr.Header.Set("X-Real-Ip", "203.0.113.1")
+			for _, value := range test.forwardedFor {
+				rr.Header.Add("X-Forwarded-For", value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), rr)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	rr := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr.RemoteAddr = "192.0.2.1:51234"
+	rr.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	assert.Equal(t, "192.0.2.1", ClientIP(rr))
+}
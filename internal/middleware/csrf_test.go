@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type csrfVerifierFunc func(ctx context.Context, token string) (bool, error)
+
+func (f csrfVerifierFunc) Verify(ctx context.Context, token string) (bool, error) {
+	return f(ctx, token)
+}
+
+func TestCSRF(t *testing.T) {
+	verifier := csrfVerifierFunc(func(ctx context.Context, token string) (bool, error) {
+		switch token {
+		case "valid":
+			return true, nil
+		case "broken":
+			return false, errors.New("lower layer error")
+		default:
+			return false, nil
+		}
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		userID any
+		header map[string]string
+		body   string
+		status int
+	}{
+		{name: "safe method", method: http.MethodGet, userID: uint64(1), status: http.StatusOK},
+		{name: "header", method: http.MethodPost, userID: uint64(1), header: map[string]string{CSRFHeader: "valid"}, status: http.StatusOK},
+		{name: "form field", method: http.MethodPost, userID: uint64(1), header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, body: "csrf_token=valid", status: http.StatusOK},
+		{name: "missing", method: http.MethodDelete, userID: uint64(1), status: http.StatusForbidden},
+		{name: "invalid", method: http.MethodPut, userID: uint64(1), header: map[string]string{CSRFHeader: "guessed"}, status: http.StatusForbidden},
+		{name: "field of a multipart body", method: http.MethodPost, userID: uint64(1), header: map[string]string{"Content-Type": "multipart/form-data; boundary=x"}, body: "--x\r\nContent-Disposition: form-data; name=\"csrf_token\"\r\n\r\nvalid\r\n--x--\r\n", status: http.StatusForbidden},
+		{name: "lower layer error", method: http.MethodPatch, userID: uint64(1), header: map[string]string{CSRFHeader: "broken"}, status: http.StatusInternalServerError},
+		{name: "bearer token", method: http.MethodPost, userID: uint64(1), header: map[string]string{"Authorization": "Bearer mt_k3mvq8tz_secret"}, status: http.StatusOK},
+		{name: "guest", method: http.MethodPost, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CSRF(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rr := httptest.NewRequest(tt.method, "/api/v1/book", strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				rr.Header.Set(k, v)
+			}
+			rr = rr.WithContext(context.WithValue(rr.Context(), KeyID, tt.userID))
+			ww := httptest.NewRecorder()
+
+			handler.ServeHTTP(ww, rr)
+
+			assert.Equal(t, tt.status, ww.Code)
+		})
+	}
+}
@@ -1,22 +1,32 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"io/fs"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 
+	"micro/internal/domain/audit"
 	"micro/internal/domain/authentication"
 	authorHandler "micro/internal/domain/author/handler"
 	authorRepo "micro/internal/domain/author/repository"
 	authorUseCase "micro/internal/domain/author/usecase"
+	"micro/internal/domain/authorization"
 	bookHandler "micro/internal/domain/book/handler"
 	bookRepo "micro/internal/domain/book/repository"
 	bookUseCase "micro/internal/domain/book/usecase"
 	"micro/internal/domain/health"
 	"micro/internal/middleware"
 	"micro/internal/utility/respond"
+	"micro/third_party/blobstore"
+	"micro/third_party/elasticsearch"
+	"micro/third_party/mailer"
+	"micro/third_party/oauth"
 )
 
 func (s *Server) InitDomains() {
@@ -26,6 +36,8 @@ func (s *Server) InitDomains() {
 	s.initAuthor()
 	s.initHealth()
 	s.initBook()
+	s.initAudit()
+	s.initAuthorization()
 }
 
 func (s *Server) initVersion() {
@@ -65,16 +77,50 @@ func (s *Server) initSwagger() {
 }
 
 func (s *Server) initBook() {
-	newBookRepo := bookRepo.New(s.sqlx)
-	newBookUseCase := bookUseCase.New(newBookRepo)
-	bookHandler.RegisterHTTPEndPoints(s.router, s.validator, newBookUseCase)
+	newBookRepo := bookRepo.NewAudit(bookRepo.New(s.sqlx), audit.NewRepo(s.sqlx))
+	newBookUseCase := bookUseCase.New(newBookRepo, s.newBlobStore())
+	bookHandler.RegisterHTTPEndPoints(s.router, s.validator, newBookUseCase, s.requireWrite(s.cfg.Permission.BookWrite))
+}
+
+// newBlobStore keeps uploaded files. Files of the local driver are served
+// under the path of its base URL, without directory listings.
+func (s *Server) newBlobStore() blobstore.BlobStore {
+	cfg := s.cfg.Storage
+	store := blobstore.New(cfg)
+	if cfg.Driver != "local" {
+		return store
+	}
+
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	prefix := strings.TrimSuffix(u.Path, "/")
+	if prefix == "" {
+		log.Fatalln("STORAGE_BASE_URL needs a path to serve local files under")
+	}
+
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.Dir(cfg.Dir)))
+	s.router.Handle(prefix+"/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	}))
+
+	return store
 }
 
 func (s *Server) initAuthor() {
 	newAuthorRepo := authorRepo.New(s.ent)
 	newLRUCache := authorRepo.NewLRUCache(newAuthorRepo)
 	newRedisCache := authorRepo.NewRedisCache(newAuthorRepo, s.cache)
-	newAuthorSearchRepo := authorRepo.NewSearch(s.ent)
+
+	var newAuthorSearchRepo authorRepo.Searcher = authorRepo.NewSearch(s.ent)
+	if s.cfg.Elasticsearch.Enable {
+		newAuthorSearchRepo = s.newAuthorElasticsearch()
+	}
 
 	newAuthorUseCase := authorUseCase.New(
 		s.cfg.Cache,
@@ -82,11 +128,95 @@ func (s *Server) initAuthor() {
 		newAuthorSearchRepo,
 		newLRUCache,
 		newRedisCache,
+		authorRepo.NewExport(s.db),
+	)
+	authorHandler.RegisterHTTPEndPoints(s.router, s.validator, newAuthorUseCase, s.requireWrite(s.cfg.Permission.AuthorWrite))
+}
+
+// newAuthorElasticsearch searches authors in Elasticsearch, and keeps the
+// index in sync with author and book writes made through ent.
+func (s *Server) newAuthorElasticsearch() authorRepo.Searcher {
+	searcher := authorRepo.NewElasticsearch(
+		elasticsearch.New(s.cfg.Elasticsearch),
+		s.cfg.Elasticsearch.Index,
 	)
-	authorHandler.RegisterHTTPEndPoints(s.router, s.validator, newAuthorUseCase)
+	if err := searcher.CreateIndex(context.Background()); err != nil {
+		log.Fatalln(err)
+	}
+
+	s.ent.Author.Use(searcher.AuthorHook())
+	s.ent.Book.Use(searcher.BookHook())
+
+	return searcher
+}
+
+func (s *Server) initAudit() {
+	audit.RegisterHTTPEndPoints(s.router, s.require(s.cfg.Permission.AuditRead), audit.NewRepo(s.sqlx))
+}
+
+func (s *Server) initAuthorization() {
+	repo := authorization.NewRepo(s.ent)
+	authorization.RegisterHTTPEndPoints(s.router, s.require(s.cfg.Permission.RoleManage), repo)
+}
+
+// require guards endpoints outside the authentication domain with a
+// permission. Requests are authenticated with the session cookie or with a
+// personal access token first, as they are under /api/v1/restricted.
+func (s *Server) require(permission string) func(http.Handler) http.Handler {
+	authenticate := s.authenticate()
+	require := middleware.Require(permission)
+
+	return func(next http.Handler) http.Handler {
+		return authenticate(require(next))
+	}
+}
+
+// requireWrite is require for endpoints that change data, which also need a
+// csrf token when they are called with the session cookie.
+func (s *Server) requireWrite(permission string) func(http.Handler) http.Handler {
+	authenticate := s.authenticate()
+	csrf := middleware.CSRF(s.csrf)
+	require := middleware.Require(permission)
+
+	return func(next http.Handler) http.Handler {
+		return authenticate(csrf(require(next)))
+	}
+}
+
+func (s *Server) authenticate() func(http.Handler) http.Handler {
+	return middleware.Authenticate(s.session, authentication.NewPersonalTokens(authentication.NewRepo(s.ent, s.db, s.session)))
 }
 
 func (s *Server) initAuthentication() {
+	// Emails are sent in the background, so that how long a password reset
+	// takes to answer does not tell whether the address is registered.
+	s.mailer = mailer.NewBackground(mailer.New(s.cfg.Mail))
+
 	repo := authentication.NewRepo(s.ent, s.db, s.session)
-	authentication.RegisterHTTPEndPoints(s.router, s.session, repo)
+	authentication.RegisterHTTPEndPoints(
+		s.router,
+		s.session,
+		s.validator,
+		repo,
+		s.mailer,
+		s.cfg.Account,
+		authentication.NewLockout(s.loginAttempts(), audit.NewRepo(s.sqlx), s.cfg.Lockout),
+		oauth.New(s.cfg.OAuth),
+		s.csrf,
+		middleware.Require(s.cfg.Permission.SessionManage),
+		middleware.Require(s.cfg.Permission.AccountUnlock),
+	)
+}
+
+// loginAttempts are counted in Redis when caching is enabled, so that they
+// are shared by every instance without a write to the database.
+func (s *Server) loginAttempts() authentication.Attempts {
+	switch {
+	case s.cluster != nil:
+		return authentication.NewRedisAttempts(s.cluster)
+	case s.cache != nil:
+		return authentication.NewRedisAttempts(s.cache)
+	default:
+		return authentication.NewPostgresAttempts(s.db)
+	}
 }
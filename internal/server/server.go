@@ -3,7 +3,6 @@ package server
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
@@ -18,7 +17,6 @@ import (
 	"syscall"
 	"time"
 
-	"entgo.io/ent"
 	"entgo.io/ent/dialect"
 	entsql "entgo.io/ent/dialect/sql"
 	"github.com/gmhafiz/scs/v2"
@@ -42,8 +40,12 @@ import (
 	"micro/third_party/otlp"
 	//_ "micro/docs"
 	"micro/ent/gen"
+	"micro/internal/domain/audit"
+	"micro/internal/domain/authorization"
 	"micro/internal/middleware"
+	"micro/internal/utility/csrf"
 	db "micro/third_party/database"
+	"micro/third_party/mailer"
 	"micro/third_party/postgresstore"
 	redisLib "micro/third_party/redis"
 	"micro/third_party/validate"
@@ -62,6 +64,9 @@ type Server struct {
 
 	session       *scs.SessionManager
 	sessionCloser *postgresstore.PostgresStore
+	csrf          csrf.Store
+
+	mailer *mailer.Background
 
 	otlp *middleware.Config
 
@@ -218,6 +223,7 @@ func (s *Server) newAuthentication() {
 	s.sessionCloser = postgresstore.NewWithCleanupInterval(s.sqlx.DB, 30*time.Minute)
 
 	s.session = manager
+	s.csrf = csrf.New(s.cfg.CSRF.Mode, manager, s.sqlx.DB)
 }
 
 func (s *Server) newRouter() {
@@ -230,10 +236,12 @@ func (s *Server) setGlobalMiddleware() {
 		w.WriteHeader(http.StatusNotFound)
 		_, _ = w.Write([]byte(`{"message": "endpoint not found"}`))
 	})
+	s.router.Use(middleware.RealIP(s.cfg.Api.TrustedProxies))
 	s.router.Use(s.cors.Handler)
 	s.router.Use(middleware.Otlp(s.cfg.OpenTelemetry.Enable))
 	s.router.Use(middleware.Json)
 	s.router.Use(middleware.LoadAndSave(s.session))
+	s.router.Use(middleware.Authorize(authorization.NewRepo(s.ent)))
 	s.router.Use(middleware.Audit)
 	if s.cfg.Api.RequestLog {
 		s.router.Use(chiMiddleware.Logger)
@@ -358,25 +366,7 @@ func (s *Server) newEnt(dsn string) {
 	drv := entsql.OpenDB(dialect.Postgres, otelDB)
 	client := gen.NewClient(gen.Driver(drv))
 
-	client.Use(func(next ent.Mutator) ent.Mutator {
-		return ent.MutateFunc(func(ctx context.Context, mutation ent.Mutation) (ent.Value, error) {
-			meta, ok := ctx.Value(middleware.KeyAuditID).(middleware.Event)
-			if !ok {
-				return next.Mutate(ctx, mutation)
-			}
-
-			val, err := next.Mutate(ctx, mutation)
-
-			meta.Table = mutation.Type()
-			meta.Action = middleware.Action(mutation.Op().String())
-
-			newValues, _ := json.Marshal(val)
-			meta.NewValues = string(newValues)
-			log.Println(meta)
-
-			return val, err
-		})
-	})
+	client.Use(audit.Hook(audit.NewRepo(s.sqlx)))
 
 	s.ent = client
 }
@@ -477,6 +467,11 @@ func gracefulShutdown(ctx context.Context, s *Server) error {
 	if err != nil {
 		log.Println(err)
 	}
+	if s.mailer != nil {
+		if err := s.mailer.Close(ctx); err != nil {
+			log.Println("emails not sent before shutdown:", err)
+		}
+	}
 	s.closeResources(ctx)
 
 	return nil
@@ -0,0 +1,101 @@
+// Package apperror holds the typed errors that the domains return to tell a
+// transport what went wrong without knowing about HTTP. respond.Problem maps
+// them to RFC 7807 problem details.
+package apperror
+
+import "errors"
+
+type Kind uint8
+
+const (
+	KindNotFound Kind = iota + 1
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindPreconditionFailed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation failed"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindForbidden:
+		return "forbidden"
+	case KindPreconditionFailed:
+		return "precondition failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a domain error of a given Kind. Detail is safe to show to clients.
+// Errors lists every individual problem of a Validation error.
+type Error struct {
+	Kind   Kind
+	Detail string
+	Errors []FieldError
+}
+
+// FieldError is a rule that a field of a request fails. Field is the JSON name
+// of the field, and Param is the parameter of the rule, if any.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Kind.String()
+}
+
+func NotFound(detail string) *Error {
+	return &Error{Kind: KindNotFound, Detail: detail}
+}
+
+func Conflict(detail string) *Error {
+	return &Error{Kind: KindConflict, Detail: detail}
+}
+
+func Validation(detail string, errs ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Detail: detail, Errors: errs}
+}
+
+func Unauthorized(detail string) *Error {
+	return &Error{Kind: KindUnauthorized, Detail: detail}
+}
+
+func Forbidden(detail string) *Error {
+	return &Error{Kind: KindForbidden, Detail: detail}
+}
+
+// PreconditionFailed is returned when a record has changed since the version
+// that a conditional request is based on.
+func PreconditionFailed(detail string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Detail: detail}
+}
+
+// As finds the first Error in err's chain.
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// Is reports whether any error in err's chain is an Error of the given kind.
+func Is(err error, kind Kind) bool {
+	e, ok := As(err)
+	return ok && e.Kind == kind
+}
@@ -30,12 +30,16 @@ func ValidToken(ctx context.Context, db *sql.DB, token string) bool {
 	return exists
 }
 
+// ErrTokenNotFound is returned by ValidAndDeleteToken when there is no valid
+// token to delete.
+var ErrTokenNotFound = errors.New("no csrf token was found")
+
 // ValidAndDeleteToken deletes the token from the store if and only if token is valid.
 // Useful for one-time token use.
 func ValidAndDeleteToken(ctx context.Context, db *sql.DB, token string) error {
 	hash, err := sum(token)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	res, err := db.ExecContext(ctx, `
@@ -47,11 +51,11 @@ func ValidAndDeleteToken(ctx context.Context, db *sql.DB, token string) error {
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return errors.New("token not found")
+		return err
 	}
 
 	if rowsAffected != 1 {
-		return errors.New("no csrf token was found")
+		return ErrTokenNotFound
 	}
 	return nil
 }
@@ -0,0 +1,144 @@
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/gmhafiz/scs/v2"
+
+	"micro/third_party/postgresstore"
+)
+
+const (
+	// ModeSession keeps one token for the whole session.
+	ModeSession = "session"
+	// ModeOnce makes a new token for every request that needs one.
+	ModeOnce = "once"
+
+	// SessionKey is where ModeSession keeps the token in the session.
+	SessionKey = "csrf_token"
+)
+
+var ErrNoSession = errors.New("csrf: there is no session to issue a token for")
+
+// Store issues tokens for the session of the request, and verifies them.
+type Store interface {
+	Issue(ctx context.Context) (string, error)
+	Verify(ctx context.Context, token string) (bool, error)
+}
+
+// New makes the store of the mode. One-time tokens are kept in the sessions
+// table.
+func New(mode string, session *scs.SessionManager, db *sql.DB) Store {
+	if mode == ModeOnce {
+		return &onceStore{session: session, db: db}
+	}
+	return &sessionStore{session: session}
+}
+
+type sessionStore struct {
+	session *scs.SessionManager
+}
+
+// Issue is the token of the session, which is made the first time.
+func (s *sessionStore) Issue(ctx context.Context) (string, error) {
+	if token := s.session.GetString(ctx, SessionKey); token != "" {
+		return token, nil
+	}
+
+	token, err := generate()
+	if err != nil {
+		return "", err
+	}
+	s.session.Put(ctx, SessionKey, token)
+
+	return token, nil
+}
+
+func (s *sessionStore) Verify(ctx context.Context, token string) (bool, error) {
+	want := s.session.GetString(ctx, SessionKey)
+	return want != "" && subtle.ConstantTimeCompare([]byte(want), []byte(token)) == 1, nil
+}
+
+// onceStore keeps each token as a row of the sessions table, as ValidToken
+// expects, with the ID of the session that it was issued to as its data.
+// Otherwise, a token issued to one user would be accepted from another.
+type onceStore struct {
+	session *scs.SessionManager
+	db      *sql.DB
+}
+
+func (s *onceStore) Issue(ctx context.Context) (string, error) {
+	sessionToken := s.session.Token(ctx)
+	if sessionToken == "" {
+		return "", ErrNoSession
+	}
+
+	token, err := generate()
+	if err != nil {
+		return "", err
+	}
+	hash, err := sum(token)
+	if err != nil {
+		return "", err
+	}
+
+	// Not through the session store, which would list the row among the
+	// sessions of the user.
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3)
+	`, hash, []byte(postgresstore.ID(sessionToken)), time.Now().Add(s.session.Lifetime))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Verify deletes the token with ValidAndDeleteToken, if it was issued to the
+// session of the request. Tokens of other sessions are left alone.
+func (s *onceStore) Verify(ctx context.Context, token string) (bool, error) {
+	sessionToken := s.session.Token(ctx)
+	if sessionToken == "" || token == "" {
+		return false, nil
+	}
+
+	hash, err := sum(token)
+	if err != nil {
+		return false, err
+	}
+
+	var issuedTo []byte
+	err = s.db.QueryRowContext(ctx, `SELECT data FROM sessions WHERE token = $1`, hash).Scan(&issuedTo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if subtle.ConstantTimeCompare(issuedTo, []byte(postgresstore.ID(sessionToken))) != 1 {
+		return false, nil
+	}
+
+	err = ValidAndDeleteToken(ctx, s.db, token)
+	if errors.Is(err, ErrTokenNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,46 @@
+package csrf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gmhafiz/scs/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore(t *testing.T) {
+	session := scs.New()
+	store := New(ModeSession, session, nil)
+
+	ctx, err := session.Load(context.Background(), "")
+	assert.Nil(t, err)
+
+	ok, err := store.Verify(ctx, "")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	token, err := store.Issue(ctx)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+
+	// The same token for the whole session, and it can be used again.
+	again, err := store.Issue(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, token, again)
+	for range 2 {
+		ok, err = store.Verify(ctx, token)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	ok, err = store.Verify(ctx, token+"x")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Another session has another token.
+	other, err := session.Load(context.Background(), "")
+	assert.Nil(t, err)
+	ok, err = store.Verify(other, token)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,82 @@
+// Package etag derives entity tags from the updated_at column of a record and
+// evaluates the If-Match and If-None-Match request headers against them.
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// New returns the strong entity tag of a record that was last updated at
+// updatedAt. Postgres keeps timestamps to the microsecond, so that is the
+// precision of the tag.
+func New(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+}
+
+// Parse returns the updated_at time that a tag made by New is derived from.
+func Parse(tag string) (time.Time, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return time.Time{}, false
+	}
+
+	micro, err := strconv.ParseInt(tag[1:len(tag)-1], 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMicro(micro).UTC(), true
+}
+
+// IfMatch returns the versions listed in the If-Match header of r. These are
+// the updated_at times that a record must still have for an update or delete
+// to go ahead. The versions are nil when the request is not conditional,
+// either because the header is absent or is "*".
+//
+// ok is false when the header lists no tag that could match any record. Weak
+// tags never match because If-Match uses the strong comparison.
+func IfMatch(r *http.Request) (versions []time.Time, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	for _, tag := range split(header) {
+		if v, ok := Parse(tag); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions, len(versions) > 0
+}
+
+// NotModified reports whether the If-None-Match header of r matches tag, in
+// which case a GET is answered with 304 Not Modified. It uses the weak
+// comparison.
+func NotModified(r *http.Request, tag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, t := range split(header) {
+		if strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func split(header string) []string {
+	tags := strings.Split(header, ",")
+	for i := range tags {
+		tags[i] = strings.TrimSpace(tags[i])
+	}
+	return tags
+}
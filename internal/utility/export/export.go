@@ -0,0 +1,136 @@
+// Package export streams records to a client as CSV or newline delimited JSON
+// while they are being read, so that a whole table is never held in memory.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"micro/internal/utility/apperror"
+)
+
+// Formats of an export, chosen by the format query parameter.
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// flushEvery is how many records are buffered before they are sent.
+const flushEvery = 100
+
+var ErrFormat = apperror.Validation("format must be csv or ndjson")
+
+// Record is a row of an export. It is written as JSON by its own fields, and
+// as CSV by the values that CSV returns in the order of the header.
+type Record interface {
+	CSV() []string
+}
+
+// Writer writes records to an http.ResponseWriter. Nothing is sent until the
+// first record is written, so that an error before then can still be answered
+// with a problem.
+type Writer struct {
+	w       http.ResponseWriter
+	header  []string
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+	written int
+}
+
+// NewWriter starts an export named name, such as books, in format. header
+// names the CSV columns. An empty format is CSV.
+func NewWriter(w http.ResponseWriter, format, name string, header []string) (*Writer, error) {
+	if format == "" {
+		format = CSV
+	}
+
+	e := &Writer{w: w, header: header, buf: bufio.NewWriter(w)}
+	switch format {
+	case CSV:
+		e.csv = csv.NewWriter(e.buf)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case NDJSON:
+		e.json = json.NewEncoder(e.buf)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		return nil, ErrFormat
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+
+	return e, nil
+}
+
+// Started reports whether the response has begun. After that, its status can
+// no longer change.
+func (e *Writer) Started() bool {
+	return e.started
+}
+
+// Write adds a record to the export.
+func (e *Writer) Write(r Record) error {
+	if !e.started {
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(r.CSV())
+	} else {
+		err = e.json.Encode(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.written++
+	if e.written%flushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// Close sends the records that are still buffered. An export without records
+// is a CSV header alone, or an empty body.
+func (e *Writer) Close() error {
+	if !e.started {
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *Writer) writeHeader() error {
+	e.started = true
+	e.w.WriteHeader(http.StatusOK)
+	if e.csv != nil {
+		return e.csv.Write(e.header)
+	}
+	return nil
+}
+
+func (e *Writer) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := e.buf.Flush(); err != nil {
+		return err
+	}
+
+	err := http.NewResponseController(e.w).Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
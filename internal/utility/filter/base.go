@@ -15,6 +15,7 @@ const (
 	queryParamOffset        = "offset"
 	queryParamDisablePaging = "disable_paging"
 	queryParamSort          = "sort"
+	queryParamCursor        = "cursor"
 )
 
 type Filter struct {
@@ -23,6 +24,11 @@ type Filter struct {
 	Limit         int
 	DisablePaging bool
 
+	// CursorPaging is set when the `cursor` query parameter is present, even
+	// if empty. An empty Cursor requests the first page.
+	CursorPaging bool
+	Cursor       string
+
 	Sort   map[string]string
 	Search bool
 }
@@ -63,6 +69,8 @@ func New(queries url.Values) *Filter {
 		Offset:        offset,
 		Limit:         limit,
 		DisablePaging: disablePaging,
+		CursorPaging:  queries.Has(queryParamCursor),
+		Cursor:        queries.Get(queryParamCursor),
 		Sort:          sortKey,
 	}
 }
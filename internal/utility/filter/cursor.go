@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"micro/internal/utility/apperror"
+)
+
+var ErrInvalidCursor = apperror.Validation("invalid cursor")
+
+// Cursor marks the last row of a page for keyset pagination. Rows are
+// ordered by (created_at, id) descending, so the next page starts strictly
+// after this pair. The encoded form is opaque to clients.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint64
+}
+
+func NewCursor(createdAt time.Time, id uint64) *Cursor {
+	return &Cursor{
+		CreatedAt: createdAt,
+		ID:        id,
+	}
+}
+
+// Encode returns the opaque string that is handed out as `next_cursor`.
+func (c *Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatUint(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode. An empty string means
+// the first page and returns a nil cursor.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	i, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return NewCursor(t, i), nil
+}
@@ -0,0 +1,92 @@
+// Package mergepatch applies JSON merge patches as defined by RFC 7396.
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// ContentType is the media type of a JSON merge patch document.
+const ContentType = "application/merge-patch+json"
+
+// ErrNotObject is returned for a patch that is not a JSON object. Such a patch
+// would replace the whole record rather than some of its fields.
+var ErrNotObject = errors.New("merge patch is not a JSON object")
+
+// Accepts reports whether the body of r can be read as a merge patch. Besides
+// ContentType, plain JSON is accepted as most clients send that by default.
+func Accepts(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentType || mediaType == "application/json"
+}
+
+// Apply merges patch into the JSON document doc and returns the result. Members
+// of patch replace those of doc, objects are merged recursively and a null
+// member removes that member from doc.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var p any
+	if err := decode(patch, &p); err != nil {
+		return nil, err
+	}
+	if _, ok := p.(map[string]any); !ok {
+		return nil, ErrNotObject
+	}
+
+	var target any
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// Members returns the names of the top level members of patch, which are the
+// fields that it changes.
+func Members(patch []byte) ([]string, error) {
+	var p map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = merge(t[name], value)
+	}
+
+	return t
+}
+
+// decode keeps numbers as written, so that large IDs are not rounded by a
+// float64.
+func decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -1,14 +1,25 @@
 package message
 
-import "errors"
+import (
+	"errors"
+
+	"micro/internal/utility/apperror"
+)
+
+// ValidationFailed is the detail of a request that fails struct validation.
+const ValidationFailed = "one or more fields are invalid"
 
 var (
-	ErrBadRequest    = errors.New("error bad request")
+	ErrBadRequest    = apperror.Validation("error bad request")
+	ErrInvalidJSON   = apperror.Validation("request body is not valid JSON")
+	ErrNotMergePatch = apperror.Validation("request body is not a JSON merge patch object")
+	ErrMediaType     = errors.New("content type must be application/merge-patch+json")
 	ErrInternalError = errors.New("error internal")
 
 	ErrFormingResponse = errors.New("error forming response")
 
-	ErrNoRecord = errors.New("no record found")
+	ErrNoRecord = apperror.NotFound("no record found")
+	ErrModified = apperror.PreconditionFailed("record has been modified since it was read")
 
 	ErrFetchingBook = errors.New("error fetching books")
 )
@@ -4,55 +4,127 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"micro/internal/utility/apperror"
 )
 
-func Errors(w http.ResponseWriter, statusCode int, errors []string) {
-	w.Header().Set("Content-Type", "application/problem+json")
-	w.WriteHeader(statusCode)
+// Problem is an RFC 7807 problem details object. Errors is an extension member
+// that lists every field that fails validation.
+type Problem struct {
+	Type     string                `json:"type"`
+	Title    string                `json:"title"`
+	Status   int                   `json:"status"`
+	Detail   string                `json:"detail,omitempty"`
+	Instance string                `json:"instance,omitempty"`
+	Errors   []apperror.FieldError `json:"errors,omitempty"`
+}
 
-	if errors == nil {
-		write(w, nil)
-		return
+// problemTypes points each status to its definition in RFC 9110. Statuses not
+// listed here use "about:blank", so their title is the status text.
+var problemTypes = map[int]string{
+	http.StatusBadRequest:          "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.1",
+	http.StatusUnauthorized:        "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.2",
+	http.StatusForbidden:           "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.4",
+	http.StatusNotFound:            "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.5",
+	http.StatusConflict:            "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.10",
+	http.StatusPreconditionFailed:  "https://www.rfc-editor.org/rfc/rfc9110#section-15.5.13",
+	http.StatusInternalServerError: "https://www.rfc-editor.org/rfc/rfc9110#section-15.6.1",
+}
+
+// StatusOf returns the HTTP status code an error maps to. Errors that are not
+// an apperror.Error are internal errors.
+func StatusOf(err error) int {
+	e, ok := apperror.As(err)
+	if !ok {
+		return http.StatusInternalServerError
 	}
 
-	p := map[string][]string{
-		"message": errors,
+	switch e.Kind {
+	case apperror.KindNotFound:
+		return http.StatusNotFound
+	case apperror.KindConflict:
+		return http.StatusConflict
+	case apperror.KindValidation:
+		return http.StatusBadRequest
+	case apperror.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperror.KindForbidden:
+		return http.StatusForbidden
+	case apperror.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
 	}
-	data, err := json.Marshal(p)
-	if err != nil {
-		log.Println(err)
+}
+
+// NewProblem builds the problem details of err for the request r. The detail
+// of an internal error is never exposed to the client.
+func NewProblem(r *http.Request, err error) *Problem {
+	status := StatusOf(err)
+
+	p := newProblem(status)
+	if r != nil {
+		p.Instance = r.URL.Path
 	}
 
-	if string(data) == "null" {
-		return
+	if e, ok := apperror.As(err); ok {
+		p.Detail = err.Error()
+		p.Errors = e.Errors
 	}
 
-	write(w, data)
+	return p
 }
 
-func Error(w http.ResponseWriter, statusCode int, message error) {
+func (p *Problem) write(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/problem+json")
-	w.WriteHeader(statusCode)
+	w.WriteHeader(p.Status)
 
-	var p map[string]string
-	if message == nil {
-		write(w, nil)
+	data, err := json.Marshal(p)
+	if err != nil {
+		log.Println(err)
 		return
 	}
 
-	p = map[string]string{
-		"message": message.Error(),
-	}
-	data, err := json.Marshal(p)
-	if err != nil {
+	write(w, data)
+}
+
+// Fail writes err as an RFC 7807 problem. The status code is chosen by the kind
+// of apperror.Error found in its chain, otherwise it is an internal error.
+func Fail(w http.ResponseWriter, r *http.Request, err error) {
+	if StatusOf(err) == http.StatusInternalServerError {
 		log.Println(err)
 	}
 
-	if string(data) == "null" {
-		return
+	NewProblem(r, err).write(w)
+}
+
+func Errors(w http.ResponseWriter, statusCode int, errors []apperror.FieldError) {
+	p := newProblem(statusCode)
+	p.Errors = errors
+
+	p.write(w)
+}
+
+func Error(w http.ResponseWriter, statusCode int, message error) {
+	p := newProblem(statusCode)
+	if message != nil {
+		p.Detail = message.Error()
 	}
 
-	write(w, data)
+	p.write(w)
+}
+
+func newProblem(status int) *Problem {
+	typ, ok := problemTypes[status]
+	if !ok {
+		typ = "about:blank"
+	}
+
+	return &Problem{
+		Type:   typ,
+		Title:  http.StatusText(status),
+		Status: status,
+	}
 }
 
 func write(w http.ResponseWriter, data []byte) {
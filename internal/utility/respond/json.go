@@ -14,8 +14,12 @@ type Standard struct {
 }
 
 type Meta struct {
-	Size  int `json:"size"`
-	Total int `json:"total"`
+	Size       int    `json:"size"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func Json(w http.ResponseWriter, statusCode int, payload interface{}) {
@@ -0,0 +1,96 @@
+package respond
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"micro/internal/utility/filter"
+)
+
+// Paginate builds the list metadata for the current request and sets an
+// RFC 8288 Link header pointing at the neighbouring pages. Offset paging
+// yields first, prev, next and last links. Keyset paging can only move
+// forward, so only next is given.
+func Paginate(w http.ResponseWriter, r *http.Request, f filter.Filter, size, total int, nextCursor string) Meta {
+	meta := Meta{
+		Size:  size,
+		Total: total,
+	}
+
+	if f.DisablePaging {
+		return meta
+	}
+
+	links := make([]string, 0, 4)
+
+	if f.CursorPaging {
+		meta.NextCursor = nextCursor
+		if nextCursor != "" {
+			meta.Next = pageURL(r, map[string]string{"cursor": nextCursor})
+			links = append(links, link(meta.Next, "next"))
+		}
+		setLinks(w, links)
+		return meta
+	}
+
+	meta.Page = f.Page
+
+	limit := f.Limit
+	if limit <= 0 {
+		setLinks(w, links)
+		return meta
+	}
+	lastPage := (total + limit - 1) / limit
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links = append(links, link(pageURL(r, pageQuery(1)), "first"))
+	if f.Page > 1 {
+		meta.Prev = pageURL(r, pageQuery(min(f.Page-1, lastPage)))
+		links = append(links, link(meta.Prev, "prev"))
+	}
+	if f.Offset+size < total {
+		meta.Next = pageURL(r, pageQuery(f.Page+1))
+		links = append(links, link(meta.Next, "next"))
+	}
+	links = append(links, link(pageURL(r, pageQuery(lastPage)), "last"))
+
+	setLinks(w, links)
+
+	return meta
+}
+
+func pageQuery(page int) map[string]string {
+	return map[string]string{"page": strconv.Itoa(page)}
+}
+
+// pageURL copies the request URL, replacing the given query parameters.
+// An explicit offset is dropped so that it does not override page.
+func pageURL(r *http.Request, set map[string]string) string {
+	q := url.Values{}
+	for k, v := range r.URL.Query() {
+		q[k] = v
+	}
+	q.Del("offset")
+	for k, v := range set {
+		q.Set(k, v)
+	}
+
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return u.String()
+}
+
+func link(uri, rel string) string {
+	return fmt.Sprintf(`<%s>; rel="%s"`, uri, rel)
+}
+
+func setLinks(w http.ResponseWriter, links []string) {
+	if len(links) == 0 {
+		return
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
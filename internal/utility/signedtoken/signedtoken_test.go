@@ -0,0 +1,39 @@
+package signedtoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	s := New("secret")
+	s.now = func() time.Time { return now }
+
+	token := s.Sign("verify", 42, "email@example.com", time.Hour)
+
+	id, err := ID(token)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(42), id)
+
+	assert.Nil(t, s.Verify("verify", token, "email@example.com"))
+
+	assert.ErrorIs(t, s.Verify("reset", token, "email@example.com"), ErrInvalid)
+	assert.ErrorIs(t, s.Verify("verify", token, "other@example.com"), ErrInvalid)
+	assert.ErrorIs(t, New("other").Verify("verify", token, "email@example.com"), ErrInvalid)
+
+	forged := "43" + token[2:]
+	assert.ErrorIs(t, s.Verify("verify", forged, "email@example.com"), ErrInvalid)
+
+	now = now.Add(time.Hour)
+	assert.ErrorIs(t, s.Verify("verify", token, "email@example.com"), ErrExpired)
+}
+
+func TestID_Invalid(t *testing.T) {
+	for _, token := range []string{"", "abc", "x.1.mac", "-1.1.mac"} {
+		_, err := ID(token)
+		assert.ErrorIs(t, err, ErrInvalid, token)
+	}
+}
@@ -3,12 +3,94 @@ package validate
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+
+	"micro/internal/utility/apperror"
 )
 
-func Validate(v *validator.Validate, generic any) []string {
-	err := v.Struct(generic)
+// fallback is the locale of messages when none of the requested locales has
+// translations.
+var fallback = en.New()
+
+// translators holds the translators of each validator. Translations are kept in
+// the translator, so that validators do not conflict over them.
+var translators sync.Map
+
+func translator(v *validator.Validate) *ut.UniversalTranslator {
+	uni, _ := translators.LoadOrStore(v, ut.New(fallback, fallback))
+	return uni.(*ut.UniversalTranslator)
+}
+
+// AddLocale makes messages of v available in another locale. Its translations
+// are then added with RegisterTranslation.
+func AddLocale(v *validator.Validate, l locales.Translator) error {
+	return translator(v).AddTranslator(l, true)
+}
+
+// RegisterTranslations adds the English messages of the built-in rules to v.
+func RegisterTranslations(v *validator.Validate) error {
+	trans, _ := translator(v).GetTranslator(fallback.Locale())
+	return enTranslations.RegisterDefaultTranslations(v, trans)
+}
+
+// RegisterTranslation adds the message of a rule in the given locale. In text,
+// {0} is replaced by the field name and {1} by the parameter of the rule.
+func RegisterTranslation(v *validator.Validate, locale, rule, text string) error {
+	trans, found := translator(v).GetTranslator(locale)
+	if !found {
+		return fmt.Errorf("no translator is added for locale %s", locale)
+	}
+
+	return v.RegisterTranslation(rule, trans,
+		func(t ut.Translator) error {
+			return t.Add(rule, text, true)
+		},
+		func(t ut.Translator, fe validator.FieldError) string {
+			msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return msg
+		},
+	)
+}
+
+// Validate returns every field of generic that fails its rules. Messages are
+// in the first of the locales that has translations, or in English.
+func Validate(v *validator.Validate, generic any, langs ...string) []apperror.FieldError {
+	return fieldErrors(v, v.Struct(generic), langs)
+}
+
+// ValidateFields is Validate limited to the top level fields of generic whose
+// JSON names are in names. The other fields are not checked, as when a patch
+// leaves them as they are.
+func ValidateFields(v *validator.Validate, generic any, names []string, langs ...string) []apperror.FieldError {
+	typ := reflect.Indirect(reflect.ValueOf(generic)).Type()
+
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && slices.Contains(names, name) {
+			fields = append(fields, typ.Field(i).Name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fieldErrors(v, v.StructPartial(generic, fields...), langs)
+}
+
+func fieldErrors(v *validator.Validate, err error, langs []string) []apperror.FieldError {
 	if err != nil {
 		// this check is only needed when your code could produce
 		// an invalid value for validation such as interface with nil
@@ -19,12 +101,29 @@ func Validate(v *validator.Validate, generic any) []string {
 			return nil
 		}
 
-		var errs []string
+		trans, _ := translator(v).FindTranslator(langs...)
+
+		var errs []apperror.FieldError
 		for _, err := range err.(validator.ValidationErrors) {
-			errs = append(errs, fmt.Sprintf("%s is %s with type %s", err.StructField(), err.Tag(), err.Type()))
+			errs = append(errs, apperror.FieldError{
+				Field:   field(err),
+				Rule:    err.Tag(),
+				Param:   err.Param(),
+				Message: err.Translate(trans),
+			})
 		}
 
 		return errs
 	}
 	return nil
 }
+
+// field is the path to the field from the validated struct, such as
+// books[0].title. The name of the struct itself is left out.
+func field(err validator.FieldError) string {
+	ns := err.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
@@ -0,0 +1,46 @@
+// Package blobstore keeps uploaded files, such as book covers, on the local
+// filesystem or in an S3-compatible bucket.
+package blobstore
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"micro/config"
+)
+
+// BlobStore stores files under keys such as books/1/cover.png. Keys use
+// forward slashes whatever the backend.
+type BlobStore interface {
+	// Put stores data under key, replacing what was there.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Delete removes key. Deleting a key that does not exist is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download key from.
+	URL(key string) string
+}
+
+func New(cfg config.Storage) BlobStore {
+	switch cfg.Driver {
+	case "local":
+		return NewLocal(cfg.Dir, cfg.BaseURL)
+	case "s3":
+		store, err := NewS3(cfg)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return store
+	default:
+		log.Fatalf("unknown storage driver %q, want local or s3", cfg.Driver)
+		return nil
+	}
+}
+
+// Key finds the key of a URL that store gave out. It is false for URLs of
+// files that are stored elsewhere.
+func Key(store BlobStore, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, store.URL(""))
+	return key, ok && key != ""
+}
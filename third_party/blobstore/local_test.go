@@ -0,0 +1,34 @@
+package blobstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLocal(dir, "http://localhost:3080/storage/")
+	ctx := context.Background()
+
+	assert.Nil(t, l.Put(ctx, "books/1/cover.png", "image/png", []byte("png")))
+	data, err := os.ReadFile(filepath.Join(dir, "books", "1", "cover.png"))
+	assert.Nil(t, err)
+	assert.Equal(t, "png", string(data))
+
+	assert.Equal(t, "http://localhost:3080/storage/books/1/cover.png", l.URL("books/1/cover.png"))
+	key, ok := Key(l, "http://localhost:3080/storage/books/1/cover.png")
+	assert.True(t, ok)
+	assert.Equal(t, "books/1/cover.png", key)
+
+	assert.Nil(t, l.Delete(ctx, "books/1/cover.png"))
+	assert.Nil(t, l.Delete(ctx, "books/1/cover.png"))
+	_, err = os.Stat(filepath.Join(dir, "books", "1", "cover.png"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NotNil(t, l.Put(ctx, "../outside.png", "image/png", []byte("png")))
+	assert.NotNil(t, l.Delete(ctx, "/etc/passwd"))
+}
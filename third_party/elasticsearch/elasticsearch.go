@@ -0,0 +1,22 @@
+package elasticsearch
+
+import (
+	"log"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+
+	"micro/config"
+)
+
+func New(cfg config.Elasticsearch) *es.Client {
+	client, err := es.NewClient(es.Config{
+		Addresses: []string{cfg.Address},
+		Username:  cfg.User,
+		Password:  cfg.Password,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return client
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sendTimeout bounds an email sent in the background, which no longer has the
+// deadline of the request that sent it.
+const sendTimeout = time.Minute
+
+// Background sends emails with another Mailer from a goroutine. Send returns
+// at once, so the time to answer a request does not depend on the mail
+// server, nor on whether an email was sent at all. A failure is logged.
+type Background struct {
+	mailer Mailer
+	wg     sync.WaitGroup
+}
+
+func NewBackground(m Mailer) *Background {
+	return &Background{mailer: m}
+}
+
+func (b *Background) Send(ctx context.Context, msg Message) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer cancel()
+
+		if err := b.mailer.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "sending email", "to", msg.To, "subject", msg.Subject, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close waits for the emails being sent, or until ctx is done.
+func (b *Background) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
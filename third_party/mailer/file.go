@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File writes each email to an .eml file in a directory, for development.
+type File struct {
+	dir  string
+	from string
+
+	now func() time.Time
+}
+
+func NewFile(dir, from string) *File {
+	return &File{
+		dir:  dir,
+		from: from,
+		now:  time.Now,
+	}
+}
+
+func (f *File) Send(_ context.Context, msg Message) error {
+	now := f.now()
+	data, err := compose(f.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".mail-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), filepath.Base(tmp.Name())[len(".mail-"):])
+	return os.Rename(tmp.Name(), filepath.Join(f.dir, name))
+}
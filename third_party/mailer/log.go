@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Log only logs emails, body included, for development.
+type Log struct {
+	from string
+}
+
+func NewLog(from string) *Log {
+	return &Log{from: from}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "sending email", "from", l.from, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var date = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+func TestCompose(t *testing.T) {
+	msg := Message{To: "email@example.com", Subject: "Vérifiez", Body: "Open https://example.com/verify?token=a.b=c"}
+
+	data, err := compose("no-reply@example.com", msg, date)
+	assert.Nil(t, err)
+
+	want := "From: no-reply@example.com\r\n" +
+		"To: email@example.com\r\n" +
+		"Subject: =?utf-8?q?V=C3=A9rifiez?=\r\n" +
+		"Date: Sun, 18 Oct 2026 09:30:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Open https://example.com/verify?token=3Da.b=3Dc"
+	assert.Equal(t, want, string(data))
+}
+
+func TestCompose_HeaderInjection(t *testing.T) {
+	for _, msg := range []Message{
+		{To: "email@example.com\r\nBcc: other@example.com", Subject: "Hello"},
+		{To: "email@example.com", Subject: "Hello\nBcc: other@example.com"},
+	} {
+		_, err := compose("no-reply@example.com", msg, date)
+		assert.ErrorIs(t, err, ErrHeader)
+	}
+}
+
+func TestFile_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	f := NewFile(dir, "no-reply@example.com")
+	f.now = func() time.Time { return date }
+
+	msg := Message{To: "email@example.com", Subject: "Hello", Body: "Hi"}
+	assert.Nil(t, f.Send(context.Background(), msg))
+	assert.Nil(t, f.Send(context.Background(), msg))
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.True(t, strings.HasPrefix(entry.Name(), "20261018T093000.000000000-"), entry.Name())
+		assert.True(t, strings.HasSuffix(entry.Name(), ".eml"), entry.Name())
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), "To: email@example.com\r\n")
+	}
+}
+
+// blocking sends once it is released.
+type blocking struct {
+	release chan struct{}
+	sent    chan Message
+}
+
+func (b *blocking) Send(_ context.Context, msg Message) error {
+	<-b.release
+	b.sent <- msg
+	return nil
+}
+
+func TestBackground_Send(t *testing.T) {
+	slow := &blocking{release: make(chan struct{}), sent: make(chan Message, 1)}
+	b := NewBackground(slow)
+
+	// Send does not wait for the mail server.
+	msg := Message{To: "email@example.com", Subject: "Hello", Body: "Hi"}
+	assert.Nil(t, b.Send(context.Background(), msg))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Close(ctx), context.DeadlineExceeded)
+
+	close(slow.release)
+	assert.Nil(t, b.Close(context.Background()))
+	assert.Equal(t, msg, <-slow.sent)
+}
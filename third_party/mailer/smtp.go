@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"micro/config"
+)
+
+// SMTP sends emails through a mail server. The connection is upgraded with
+// STARTTLS when the server offers it, and authenticated when a username is
+// set.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+
+	now func() time.Time
+}
+
+func NewSMTP(cfg config.Mail) *SMTP {
+	s := &SMTP{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+		now:  time.Now,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := compose(s.from, msg, s.now())
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, data)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
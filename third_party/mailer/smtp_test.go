@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/config"
+)
+
+// fakeSMTP accepts one message, without STARTTLS or authentication, and
+// hands back the envelope and data it received.
+func fakeSMTP(t *testing.T) (config.Mail, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL") || strings.HasPrefix(cmd, "RCPT"):
+				lines = append(lines, line)
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	assert.Nil(t, err)
+	p, err := strconv.Atoi(port)
+	assert.Nil(t, err)
+
+	return config.Mail{Host: host, Port: p, From: "no-reply@example.com"}, received
+}
+
+func TestSMTP_Send(t *testing.T) {
+	cfg, received := fakeSMTP(t)
+
+	s := NewSMTP(cfg)
+	s.now = func() time.Time { return date }
+
+	err := s.Send(context.Background(), Message{To: "email@example.com", Subject: "Hello", Body: "Hi"})
+	assert.Nil(t, err)
+
+	select {
+	case lines := <-received:
+		assert.Equal(t, "MAIL FROM:<no-reply@example.com>", lines[0])
+		assert.Equal(t, "RCPT TO:<email@example.com>", lines[1])
+		assert.Contains(t, lines, "Subject: Hello")
+		assert.Equal(t, "Hi", lines[len(lines)-1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was received")
+	}
+}
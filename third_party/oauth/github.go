@@ -0,0 +1,95 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"micro/config"
+)
+
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+// GitHub reads the user and their primary email address from the API of
+// GitHub, as it issues no ID tokens.
+type GitHub struct {
+	client client
+
+	authURL  string
+	tokenURL string
+	apiURL   string
+}
+
+func NewGitHub(cfg config.OAuthProvider, redirectURL string) *GitHub {
+	c := newClient(cfg, redirectURL, "read:user", "user:email")
+	c.secretInForm = true
+
+	return &GitHub{
+		client:   c,
+		authURL:  githubAuthURL,
+		tokenURL: githubTokenURL,
+		apiURL:   githubAPIURL,
+	}
+}
+
+// AuthCodeURL ignores the nonce, which is only for ID tokens.
+func (p *GitHub) AuthCodeURL(_ context.Context, state, _, challenge string) (string, error) {
+	return p.client.authCodeURL(p.authURL, state, challenge, nil), nil
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code, verifier, _ string) (*Identity, error) {
+	t, err := p.client.exchange(ctx, p.tokenURL, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, t.AccessToken, "/user", &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, t.AccessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: "github",
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *GitHub) get(ctx context.Context, accessToken, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	return do(req, v)
+}
@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/config"
+)
+
+func TestGitHub(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		assert.Equal(t, "client", r.PostFormValue("client_id"))
+		assert.Equal(t, "secret", r.PostFormValue("client_secret"))
+		assert.Equal(t, "verifier", r.PostFormValue("code_verifier"))
+
+		// GitHub describes errors with 200.
+		if r.PostFormValue("code") != "code" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 583231, "login": "octocat"})
+	})
+	mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := NewGitHub(config.OAuthProvider{ClientID: "client", ClientSecret: "secret"}, "http://localhost:3080/api/v1/oauth/github/callback")
+	p.tokenURL = server.URL + "/login/oauth/access_token"
+	p.apiURL = server.URL
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", Challenge("verifier"))
+	assert.Nil(t, err)
+	u, err := url.Parse(authURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "github.com", u.Host)
+	assert.Equal(t, "read:user user:email", u.Query().Get("scope"))
+	assert.Equal(t, Challenge("verifier"), u.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Empty(t, u.Query().Get("nonce"))
+
+	identity, err := p.Exchange(context.Background(), "code", "verifier", "")
+	assert.Nil(t, err)
+	assert.Equal(t, &Identity{
+		Provider:      "github",
+		Subject:       "583231",
+		Email:         "octocat@example.com",
+		EmailVerified: true,
+		Name:          "octocat",
+	}, identity)
+
+	_, err = p.Exchange(context.Background(), "wrong", "verifier", "")
+	assert.ErrorContains(t, err, "bad_verification_code")
+}
@@ -0,0 +1,195 @@
+// Package oauth logs users in with their accounts at external providers,
+// with the OAuth 2.0 authorization code flow and PKCE. OpenID Connect
+// providers, Google included, are discovered from their issuer. GitHub does
+// not support OpenID Connect, so its users are read from its API.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"micro/config"
+)
+
+// Identity is the account that a user logged in with at a provider.
+type Identity struct {
+	Provider string
+	// Subject identifies the account at the provider. Unlike the email, it
+	// does not change.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider sends users to log in at an external provider, which sends them
+// back to the redirect URL with a code.
+type Provider interface {
+	// AuthCodeURL is where users log in at the provider. The state and nonce
+	// come back with the code and in the ID token, and the challenge is of
+	// the PKCE verifier given to Exchange.
+	AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error)
+	// Exchange trades the code for the identity of the user.
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// New makes the providers that have a client ID, keyed by their names.
+func New(cfg config.OAuth) map[string]Provider {
+	providers := make(map[string]Provider)
+
+	redirect := func(name string) string {
+		return strings.ReplaceAll(cfg.RedirectURL, "{provider}", name)
+	}
+
+	if cfg.Google.ClientID != "" {
+		google := cfg.Google
+		if google.Issuer == "" {
+			google.Issuer = GoogleIssuer
+		}
+		providers["google"] = NewOIDC("google", google, redirect("google"))
+	}
+	if cfg.GitHub.ClientID != "" {
+		providers["github"] = NewGitHub(cfg.GitHub, redirect("github"))
+	}
+	if cfg.OIDC.ClientID != "" {
+		providers[cfg.OIDC.Name] = NewOIDC(cfg.OIDC.Name, cfg.OIDC, redirect(cfg.OIDC.Name))
+	}
+
+	return providers
+}
+
+// NewVerifier makes a random PKCE code verifier. The same helper makes
+// states and nonces.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge is the S256 PKCE code challenge of verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// client is the registration of this service at a provider.
+type client struct {
+	id          string
+	secret      string
+	redirectURL string
+	scopes      []string
+	// secretInForm sends the credentials in the form instead of with basic
+	// authentication, for providers that only support that.
+	secretInForm bool
+}
+
+func newClient(cfg config.OAuthProvider, redirectURL string, scopes ...string) client {
+	return client{
+		id:          cfg.ClientID,
+		secret:      cfg.ClientSecret,
+		redirectURL: redirectURL,
+		scopes:      append(scopes, cfg.Scopes...),
+	}
+}
+
+func (c client) authCodeURL(authURL, state, challenge string, extra url.Values) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.id},
+		"redirect_uri":          {c.redirectURL},
+		"scope":                 {strings.Join(c.scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	for key, values := range extra {
+		query[key] = values
+	}
+
+	sep := "?"
+	if strings.Contains(authURL, "?") {
+		sep = "&"
+	}
+	return authURL + sep + query.Encode()
+}
+
+type token struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange trades the code at the token endpoint of the provider.
+func (c client) exchange(ctx context.Context, tokenURL, code, verifier string) (*token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+		"code_verifier": {verifier},
+	}
+	if c.secretInForm {
+		form.Set("client_id", c.id)
+		form.Set("client_secret", c.secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !c.secretInForm {
+		req.SetBasicAuth(url.QueryEscape(c.id), url.QueryEscape(c.secret))
+	}
+
+	var t token
+	// Errors are answered with 400, or with 200 by GitHub, and described in
+	// the body either way.
+	if err := do(req, &t, http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized); err != nil {
+		return nil, err
+	}
+	if t.Error != "" {
+		return nil, fmt.Errorf("oauth: exchanging the code: %s %s", t.Error, t.ErrorDescription)
+	}
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("oauth: exchanging the code: no access token")
+	}
+
+	return &t, nil
+}
+
+// do sends req and decodes the JSON response into v, if its status is one of
+// ok, or http.StatusOK by default.
+func do(req *http.Request, v any, ok ...int) error {
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if len(ok) == 0 {
+		ok = []int{http.StatusOK}
+	}
+	for _, status := range ok {
+		if res.StatusCode == status {
+			return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+		}
+	}
+
+	return fmt.Errorf("oauth: %s %s: %s", req.Method, req.URL.Redacted(), res.Status)
+}
@@ -0,0 +1,271 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"micro/config"
+)
+
+const GoogleIssuer = "https://accounts.google.com"
+
+var ErrIDToken = errors.New("oauth: the ID token is invalid")
+
+// OIDC is an OpenID Connect provider. Its endpoints and keys are discovered
+// from its issuer when they are first needed.
+type OIDC struct {
+	name   string
+	issuer string
+	client client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]any
+}
+
+// discovery is the part of the OpenID configuration of a provider that is
+// used.
+type discovery struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+func NewOIDC(name string, cfg config.OAuthProvider, redirectURL string) *OIDC {
+	return &OIDC{
+		name:   name,
+		issuer: strings.TrimSuffix(cfg.Issuer, "/"),
+		client: newClient(cfg, redirectURL, "openid", "email", "profile"),
+	}
+}
+
+func (p *OIDC) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return p.client.authCodeURL(d.AuthorizationEndpoint, state, challenge, url.Values{"nonce": {nonce}}), nil
+}
+
+// Exchange trades the code for an ID token, and takes the identity from its
+// claims once the token is verified.
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := p.client
+	c.secretInForm = len(d.TokenEndpointAuthMethods) > 0 &&
+		!slices.Contains(d.TokenEndpointAuthMethods, "client_secret_basic") &&
+		slices.Contains(d.TokenEndpointAuthMethods, "client_secret_post")
+
+	t, err := c.exchange(ctx, d.TokenEndpoint, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	if t.IDToken == "" {
+		return nil, fmt.Errorf("%w: there is none", ErrIDToken)
+	}
+
+	claims, err := p.verify(ctx, t.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string    `json:"nonce"`
+	Email         string    `json:"email"`
+	EmailVerified looseBool `json:"email_verified"`
+	Name          string    `json:"name"`
+}
+
+// looseBool is also read from "true" and "false", which some providers send.
+type looseBool bool
+
+func (b *looseBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("oauth: %s is not a boolean", data)
+	}
+	return nil
+}
+
+// verify checks the signature of the ID token with the keys of the provider,
+// and that it was issued by the provider to this client for this login.
+func (p *OIDC) verify(ctx context.Context, raw, nonce string) (*idClaims, error) {
+	var claims idClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "ES256"}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIDToken, err)
+	}
+
+	switch {
+	case !claims.VerifyIssuer(p.issuer, true):
+		return nil, fmt.Errorf("%w: issued by %s", ErrIDToken, claims.Issuer)
+	case !claims.VerifyAudience(p.client.id, true):
+		return nil, fmt.Errorf("%w: issued to another client", ErrIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: the nonce does not match", ErrIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: there is no subject", ErrIDToken)
+	}
+
+	return &claims, nil
+}
+
+func (p *OIDC) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d discovery
+	if err := do(req, &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("oauth: %s is configured as the issuer, but the provider is %s", p.issuer, d.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key is the public key with kid. The keys are fetched again when there is
+// none, as providers rotate them.
+func (p *OIDC) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	jwksURI := ""
+	if p.discovery != nil {
+		jwksURI = p.discovery.JWKSURI
+	}
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := do(req, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.public()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = public
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oauth: no key %q", kid)
+	}
+	return key, nil
+}
+
+// jwk is an RSA or P-256 public key of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) public() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := bigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := bigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("oauth: unsupported curve %s", k.Crv)
+		}
+		x, err := bigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := bigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("oauth: unsupported key type %s", k.Kty)
+	}
+}
+
+func bigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
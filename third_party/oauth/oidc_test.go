@@ -0,0 +1,79 @@
+package oauth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/third_party/oauth"
+	"micro/third_party/oauth/oauthtest"
+)
+
+const redirectURL = "http://localhost:3080/api/v1/oauth/test/callback"
+
+// authorize logs in at the provider, and returns the query that it redirects
+// back with.
+func authorize(t *testing.T, p oauth.Provider, state, nonce, verifier string) url.Values {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, oauth.Challenge(verifier))
+	assert.Nil(t, err)
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := noRedirect.Get(authURL)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	assert.Nil(t, err)
+	assert.Equal(t, redirectURL, location.Scheme+"://"+location.Host+location.Path)
+
+	return location.Query()
+}
+
+func TestOIDC(t *testing.T) {
+	provider := oauthtest.NewProvider()
+	defer provider.Close()
+	provider.LogIn(oauthtest.User{Subject: "248289761001", Email: "jane@example.com", EmailVerified: true, Name: "Jane"})
+
+	p := oauth.NewOIDC("test", provider.Config(), redirectURL)
+	ctx := context.Background()
+
+	verifier, err := oauth.NewVerifier()
+	assert.Nil(t, err)
+	query := authorize(t, p, "state", "nonce", verifier)
+	assert.Equal(t, "state", query.Get("state"))
+
+	identity, err := p.Exchange(ctx, query.Get("code"), verifier, "nonce")
+	assert.Nil(t, err)
+	assert.Equal(t, &oauth.Identity{
+		Provider:      "test",
+		Subject:       "248289761001",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Name:          "Jane",
+	}, identity)
+
+	// A code is exchanged once.
+	_, err = p.Exchange(ctx, query.Get("code"), verifier, "nonce")
+	assert.NotNil(t, err)
+
+	// Without the verifier of the challenge.
+	query = authorize(t, p, "state", "nonce", verifier)
+	_, err = p.Exchange(ctx, query.Get("code"), verifier+"x", "nonce")
+	assert.NotNil(t, err)
+
+	// The ID token was issued for another login.
+	query = authorize(t, p, "state", "nonce", verifier)
+	_, err = p.Exchange(ctx, query.Get("code"), verifier, "another nonce")
+	assert.True(t, errors.Is(err, oauth.ErrIDToken))
+}
@@ -2,16 +2,25 @@
 // It is nearly identical to it except:
 //
 //  1. It saves a uint64 data along with the session data for the purpose of user session invalidation.
+//
 //  2. Tokens are hashed before being saved into the database.
 //
-// The schema is identical to scs library but with added `user_id` foreign key column:
+//  3. It records the device a session is used from, and when it was last seen, so that users
+//     can tell their sessions apart.
+//
+// The schema is identical to scs library but with added `user_id` foreign key column, and the
+// columns of the device:
 //
 //	CREATE TABLE IF NOT EXISTS sessions
 //	(
-//	    token   TEXT PRIMARY KEY,
-//	    user_id BIGINT      NOT NULL CONSTRAINT session_user_fk REFERENCES users ON DELETE CASCADE ,
-//	    data    BYTEA       NOT NULL,
-//	    expiry  TIMESTAMPTZ NOT NULL
+//	    token        TEXT PRIMARY KEY,
+//	    user_id      BIGINT      NOT NULL CONSTRAINT session_user_fk REFERENCES users ON DELETE CASCADE ,
+//	    data         BYTEA       NOT NULL,
+//	    expiry       TIMESTAMPTZ NOT NULL,
+//	    user_agent   TEXT        NOT NULL DEFAULT '',
+//	    ip_address   TEXT        NOT NULL DEFAULT '',
+//	    created_at   TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+//	    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp
 //	);
 //
 // If number of records in `expiry` column is large, can consider indexing it using BRIN index
@@ -31,6 +40,9 @@ import (
 	"micro/internal/middleware"
 )
 
+// touchInterval is how often the time a session was last seen is updated.
+const touchInterval = time.Minute
+
 // PostgresStore represents the session store.
 type PostgresStore struct {
 	db          *sql.DB
@@ -58,24 +70,41 @@ func (p *PostgresStore) FindCtx(ctx context.Context, token string) (b []byte, ex
 		return nil, false, err
 	}
 
+	var lastSeen time.Time
 	row := p.db.QueryRowContext(ctx, `
-		SELECT data FROM sessions 
+		SELECT data, last_seen_at FROM sessions 
             WHERE token = $1 
               AND current_timestamp < expiry 
             ORDER BY expiry desc`, hash)
-	err = row.Scan(&b)
+	err = row.Scan(&b, &lastSeen)
 	if err == sql.ErrNoRows {
 		return nil, false, nil
 	} else if err != nil {
 		return nil, false, err
 	}
+
+	// Sessions are read on every request, so the time they were last seen is
+	// only written once in a while.
+	if time.Since(lastSeen) > touchInterval {
+		client, _ := ctx.Value(middleware.KeyClient).(middleware.Client)
+		_, err = p.db.ExecContext(ctx, `
+			UPDATE sessions 
+			SET last_seen_at = current_timestamp, 
+				ip_address = COALESCE(NULLIF($2, ''), ip_address) 
+			WHERE token = $1`, hash, client.IPAddress)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
 	return b, true, nil
 }
 
 // CommitCtx adds a session token and data to the PostgresStore instance with the
 // given expiry time. If the session token already exists, then the data and expiry
-// time are updated. Hashed token is stored into database. User ID is retrieved from request
-// context since modifying method signature will no longer implements scs's Store interface.
+// time are updated. Hashed token is stored into database. User ID, and the device from
+// middleware.Client, are retrieved from request context since modifying method signature
+// will no longer implements scs's Store interface.
 func (p *PostgresStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
 	var userID any
 	userID, ok := ctx.Value(middleware.KeyID).(uint64)
@@ -83,19 +112,24 @@ func (p *PostgresStore) CommitCtx(ctx context.Context, token string, b []byte, e
 		userID = nil
 	}
 
+	client, _ := ctx.Value(middleware.KeyClient).(middleware.Client)
+
 	hash, err := sum(token)
 	if err != nil {
 		return err
 	}
 
 	_, err = p.db.ExecContext(ctx, `
-		INSERT INTO sessions (token, user_id, data, expiry) 
-		VALUES ($1, $2, $3, $4) 
+		INSERT INTO sessions (token, user_id, data, expiry, user_agent, ip_address) 
+		VALUES ($1, $2, $3, $4, $5, $6) 
 		ON CONFLICT (token) 
 			DO UPDATE 
 			SET data = EXCLUDED.data, 
-				expiry = EXCLUDED.expiry
-				`, hash, userID, b, expiry)
+				expiry = EXCLUDED.expiry,
+				user_agent = COALESCE(NULLIF(EXCLUDED.user_agent, ''), sessions.user_agent),
+				ip_address = COALESCE(NULLIF(EXCLUDED.ip_address, ''), sessions.ip_address),
+				last_seen_at = current_timestamp
+				`, hash, userID, b, expiry, client.UserAgent, client.IPAddress)
 	if err != nil {
 		return err
 	}
@@ -203,6 +237,13 @@ func (p *PostgresStore) deleteExpired() error {
 	return err
 }
 
+// ID is how the session with token is known in the sessions table. It is a
+// hash of the token, so it can be shown without giving the session away.
+func ID(token string) string {
+	id, _ := sum(token)
+	return id
+}
+
 func sum(token string) (string, error) {
 	h := xxhash.New()
 	_, err := h.Write([]byte(token))
@@ -6,12 +6,15 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/gmhafiz/scs/v2"
 	_ "github.com/lib/pq"
 
 	"micro/config"
@@ -72,10 +75,14 @@ CREATE TABLE IF NOT EXISTS users
 	_, err = db.ExecContext(ctx, `
 CREATE TABLE IF NOT EXISTS sessions
 (
-    token   TEXT PRIMARY KEY,
-    user_id BIGINT      NOT NULL CONSTRAINT session_user_fk REFERENCES users ON DELETE CASCADE ,
-    data    BYTEA       NOT NULL,
-    expiry  TIMESTAMPTZ NOT NULL
+    token        TEXT PRIMARY KEY,
+    user_id      BIGINT      NOT NULL CONSTRAINT session_user_fk REFERENCES users ON DELETE CASCADE ,
+    data         BYTEA       NOT NULL,
+    expiry       TIMESTAMPTZ NOT NULL,
+    user_agent   TEXT        NOT NULL DEFAULT '',
+    ip_address   TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp
 );`)
 	if err != nil {
 		log.Println(err)
@@ -434,3 +441,118 @@ func TestStopNilCleanup(t *testing.T) {
 	// A send to a nil channel will block forever
 	p.StopCleanup()
 }
+
+func TestDevice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	dsn := os.Getenv("SCS_POSTGRES_TEST_DSN")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("TRUNCATE TABLE sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithCleanupInterval(db, 0)
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, middleware.KeyID, uint64(1))
+	ctx = context.WithValue(ctx, middleware.KeyClient, middleware.Client{UserAgent: "curl/8.0", IPAddress: "192.0.2.1"})
+
+	err = p.CommitCtx(ctx, "session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var userAgent, ipAddress string
+	row := db.QueryRow("SELECT user_agent, ip_address FROM sessions WHERE token = $1", ID("session_token"))
+	if err = row.Scan(&userAgent, &ipAddress); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "curl/8.0" || ipAddress != "192.0.2.1" {
+		t.Fatalf("got %v, %v: expected %v, %v", userAgent, ipAddress, "curl/8.0", "192.0.2.1")
+	}
+
+	// A session that was not seen for a while is touched from its new address.
+	_, err = db.Exec("UPDATE sessions SET last_seen_at = current_timestamp - interval '1 hour'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = context.WithValue(ctx, middleware.KeyClient, middleware.Client{UserAgent: "curl/8.0", IPAddress: "192.0.2.2"})
+	if _, _, err = p.FindCtx(ctx, "session_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	var lastSeen time.Time
+	row = db.QueryRow("SELECT ip_address, last_seen_at FROM sessions WHERE token = $1", ID("session_token"))
+	if err = row.Scan(&ipAddress, &lastSeen); err != nil {
+		t.Fatal(err)
+	}
+	if ipAddress != "192.0.2.2" {
+		t.Fatalf("got %v: expected %v", ipAddress, "192.0.2.2")
+	}
+	if time.Since(lastSeen) > time.Minute {
+		t.Fatalf("got %v: expected a time within the last minute", lastSeen)
+	}
+}
+
+// TestLoadAndSave logs in through middleware.LoadAndSave, which must hand the
+// user ID that the handler put into the session on to the store.
+func TestLoadAndSave(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	dsn := os.Getenv("SCS_POSTGRES_TEST_DSN")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("TRUNCATE TABLE sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var userID uint64
+	row := db.QueryRow("SELECT id FROM users WHERE email = $1", "admin@example.com")
+	if err = row.Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+
+	session := scs.New()
+	session.Store = NewWithCleanupInterval(db, 0)
+
+	login := middleware.LoadAndSave(session)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := session.RenewToken(r.Context()); err != nil {
+			t.Fatal(err)
+		}
+		session.Put(r.Context(), string(middleware.KeyID), userID)
+	}))
+
+	ww := httptest.NewRecorder()
+	login.ServeHTTP(ww, httptest.NewRequest(http.MethodPost, "/api/v1/login", nil))
+	if ww.Code != http.StatusOK {
+		t.Fatalf("got %v: expected %v", ww.Code, http.StatusOK)
+	}
+
+	var stored sql.NullInt64
+	row = db.QueryRow("SELECT user_id FROM sessions")
+	if err = row.Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Valid || uint64(stored.Int64) != userID {
+		t.Fatalf("got %v: expected %v", stored, userID)
+	}
+}
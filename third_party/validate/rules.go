@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// ISODate is a date in ISO 8601, either 2006-01-02 or in RFC 3339 such as
+	// 2006-01-02T15:04:05Z.
+	ISODate = "iso_date"
+
+	// StrongPassword is a password of at least minPasswordLength characters
+	// that mixes at least two of lowercase and uppercase letters, digits and
+	// symbols.
+	StrongPassword = "strong_password"
+)
+
+const minPasswordLength = 13
+
+type rule struct {
+	tag     string
+	fn      validator.Func
+	message string
+}
+
+var rules = []rule{
+	{
+		tag:     ISODate,
+		fn:      isISODate,
+		message: "{0} must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z",
+	},
+	{
+		tag:     StrongPassword,
+		fn:      isStrongPassword,
+		message: fmt.Sprintf("{0} must be at least %d characters and mix at least two of lowercase letters, uppercase letters, digits and symbols", minPasswordLength),
+	},
+}
+
+func isISODate(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+
+	if _, err := time.Parse(time.DateOnly, s); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, s)
+
+	return err == nil
+}
+
+func isStrongPassword(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	if utf8.RuneCountInString(s) < minPasswordLength {
+		return false
+	}
+
+	var lower, upper, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower+upper+digit+symbol >= 2
+}
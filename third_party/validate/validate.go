@@ -1,7 +1,43 @@
 package validate
 
-import "github.com/go-playground/validator/v10"
+import (
+	"log"
+	"reflect"
+	"strings"
 
+	"github.com/go-playground/validator/v10"
+
+	utilValidate "micro/internal/utility/validate"
+)
+
+// New returns a validator that names fields by their JSON tag and knows the
+// custom rules of this project, along with their English messages.
 func New() *validator.Validate {
-	return validator.New()
+	v := validator.New()
+	v.RegisterTagNameFunc(jsonName)
+
+	if err := utilValidate.RegisterTranslations(v); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, r := range rules {
+		if err := v.RegisterValidation(r.tag, r.fn); err != nil {
+			log.Fatal(err)
+		}
+		if err := utilValidate.RegisterTranslation(v, "en", r.tag, r.message); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return v
+}
+
+// jsonName is the name of a field in a JSON request. Fields without a JSON name
+// keep their Go name.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
 }
@@ -0,0 +1,100 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"micro/internal/utility/apperror"
+	utilValidate "micro/internal/utility/validate"
+)
+
+func TestNew(t *testing.T) {
+	type request struct {
+		PublishedDate string `json:"published_date" validate:"required,iso_date"`
+		Password      string `json:"password" validate:"required,strong_password"`
+		Limit         int    `json:"limit" validate:"min=1"`
+		Internal      string `json:"-" validate:"required"`
+	}
+
+	tests := []struct {
+		name string
+		req  request
+		want []apperror.FieldError
+	}{
+		{
+			name: "valid",
+			req: request{
+				PublishedDate: "2022-03-07",
+				Password:      "highEntropyPassword",
+				Limit:         1,
+				Internal:      "set",
+			},
+			want: nil,
+		},
+		{
+			name: "RFC 3339 date",
+			req: request{
+				PublishedDate: "2022-03-07T10:00:00+08:00",
+				Password:      "correct horse battery",
+				Limit:         1,
+				Internal:      "set",
+			},
+			want: nil,
+		},
+		{
+			name: "invalid",
+			req: request{
+				PublishedDate: "07/03/2022",
+				Password:      "lowercaseonlypassword",
+				Limit:         0,
+			},
+			want: []apperror.FieldError{
+				{
+					Field:   "published_date",
+					Rule:    ISODate,
+					Message: "published_date must be an ISO 8601 date such as 2006-01-02 or 2006-01-02T15:04:05Z",
+				},
+				{
+					Field:   "password",
+					Rule:    StrongPassword,
+					Message: "password must be at least 13 characters and mix at least two of lowercase letters, uppercase letters, digits and symbols",
+				},
+				{
+					Field:   "limit",
+					Rule:    "min",
+					Param:   "1",
+					Message: "limit must be 1 or greater",
+				},
+				{
+					Field:   "Internal",
+					Rule:    "required",
+					Message: "Internal is a required field",
+				},
+			},
+		},
+		{
+			name: "short password",
+			req: request{
+				PublishedDate: "2022-03-07",
+				Password:      "Sh0rt!",
+				Limit:         1,
+				Internal:      "set",
+			},
+			want: []apperror.FieldError{
+				{
+					Field:   "password",
+					Rule:    StrongPassword,
+					Message: "password must be at least 13 characters and mix at least two of lowercase letters, uppercase letters, digits and symbols",
+				},
+			},
+		},
+	}
+
+	v := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, utilValidate.Validate(v, tt.req))
+		})
+	}
+}